@@ -0,0 +1,82 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// OnActivate is invoked when the plugin is activated.
+//
+// This implementation starts measuring the Mattermost API calls for load shedding, migrates legacy
+// settings and loads the configuration, which ensures the bot and the SRE channels exist, and
+// migrates the data stored in the KV store. It then registers the HTTP API and the slash commands,
+// schedules the background jobs, resumes the pending reminders, starts refreshing the team cache
+// and warms up the ticket cache.
+func (p *Plugin) OnActivate() error {
+	p.monitorAPI()
+
+	if p.client == nil {
+		p.client = pluginapi.NewClient(p.API, p.Driver)
+	}
+
+	if err := p.migrateSettings(); err != nil {
+		return errors.Wrap(err, "failed to migrate settings")
+	}
+
+	if err := p.OnConfigurationChange(); err != nil {
+		return err
+	}
+
+	if err := p.migrateKVSchema(); err != nil {
+		return errors.Wrap(err, "failed to migrate KV schema")
+	}
+
+	bundle, err := i18n.InitBundle(p.API, i18nPath)
+	if err != nil {
+		p.API.LogWarn("Failed to load translations, messages will be shown in English", "err", err.Error())
+	}
+	p.i18nBundle = bundle
+
+	p.initializeAPI()
+	p.checkSiteURL()
+
+	p.stopTeamCacheRefresh = make(chan struct{})
+	go p.refreshTeamCache(p.stopTeamCacheRefresh)
+
+	if err := p.registerCommands(); err != nil {
+		return errors.Wrap(err, "failed to register commands")
+	}
+
+	if err := p.scheduleJobs(p.getConfiguration()); err != nil {
+		return err
+	}
+
+	// Reminders are stored in the KV store, so the scheduler resumes those pending when it starts.
+	reminderScheduler := cluster.GetJobOnceScheduler(p.API)
+	if err := reminderScheduler.SetCallback(p.handleJobOnce); err != nil {
+		return errors.Wrap(err, "failed to set reminder callback")
+	}
+	if err := reminderScheduler.Start(); err != nil {
+		return errors.Wrap(err, "failed to start reminder scheduler")
+	}
+	p.reminderScheduler = reminderScheduler
+
+	go p.warmUp()
+
+	return nil
+}
+
+// OnDeactivate is invoked when the plugin is deactivated. This is the plugin's last chance to use
+// the API, and the plugin will be terminated shortly after this invocation.
+func (p *Plugin) OnDeactivate() error {
+	if p.stopTeamCacheRefresh != nil {
+		close(p.stopTeamCacheRefresh)
+	}
+
+	p.closeJobs()
+
+	return nil
+}
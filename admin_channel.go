@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// jobFailureReportInterval is how often a failing job is reported to the admin channel, so that
+// jobs failing every run don't flood it.
+const jobFailureReportInterval = time.Hour
+
+// jobFailureReports tracks when the failures of each job were last reported.
+type jobFailureReports struct {
+	lock       sync.Mutex
+	reportedAt map[string]time.Time
+}
+
+// shouldReport reports whether a failure of the job should be reported now, recording it if so.
+func (r *jobFailureReports) shouldReport(job string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.reportedAt == nil {
+		r.reportedAt = make(map[string]time.Time)
+	}
+	if time.Since(r.reportedAt[job]) < jobFailureReportInterval {
+		return false
+	}
+	r.reportedAt[job] = time.Now()
+
+	return true
+}
+
+// resolveChannelSetting returns the id of the channel named by a setting given as "team/channel"
+// by name, or an empty string if it is not configured or cannot be found.
+func (p *Plugin) resolveChannelSetting(name, setting string) string {
+	setting = strings.TrimSpace(setting)
+	if setting == "" {
+		return ""
+	}
+
+	teamName, channelName, ok := strings.Cut(setting, "/")
+	if !ok {
+		p.API.LogWarn("Invalid channel setting, expected team/channel", "setting", name, "value", setting)
+		return ""
+	}
+
+	channel, appErr := p.API.GetChannelByNameForTeamName(strings.TrimSpace(teamName), strings.TrimSpace(channelName), false)
+	if appErr != nil {
+		p.API.LogWarn("Failed to find configured channel", "setting", name, "value", setting, "err", appErr.Error())
+		return ""
+	}
+
+	return channel.Id
+}
+
+// postAdminNotice posts an operational message to the admin channel, if one is configured.
+func (p *Plugin) postAdminNotice(message string) {
+	channelID := p.getConfiguration().adminChannelID
+	if channelID == "" {
+		return
+	}
+
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: channelID,
+		Message:   message,
+	}); appErr != nil {
+		p.API.LogError("Failed to post to admin channel", "err", appErr.Error())
+	}
+}
+
+// reportJobFailure logs the failure of a background job, records it in the job's status and
+// reports it to the admin channel, at most once per jobFailureReportInterval for each job.
+func (p *Plugin) reportJobFailure(job, message string, err error) {
+	p.API.LogError(message, "job", job, "err", err.Error())
+	p.recordJobFailure(job, message+": "+err.Error())
+
+	if p.jobFailureReports.shouldReport(job) {
+		p.postAdminNotice(":warning: " + job + " failed: " + message + ": " + err.Error())
+	}
+}
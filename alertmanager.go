@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	alertStatusFiring   = "firing"
+	alertStatusResolved = "resolved"
+
+	// alertKeyPrefix prefixes the KV index from an alert fingerprint to the ticket tracking it.
+	alertKeyPrefix = "alert_"
+)
+
+// alertmanagerPayload is the body of an Alertmanager webhook notification.
+type alertmanagerPayload struct {
+	Status      string              `json:"status"`
+	Receiver    string              `json:"receiver"`
+	ExternalURL string              `json:"externalURL"`
+	Alerts      []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     string            `json:"startsAt"`
+	EndsAt       string            `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+func alertKey(fingerprint string) string {
+	return alertKeyPrefix + fingerprint
+}
+
+// alertPriority maps the severity label of an alert to a ticket priority.
+func alertPriority(alert alertmanagerAlert) string {
+	switch strings.ToLower(alert.Labels["severity"]) {
+	case "critical", "page":
+		return ticketPriorityHigh
+	case "warning":
+		return ticketPriorityMedium
+	default:
+		return ticketPriorityLow
+	}
+}
+
+// verifyAlertmanagerToken rejects the Alertmanager webhooks not authenticated with the
+// AlertmanagerToken. It runs before deliveries are deduplicated, so that unauthenticated requests
+// cannot claim delivery ids.
+func (p *Plugin) verifyAlertmanagerToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := p.getConfiguration().AlertmanagerToken
+		if token == "" {
+			http.Error(w, "The Alertmanager webhook is not enabled", http.StatusNotFound)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAlertmanager receives Alertmanager webhook notifications. Every firing alert opens a
+// ticket, unless its fingerprint is already tracked by an unresolved ticket, and the ticket is
+// resolved once Alertmanager reports the alert as resolved. Tickets are filed in the team named by
+// the team query parameter.
+func (p *Plugin) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
+	team, appErr := p.API.GetTeamByName(r.URL.Query().Get("team"))
+	if appErr != nil {
+		http.Error(w, "Unknown team", http.StatusBadRequest)
+		return
+	}
+	if _, ok := p.getConfiguration().demoChannelIDs[team.Id]; !ok || !p.teamEnabled(team.Id) {
+		http.Error(w, "SRE requests are not enabled in this team", http.StatusBadRequest)
+		return
+	}
+
+	var payload alertmanagerPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		p.API.LogError("Failed to decode Alertmanager payload", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	failed := false
+	for _, alert := range payload.Alerts {
+		if alert.Fingerprint == "" {
+			continue
+		}
+
+		var err error
+		switch alert.Status {
+		case alertStatusFiring:
+			err = p.openAlertTicket(team.Id, alert, alertAttachment(alert))
+		case alertStatusResolved:
+			err = p.resolveAlertTicket(alert)
+		}
+		if err != nil {
+			p.API.LogError("Failed to process alert", "fingerprint", alert.Fingerprint, "err", err.Error())
+			failed = true
+		}
+	}
+
+	// Let Alertmanager retry the notification, which is safe since alerts are tracked by fingerprint.
+	if failed {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getAlertTicket returns the ticket tracking the alert with the given fingerprint, or nil if there
+// is none.
+func (p *Plugin) getAlertTicket(fingerprint string) (*Ticket, error) {
+	var ticketID string
+	if err := p.client.KV.Get(alertKey(fingerprint), &ticketID); err != nil {
+		return nil, errors.Wrap(err, "failed to get alert index")
+	}
+	if ticketID == "" {
+		return nil, nil
+	}
+
+	return p.getTicket(ticketID)
+}
+
+// openAlertTicket opens a ticket for the firing alert, unless its fingerprint is already tracked by
+// an unresolved ticket, and posts the details of the alert in the ticket's thread.
+func (p *Plugin) openAlertTicket(teamID string, alert alertmanagerAlert, details *model.SlackAttachment) error {
+	existing, err := p.getAlertTicket(alert.Fingerprint)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Status != ticketStatusResolved {
+		return nil
+	}
+
+	summary := alert.Annotations["summary"]
+	if summary == "" {
+		summary = alert.Labels["alertname"]
+	}
+	description := alert.Annotations["description"]
+	if alert.GeneratorURL != "" && p.validateExternalURL(alert.GeneratorURL, "alert source") == nil {
+		description = strings.TrimSpace(fmt.Sprintf("%s\n\n[Source](%s)", description, alert.GeneratorURL))
+	}
+
+	ticket := &Ticket{
+		TeamID:           teamID,
+		ReporterID:       p.botID,
+		Summary:          fmt.Sprintf("[Alert] %s", summary),
+		Description:      description,
+		Priority:         alertPriority(alert),
+		AlertFingerprint: alert.Fingerprint,
+	}
+	if err := p.createTicket(ticket); err != nil {
+		return err
+	}
+
+	if _, err := p.client.KV.Set(alertKey(alert.Fingerprint), ticket.ID); err != nil {
+		return errors.Wrap(err, "failed to save alert index")
+	}
+
+	if err := p.createTicketReply(ticket, &model.Post{
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{details},
+		},
+	}, false); err != nil {
+		p.API.LogWarn("Failed to post alert details", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		p.API.LogError("Failed to notify responders", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	return nil
+}
+
+// resolveAlertTicket resolves the ticket tracking the alert, if it is unresolved.
+func (p *Plugin) resolveAlertTicket(alert alertmanagerAlert) error {
+	ticket, err := p.getAlertTicket(alert.Fingerprint)
+	if err != nil {
+		return err
+	}
+	if ticket == nil {
+		return nil
+	}
+
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.Status == ticketStatusResolved {
+			return errTicketUnchanged
+		}
+		setTicketStatus(ticket, ticketStatusResolved, p.botID)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	p.sendTicketEvent(ticketEventResolved, ticket, "")
+
+	if err := p.postTicketTransition(ticket, ":white_check_mark: The alert resolved, so this request was resolved automatically."); err != nil {
+		return err
+	}
+	p.proposePostmortemReview(ticket)
+	p.sendSatisfactionSurvey(ticket)
+
+	return nil
+}
+
+// alertAttachment renders the labels and annotations of an alert.
+func alertAttachment(alert alertmanagerAlert) *model.SlackAttachment {
+	return &model.SlackAttachment{
+		Color: "#d24b4e",
+		Title: "Alert details",
+		Fields: []*model.SlackAttachmentField{{
+			Title: "Labels",
+			Value: formatAlertMap(alert.Labels),
+		}, {
+			Title: "Annotations",
+			Value: formatAlertMap(alert.Annotations),
+		}, {
+			Title: "Started",
+			Value: alert.StartsAt,
+			Short: true,
+		}, {
+			Title: "Fingerprint",
+			Value: alert.Fingerprint,
+			Short: true,
+		}},
+	}
+}
+
+// formatAlertMap renders labels or annotations as a sorted list.
+func formatAlertMap(values map[string]string) string {
+	if len(values) == 0 {
+		return "_None_"
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("- **%s:** %s", key, values[key]))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// reporterMention returns how to refer to the reporter of the ticket in posts and integrations:
+// an @-mention, or a placeholder for anonymous tickets.
+func (p *Plugin) reporterMention(ticket *Ticket) string {
+	if ticket.Anonymous {
+		return localize(p.serverLocalizer(), &i18n.Message{ID: "ticket.anonymous_reporter", Other: "_Anonymous_"}, nil)
+	}
+
+	return p.mentionUser(ticket.ReporterID)
+}
+
+// canSeeReporter reports whether the user may know who reported the ticket: anyone for tickets
+// that aren't anonymous, and otherwise only the reporter and SRE admins.
+func (p *Plugin) canSeeReporter(userID string, ticket *Ticket) bool {
+	return !ticket.Anonymous || userID == ticket.ReporterID || p.isSREAdmin(userID)
+}
+
+// redactReporter returns the ticket as the user may see it: a copy without its reporter if the
+// ticket is anonymous and the user may not know who reported it, or else the ticket itself.
+func (p *Plugin) redactReporter(userID string, ticket *Ticket) *Ticket {
+	if p.canSeeReporter(userID, ticket) {
+		return ticket
+	}
+
+	return redactedReporter(ticket)
+}
+
+// redactedReporter returns a copy of the ticket, without its reporter if it is anonymous, for
+// external systems.
+func redactedReporter(ticket *Ticket) *Ticket {
+	redacted := ticket.clone()
+	if redacted.Anonymous {
+		redacted.ReporterID = ""
+	}
+
+	return redacted
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// ticketPatch is the body of a PATCH /api/v1/tickets/{id} request. Nil fields are left unchanged.
+type ticketPatch struct {
+	Summary     *string `json:"summary"`
+	Description *string `json:"description"`
+	Priority    *string `json:"priority"`
+	Status      *string `json:"status"`
+	AssigneeID  *string `json:"assignee_id"`
+
+	// DueAt is the due date in milliseconds, or zero to clear it.
+	DueAt *int64 `json:"due_at"`
+
+	// CustomFields sets the given custom fields, clearing those set to an empty value.
+	CustomFields map[string]string `json:"custom_fields"`
+
+	// Revision is the revision of the ticket the patch is based on, if the client wants the patch
+	// rejected when someone else updated the ticket since.
+	Revision *int64 `json:"revision"`
+
+	// Justification is required to change the priority, and recorded with the change.
+	Justification string `json:"justification"`
+}
+
+// ticketCreateRequest is the body of a POST /api/v1/tickets request.
+type ticketCreateRequest struct {
+	TeamID       string `json:"team_id"`
+	Summary      string `json:"summary"`
+	Description  string `json:"description"`
+	Priority     string `json:"priority"`
+	AssigneeID   string `json:"assignee_id"`
+	Confidential bool   `json:"confidential"`
+	Anonymous    bool   `json:"anonymous"`
+
+	Labels       []string          `json:"labels"`
+	CustomFields map[string]string `json:"custom_fields"`
+}
+
+func (p *Plugin) initializeTicketAPI(router *mux.Router) {
+	apiRouter := router.PathPrefix(apiV1Prefix).Subrouter()
+	apiRouter.Use(p.authenticateAPIToken)
+	apiRouter.Use(p.mattermostAuthorizationRequired)
+	apiRouter.Use(p.csrfProtected)
+
+	apiRouter.HandleFunc("", requireTokenScope(apiTokenScopeRead, p.handleAPIIndex)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/", requireTokenScope(apiTokenScopeRead, p.handleAPIIndex)).Methods(http.MethodGet)
+	for _, endpoint := range p.apiEndpoints() {
+		apiRouter.HandleFunc(endpoint.route, requireTokenScope(endpoint.TokenScope, endpoint.handler)).Methods(endpoint.Method)
+	}
+}
+
+// mattermostAuthorizationRequired rejects requests that were not authenticated by the server.
+func (p *Plugin) mattermostAuthorizationRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Mattermost-User-ID") == "" {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isValidPriority(priority string) bool {
+	return priority == ticketPriorityHigh || priority == ticketPriorityMedium || priority == ticketPriorityLow
+}
+
+func isValidStatus(status string) bool {
+	return status == ticketStatusOpen || status == ticketStatusAcknowledged || status == ticketStatusWaitingOnReporter || status == ticketStatusResolved
+}
+
+func (p *Plugin) isSystemAdmin(userID string) bool {
+	return p.API.HasPermissionTo(userID, model.PermissionManageSystem)
+}
+
+// canViewTicket reports whether the user may read the ticket: members of the ticket's team may
+// read public tickets, while confidential tickets are restricted to their participants within the
+// team. Tickets are never visible outside their team, except to system admins.
+func (p *Plugin) canViewTicket(userID string, ticket *Ticket) bool {
+	if p.isSystemAdmin(userID) {
+		return true
+	}
+
+	return p.isTeamMember(ticket.TeamID, userID) && p.canViewConfidentialTicket(userID, ticket)
+}
+
+// canViewConfidentialTicket reports whether a member of the ticket's team may read the ticket,
+// which is always the case for public tickets. The bot, which API tokens act as, never reads
+// confidential tickets, even those it reported on behalf of integrations.
+func (p *Plugin) canViewConfidentialTicket(userID string, ticket *Ticket) bool {
+	if !ticket.Confidential {
+		return true
+	}
+	if userID == p.botID {
+		return false
+	}
+
+	return userID == ticket.ReporterID ||
+		userID == ticket.AssigneeID ||
+		userID == p.getConfiguration().incidentCommanderID
+}
+
+func (p *Plugin) isTeamMember(teamID, userID string) bool {
+	// The bot, which API tokens act as, belongs to every team the plugin is enabled in.
+	if userID == p.botID {
+		_, ok := p.getConfiguration().demoChannelIDs[teamID]
+		return ok
+	}
+
+	member, appErr := p.API.GetTeamMember(teamID, userID)
+	return appErr == nil && member.DeleteAt == 0
+}
+
+// isAssignableUser reports whether tickets of the team may be assigned to the user: an active team
+// member who is not a bot.
+func (p *Plugin) isAssignableUser(teamID, userID string) bool {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil || user.DeleteAt != 0 || user.IsBot {
+		return false
+	}
+
+	return p.isTeamMember(teamID, user.Id)
+}
+
+// listVisibleTickets returns the tickets the user may view, read only from the cache partitions of
+// the user's teams, or of the given team if it is set and the user is a member. System admins view
+// the tickets of every team.
+func (p *Plugin) listVisibleTickets(userID, teamID string) ([]*Ticket, error) {
+	isSystemAdmin := p.isSystemAdmin(userID)
+
+	var teamIDs []string
+	switch {
+	case teamID != "":
+		if !isSystemAdmin && !p.isTeamMember(teamID, userID) {
+			return []*Ticket{}, nil
+		}
+		teamIDs = []string{teamID}
+	case userID == p.botID:
+		for teamID := range p.getConfiguration().demoChannelIDs {
+			teamIDs = append(teamIDs, teamID)
+		}
+		if len(teamIDs) == 0 {
+			return []*Ticket{}, nil
+		}
+	case !isSystemAdmin:
+		teams, appErr := p.API.GetTeamsForUser(userID)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to get teams of user")
+		}
+		for _, team := range teams {
+			teamIDs = append(teamIDs, team.Id)
+		}
+		// Listing no team would list every team.
+		if len(teamIDs) == 0 {
+			return []*Ticket{}, nil
+		}
+	}
+
+	tickets, err := p.listCachedTickets(teamIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []*Ticket{}
+	for _, ticket := range tickets {
+		if isSystemAdmin || p.canViewConfidentialTicket(userID, ticket) {
+			visible = append(visible, ticket)
+		}
+	}
+
+	return visible, nil
+}
+
+// canEditTicket reports whether the user may modify the ticket: its reporter, its assignee, the
+// incident commander and system admins.
+func (p *Plugin) canEditTicket(userID string, ticket *Ticket) bool {
+	return userID == ticket.ReporterID ||
+		userID == ticket.AssigneeID ||
+		userID == p.getConfiguration().incidentCommanderID ||
+		p.isSystemAdmin(userID)
+}
+
+// canRespondToTicket reports whether the user may take charge of the ticket by acknowledging,
+// assigning or escalating it: its assignee, the incident commander, system admins, and the
+// responders who may not be assigned yet, which are the user on call and the SRE admins. Unlike
+// editing, reporters may not respond to their own tickets, which would stop their escalation.
+func (p *Plugin) canRespondToTicket(userID string, ticket *Ticket) bool {
+	return userID == ticket.AssigneeID ||
+		userID == p.getConfiguration().incidentCommanderID ||
+		p.isSystemAdmin(userID) ||
+		userID == p.onCallUserID() ||
+		p.isSREAdmin(userID)
+}
+
+func (p *Plugin) handleListTickets(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	query := r.URL.Query()
+
+	labels, err := parseLabels(strings.Join(query["label"], ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tickets, err := p.listVisibleTickets(userID, query.Get("team_id"))
+	if err != nil {
+		p.requestLogger(r).Error("Failed to list tickets", "err", err.Error())
+		http.Error(w, "Failed to list tickets", http.StatusInternalServerError)
+		return
+	}
+
+	filtered := []*Ticket{}
+	for _, ticket := range tickets {
+		if status := query.Get("status"); status != "" && !strings.EqualFold(ticket.Status, status) {
+			continue
+		}
+		if priority := query.Get("priority"); priority != "" && !strings.EqualFold(ticket.Priority, priority) {
+			continue
+		}
+		if assignee := query.Get("assignee"); assignee != "" && ticket.AssigneeID != assignee {
+			continue
+		}
+		if !ticket.hasLabels(labels) {
+			continue
+		}
+
+		filtered = append(filtered, p.redactReporter(userID, ticket))
+	}
+
+	p.writeJSON(w, filtered)
+}
+
+func (p *Plugin) handleGetTicket(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := p.ticketFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	p.writeJSON(w, p.redactReporter(r.Header.Get("Mattermost-User-ID"), ticket))
+}
+
+func (p *Plugin) handleCreateTicket(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	var request ticketCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	request.Summary = strings.TrimSpace(request.Summary)
+	if request.Summary == "" {
+		http.Error(w, "A summary is required", http.StatusBadRequest)
+		return
+	}
+	if request.Priority == "" {
+		request.Priority = ticketPriorityMedium
+	}
+	if !isValidPriority(request.Priority) {
+		http.Error(w, "Invalid priority", http.StatusBadRequest)
+		return
+	}
+	if request.Confidential && p.getConfiguration().MinimalPermissions {
+		http.Error(w, "Confidential requests are disabled on this server", http.StatusBadRequest)
+		return
+	}
+	if request.Anonymous && request.Confidential {
+		http.Error(w, "Confidential requests cannot be anonymous", http.StatusBadRequest)
+		return
+	}
+	labels, err := parseLabels(strings.Join(request.Labels, ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	customFields, customFieldErrors := p.getConfiguration().validateCustomFields(request.CustomFields, false)
+	if len(customFieldErrors) > 0 {
+		http.Error(w, customFieldsError(customFieldErrors), http.StatusBadRequest)
+		return
+	}
+
+	if !p.isTeamMember(request.TeamID, userID) {
+		http.Error(w, "Not a member of the team", http.StatusForbidden)
+		return
+	}
+	if _, ok := p.getConfiguration().demoChannelIDs[request.TeamID]; !ok || !p.teamEnabled(request.TeamID) {
+		http.Error(w, "SRE requests are not enabled in this team", http.StatusBadRequest)
+		return
+	}
+	if request.AssigneeID != "" && !p.isAssignableUser(request.TeamID, request.AssigneeID) {
+		http.Error(w, "The assignee is not an active member of the team", http.StatusBadRequest)
+		return
+	}
+
+	ticket := &Ticket{
+		TeamID:       request.TeamID,
+		ReporterID:   userID,
+		AssigneeID:   request.AssigneeID,
+		Summary:      request.Summary,
+		Description:  request.Description,
+		Priority:     request.Priority,
+		Confidential: request.Confidential,
+		Anonymous:    request.Anonymous,
+		Labels:       labels,
+	}
+	setTicketCustomFields(ticket, customFields)
+	p.getConfiguration().suggestTicketPriority(ticket, true)
+
+	if err := p.createTicket(ticket); err != nil {
+		p.requestLogger(r).Error("Failed to create ticket", "err", err.Error())
+		http.Error(w, "Failed to create ticket", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(ticket); err != nil {
+		p.requestLogger(r).Error("Failed to write ticket", "err", err.Error())
+	}
+}
+
+func (p *Plugin) handlePatchTicket(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	ticket, ok := p.ticketFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// API tokens with the admin scope act as SRE admins, while the bot may only edit the tickets it
+	// reported.
+	isTokenAdmin := requestTokenHasScope(r, apiTokenScopeAdmin)
+	if !isTokenAdmin && !p.canEditTicket(userID, ticket) {
+		http.Error(w, "Not authorized to edit this ticket", http.StatusForbidden)
+		return
+	}
+
+	var patch ticketPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if patch.Priority != nil && !isValidPriority(*patch.Priority) {
+		http.Error(w, "Invalid priority", http.StatusBadRequest)
+		return
+	}
+	priorityChanged := patch.Priority != nil && *patch.Priority != ticket.Priority
+	if priorityChanged && strings.TrimSpace(patch.Justification) == "" {
+		http.Error(w, "A justification is required to change the priority", http.StatusBadRequest)
+		return
+	}
+	if patch.Status != nil && !isValidStatus(*patch.Status) {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	if patch.DueAt != nil && *patch.DueAt < 0 {
+		http.Error(w, "Invalid due date", http.StatusBadRequest)
+		return
+	}
+	if patch.AssigneeID != nil && *patch.AssigneeID != "" && *patch.AssigneeID != ticket.AssigneeID && !p.isAssignableUser(ticket.TeamID, *patch.AssigneeID) {
+		http.Error(w, "The assignee is not an active member of the team", http.StatusBadRequest)
+		return
+	}
+	if patch.Summary != nil && strings.TrimSpace(*patch.Summary) == "" {
+		http.Error(w, "A summary is required", http.StatusBadRequest)
+		return
+	}
+	customFields, customFieldErrors := p.getConfiguration().validateCustomFields(patch.CustomFields, true)
+	if len(customFieldErrors) > 0 {
+		http.Error(w, customFieldsError(customFieldErrors), http.StatusBadRequest)
+		return
+	}
+	// Taking charge of the ticket follows the policy of its buttons, so that reporters cannot stop
+	// the escalation of their own tickets.
+	takesCharge := (patch.AssigneeID != nil && *patch.AssigneeID != ticket.AssigneeID) ||
+		(patch.Status != nil && *patch.Status == ticketStatusAcknowledged && ticket.Status != ticketStatusAcknowledged)
+	if takesCharge && !isTokenAdmin && !p.canRespondToTicket(userID, ticket) {
+		http.Error(w, "Not authorized to assign or acknowledge this ticket", http.StatusForbidden)
+		return
+	}
+	if patch.Status != nil && *patch.Status == ticketStatusResolved && ticket.Status != ticketStatusResolved && !isTokenAdmin &&
+		!p.authorizeDestructiveAction(userID, "resolve_ticket", "ticket_id", ticket.ID) {
+		http.Error(w, "Not authorized to resolve this ticket", http.StatusForbidden)
+		return
+	}
+
+	// Patches naming the revision they were made against conflict with any later change, while the
+	// others are applied again to the ticket as it was saved last.
+	var statusChanged bool
+	savedTicket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if patch.Revision != nil && *patch.Revision != ticket.Revision {
+			return errTicketConflict
+		}
+
+		if patch.Summary != nil {
+			ticket.Summary = strings.TrimSpace(*patch.Summary)
+		}
+		if patch.Description != nil {
+			ticket.Description = *patch.Description
+		}
+		setTicketCustomFields(ticket, customFields)
+		now := model.GetMillis()
+		if patch.AssigneeID != nil {
+			setTicketAssignee(ticket, *patch.AssigneeID, userID, now)
+		}
+		if patch.DueAt != nil && *patch.DueAt != ticket.DueAt {
+			setTicketDueDate(ticket, *patch.DueAt)
+		}
+		statusChanged = patch.Status != nil && *patch.Status != ticket.Status
+		if statusChanged {
+			setTicketStatus(ticket, *patch.Status, userID)
+		}
+		return nil
+	})
+	if errors.Is(err, errTicketConflict) {
+		http.Error(w, ticketConflictMessage, http.StatusConflict)
+		return
+	} else if err != nil {
+		p.requestLogger(r).withTicket(ticket).Error("Failed to save ticket", "err", err.Error())
+		http.Error(w, "Failed to save ticket", http.StatusInternalServerError)
+		return
+	}
+	ticket = savedTicket
+
+	// Priority changes follow the policy of the priority dialog: they are justified, posted in the
+	// ticket's thread and notified to the responders of the new priority.
+	if priorityChanged && *patch.Priority != ticket.Priority {
+		changedTicket, message, err := p.changeTicketPriority(ticket, *patch.Priority, userID, patch.Justification)
+		if errors.Is(err, errTicketConflict) {
+			http.Error(w, ticketConflictMessage, http.StatusConflict)
+			return
+		} else if err != nil {
+			p.requestLogger(r).withTicket(ticket).Error("Failed to change ticket priority", "err", err.Error())
+			http.Error(w, "Failed to change ticket priority", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		ticket = changedTicket
+	} else if err := p.updateTicketPost(ticket); err != nil {
+		p.requestLogger(r).withTicket(ticket).Warn("Failed to update ticket post", "err", err.Error())
+	}
+
+	if statusChanged {
+		switch ticket.Status {
+		case ticketStatusAcknowledged:
+			p.sendTicketEvent(ticketEventAcknowledged, ticket, userID)
+		case ticketStatusWaitingOnReporter:
+			p.sendTicketEvent(ticketEventWaiting, ticket, userID)
+		case ticketStatusResolved:
+			p.sendTicketEvent(ticketEventResolved, ticket, userID)
+			p.proposePostmortemReview(ticket)
+			p.sendSatisfactionSurvey(ticket)
+		}
+	}
+
+	p.writeJSON(w, p.redactReporter(userID, ticket))
+}
+
+// ticketFromRequest loads the ticket named in the request path and checks the requesting user may
+// view it, writing the error response otherwise.
+func (p *Plugin) ticketFromRequest(w http.ResponseWriter, r *http.Request) (*Ticket, bool) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	ticket, err := p.getTicket(mux.Vars(r)["id"])
+	if err != nil {
+		p.requestLogger(r).Error("Failed to get ticket", "err", err.Error())
+		http.Error(w, "Failed to get ticket", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	// Report tickets the user may not view as missing, so that their existence isn't disclosed.
+	if ticket == nil || !p.canViewTicket(userID, ticket) {
+		http.Error(w, "Ticket not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return ticket, true
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	// apiV1Prefix is the path prefix of the version 1 REST API.
+	apiV1Prefix = "/api/v1"
+
+	apiScopeUser        = "user"
+	apiScopeTeamMember  = "team_member"
+	apiScopeTicketView  = "ticket_view"
+	apiScopeTicketEdit  = "ticket_edit"
+	apiScopeSREAdmin    = "sre_admin"
+	apiScopeSystemAdmin = "system_admin"
+)
+
+// apiScopes describes the permissions required by the REST API endpoints.
+var apiScopes = map[string]string{
+	apiScopeUser:        "Authenticated Mattermost user, through a session or a personal access token, or an API token acting as the bot.",
+	apiScopeTeamMember:  "Member of the team of the ticket.",
+	apiScopeTicketView:  "Member of the ticket's team, or a participant of a confidential ticket.",
+	apiScopeTicketEdit:  "Reporter or assignee of the ticket, or the incident commander.",
+	apiScopeSREAdmin:    "SRE admin, required to resolve tickets when SRE admins are configured, to view the ticket statistics and to wipe the plugin data.",
+	apiScopeSystemAdmin: "System admin, granted every other scope.",
+}
+
+// apiEndpoint is an endpoint of the REST API, as listed by the API index.
+type apiEndpoint struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"`
+
+	// TokenScope is the scope an API token needs to call the endpoint, or empty if API tokens may
+	// not call it.
+	TokenScope string `json:"token_scope,omitempty"`
+
+	// route is the gorilla/mux route of the endpoint, relative to the API prefix.
+	route   string
+	handler http.HandlerFunc
+}
+
+// apiIndex is the response of GET /api/v1, which lets integrators discover the capabilities of
+// the API.
+type apiIndex struct {
+	PluginID         string            `json:"plugin_id"`
+	Version          string            `json:"version"`
+	APIVersion       string            `json:"api_version"`
+	MinServerVersion string            `json:"min_server_version,omitempty"`
+	ServerVersion    string            `json:"server_version"`
+	Compatible       bool              `json:"compatible"`
+	Endpoints        []apiEndpoint     `json:"endpoints"`
+	Scopes           map[string]string `json:"scopes"`
+	TokenScopes      map[string]string `json:"token_scopes"`
+}
+
+// apiEndpoints returns the endpoints of the REST API, which are both registered and listed by the
+// API index from this list so that the index can't drift from the routes.
+func (p *Plugin) apiEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{
+			Method:      http.MethodGet,
+			Path:        "/tickets",
+			Description: "List the tickets the user can view, filtered by the status, priority, assignee and team_id query parameters.",
+			Scopes:      []string{apiScopeUser},
+			route:       "/tickets",
+			TokenScope:  apiTokenScopeRead,
+			handler:     p.handleListTickets,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/tickets",
+			Description: "Create a ticket.",
+			Scopes:      []string{apiScopeUser, apiScopeTeamMember},
+			route:       "/tickets",
+			TokenScope:  apiTokenScopeWrite,
+			handler:     p.handleCreateTicket,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/tickets/search",
+			Description: "Search the summaries and descriptions of the tickets the user can view for the words of the q query parameter, filtered by the team_id, status, priority, assignee, from and to (YYYY-MM-DD) query parameters.",
+			Scopes:      []string{apiScopeUser},
+			route:       "/tickets/search",
+			TokenScope:  apiTokenScopeRead,
+			handler:     p.handleSearchTickets,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/tickets/{id}",
+			Description: "Get a ticket.",
+			Scopes:      []string{apiScopeUser, apiScopeTicketView},
+			route:       "/tickets/{id:[A-Za-z0-9]+}",
+			TokenScope:  apiTokenScopeRead,
+			handler:     p.handleGetTicket,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/tickets/{id}/timeline",
+			Description: "Get every event of a ticket in chronological order: its submission, assignments, status and priority changes, comments, escalations and integration syncs.",
+			Scopes:      []string{apiScopeUser, apiScopeTicketView},
+			route:       "/tickets/{id:[A-Za-z0-9]+}/timeline",
+			TokenScope:  apiTokenScopeRead,
+			handler:     p.handleGetTicketTimeline,
+		},
+		{
+			Method:      http.MethodPatch,
+			Path:        "/tickets/{id}",
+			Description: "Update the summary, description, priority, status, assignee, due date or custom fields of a ticket.",
+			Scopes:      []string{apiScopeUser, apiScopeTicketView, apiScopeTicketEdit, apiScopeSREAdmin},
+			route:       "/tickets/{id:[A-Za-z0-9]+}",
+			TokenScope:  apiTokenScopeWrite,
+			handler:     p.handlePatchTicket,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/stats",
+			Description: "Get the ticket statistics of the period query parameter, one of 7d, 30d (the default) or 90d: MTTA and MTTR in seconds, volumes by priority and category, and a series by day. Statistics are aggregated nightly and cover complete days, in UTC.",
+			Scopes:      []string{apiScopeUser, apiScopeSREAdmin},
+			route:       "/stats",
+			TokenScope:  apiTokenScopeAdmin,
+			handler:     p.handleStats,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/wipe",
+			Description: "Wipe every piece of plugin data in two steps: without a confirmation in the body, return the confirmation code; with {\"confirmation\": code}, send a backup to the user by direct message and wipe the data.",
+			Scopes:      []string{apiScopeUser, apiScopeSystemAdmin},
+			route:       "/wipe",
+			handler:     p.handleWipe,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/settings/channels",
+			Description: "Look up the channels matching the term query parameter, optionally in the team of the team_id query parameter, as options of the channel settings of the System Console: {\"value\": \"team/channel\", \"label\"}.",
+			Scopes:      []string{apiScopeUser, apiScopeSystemAdmin},
+			route:       "/settings/channels",
+			handler:     p.handleSettingsChannels,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/settings/users",
+			Description: "Look up the users matching the term query parameter, as options of the user settings of the System Console: {\"value\": username, \"label\"}.",
+			Scopes:      []string{apiScopeUser, apiScopeSystemAdmin},
+			route:       "/settings/users",
+			handler:     p.handleSettingsUsers,
+		},
+	}
+}
+
+func (p *Plugin) handleAPIIndex(w http.ResponseWriter, r *http.Request) {
+	serverVersion := p.API.GetServerVersion()
+
+	compatible := true
+	if manifest.MinServerVersion != "" {
+		var err error
+		if compatible, err = manifest.MeetMinServerVersion(serverVersion); err != nil {
+			p.API.LogWarn("Failed to check server compatibility", "server_version", serverVersion, "err", err.Error())
+		}
+	}
+
+	endpoints := p.apiEndpoints()
+	for i := range endpoints {
+		endpoints[i].Path = apiV1Prefix + endpoints[i].Path
+	}
+
+	p.writeJSON(w, &apiIndex{
+		PluginID:         manifest.Id,
+		Version:          manifest.Version,
+		APIVersion:       strings.TrimPrefix(apiV1Prefix, "/api/"),
+		MinServerVersion: manifest.MinServerVersion,
+		ServerVersion:    serverVersion,
+		Compatible:       compatible,
+		Endpoints:        endpoints,
+		Scopes:           apiScopes,
+		TokenScopes:      apiTokenScopes,
+	})
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// apiTokenKeyPrefix prefixes the KV key of each API token, named by the hash of the token.
+	apiTokenKeyPrefix = "api_token_"
+
+	// apiTokenPrefix starts every API token, which tells them apart from Mattermost access tokens.
+	apiTokenPrefix = "sre_"
+
+	// apiTokenIDLength is the length of the ids of the API tokens, a prefix of their hash.
+	apiTokenIDLength = 12
+
+	// apiTokenUsageResolution bounds how often the last use of a token is saved.
+	apiTokenUsageResolution = time.Hour
+
+	apiTokenScopeRead  = "read"
+	apiTokenScopeWrite = "write"
+	apiTokenScopeAdmin = "admin"
+)
+
+// apiTokenScopes describes the scopes of the API tokens, each granting the scopes before it.
+var apiTokenScopes = map[string]string{
+	apiTokenScopeRead:  "Read the tickets of the teams the plugin is enabled in, except confidential tickets.",
+	apiTokenScopeWrite: "Open tickets, and edit the tickets opened by API tokens and integrations.",
+	apiTokenScopeAdmin: "Edit and resolve any ticket the token can read, and view the ticket statistics.",
+}
+
+// apiTokenScopeLevels orders the scopes of the API tokens.
+var apiTokenScopeLevels = map[string]int{
+	apiTokenScopeRead:  1,
+	apiTokenScopeWrite: 2,
+	apiTokenScopeAdmin: 3,
+}
+
+// apiToken is a token issued by a system admin, authenticating systems such as CI pipelines to the
+// REST API without impersonating a user. Requests authenticated by a token act as the bot. Only
+// the hash of the token is stored.
+type apiToken struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	Hash      string `json:"hash"`
+	CreatedBy string `json:"created_by"`
+	CreateAt  int64  `json:"create_at"`
+
+	// LastUsedAt is when the token last authenticated a request, within apiTokenUsageResolution.
+	LastUsedAt int64 `json:"last_used_at,omitempty"`
+}
+
+// hasScope reports whether the token grants the scope.
+func (t *apiToken) hasScope(scope string) bool {
+	level, ok := apiTokenScopeLevels[scope]
+	return ok && apiTokenScopeLevels[t.Scope] >= level
+}
+
+// hashAPIToken returns the hash of an API token, which names its KV key.
+func hashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// newAPIToken returns a new random API token.
+func newAPIToken() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "failed to generate API token")
+	}
+
+	return apiTokenPrefix + hex.EncodeToString(random), nil
+}
+
+// createAPIToken issues a token with the given name and scope, returning it along with the token
+// itself, which is never shown again.
+func (p *Plugin) createAPIToken(name, scope, userID string) (*apiToken, string, error) {
+	secret, err := newAPIToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash := hashAPIToken(secret)
+	token := &apiToken{
+		ID:        hash[:apiTokenIDLength],
+		Name:      name,
+		Scope:     scope,
+		Hash:      hash,
+		CreatedBy: userID,
+		CreateAt:  model.GetMillis(),
+	}
+	if _, err := p.client.KV.Set(apiTokenKeyPrefix+hash, token); err != nil {
+		return nil, "", errors.Wrap(err, "failed to save API token")
+	}
+
+	return token, secret, nil
+}
+
+// getAPIToken returns the API token matching the given token, or nil if there is none.
+func (p *Plugin) getAPIToken(secret string) (*apiToken, error) {
+	var token *apiToken
+	if err := p.client.KV.Get(apiTokenKeyPrefix+hashAPIToken(secret), &token); err != nil {
+		return nil, errors.Wrap(err, "failed to get API token")
+	}
+
+	return token, nil
+}
+
+// listAPITokens returns the API tokens, oldest first.
+func (p *Plugin) listAPITokens() ([]*apiToken, error) {
+	var tokens []*apiToken
+	for page := 0; ; page++ {
+		keys, err := p.client.KV.ListKeys(page, kvListPerPage, pluginapi.WithPrefix(apiTokenKeyPrefix))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list API tokens")
+		}
+
+		for _, key := range keys {
+			var token *apiToken
+			if err := p.client.KV.Get(key, &token); err != nil {
+				return nil, errors.Wrap(err, "failed to get API token")
+			}
+			if token != nil {
+				tokens = append(tokens, token)
+			}
+		}
+
+		if len(keys) < kvListPerPage {
+			break
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreateAt < tokens[j].CreateAt })
+
+	return tokens, nil
+}
+
+// apiTokenContextKey is the context key of the API token authenticating a request.
+type apiTokenContextKey struct{}
+
+// requestAPIToken returns the API token authenticating the request, or nil if the request was
+// authenticated by the server.
+func requestAPIToken(r *http.Request) *apiToken {
+	token, _ := r.Context().Value(apiTokenContextKey{}).(*apiToken)
+	return token
+}
+
+// requestTokenHasScope reports whether the request was authenticated by an API token granting the
+// scope.
+func requestTokenHasScope(r *http.Request, scope string) bool {
+	token := requestAPIToken(r)
+	return token != nil && token.hasScope(scope)
+}
+
+// authenticateAPIToken authenticates the requests bearing an API token rather than a Mattermost
+// session, acting as the bot. Tokens are passed as "Authorization: Bearer sre_...", which the
+// server forwards since they aren't Mattermost access tokens.
+func (p *Plugin) authenticateAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := strings.CutPrefix(r.Header.Get(model.HeaderAuth), model.HeaderBearer+" ")
+		if r.Header.Get("Mattermost-User-ID") != "" || !ok || !strings.HasPrefix(secret, apiTokenPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := p.getAPIToken(secret)
+		if err != nil {
+			p.requestLogger(r).Error("Failed to authenticate API token", "err", err.Error())
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		if token == nil {
+			http.Error(w, "Invalid API token", http.StatusUnauthorized)
+			return
+		}
+
+		if now := model.GetMillis(); now-token.LastUsedAt >= apiTokenUsageResolution.Milliseconds() {
+			token.LastUsedAt = now
+			if _, err := p.client.KV.Set(apiTokenKeyPrefix+token.Hash, token); err != nil {
+				p.requestLogger(r).Warn("Failed to record API token use", "token_id", token.ID, "err", err.Error())
+			}
+		}
+
+		r.Header.Set("Mattermost-User-ID", p.botID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiTokenContextKey{}, token)))
+	})
+}
+
+// requireTokenScope rejects the requests authenticated by an API token lacking the scope. An empty
+// scope rejects every API token.
+func requireTokenScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := requestAPIToken(r)
+		if token == nil {
+			handler(w, r)
+			return
+		}
+
+		if scope == "" {
+			http.Error(w, "Not available to API tokens", http.StatusForbidden)
+			return
+		}
+		if !token.hasScope(scope) {
+			http.Error(w, fmt.Sprintf("The API token lacks the %s scope", scope), http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// apiTokenArguments adds the name and scope of a new API token to the autocomplete data of the
+// token create subcommand.
+func apiTokenArguments(data *model.AutocompleteData) {
+	data.AddTextArgument("Name of the token", "[name]", "")
+	items := make([]model.AutocompleteListItem, 0, len(apiTokenScopes))
+	for _, scope := range []string{apiTokenScopeRead, apiTokenScopeWrite, apiTokenScopeAdmin} {
+		items = append(items, model.AutocompleteListItem{Item: scope, HelpText: apiTokenScopes[scope]})
+	}
+	data.AddStaticListArgument("Scope of the token", true, items)
+}
+
+// executeCommandTokenCreate issues an API token, shown once to the system admin creating it.
+func (p *Plugin) executeCommandTokenCreate(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 2 {
+		return ephemeralResponse(commandUsage("token", "create"))
+	}
+
+	name, scope := params[0], strings.ToLower(params[1])
+	if _, ok := apiTokenScopes[scope]; !ok {
+		return ephemeralResponse(fmt.Sprintf("Invalid scope %q, expected read, write or admin. %s", params[1], commandUsage("token", "create")))
+	}
+
+	token, secret, err := p.createAPIToken(name, scope, args.UserId)
+	if err != nil {
+		p.API.LogError("Failed to create API token", "err", err.Error())
+		return ephemeralResponse("Failed to create the API token.")
+	}
+
+	p.postAdminNotice(fmt.Sprintf(":key: %s created the API token %s (`%s`) with the %s scope.", p.mentionUser(args.UserId), token.Name, token.ID, token.Scope))
+
+	return ephemeralResponse(fmt.Sprintf("Created the API token %s with the %s scope. Copy it now, it won't be shown again:\n```\n%s\n```\nSend it in the `Authorization: Bearer <token>` header of the requests to `%s`. Revoke it with `/%s token revoke %s`.",
+		token.Name, token.Scope, secret, pluginCallbackURL(apiV1Prefix), commandTriggerSRERequest, token.ID))
+}
+
+func (p *Plugin) executeCommandTokenList() *model.CommandResponse {
+	tokens, err := p.listAPITokens()
+	if err != nil {
+		p.API.LogError("Failed to list API tokens", "err", err.Error())
+		return ephemeralResponse("Failed to list the API tokens.")
+	}
+	if len(tokens) == 0 {
+		return ephemeralResponse(fmt.Sprintf("There are no API tokens. Create one with `/%s token create`.", commandTriggerSRERequest))
+	}
+
+	lines := []string{"| ID | Name | Scope | Created by | Created | Last used |", "| --- | --- | --- | --- | --- | --- |"}
+	for _, token := range tokens {
+		lines = append(lines, fmt.Sprintf("| `%s` | %s | %s | %s | %s | %s |", token.ID, token.Name, token.Scope, p.mentionUser(token.CreatedBy), formatJobTime(token.CreateAt), formatJobTime(token.LastUsedAt)))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
+
+// executeCommandTokenRevoke revokes the API token with the given id, or the given name.
+func (p *Plugin) executeCommandTokenRevoke(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("token", "revoke"))
+	}
+
+	tokens, err := p.listAPITokens()
+	if err != nil {
+		p.API.LogError("Failed to list API tokens", "err", err.Error())
+		return ephemeralResponse("Failed to revoke the API token.")
+	}
+
+	var matches []*apiToken
+	for _, token := range tokens {
+		if token.ID == params[0] || token.Name == params[0] {
+			matches = append(matches, token)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return ephemeralResponse(fmt.Sprintf("Unknown API token %s. Run `/%s token list` to list the tokens.", params[0], commandTriggerSRERequest))
+	case len(matches) > 1:
+		return ephemeralResponse(fmt.Sprintf("Several API tokens are named %s. Revoke them by id instead.", params[0]))
+	}
+
+	token := matches[0]
+	if err := p.client.KV.Delete(apiTokenKeyPrefix + token.Hash); err != nil {
+		p.API.LogError("Failed to delete API token", "token_id", token.ID, "err", err.Error())
+		return ephemeralResponse("Failed to revoke the API token.")
+	}
+
+	p.postAdminNotice(fmt.Sprintf(":key: %s revoked the API token %s (`%s`).", p.mentionUser(args.UserId), token.Name, token.ID))
+
+	return ephemeralResponse(fmt.Sprintf("Revoked the API token %s.", token.Name))
+}
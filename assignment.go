@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"plugin-test/utils"
+)
+
+const (
+	// assignmentSimilarityThreshold is the minimum token overlap between the summaries of two
+	// tickets affecting different services and sharing no label for the resolver of one to be
+	// suggested as the assignee of the other.
+	assignmentSimilarityThreshold = 0.2
+
+	// maxAssignmentSuggestions caps the number of assignees suggested when triaging a ticket.
+	maxAssignmentSuggestions = 3
+)
+
+// suggestAssignees returns the users who most recently resolved tickets similar to the given one,
+// most recent first.
+func (p *Plugin) suggestAssignees(ticket *Ticket) ([]string, error) {
+	tickets, err := p.listCachedTickets(ticket.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := utils.Tokenize(ticket.Summary)
+
+	var similar []*Ticket
+	for _, candidate := range tickets {
+		if candidate.ID == ticket.ID || candidate.TeamID != ticket.TeamID || candidate.Status != ticketStatusResolved {
+			continue
+		}
+		if candidate.ResolvedBy == "" && candidate.AssigneeID == "" {
+			continue
+		}
+		if !similarTickets(ticket, candidate, tokens) {
+			continue
+		}
+
+		similar = append(similar, candidate)
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		return similar[i].ResolvedAt > similar[j].ResolvedAt
+	})
+
+	var suggestions []string
+	for _, candidate := range similar {
+		resolverID := candidate.ResolvedBy
+		if resolverID == "" {
+			resolverID = candidate.AssigneeID
+		}
+		if resolverID == ticket.AssigneeID || contains(suggestions, resolverID) {
+			continue
+		}
+
+		suggestions = append(suggestions, resolverID)
+		if len(suggestions) == maxAssignmentSuggestions {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
+
+// similarTickets reports whether the candidate is similar to the ticket, whose summary has the
+// given tokens: it affects the same service, shares one of the ticket's labels, or has a similar
+// summary.
+func similarTickets(ticket, candidate *Ticket, tokens map[string]bool) bool {
+	if ticket.Service != "" && strings.EqualFold(ticket.Service, candidate.Service) {
+		return true
+	}
+	for _, label := range ticket.Labels {
+		if contains(candidate.Labels, label) {
+			return true
+		}
+	}
+
+	return utils.TokenOverlap(tokens, utils.Tokenize(candidate.Summary)) >= assignmentSimilarityThreshold
+}
+
+// sendTriageView shows the user an ephemeral triage view of the ticket, with one-click buttons
+// applying the suggested priority and assigning the ticket to the suggested assignees.
+func (p *Plugin) sendTriageView(ticket *Ticket, userID string) (string, error) {
+	suggestions, err := p.suggestAssignees(ticket)
+	if err != nil {
+		return "", err
+	}
+
+	var attachments []*model.SlackAttachment
+	if ticket.hasPrioritySuggestion() {
+		attachments = append(attachments, prioritySuggestionAttachment(ticket))
+	}
+
+	if len(suggestions) == 0 {
+		if len(attachments) == 0 {
+			return "No similar resolved requests were found to suggest an assignee.", nil
+		}
+	} else {
+		attachments = append(attachments, p.assigneeSuggestionAttachment(ticket, suggestions))
+	}
+
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botID,
+		ChannelId: ticket.ChannelID,
+		RootId:    ticket.PostID,
+		Message:   fmt.Sprintf("#### Triage: %s", ticket.Summary),
+		Props: model.StringInterface{
+			"attachments": attachments,
+		},
+	})
+
+	return "", nil
+}
+
+// assigneeSuggestionAttachment renders one-click buttons assigning the ticket to the suggested
+// assignees.
+func (p *Plugin) assigneeSuggestionAttachment(ticket *Ticket, suggestions []string) *model.SlackAttachment {
+	var actions []*model.PostAction
+	for _, suggestion := range suggestions {
+		actions = append(actions, &model.PostAction{
+			Type: model.PostActionTypeButton,
+			Name: fmt.Sprintf("Assign %s", p.mentionUser(suggestion)),
+			Integration: &model.PostActionIntegration{
+				URL: ticketActionURL(ticketActionAssign),
+				Context: model.StringInterface{
+					"ticket_id":   ticket.ID,
+					"assignee_id": suggestion,
+				},
+			},
+		})
+	}
+
+	return &model.SlackAttachment{
+		Text:    "These users most recently resolved similar requests:",
+		Actions: actions,
+	}
+}
+
+// assignTicket assigns the ticket to assigneeID on behalf of userID.
+func (p *Plugin) assignTicket(ticket *Ticket, assigneeID, userID string) (string, error) {
+	if !p.canRespondToTicket(userID, ticket) {
+		return "You are not allowed to assign this request.", nil
+	}
+	if assigneeID == "" {
+		return "No assignee was selected.", nil
+	}
+	if assigneeID == ticket.AssigneeID {
+		return fmt.Sprintf("This request is already assigned to %s.", p.mentionUser(assigneeID)), nil
+	}
+	if !p.isAssignableUser(ticket.TeamID, assigneeID) {
+		return fmt.Sprintf("%s cannot be assigned requests of this team.", p.mentionUser(assigneeID)), nil
+	}
+
+	var unchanged string
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if !p.canRespondToTicket(userID, ticket) {
+			unchanged = "You are not allowed to assign this request."
+			return errTicketUnchanged
+		}
+		if assigneeID == ticket.AssigneeID {
+			unchanged = fmt.Sprintf("This request is already assigned to %s.", p.mentionUser(assigneeID))
+			return errTicketUnchanged
+		}
+		if !p.isAssignableUser(ticket.TeamID, assigneeID) {
+			unchanged = fmt.Sprintf("%s cannot be assigned requests of this team.", p.mentionUser(assigneeID))
+			return errTicketUnchanged
+		}
+		setTicketAssignee(ticket, assigneeID, userID, model.GetMillis())
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return unchanged, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return "", err
+	}
+
+	dmMessage := p.ticketDirectMessage(ticket, fmt.Sprintf("%s assigned you a request.", p.mentionUser(userID)))
+	message := fmt.Sprintf("%s assigned this request to %s.", p.mentionUser(userID), p.notifyUser(assigneeID, dmMessage))
+	if err := p.postTicketReply(ticket, message); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
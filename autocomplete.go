@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// ticketsAutocompleteURL is the URL of the dynamic list of ticket ids, relative to the plugin.
+	// The server resolves it when the command is registered.
+	ticketsAutocompleteURL = "autocomplete/tickets"
+
+	// maxAutocompleteTickets bounds how many tickets the dynamic list suggests.
+	maxAutocompleteTickets = 25
+
+	// autocompleteRecentWindow is how long resolved tickets are still suggested after their last
+	// update.
+	autocompleteRecentWindow = 7 * 24 * time.Hour
+
+	// maxAutocompleteHintLength bounds the summary shown next to each suggested ticket.
+	maxAutocompleteHintLength = 60
+)
+
+// autocompleteTyped returns the word of the command being typed, or an empty string if the user is
+// starting a new word.
+func autocompleteTyped(userInput string) string {
+	if userInput == "" || strings.HasSuffix(userInput, " ") {
+		return ""
+	}
+	fields := strings.Fields(userInput)
+
+	return fields[len(fields)-1]
+}
+
+// matchesAutocomplete reports whether the ticket matches the word being typed, either by its key
+// or id, or by its summary.
+func (t *Ticket) matchesAutocomplete(typed string) bool {
+	typed = strings.ToLower(typed)
+
+	return strings.HasPrefix(strings.ToLower(t.ticketName()), typed) ||
+		strings.HasPrefix(t.ID, typed) ||
+		strings.Contains(strings.ToLower(t.Summary), typed)
+}
+
+// handleAutocompleteTickets serves the dynamic list of the subcommands taking a ticket: the tickets
+// the user reported or is assigned to in the current team, open ones and those resolved recently,
+// newest first.
+func (p *Plugin) handleAutocompleteTickets(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	query := r.URL.Query()
+	teamID := query.Get("team_id")
+
+	items := []model.AutocompleteListItem{}
+	if teamID == "" || !p.teamEnabled(teamID) {
+		p.writeJSON(w, items)
+		return
+	}
+
+	tickets, err := p.listVisibleTickets(userID, teamID)
+	if err != nil {
+		p.requestLogger(r).Error("Failed to list tickets for autocomplete", "err", err.Error())
+		http.Error(w, "Failed to list tickets", http.StatusInternalServerError)
+		return
+	}
+
+	typed := autocompleteTyped(query.Get("user_input"))
+	recent := model.GetMillis() - autocompleteRecentWindow.Milliseconds()
+	var suggested []*Ticket
+	for _, ticket := range tickets {
+		if ticket.ReporterID != userID && ticket.AssigneeID != userID {
+			continue
+		}
+		if ticket.Status == ticketStatusResolved && ticket.UpdateAt < recent {
+			continue
+		}
+		if typed != "" && !ticket.matchesAutocomplete(typed) {
+			continue
+		}
+
+		suggested = append(suggested, ticket)
+	}
+
+	sort.Slice(suggested, func(i, j int) bool {
+		return suggested[i].CreateAt > suggested[j].CreateAt
+	})
+	if len(suggested) > maxAutocompleteTickets {
+		suggested = suggested[:maxAutocompleteTickets]
+	}
+
+	localizer := p.userLocalizer(userID)
+	for _, ticket := range suggested {
+		hint := ticket.Summary
+		if runes := []rune(hint); len(runes) > maxAutocompleteHintLength {
+			hint = string(runes[:maxAutocompleteHintLength]) + "…"
+		}
+		items = append(items, model.AutocompleteListItem{
+			Item:     ticket.ticketName(),
+			Hint:     hint,
+			HelpText: fmt.Sprintf("%s · %s", localizeLabel(localizer, statusLabels, ticket.Status), localizeLabel(localizer, priorityLabels, ticket.Priority)),
+		})
+	}
+
+	p.writeJSON(w, items)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// weekdayNames maps the abbreviated day names accepted by BusinessDays to weekdays.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// businessHours are the days and hours during which Low and Medium priority tickets notify their
+// responders.
+type businessHours struct {
+	location *time.Location
+	days     [7]bool
+
+	// openHour, openMinute, closeHour and closeMinute are the opening and closing times of every
+	// business day, in location.
+	openHour, openMinute   int
+	closeHour, closeMinute int
+}
+
+// parseBusinessHours parses the business hours settings, returning nil when BusinessHours is empty,
+// in which case every hour is a business hour.
+func parseBusinessHours(configuration *configuration) (*businessHours, error) {
+	hours := strings.TrimSpace(configuration.BusinessHours)
+	if hours == "" {
+		return nil, nil
+	}
+
+	b := &businessHours{location: time.UTC}
+	if timezone := strings.TrimSpace(configuration.BusinessHoursTimezone); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid business hours timezone %q", timezone)
+		}
+		b.location = location
+	}
+
+	openTime, closeTime, ok := strings.Cut(hours, "-")
+	if !ok {
+		return nil, errors.Errorf("invalid business hours %q, expected HH:MM-HH:MM", hours)
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(openTime), "%d:%d", &b.openHour, &b.openMinute); err != nil {
+		return nil, errors.Wrapf(err, "invalid business hours opening time %q", openTime)
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(closeTime), "%d:%d", &b.closeHour, &b.closeMinute); err != nil {
+		return nil, errors.Wrapf(err, "invalid business hours closing time %q", closeTime)
+	}
+	if b.openHour*60+b.openMinute >= b.closeHour*60+b.closeMinute || b.closeHour*60+b.closeMinute > 24*60 {
+		return nil, errors.Errorf("invalid business hours %q, the opening time must be before the closing time", hours)
+	}
+
+	days := strings.TrimSpace(configuration.BusinessDays)
+	if days == "" {
+		days = "Mon-Fri"
+	}
+	for _, item := range strings.Split(days, ",") {
+		first, last, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(item)), "-")
+		if !isRange {
+			last = first
+		}
+		firstDay, ok := weekdayNames[strings.TrimSpace(first)]
+		if !ok {
+			return nil, errors.Errorf("invalid business day %q", item)
+		}
+		lastDay, ok := weekdayNames[strings.TrimSpace(last)]
+		if !ok {
+			return nil, errors.Errorf("invalid business day %q", item)
+		}
+
+		// Ranges may wrap around the week, such as Sat-Mon.
+		for day := firstDay; ; day = (day + 1) % 7 {
+			b.days[day] = true
+			if day == lastDay {
+				break
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// opening and closing return the opening and closing times of the day of t.
+func (b *businessHours) opening(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), b.openHour, b.openMinute, 0, 0, b.location)
+}
+
+func (b *businessHours) closing(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), b.closeHour, b.closeMinute, 0, 0, b.location)
+}
+
+// isOpen reports whether t is within business hours. Every time is within business hours when
+// none are configured.
+func (b *businessHours) isOpen(t time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	t = t.In(b.location)
+	return b.days[t.Weekday()] && !t.Before(b.opening(t)) && t.Before(b.closing(t))
+}
+
+// nextOpening returns the start of the next business hours after t.
+func (b *businessHours) nextOpening(t time.Time) time.Time {
+	t = t.In(b.location)
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		if opening := b.opening(day); b.days[day.Weekday()] && opening.After(t) {
+			return opening
+		}
+	}
+
+	// Unreachable, since parseBusinessHours always sets at least one day.
+	return t
+}
+
+// formatTime formats a time in milliseconds for display in the business hours timezone.
+func (b *businessHours) formatTime(millis int64) string {
+	location := time.UTC
+	if b != nil {
+		location = b.location
+	}
+
+	return time.UnixMilli(millis).In(location).Format("Mon Jan 2 15:04 MST")
+}
+
+// queueOutsideBusinessHours queues a new Low or Medium priority ticket submitted outside business
+// hours, so that its responders are only notified once business hours start. High priority and
+// confidential tickets are never queued.
+func (c *configuration) queueOutsideBusinessHours(ticket *Ticket) {
+	if ticket.Priority == ticketPriorityHigh || ticket.Confidential {
+		return
+	}
+
+	now := time.UnixMilli(ticket.CreateAt)
+	if c.businessHours.isOpen(now) {
+		return
+	}
+
+	ticket.QueuedUntil = c.businessHours.nextOpening(now).UnixMilli()
+}
+
+// isQueued reports whether the ticket waits for business hours to notify its responders.
+func (t *Ticket) isQueued() bool {
+	return t.QueuedUntil != 0 && !t.QueueAnnounced
+}
+
+// slaStartAt returns when the ticket's SLA clock started: once business hours started for queued
+// tickets, or else when the ticket was submitted.
+func (t *Ticket) slaStartAt() int64 {
+	if t.QueuedUntil > t.CreateAt {
+		return t.QueuedUntil
+	}
+
+	return t.CreateAt
+}
+
+// expectedResponse describes when an open ticket is expected to be acknowledged, or returns an
+// empty string if nothing is expected.
+func (c *configuration) expectedResponse(ticket *Ticket) string {
+	if ticket.Status != ticketStatusOpen {
+		return ""
+	}
+
+	if sla, ok := c.ticketSLA(ticket); ok {
+		return "By " + c.businessHours.formatTime(ticket.slaStartAt()+ticket.PausedDuration+sla.Milliseconds())
+	}
+	if ticket.isQueued() {
+		return "After " + c.businessHours.formatTime(ticket.QueuedUntil)
+	}
+
+	return ""
+}
+
+// announceQueuedTickets posts, once business hours start, a digest of the tickets submitted
+// outside business hours to the SRE channel of their team, notifying their responders.
+func (p *Plugin) announceQueuedTickets() {
+	tickets, err := p.listTickets()
+	if err != nil {
+		p.reportJobFailure("BackgroundJob", "Failed to list tickets for queued ticket announcement", err)
+		return
+	}
+
+	now := model.GetMillis()
+	queuedByTeam := make(map[string][]*Ticket)
+	for _, ticket := range tickets {
+		if ticket.isQueued() && now >= ticket.QueuedUntil {
+			queuedByTeam[ticket.TeamID] = append(queuedByTeam[ticket.TeamID], ticket)
+		}
+	}
+
+	for teamID, queued := range queuedByTeam {
+		sort.Slice(queued, func(i, j int) bool { return queued[i].CreateAt < queued[j].CreateAt })
+
+		if err := p.announceTeamQueuedTickets(teamID, queued); err != nil {
+			p.API.LogError("Failed to announce queued tickets", "team_id", teamID, "err", err.Error())
+		}
+	}
+}
+
+// announceTeamQueuedTickets marks the queued tickets of the team as announced, then announces
+// them. The tickets are saved first, so that a ticket is announced once even if it changes
+// meanwhile or another node announces it too.
+func (p *Plugin) announceTeamQueuedTickets(teamID string, tickets []*Ticket) error {
+	var queued []*Ticket
+	for _, ticket := range tickets {
+		ticketID := ticket.ID
+		ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+			if !ticket.isQueued() {
+				return errTicketUnchanged
+			}
+			ticket.QueueAnnounced = true
+			return nil
+		})
+		if errors.Is(err, errTicketUnchanged) {
+			continue
+		}
+		if err != nil {
+			p.API.LogError("Failed to save announced ticket", "ticket_id", ticketID, "err", err.Error())
+			continue
+		}
+		queued = append(queued, ticket)
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+
+	lines := []string{"#### :sunrise: SRE requests submitted outside business hours"}
+	var userIDs, groupNames []string
+	if onCallUserID := p.onCallUserID(); onCallUserID != "" {
+		userIDs = append(userIDs, onCallUserID)
+	}
+	for _, ticket := range queued {
+		link := ticket.Summary
+		if permalink, err := p.ticketPermalink(ticket); err == nil {
+			link = fmt.Sprintf("[%s](%s)", ticket.Summary, permalink)
+		}
+		lines = append(lines, fmt.Sprintf("- %s (%s, %s)", link, ticket.Priority, strings.ToLower(ticket.Status)))
+
+		if ticket.Status == ticketStatusResolved {
+			continue
+		}
+		responderIDs, responderGroups := p.responders(ticket.Priority)
+		userIDs = append(userIDs, responderIDs...)
+		for _, name := range append(responderGroups, p.getConfiguration().routingGroupNames(ticket)...) {
+			if !contains(groupNames, name) {
+				groupNames = append(groupNames, name)
+			}
+		}
+	}
+	if mentions := p.notifyRecipients(userIDs, groupNames, strings.Join(lines, "\n")); mentions != "" {
+		lines = append(lines, "", mentions+" please take a look.")
+	}
+
+	if _, err := p.createRoutedPost(teamID, func(channelID string) (*model.Post, error) {
+		return &model.Post{
+			UserId:    p.botID,
+			ChannelId: channelID,
+			Message:   strings.Join(lines, "\n"),
+		}, nil
+	}); err != nil {
+		return err
+	}
+
+	for _, ticket := range queued {
+		if err := p.updateTicketPost(ticket); err != nil {
+			p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+
+	return nil
+}
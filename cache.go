@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// ticketCacheTTL bounds how stale the cached ticket list may be. Tickets saved on this node are
+// updated in place, and the other nodes of a cluster are told to reload them through
+// ticketCacheClusterEvent, so the TTL only covers lost cluster messages.
+const ticketCacheTTL = 5 * time.Minute
+
+// ticketCacheClusterEvent is the id of the cluster event telling the other nodes that a ticket
+// was saved or deleted, or that every ticket was wiped.
+const ticketCacheClusterEvent = "ticket_cache_invalidate"
+
+// ticketCacheInvalidation is the payload of ticketCacheClusterEvent.
+type ticketCacheInvalidation struct {
+	// TicketID is the changed ticket, or empty if the whole cache must be cleared.
+	TicketID string `json:"ticket_id,omitempty"`
+}
+
+// ticketCache is an in-memory copy of every stored ticket, serving the list and search paths that
+// would otherwise scan the whole ticket store. Tickets are partitioned by team, so that query paths
+// only ever read the tickets of the teams they are scoped to. Background jobs, which must see the
+// latest state, read the store directly. The zero value is an empty cache.
+type ticketCache struct {
+	lock sync.Mutex
+
+	// teams maps team ids to the tickets of the team by id, or is nil while the cache is empty.
+	teams    map[string]map[string]*Ticket
+	expireAt time.Time
+}
+
+// clone copies the ticket so that cached tickets are never shared with callers.
+func (t *Ticket) clone() *Ticket {
+	clone := *t
+	clone.Timeline = cloneElements(t.Timeline)
+	clone.Comments = cloneElements(t.Comments)
+	clone.History = cloneElements(t.History)
+	clone.Activity = cloneElements(t.Activity)
+	clone.DueRemindersSent = append([]string(nil), t.DueRemindersSent...)
+	clone.SuggestedPrioritySignals = append([]string(nil), t.SuggestedPrioritySignals...)
+	clone.Watchers = append([]string(nil), t.Watchers...)
+	clone.DuplicateIDs = append([]string(nil), t.DuplicateIDs...)
+	clone.Labels = append([]string(nil), t.Labels...)
+	clone.PendingCustomFields = append([]string(nil), t.PendingCustomFields...)
+	clone.Files = cloneElements(t.Files)
+	clone.Commits = cloneElements(t.Commits)
+	clone.VaultArtifacts = cloneElements(t.VaultArtifacts)
+
+	// Deep copy CustomFields, a reference type.
+	if t.CustomFields != nil {
+		clone.CustomFields = make(map[string]string, len(t.CustomFields))
+		for name, value := range t.CustomFields {
+			clone.CustomFields[name] = value
+		}
+	}
+
+	return &clone
+}
+
+// cloneElements copies the slice along with the elements it points to, which hold no references.
+func cloneElements[T any](elements []*T) []*T {
+	if elements == nil {
+		return nil
+	}
+
+	clones := make([]*T, len(elements))
+	for i, element := range elements {
+		if element != nil {
+			clone := *element
+			clones[i] = &clone
+		}
+	}
+
+	return clones
+}
+
+// list returns a copy of the cached tickets of the given teams, or of every team if none is given,
+// or false if the cache is empty or expired.
+func (c *ticketCache) list(teamIDs ...string) ([]*Ticket, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.teams == nil || time.Now().After(c.expireAt) {
+		return nil, false
+	}
+
+	if len(teamIDs) == 0 {
+		for teamID := range c.teams {
+			teamIDs = append(teamIDs, teamID)
+		}
+	}
+
+	var tickets []*Ticket
+	for _, teamID := range teamIDs {
+		for _, ticket := range c.teams[teamID] {
+			tickets = append(tickets, ticket.clone())
+		}
+	}
+
+	return tickets, true
+}
+
+// replace fills the cache with the given tickets.
+func (c *ticketCache) replace(tickets []*Ticket) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.teams = make(map[string]map[string]*Ticket)
+	for _, ticket := range tickets {
+		c.store(ticket)
+	}
+	c.expireAt = time.Now().Add(ticketCacheTTL)
+}
+
+// store adds a copy of the ticket to its team's partition. The lock must be held.
+func (c *ticketCache) store(ticket *Ticket) {
+	if c.teams[ticket.TeamID] == nil {
+		c.teams[ticket.TeamID] = make(map[string]*Ticket)
+	}
+	c.teams[ticket.TeamID][ticket.ID] = ticket.clone()
+}
+
+// update stores the ticket in the cache, if the cache is loaded.
+func (c *ticketCache) update(ticket *Ticket) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.teams != nil {
+		c.store(ticket)
+	}
+}
+
+// remove drops the ticket from the cache.
+func (c *ticketCache) remove(ticketID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, tickets := range c.teams {
+		delete(tickets, ticketID)
+	}
+}
+
+// clear empties the cache.
+func (c *ticketCache) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.teams = nil
+}
+
+// invalidateTicketCache tells the other nodes of the cluster to reload the ticket from the store,
+// or to clear their cache if ticketID is empty. The local cache is kept up to date by the caller.
+func (p *Plugin) invalidateTicketCache(ticketID string) {
+	data, err := json.Marshal(ticketCacheInvalidation{TicketID: ticketID})
+	if err != nil {
+		p.API.LogWarn("Failed to encode ticket cache invalidation", "ticket_id", ticketID, "err", err.Error())
+		return
+	}
+
+	if err := p.API.PublishPluginClusterEvent(
+		model.PluginClusterEvent{Id: ticketCacheClusterEvent, Data: data},
+		model.PluginClusterEventSendOptions{SendType: model.PluginClusterEventSendTypeReliable},
+	); err != nil {
+		p.API.LogWarn("Failed to publish ticket cache invalidation", "ticket_id", ticketID, "err", err.Error())
+	}
+}
+
+// OnPluginClusterEvent is invoked when another node of the cluster publishes a plugin event.
+//
+// This implementation reloads the tickets saved or deleted by other nodes into the ticket cache,
+// and clears it when they wipe the data.
+func (p *Plugin) OnPluginClusterEvent(_ *plugin.Context, ev model.PluginClusterEvent) {
+	if ev.Id != ticketCacheClusterEvent {
+		return
+	}
+
+	var invalidation ticketCacheInvalidation
+	if err := json.Unmarshal(ev.Data, &invalidation); err != nil || invalidation.TicketID == "" {
+		p.ticketCache.clear()
+		return
+	}
+
+	ticket, err := p.getTicket(invalidation.TicketID)
+	if err != nil {
+		p.API.LogWarn("Failed to reload invalidated ticket", "ticket_id", invalidation.TicketID, "err", err.Error())
+		p.ticketCache.clear()
+		return
+	}
+
+	// The ticket may have moved to another team, so it is dropped from every partition first.
+	p.ticketCache.remove(invalidation.TicketID)
+	if ticket != nil {
+		p.ticketCache.update(ticket)
+	}
+}
+
+// listCachedTickets returns the stored tickets of the given teams, or of every team if none is
+// given, served from the ticket cache when it is fresh.
+func (p *Plugin) listCachedTickets(teamIDs ...string) ([]*Ticket, error) {
+	if tickets, ok := p.ticketCache.list(teamIDs...); ok {
+		return tickets, nil
+	}
+
+	tickets, err := p.listTickets()
+	if err != nil {
+		return nil, err
+	}
+	p.ticketCache.replace(tickets)
+
+	if len(teamIDs) == 0 {
+		return tickets, nil
+	}
+
+	var teamTickets []*Ticket
+	for _, ticket := range tickets {
+		if contains(teamIDs, ticket.TeamID) {
+			teamTickets = append(teamTickets, ticket)
+		}
+	}
+
+	return teamTickets, nil
+}
+
+const (
+	// teamCacheTTL bounds how stale the cached team list may be. The cache is also refreshed
+	// whenever the configuration changes or a user joins a team missing from it.
+	teamCacheTTL = 10 * time.Minute
+
+	// teamCacheRefreshInterval is how often the team cache is refreshed in the background. It is
+	// shorter than the TTL, so that hooks are served from the cache as long as the server answers.
+	teamCacheRefreshInterval = 5 * time.Minute
+)
+
+// teamCache is an in-memory copy of the teams of the server, saving the configuration hooks and
+// ticket permalinks a round trip to the server. The zero value is an empty cache.
+type teamCache struct {
+	lock     sync.Mutex
+	teams    map[string]*model.Team
+	expireAt time.Time
+}
+
+// cloneTeam copies the team so that cached teams are never shared with callers. The pointer
+// fields of teams are never modified, so they are left shared.
+func cloneTeam(team *model.Team) *model.Team {
+	clone := *team
+	return &clone
+}
+
+// list returns the cached teams, or false if the cache is empty or expired.
+func (c *teamCache) list() ([]*model.Team, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.teams == nil || time.Now().After(c.expireAt) {
+		return nil, false
+	}
+
+	teams := make([]*model.Team, 0, len(c.teams))
+	for _, team := range c.teams {
+		teams = append(teams, cloneTeam(team))
+	}
+
+	return teams, true
+}
+
+// get returns the cached team with the given id, or false if it is not cached or the cache
+// expired.
+func (c *teamCache) get(teamID string) (*model.Team, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	team, ok := c.teams[teamID]
+	if !ok || time.Now().After(c.expireAt) {
+		return nil, false
+	}
+
+	return cloneTeam(team), true
+}
+
+// replace fills the cache with the given teams.
+func (c *teamCache) replace(teams []*model.Team) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.teams = make(map[string]*model.Team, len(teams))
+	for _, team := range teams {
+		c.teams[team.Id] = cloneTeam(team)
+	}
+	c.expireAt = time.Now().Add(teamCacheTTL)
+}
+
+// invalidate empties the cache, so that the teams are fetched again on the next lookup.
+func (c *teamCache) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.teams = nil
+}
+
+// listCachedTeams returns every team, served from the team cache when it is fresh.
+func (p *Plugin) listCachedTeams() ([]*model.Team, *model.AppError) {
+	if teams, ok := p.teamCache.list(); ok {
+		return teams, nil
+	}
+
+	teams, appErr := p.API.GetTeams()
+	if appErr != nil {
+		return nil, appErr
+	}
+	p.teamCache.replace(teams)
+
+	return teams, nil
+}
+
+// listScopedTeams returns the teams the plugin is enabled in, as configured by Teams, served from
+// the team cache when it is fresh.
+func (p *Plugin) listScopedTeams(configuration *configuration) ([]*model.Team, *model.AppError) {
+	teams, appErr := p.listCachedTeams()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	scoped := make([]*model.Team, 0, len(teams))
+	for _, team := range teams {
+		if configuration.inTeamScope(team) {
+			scoped = append(scoped, team)
+		}
+	}
+
+	return scoped, nil
+}
+
+// refreshTeamCache refreshes the team cache every teamCacheRefreshInterval until stop is closed.
+// Every plugin instance keeps its own cache, so this runs on all of them rather than as a cluster
+// job.
+func (p *Plugin) refreshTeamCache(stop <-chan struct{}) {
+	ticker := time.NewTicker(teamCacheRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			teams, appErr := p.API.GetTeams()
+			if appErr != nil {
+				p.API.LogWarn("Failed to refresh the team cache", "err", appErr.Error())
+				continue
+			}
+			p.teamCache.replace(teams)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// getCachedTeam returns the team with the given id, served from the team cache when it is fresh.
+func (p *Plugin) getCachedTeam(teamID string) (*model.Team, *model.AppError) {
+	if team, ok := p.teamCache.get(teamID); ok {
+		return team, nil
+	}
+
+	return p.API.GetTeam(teamID)
+}
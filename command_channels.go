@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parseCommandChannels resolves the CommandChannels setting into the channels, mapped to their
+// team, and the teams in which the slash command and the intake dialog may be used. Entries that
+// cannot be found are logged and skipped.
+func (p *Plugin) parseCommandChannels(configuration *configuration) (map[string]string, map[string]bool) {
+	channelTeams := make(map[string]string)
+	teamIDs := make(map[string]bool)
+	for _, entry := range strings.Split(configuration.CommandChannels, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		teamName, channelName, ok := strings.Cut(entry, "/")
+		if !ok || strings.TrimSpace(channelName) == "*" {
+			team, appErr := p.API.GetTeamByName(strings.ToLower(strings.TrimSpace(teamName)))
+			if appErr != nil {
+				p.API.LogWarn("Failed to find configured team", "setting", "CommandChannels", "value", entry, "err", appErr.Error())
+				continue
+			}
+			teamIDs[team.Id] = true
+			continue
+		}
+
+		channel, appErr := p.API.GetChannelByNameForTeamName(strings.TrimSpace(teamName), strings.TrimSpace(channelName), false)
+		if appErr != nil {
+			p.API.LogWarn("Failed to find configured channel", "setting", "CommandChannels", "value", entry, "err", appErr.Error())
+			continue
+		}
+		channelTeams[channel.Id] = channel.TeamId
+	}
+
+	return channelTeams, teamIDs
+}
+
+// commandAllowedIn reports whether the slash command and the intake dialog may be used in the
+// channel of the team. They may be used anywhere unless CommandChannels is set.
+func (c *configuration) commandAllowedIn(teamID, channelID string) bool {
+	if strings.TrimSpace(c.CommandChannels) == "" {
+		return true
+	}
+
+	return c.commandTeamIDs[teamID] || c.commandChannelTeams[channelID] != ""
+}
+
+// commandRedirectMessage tells the user the slash command can't be used in the current channel,
+// pointing them to the channels of the team where it can.
+func (p *Plugin) commandRedirectMessage(teamID string) string {
+	var mentions []string
+	for channelID, channelTeamID := range p.getConfiguration().commandChannelTeams {
+		if channelTeamID != teamID {
+			continue
+		}
+
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil {
+			p.API.LogWarn("Failed to get command channel", "channel_id", channelID, "err", appErr.Error())
+			continue
+		}
+		mentions = append(mentions, "~"+channel.Name)
+	}
+
+	sort.Strings(mentions)
+
+	if len(mentions) == 0 {
+		return fmt.Sprintf("SRE requests are not available in this team. Ask your admin where /%s can be used.", commandTriggerSRERequest)
+	}
+
+	return fmt.Sprintf("/%s is not available in this channel. Please use it in %s.", commandTriggerSRERequest, strings.Join(mentions, ", "))
+}
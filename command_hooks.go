@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	commandTriggerSRERequest = "sre-request"
+)
+
+func (p *Plugin) registerCommands() error {
+	// Command help is shown to every user, so it is rendered in the server language.
+	localizer := p.serverLocalizer()
+	autocompleteData := getSRERequestAutocompleteData()
+	localizeAutocomplete(localizer, autocompleteData, "command")
+
+	if err := p.API.RegisterCommand(&model.Command{
+		Trigger:          commandTriggerSRERequest,
+		AutoComplete:     true,
+		AutoCompleteDesc: localize(localizer, &i18n.Message{ID: "command.sre-request.description", Other: "Open the SRE request dialog."}, nil),
+		DisplayName:      localize(localizer, &i18n.Message{ID: "command.sre-request.display_name", Other: "SRE Request"}, nil),
+		AutocompleteData: autocompleteData,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to register %s command", commandTriggerSRERequest)
+	}
+
+	return nil
+}
+
+// ExecuteCommand executes a command that has been previously registered via the RegisterCommand
+// API.
+func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (response *model.CommandResponse, appErr *model.AppError) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			p.reportPanic(commandSource(args), recovered)
+			response, appErr = ephemeralResponse(commandPanicMessage), nil
+		}
+	}()
+
+	trigger := strings.TrimPrefix(strings.Fields(args.Command)[0], "/")
+	switch trigger {
+	case commandTriggerSRERequest:
+		return p.executeCommandSRERequest(args), nil
+
+	default:
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         fmt.Sprintf("Unknown command: %s", args.Command),
+		}, nil
+	}
+}
+
+func (p *Plugin) executeCommandSRERequest(args *model.CommandArgs) *model.CommandResponse {
+	if !p.getConfiguration().commandAllowedIn(args.TeamId, args.ChannelId) {
+		return ephemeralResponse(p.commandRedirectMessage(args.TeamId))
+	}
+
+	fields := strings.Fields(args.Command)
+	subcommand := ""
+	if len(fields) > 1 {
+		subcommand = fields[1]
+	}
+
+	handler := subcommand
+	if handler == "" {
+		handler = "dialog"
+	}
+	p.countUsage("command: " + handler)
+
+	logger := p.commandLogger(args, handler)
+	logger.Debug("Executing command", "channel_id", args.ChannelId)
+
+	return p.dispatchCommand(args, fields[1:], logger)
+}
+
+func (p *Plugin) executeCommandDelete(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("delete"))
+	}
+
+	ticket, err := p.getTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+
+	if err := p.deleteTicket(ticket, args.UserId); err != nil {
+		p.API.LogError("Failed to delete ticket", "ticket_id", ticket.ID, "err", err.Error())
+		return ephemeralResponse("Failed to delete the ticket.")
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Moved ticket %s to the trash. It can be restored for %d days.", ticket.ID, int(trashRetentionPeriod.Hours()/24)))
+}
+
+func (p *Plugin) executeCommandTrashList() *model.CommandResponse {
+	tickets, err := p.listTrashedTickets()
+	if err != nil {
+		p.API.LogError("Failed to list trashed tickets", "err", err.Error())
+		return ephemeralResponse("Failed to list the trash.")
+	}
+	if len(tickets) == 0 {
+		return ephemeralResponse("The trash is empty.")
+	}
+
+	lines := []string{"| Ticket | Summary | Deleted by | Purged on |", "| --- | --- | --- | --- |"}
+	for _, ticket := range tickets {
+		purgeAt := time.UnixMilli(ticket.DeleteAt).Add(trashRetentionPeriod)
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s | %s |", ticket.ID, ticket.Summary, p.mentionUser(ticket.DeletedBy), purgeAt.Format("2006-01-02")))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
+
+func (p *Plugin) executeCommandTrashRestore(params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("trash", "restore"))
+	}
+
+	ticket, err := p.restoreTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to restore ticket", "ticket_id", params[0], "err", err.Error())
+		return ephemeralResponse("Failed to restore the ticket.")
+	}
+	if ticket == nil {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s is not in the trash.", params[0]))
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Restored ticket %s.", ticket.ID))
+}
+
+func (p *Plugin) executeCommandWebhookTest(args *model.CommandArgs) *model.CommandResponse {
+	if p.getConfiguration().EventWebhookURL == "" {
+		return ephemeralResponse("No event webhook URL is configured.")
+	}
+
+	if err := p.deliverEvent(&TicketEvent{
+		ID:       model.NewId(),
+		Type:     ticketEventTest,
+		CreateAt: model.GetMillis(),
+		UserID:   args.UserId,
+	}); err != nil {
+		p.API.LogWarn("Failed to deliver test event", "err", err.Error())
+		return ephemeralResponse(fmt.Sprintf("Failed to deliver the test event: %s", err.Error()))
+	}
+
+	return ephemeralResponse("Delivered a test event to the event webhook.")
+}
+
+func ephemeralResponse(text string) *model.CommandResponse {
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         text,
+	}
+}
+
+func (p *Plugin) executeCommandDialog(args *model.CommandArgs) *model.CommandResponse {
+	if _, ok := p.getConfiguration().demoChannelIDs[args.TeamId]; !ok {
+		return ephemeralResponse("SRE requests are not enabled in this team.")
+	}
+
+	if err := p.openIntakeDialog(args.TriggerId, args.TeamId, args.UserId, nil); err != nil {
+		errorMessage := "Failed to open Interactive Dialog"
+		p.API.LogError(errorMessage, "err", err.Error())
+		return &model.CommandResponse{
+			ResponseType: model.CommandResponseTypeEphemeral,
+			Text:         errorMessage,
+		}
+	}
+
+	return &model.CommandResponse{}
+}
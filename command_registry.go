@@ -0,0 +1,783 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// ticketReferenceHint is how subcommands taking a ticket key or id name their argument.
+const ticketReferenceHint = "[" + ticketKeyProject + "-123|ticket id]"
+
+// commandSpec describes a subcommand of /sre-request. Commands are routed to the subcommands
+// described here, the autocomplete data of the command and its help are built from them, and
+// command handlers print their usage from them.
+type commandSpec struct {
+	Trigger     string
+	Usage       string
+	Description string
+
+	// Permission is the API scope required to run the subcommand, such as apiScopeSystemAdmin, or
+	// empty if every user may run it. System and SRE admin permissions are enforced before the
+	// handler runs, while ticket permissions are checked by the handler.
+	Permission string
+
+	// Flags are the names of the --name=value flags the subcommand accepts.
+	Flags []string
+
+	// Examples are invocations of the subcommand, without the leading /sre-request.
+	Examples []string
+
+	SubCommands []*commandSpec
+
+	// handler runs the subcommand. Subcommands grouping other subcommands may have none, in which
+	// case they print their usage.
+	handler commandHandler
+
+	// arguments adds the positional arguments of the subcommand to its autocomplete data.
+	arguments func(data *model.AutocompleteData)
+
+	// auditAction names the destructive action of admin subcommands, whose denials are audited.
+	auditAction string
+
+	// long subcommands run through runLongCommand.
+	long bool
+
+	// skipDelay exempts the subcommand from IntegrationRequestDelay, since it calls no integration.
+	skipDelay bool
+
+	// availableWhenDisabled lets the subcommand run in teams the plugin is disabled in.
+	availableWhenDisabled bool
+
+	// hidden subcommands are left out of autocomplete and help.
+	hidden bool
+}
+
+// textArgument returns an arguments function adding a single free-form argument.
+func textArgument(helpText, hint string) func(data *model.AutocompleteData) {
+	return func(data *model.AutocompleteData) {
+		data.AddTextArgument(helpText, hint, "")
+	}
+}
+
+// ticketArgument returns an arguments function adding a ticket suggested by the dynamic list of
+// the user's tickets.
+func ticketArgument(helpText string) func(data *model.AutocompleteData) {
+	return func(data *model.AutocompleteData) {
+		data.AddDynamicListArgument(helpText, ticketsAutocompleteURL, true)
+	}
+}
+
+// commandRegistry returns the subcommands of /sre-request, in the order they are listed by
+// autocomplete and help.
+func commandRegistry() []*commandSpec {
+	return []*commandSpec{
+		{
+			Trigger:     "quick",
+			Usage:       "[high|medium|low] [summary]",
+			Description: "Open a ticket without the dialog. You are asked for the required fields in the ticket's thread.",
+			Examples:    []string{"quick high Database replica lagging behind primary"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddStaticListArgument("Priority of the ticket", true, []model.AutocompleteListItem{
+					{Item: "high", HelpText: "Urgent, such as an outage."},
+					{Item: "medium", HelpText: "Needs attention soon."},
+					{Item: "low", HelpText: "Can wait."},
+				})
+				data.AddTextArgument("Summary of the request, also used as its description", "[summary]", "")
+			},
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandQuick(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "from-message",
+			Usage:       "[message link]",
+			Description: "Open the SRE request dialog pre-filled with a message, its author and its link.",
+			Examples:    []string{"from-message https://chat.example.com/sre/pl/8xk3tz7wqbfbdp1ycs3ypx4rhe"},
+			arguments:   textArgument("Link of the message, from its Copy Link menu item", "[message link]"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandFromMessage(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "show",
+			Usage:       ticketReferenceHint + " [--admin]",
+			Description: "Show the details of a ticket.",
+			Examples:    []string{"show SRE-123"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddDynamicListArgument("Key or id of the ticket", ticketsAutocompleteURL, true)
+				data.AddTextArgument("--admin to reveal the reporter of an anonymous ticket", "[--admin]", "")
+			},
+			Flags: []string{"admin"},
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandShow(request.args, request.params, request.flags)
+			},
+		},
+		{
+			Trigger:     "path",
+			Usage:       ticketReferenceHint,
+			Description: "Show who will be notified next, and when, if nobody acts on a ticket.",
+			Examples:    []string{"path SRE-123"},
+			arguments:   ticketArgument("Key or id of the ticket"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandPath(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "timeline",
+			Usage:       ticketReferenceHint,
+			Description: "Show every event of a ticket in chronological order.",
+			Examples:    []string{"timeline SRE-123"},
+			arguments:   ticketArgument("Key or id of the ticket"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandTimeline(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "watch",
+			Usage:       ticketReferenceHint,
+			Description: "Receive a direct message when the status of a ticket changes or someone comments on it.",
+			Examples:    []string{"watch SRE-123"},
+			arguments:   ticketArgument("Key or id of the ticket"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandWatch(request.args, request.params, true)
+			},
+		},
+		{
+			Trigger:     "unwatch",
+			Usage:       ticketReferenceHint,
+			Description: "Stop watching a ticket.",
+			Examples:    []string{"unwatch SRE-123"},
+			arguments:   ticketArgument("Key or id of the ticket"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandWatch(request.args, request.params, false)
+			},
+		},
+		{
+			Trigger:     "remind",
+			Usage:       ticketReferenceHint + " [duration]",
+			Description: "Ping the assignee of a ticket in its thread after a duration, such as 4h or 2d.",
+			Examples:    []string{"remind SRE-123 4h", "remind SRE-123 2d"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddDynamicListArgument("Key or id of the ticket", ticketsAutocompleteURL, true)
+				data.AddTextArgument("How long to wait, such as 4h or 2d", "[duration]", "")
+			},
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandRemind(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "merge",
+			Usage:       "[duplicate SRE-123] [canonical SRE-456]",
+			Description: "Close a ticket as a duplicate of another, moving its watchers to it.",
+			Permission:  apiScopeTicketEdit,
+			Examples:    []string{"merge SRE-123 SRE-100"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddDynamicListArgument("Key or id of the duplicate ticket", ticketsAutocompleteURL, true)
+				data.AddDynamicListArgument("Key or id of the ticket it duplicates", ticketsAutocompleteURL, true)
+			},
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandMerge(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "delete",
+			Usage:       "[ticket id]",
+			Description: "Move a ticket to the trash. Only available to SRE admins.",
+			Permission:  apiScopeSREAdmin,
+			arguments:   textArgument("Id of the ticket to delete", "[ticket id]"),
+			auditAction: "delete_ticket",
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandDelete(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "trash",
+			Usage:       "[list|restore]",
+			Description: "Manage deleted tickets. Only available to SRE admins.",
+			Permission:  apiScopeSREAdmin,
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "list",
+					Description: "List the tickets in the trash.",
+					long:        true,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandTrashList()
+					},
+				},
+				{
+					Trigger:     "restore",
+					Usage:       "[ticket id]",
+					Description: "Restore a ticket from the trash.",
+					arguments:   textArgument("Id of the ticket to restore", "[ticket id]"),
+					long:        true,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandTrashRestore(request.params)
+					},
+				},
+			},
+			auditAction: "manage_trash",
+		},
+		{
+			Trigger:     "wipe",
+			Usage:       "[confirm code]",
+			Description: "Wipe all plugin data after sending you a backup. Only available to system admins.",
+			Permission:  apiScopeSystemAdmin,
+			arguments:   textArgument("Confirmation code, as given by the first step", "[confirm code]"),
+			auditAction: "wipe_data",
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandWipe(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "export",
+			Usage:       "[--status=closed] [--label=name] [--since=30d] [--format=csv|json]",
+			Description: "Export the tickets you can view as CSV or JSON.",
+			Examples:    []string{"export --status=closed --since=30d", "export --label=database --format=json"},
+			arguments:   textArgument("Filters and format", "[--status=closed] [--label=name] [--since=30d] [--format=csv|json]"),
+			Flags:       []string{"status", "label", "since", "format"},
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandExport(request.args, request.params, request.flags)
+			},
+		},
+		{
+			Trigger:     "search",
+			Usage:       "[words] [status:open] [priority:high] [assignee:@user] [label:name] [from:YYYY-MM-DD] [to:YYYY-MM-DD]",
+			Description: "Search the tickets you can view.",
+			Examples:    []string{"search database timeout status:open", "search assignee:@alice label:networking from:2024-01-01"},
+			arguments:   textArgument("Words and filters", "[words] [status:open] [priority:high] [assignee:@user] [label:name] [from:YYYY-MM-DD] [to:YYYY-MM-DD]"),
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandSearch(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "due",
+			Usage:       "[ticket id] [YYYY-MM-DD [HH:MM]|clear]",
+			Description: "Set or clear the due date of a ticket, in UTC.",
+			Permission:  apiScopeTicketEdit,
+			arguments:   textArgument("Ticket id and due date", "[ticket id] [YYYY-MM-DD [HH:MM]|clear]"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandDue(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "label",
+			Usage:       ticketReferenceHint + " [add|remove] [label]",
+			Description: "Add a label to a ticket, or remove one.",
+			Permission:  apiScopeTicketEdit,
+			Examples:    []string{"label SRE-123 add database", "label SRE-123 remove networking"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddDynamicListArgument("Key or id of the ticket", ticketsAutocompleteURL, true)
+				data.AddStaticListArgument("Whether to add or remove the label", true, []model.AutocompleteListItem{
+					{Item: "add", HelpText: "Add the label to the ticket."},
+					{Item: "remove", HelpText: "Remove the label from the ticket."},
+				})
+				data.AddTextArgument("Label, made of letters, digits, dashes, underscores and dots", "[label]", "")
+			},
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandLabel(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "oncall",
+			Usage:       "[show|set|override]",
+			Description: "Manage the on-call rotation.",
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "show",
+					Description: "Show who is on call.",
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandOnCallShow()
+					},
+				},
+				{
+					Trigger:     "set",
+					Usage:       "[@user1 @user2 ...]",
+					Description: "Set the weekly on-call rotation. Only available to system admins.",
+					Permission:  apiScopeSystemAdmin,
+					Examples:    []string{"oncall set @alice @bob @carol"},
+					arguments:   textArgument("Users of the rotation, in order", "[@user1 @user2 ...]"),
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandOnCallSet(request.params)
+					},
+				},
+				{
+					Trigger:     "override",
+					Usage:       "[@user] [duration]",
+					Description: "Put a user on call instead of the rotation, for 24h by default.",
+					Examples:    []string{"oncall override @alice 12h"},
+					arguments: func(data *model.AutocompleteData) {
+						data.AddTextArgument("User to put on call", "[@user]", "")
+						data.AddTextArgument("How long the override lasts, e.g. 12h", "[duration]", "")
+					},
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandOnCallOverride(request.args, request.params)
+					},
+				},
+			},
+			// Without a subcommand, show who is on call.
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandOnCallShow()
+			},
+		},
+		{
+			Trigger:     "services",
+			Usage:       "[list|add|remove]",
+			Description: "Manage the service catalog offered by the intake dialog.",
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "list",
+					Description: "List the services of the catalog.",
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandServicesList()
+					},
+				},
+				{
+					Trigger:     "add",
+					Usage:       "[name]",
+					Description: "Add a service to the catalog. Only available to system admins.",
+					Permission:  apiScopeSystemAdmin,
+					arguments:   textArgument("Name of the service", "[name]"),
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandServicesEdit(request.params, true)
+					},
+				},
+				{
+					Trigger:     "remove",
+					Usage:       "[name]",
+					Description: "Remove a service from the catalog. Only available to system admins.",
+					Permission:  apiScopeSystemAdmin,
+					arguments:   textArgument("Name of the service", "[name]"),
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandServicesEdit(request.params, false)
+					},
+				},
+			},
+			// Without a subcommand, list the services.
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandServicesList()
+			},
+		},
+		{
+			Trigger:     "vault",
+			Usage:       "[upload|list]",
+			Description: "Share sensitive artifacts of a ticket through the secure vault.",
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "upload",
+					Usage:       "[ticket id] [file name]",
+					Description: "Get a link to upload a file to the secure vault.",
+					arguments:   textArgument("Ticket id and file name", "[ticket id] [file name]"),
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandVaultUpload(request.args, request.params)
+					},
+				},
+				{
+					Trigger:     "list",
+					Usage:       "[ticket id]",
+					Description: "Get download links of the artifacts of a ticket.",
+					arguments:   textArgument("Id of the ticket", "[ticket id]"),
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandVaultList(request.args, request.params)
+					},
+				},
+			},
+		},
+		{
+			Trigger:     "notify",
+			Usage:       "[dm|channel|off]",
+			Description: "Choose how you are notified of escalations, assignments and digests.",
+			Examples:    []string{"notify dm"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddStaticListArgument("Notification preference", true, []model.AutocompleteListItem{
+					{Item: notificationPreferenceDM, HelpText: "Receive a direct message instead of channel mentions."},
+					{Item: notificationPreferenceChannel, HelpText: "Be mentioned in the SRE channel, the default."},
+					{Item: notificationPreferenceOff, HelpText: "Don't be notified."},
+				})
+			},
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandNotify(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "statuspage",
+			Usage:       "[ticket id]",
+			Description: "Review and post a customer update for a ticket.",
+			Permission:  apiScopeTicketEdit,
+			arguments:   textArgument("Id of the ticket", "[ticket id]"),
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandStatusPage(request.args, request.params)
+			},
+		},
+		{
+			Trigger:     "stats",
+			Usage:       "[7d|30d|90d]",
+			Description: "Show the MTTA, MTTR and volume of tickets. Only available to SRE admins.",
+			Permission:  apiScopeSREAdmin,
+			Examples:    []string{"stats 7d"},
+			arguments: func(data *model.AutocompleteData) {
+				data.AddStaticListArgument("Period covered by the statistics, 30d by default", false, []model.AutocompleteListItem{
+					{Item: "7d", HelpText: "The last 7 days."},
+					{Item: "30d", HelpText: "The last 30 days."},
+					{Item: "90d", HelpText: "The last 90 days."},
+				})
+			},
+			long: true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandStats(request.params)
+			},
+		},
+		{
+			Trigger:     "rules",
+			Usage:       "[list|test]",
+			Description: "Debug the routing rules of tickets.",
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "list",
+					Description: "List the routing rules, in evaluation order.",
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandRulesList()
+					},
+				},
+				{
+					Trigger:     "test",
+					Usage:       "[SRE-123|ticket id|category=... priority=...]",
+					Description: "Show which routing rule matches a ticket, or a ticket with the given fields.",
+					Examples:    []string{"rules test SRE-123", "rules test category=pipeline priority=high"},
+					arguments:   textArgument("Key or id of a ticket, or fields such as category=pipeline priority=high", "[SRE-123|ticket id|category=... priority=...]"),
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandRulesTest(request.args, request.params)
+					},
+				},
+			},
+		},
+		{
+			Trigger:     "jobs",
+			Usage:       "[list|run|pause|resume]",
+			Description: "Inspect and run the background jobs. Only available to system admins.",
+			Permission:  apiScopeSystemAdmin,
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "list",
+					Description: "List the background jobs with their last run and last error.",
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandJobsList()
+					},
+				},
+				{
+					Trigger:     "run",
+					Usage:       "[job]",
+					Description: "Run a job right away, even if it is paused.",
+					Examples:    []string{"jobs run sla"},
+					arguments:   jobArgument,
+					long:        true,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandJobsRun(request.params)
+					},
+				},
+				{
+					Trigger:     "pause",
+					Usage:       "[job]",
+					Description: "Skip the scheduled runs of a job across the cluster.",
+					Examples:    []string{"jobs pause email"},
+					arguments:   jobArgument,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandJobsPause(request.args, request.params, true)
+					},
+				},
+				{
+					Trigger:     "resume",
+					Usage:       "[job]",
+					Description: "Resume the scheduled runs of a paused job.",
+					Examples:    []string{"jobs resume email"},
+					arguments:   jobArgument,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandJobsPause(request.args, request.params, false)
+					},
+				},
+			},
+		},
+		{
+			Trigger:     "token",
+			Usage:       "[create|list|revoke]",
+			Description: "Manage the API tokens authenticating systems such as CI pipelines to the REST API. Only available to system admins.",
+			Permission:  apiScopeSystemAdmin,
+			SubCommands: []*commandSpec{
+				{
+					Trigger:     "create",
+					Usage:       "[name] [read|write|admin]",
+					Description: "Create an API token, shown once.",
+					Examples:    []string{"token create ci write"},
+					arguments:   apiTokenArguments,
+					skipDelay:   true,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandTokenCreate(request.args, request.params)
+					},
+				},
+				{
+					Trigger:     "list",
+					Description: "List the API tokens with their scope and last use.",
+					skipDelay:   true,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandTokenList()
+					},
+				},
+				{
+					Trigger:     "revoke",
+					Usage:       "[id|name]",
+					Description: "Revoke an API token.",
+					Examples:    []string{"token revoke ci"},
+					arguments:   textArgument("ID or name of the token", "[id|name]"),
+					skipDelay:   true,
+					handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+						return p.executeCommandTokenRevoke(request.args, request.params)
+					},
+				},
+			},
+		},
+		{
+			Trigger:     "usage",
+			Usage:       "[weeks]",
+			Description: "Report which features are used. Only available to system admins.",
+			Permission:  apiScopeSystemAdmin,
+			Examples:    []string{"usage 8"},
+			arguments:   textArgument("How many weeks the report covers, 4 by default", "[weeks]"),
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandUsage(request.params)
+			},
+		},
+		{
+			Trigger:     "capabilities",
+			Description: "List the capabilities disabled by the minimal-permission mode.",
+			skipDelay:   true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandCapabilities()
+			},
+		},
+		{
+			Trigger:               "enable",
+			Usage:                 "[team]",
+			Description:           "Enable the plugin in a team, the current one by default. Only available to system admins.",
+			Permission:            apiScopeSystemAdmin,
+			arguments:             textArgument("Name of the team", "[team]"),
+			auditAction:           "enable_team",
+			availableWhenDisabled: true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandTeamToggle(request.args, request.params, true)
+			},
+		},
+		{
+			Trigger:               "disable",
+			Usage:                 "[team]",
+			Description:           "Disable the plugin in a team, the current one by default. Only available to system admins.",
+			Permission:            apiScopeSystemAdmin,
+			arguments:             textArgument("Name of the team", "[team]"),
+			auditAction:           "disable_team",
+			availableWhenDisabled: true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandTeamToggle(request.args, request.params, false)
+			},
+		},
+		{
+			Trigger:     "deps",
+			Description: "Check the health of the upstream dependencies.",
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandDeps()
+			},
+		},
+		{
+			Trigger:               "debug",
+			Usage:                 "logs [on|off]",
+			Description:           "Turn debug logs on for an hour, or off. Only available to system admins.",
+			Permission:            apiScopeSystemAdmin,
+			Examples:              []string{"debug logs on"},
+			arguments:             textArgument("Whether to turn debug logs on or off", "logs [on|off]"),
+			availableWhenDisabled: true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandDebug(request.params, request.logger)
+			},
+		},
+		{
+			Trigger:     "webhook-test",
+			Description: "Send a test event to the event webhook. Only available to system admins.",
+			Permission:  apiScopeSystemAdmin,
+			long:        true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandWebhookTest(request.args)
+			},
+		},
+		{
+			Trigger:     "crash",
+			Description: "Panic on purpose, to exercise the panic recovery.",
+			Permission:  apiScopeSREAdmin,
+			auditAction: "simulate_crash",
+			hidden:      true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandCrash(request.args)
+			},
+		},
+		{
+			Trigger:     "help",
+			Usage:       "[subcommand]",
+			Description: "Show how to use the commands.",
+			Examples:    []string{"help", "help oncall set"},
+			arguments:   textArgument("Subcommand to describe", "[subcommand]"),
+			skipDelay:   true,
+			handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+				return p.executeCommandHelp(request.args, request.params)
+			},
+		},
+	}
+}
+
+// autocompleteData builds the autocomplete data of the subcommand and its own subcommands.
+func (s *commandSpec) autocompleteData() *model.AutocompleteData {
+	data := model.NewAutocompleteData(s.Trigger, s.Usage, s.Description)
+	if s.arguments != nil {
+		s.arguments(data)
+	}
+	for _, subCommand := range s.SubCommands {
+		data.AddCommand(subCommand.autocompleteData())
+	}
+
+	return data
+}
+
+func getSRERequestAutocompleteData() *model.AutocompleteData {
+	command := model.NewAutocompleteData(commandTriggerSRERequest, "[command]", "Open the SRE request dialog or manage tickets.")
+	for _, spec := range commandRegistry() {
+		if !spec.hidden {
+			command.AddCommand(spec.autocompleteData())
+		}
+	}
+
+	return command
+}
+
+// findCommandSpec returns the subcommand named by the path, such as ["oncall", "set"], or nil if
+// there is none.
+func findCommandSpec(path []string) *commandSpec {
+	route, rest := routeCommand(path)
+	if len(route) == 0 || len(rest) > 0 {
+		return nil
+	}
+
+	return route[len(route)-1]
+}
+
+// commandUsage returns the usage of the subcommand named by the path, as printed by command
+// handlers given invalid arguments. Subcommands grouping other subcommands list their usages.
+func commandUsage(path ...string) string {
+	spec := findCommandSpec(path)
+	if spec == nil {
+		return "Usage: /" + commandTriggerSRERequest + " help"
+	}
+
+	var usages []string
+	if len(spec.SubCommands) > 0 {
+		for _, subCommand := range spec.SubCommands {
+			usages = append(usages, subCommand.invocation(append(append([]string(nil), path...), subCommand.Trigger)))
+		}
+	} else {
+		usages = append(usages, spec.invocation(path))
+	}
+
+	return "Usage: " + strings.Join(usages, " or ")
+}
+
+// invocation returns the full command running the subcommand at the path, with its arguments.
+func (s *commandSpec) invocation(path []string) string {
+	return strings.TrimSpace(fmt.Sprintf("/%s %s %s", commandTriggerSRERequest, strings.Join(path, " "), s.Usage))
+}
+
+// localizedPermission returns who may run a subcommand requiring the permission.
+func localizedPermission(localizer *i18n.Localizer, permission string) string {
+	switch permission {
+	case apiScopeSystemAdmin:
+		return localize(localizer, &i18n.Message{ID: "command.permission.system_admin", Other: "System admins"}, nil)
+	case apiScopeSREAdmin:
+		return localize(localizer, &i18n.Message{ID: "command.permission.sre_admin", Other: "SRE admins"}, nil)
+	case apiScopeTicketEdit:
+		return localize(localizer, &i18n.Message{ID: "command.permission.ticket_edit", Other: "Reporter or assignee of the ticket, the incident commander and system admins"}, nil)
+	default:
+		return localize(localizer, &i18n.Message{ID: "command.permission.everyone", Other: "Everyone"}, nil)
+	}
+}
+
+// localizedDescription returns the description of the subcommand at the path, translated under the
+// same id as its autocomplete help.
+func (s *commandSpec) localizedDescription(localizer *i18n.Localizer, path []string) string {
+	id := "command." + commandTriggerSRERequest + "." + strings.Join(path, ".") + ".help"
+
+	return localize(localizer, &i18n.Message{ID: id, Other: s.Description}, nil)
+}
+
+// executeCommandHelp lists the subcommands, or describes the given one: its usage, who may run it,
+// examples and its own subcommands.
+func (p *Plugin) executeCommandHelp(args *model.CommandArgs, params []string) *model.CommandResponse {
+	localizer := p.userLocalizer(args.UserId)
+
+	if len(params) == 0 {
+		lines := []string{
+			"#### " + localize(localizer, &i18n.Message{ID: "command.help.title", Other: "SRE request commands"}, nil),
+			localize(localizer, &i18n.Message{
+				ID:    "command.help.introduction",
+				Other: "Run `/{{.Trigger}}` to open the SRE request dialog, or `/{{.Trigger}} help [subcommand]` to learn more about a subcommand.",
+			}, map[string]interface{}{"Trigger": commandTriggerSRERequest}),
+			"",
+		}
+		for _, spec := range commandRegistry() {
+			if spec.hidden {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("- `%s`: %s", spec.invocation([]string{spec.Trigger}), spec.localizedDescription(localizer, []string{spec.Trigger})))
+		}
+
+		return ephemeralResponse(strings.Join(lines, "\n"))
+	}
+
+	spec := findCommandSpec(params)
+	if spec == nil || spec.hidden {
+		return ephemeralResponse(localize(localizer, &i18n.Message{
+			ID:    "command.help.unknown",
+			Other: "Unknown subcommand {{.Subcommand}}. Run `/{{.Trigger}} help` to list the subcommands.",
+		}, map[string]interface{}{"Subcommand": strings.Join(params, " "), "Trigger": commandTriggerSRERequest}))
+	}
+
+	path := make([]string, 0, len(params))
+	for _, word := range params {
+		path = append(path, strings.ToLower(word))
+	}
+
+	lines := []string{
+		"#### `" + spec.invocation(path) + "`",
+		spec.localizedDescription(localizer, path),
+		"",
+		fmt.Sprintf("**%s** %s", localize(localizer, &i18n.Message{ID: "command.help.permission", Other: "Who can run it:"}, nil), localizedPermission(localizer, spec.Permission)),
+	}
+
+	if len(spec.Examples) > 0 {
+		lines = append(lines, "", "**"+localize(localizer, &i18n.Message{ID: "command.help.examples", Other: "Examples:"}, nil)+"**")
+		for _, example := range spec.Examples {
+			lines = append(lines, fmt.Sprintf("- `/%s %s`", commandTriggerSRERequest, example))
+		}
+	}
+
+	if len(spec.SubCommands) > 0 {
+		lines = append(lines, "", "**"+localize(localizer, &i18n.Message{ID: "command.help.subcommands", Other: "Subcommands:"}, nil)+"**")
+		for _, subCommand := range spec.SubCommands {
+			subPath := append(append([]string(nil), path...), subCommand.Trigger)
+			lines = append(lines, fmt.Sprintf("- `%s`: %s", subCommand.invocation(subPath), subCommand.localizedDescription(localizer, subPath)))
+		}
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
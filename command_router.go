@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// commandRequest is an invocation of a subcommand of /sre-request, as routed by
+// executeCommandSRERequest.
+type commandRequest struct {
+	args *model.CommandArgs
+
+	// path names the subcommand, such as ["oncall", "set"]. It is empty for the dialog.
+	path []string
+
+	// params are the positional arguments following the path, and flags the --name=value
+	// arguments declared by the subcommand.
+	params []string
+	flags  commandFlags
+
+	logger *pluginLogger
+}
+
+// commandHandler runs a subcommand.
+type commandHandler func(p *Plugin, request *commandRequest) *model.CommandResponse
+
+// commandMiddleware wraps the handler of a subcommand, given the subcommands of its path from the
+// outermost to the innermost.
+type commandMiddleware func(route []*commandSpec, next commandHandler) commandHandler
+
+// commandMiddlewares wrap the handlers of every subcommand, the first one running first.
+var commandMiddlewares = []commandMiddleware{
+	withCommandDelay,
+	withTeamEnabled,
+	withCommandPermission,
+	withLongCommand,
+}
+
+// commandFlags are the flags of a subcommand, by name. Flags may be repeated, and flags without a
+// value, such as --admin, have an empty value.
+type commandFlags map[string][]string
+
+// has reports whether the flag was given.
+func (f commandFlags) has(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+// get returns the last value of the flag, or an empty string if it wasn't given.
+func (f commandFlags) get(name string) string {
+	values := f[name]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[len(values)-1]
+}
+
+// parseCommandFlags splits the words following a subcommand into its positional arguments and its
+// flags, which must be declared by the subcommand.
+func parseCommandFlags(spec *commandSpec, words []string) ([]string, commandFlags, error) {
+	var params []string
+	flags := commandFlags{}
+	for _, word := range words {
+		if !strings.HasPrefix(word, "--") || word == "--" {
+			params = append(params, word)
+			continue
+		}
+
+		name, value, _ := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+		if !contains(spec.Flags, name) {
+			return nil, nil, errors.Errorf("unknown flag --%s", name)
+		}
+		flags[name] = append(flags[name], value)
+	}
+
+	return params, flags, nil
+}
+
+// dialogCommand opens the intake dialog when /sre-request is run without a subcommand.
+var dialogCommand = &commandSpec{
+	handler: func(p *Plugin, request *commandRequest) *model.CommandResponse {
+		return p.executeCommandDialog(request.args)
+	},
+}
+
+// routeCommand resolves the words following /sre-request to the subcommands they name, from the
+// outermost to the innermost, and returns the remaining words.
+func routeCommand(words []string) ([]*commandSpec, []string) {
+	var route []*commandSpec
+	specs := commandRegistry()
+	for len(words) > 0 {
+		var next *commandSpec
+		for _, spec := range specs {
+			if strings.EqualFold(spec.Trigger, words[0]) {
+				next = spec
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		route = append(route, next)
+		specs = next.SubCommands
+		words = words[1:]
+	}
+
+	return route, words
+}
+
+// dispatchCommand routes the command to the handler of its subcommand, wrapped by the command
+// middlewares.
+func (p *Plugin) dispatchCommand(args *model.CommandArgs, words []string, logger *pluginLogger) *model.CommandResponse {
+	route, rest := routeCommand(words)
+	if len(route) == 0 {
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "--") {
+			return ephemeralResponse(fmt.Sprintf("Unknown command: %s. Run `/%s help` to list the commands.", rest[0], commandTriggerSRERequest))
+		}
+		route = []*commandSpec{dialogCommand}
+	}
+
+	path := make([]string, 0, len(route))
+	for _, spec := range route {
+		if spec.Trigger != "" {
+			path = append(path, spec.Trigger)
+		}
+	}
+
+	// Subcommands grouping other subcommands only run on their own if they have a handler, and
+	// aren't given what looks like an unknown subcommand.
+	spec := route[len(route)-1]
+	if spec.handler == nil || (len(spec.SubCommands) > 0 && len(rest) > 0) {
+		return ephemeralResponse(commandUsage(path...))
+	}
+
+	params, flags, err := parseCommandFlags(spec, rest)
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("%s. %s", err.Error(), commandUsage(path...)))
+	}
+
+	handler := spec.handler
+	for i := len(commandMiddlewares) - 1; i >= 0; i-- {
+		handler = commandMiddlewares[i](route, handler)
+	}
+
+	return handler(p, &commandRequest{
+		args:   args,
+		path:   path,
+		params: params,
+		flags:  flags,
+		logger: logger,
+	})
+}
+
+// withCommandDelay waits for IntegrationRequestDelay before running subcommands, unless they are
+// exempt from it.
+func withCommandDelay(route []*commandSpec, next commandHandler) commandHandler {
+	if route[len(route)-1].skipDelay {
+		return next
+	}
+
+	return func(p *Plugin, request *commandRequest) *model.CommandResponse {
+		if delay := p.getConfiguration().IntegrationRequestDelay; delay > 0 {
+			time.Sleep(time.Duration(delay) * time.Second)
+		}
+
+		return next(p, request)
+	}
+}
+
+// withTeamEnabled refuses to run subcommands in teams the plugin is disabled in, except those
+// re-enabling or troubleshooting it.
+func withTeamEnabled(route []*commandSpec, next commandHandler) commandHandler {
+	if route[0].availableWhenDisabled {
+		return next
+	}
+
+	return func(p *Plugin, request *commandRequest) *model.CommandResponse {
+		if !p.teamEnabled(request.args.TeamId) {
+			return ephemeralResponse("The plugin is disabled in this team. A system admin can enable it with `/sre-request enable`.")
+		}
+
+		return next(p, request)
+	}
+}
+
+// withCommandPermission refuses to run subcommands the user lacks the permission of, checking the
+// permission of every subcommand of the path. Permissions depending on a ticket are checked by the
+// handlers, once they found the ticket.
+func withCommandPermission(route []*commandSpec, next commandHandler) commandHandler {
+	return func(p *Plugin, request *commandRequest) *model.CommandResponse {
+		for i, spec := range route {
+			var allowed bool
+			var role string
+			switch spec.Permission {
+			case apiScopeSystemAdmin:
+				role = "system admins"
+				if spec.auditAction != "" {
+					allowed = p.authorizeSystemAdminAction(request.args.UserId, spec.auditAction)
+				} else {
+					allowed = p.isSystemAdmin(request.args.UserId)
+				}
+			case apiScopeSREAdmin:
+				role = "SRE admins"
+				if spec.auditAction != "" {
+					allowed = p.authorizeDestructiveAction(request.args.UserId, spec.auditAction)
+				} else {
+					allowed = p.isSREAdmin(request.args.UserId)
+				}
+			default:
+				allowed = true
+			}
+
+			if !allowed {
+				return ephemeralResponse(fmt.Sprintf("Only %s can run `/%s %s`.", role, commandTriggerSRERequest, strings.Join(request.path[:i+1], " ")))
+			}
+		}
+
+		return next(p, request)
+	}
+}
+
+// withLongCommand runs subcommands that may take a while through runLongCommand.
+func withLongCommand(route []*commandSpec, next commandHandler) commandHandler {
+	if !route[len(route)-1].long {
+		return next
+	}
+
+	return func(p *Plugin, request *commandRequest) *model.CommandResponse {
+		return p.runLongCommand(request.args, func() *model.CommandResponse {
+			return next(p, request)
+		})
+	}
+}
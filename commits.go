@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// ticketReferencePattern finds the ticket keys referenced in commit messages, such as SRE-42.
+var ticketReferencePattern = regexp.MustCompile(`(?i)\b` + ticketKeyProject + `-\d+\b`)
+
+// TicketCommit is a commit referencing a ticket, as reported by CI.
+type TicketCommit struct {
+	SHA        string `json:"sha"`
+	Repository string `json:"repository,omitempty"`
+	Message    string `json:"message"`
+	URL        string `json:"url,omitempty"`
+	Author     string `json:"author,omitempty"`
+	CreateAt   int64  `json:"create_at"`
+}
+
+// commitsPayload is the body of the commits webhook. It has the shape of GitHub push webhooks, so
+// that they can be sent as is, while other CI systems send the same fields.
+type commitsPayload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Commits []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"commits"`
+}
+
+// referencedTicketKeys returns the distinct ticket keys referenced in the message.
+func referencedTicketKeys(message string) []string {
+	var keys []string
+	for _, reference := range ticketReferencePattern.FindAllString(message, -1) {
+		if key, ok := normalizeTicketKey(reference); ok && !contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// shortSHA abbreviates a commit hash for display.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+
+	return sha
+}
+
+// linkCommit records the commit on the ticket and posts it in the ticket's thread, returning false
+// if the commit was already linked.
+func (p *Plugin) linkCommit(ticket *Ticket, commit *TicketCommit) (bool, error) {
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		for _, linked := range ticket.Commits {
+			if linked.SHA == commit.SHA {
+				return errTicketUnchanged
+			}
+		}
+		ticket.Commits = append(ticket.Commits, commit)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	sha := fmt.Sprintf("`%s`", shortSHA(commit.SHA))
+	if commit.URL != "" {
+		sha = fmt.Sprintf("[%s](%s)", sha, commit.URL)
+	}
+	message := fmt.Sprintf(":link: Commit %s", sha)
+	if commit.Repository != "" {
+		message += fmt.Sprintf(" in %s", commit.Repository)
+	}
+	if commit.Author != "" {
+		message += fmt.Sprintf(" by %s", commit.Author)
+	}
+	message += fmt.Sprintf(" references this request:\n> %s", commit.Message)
+
+	if err := p.postTicketReply(ticket, message); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// handleCommitsWebhook receives the commits built by CI and links those referencing ticket keys to
+// their tickets. Since it posts in ticket threads, it only accepts webhooks signed with the
+// WebhookSigningSecret, which verifyWebhookSignature checks.
+func (p *Plugin) handleCommitsWebhook(w http.ResponseWriter, r *http.Request) {
+	if p.getConfiguration().WebhookSigningSecret == "" {
+		http.Error(w, "The commits webhook is not enabled", http.StatusNotFound)
+		return
+	}
+
+	// GitHub push webhooks carry commits, while its other events are acknowledged and ignored.
+	if event := r.Header.Get("X-GitHub-Event"); event != "" && event != "push" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var payload commitsPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		p.API.LogError("Failed to decode commits webhook payload", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	linked := 0
+	for _, reported := range payload.Commits {
+		if reported.ID == "" {
+			continue
+		}
+
+		// Only the subject line of the commit is posted.
+		subject, _, _ := strings.Cut(strings.TrimSpace(reported.Message), "\n")
+		for _, key := range referencedTicketKeys(reported.Message) {
+			ticket, err := p.findTicket(key)
+			if err != nil {
+				p.API.LogError("Failed to get ticket for commit", "ticket_key", key, "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if ticket == nil {
+				continue
+			}
+
+			ok, err := p.linkCommit(ticket, &TicketCommit{
+				SHA:        reported.ID,
+				Repository: payload.Repository.FullName,
+				Message:    subject,
+				URL:        reported.URL,
+				Author:     reported.Author.Name,
+				CreateAt:   model.GetMillis(),
+			})
+			if err != nil {
+				p.API.LogError("Failed to link commit", "ticket_id", ticket.ID, "sha", reported.ID, "err", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if ok {
+				linked++
+			}
+		}
+	}
+
+	p.writeJSON(w, map[string]int{"linked": linked})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// isBrowserRequest reports whether the request was sent by a browser on behalf of a logged in
+// user, as opposed to an integration authenticating with a token.
+func isBrowserRequest(r *http.Request) bool {
+	if r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != "" {
+		return true
+	}
+
+	_, err := r.Cookie("MMAUTHTOKEN")
+	return err == nil
+}
+
+// csrfProtected enforces the Mattermost CSRF conventions on endpoints called by the web app:
+// state-changing browser requests must be XHRs, marked with the X-Requested-With header that
+// cross-site forms cannot set, and must originate from the site itself. Requests from
+// integrations are not affected.
+func (p *Plugin) csrfProtected(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions || !isBrowserRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Header.Get("X-Requested-With") != "XMLHttpRequest" {
+			p.API.LogWarn("Rejected browser request without X-Requested-With", "path", r.URL.Path, "user_id", r.Header.Get("Mattermost-User-ID"))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" && !p.isSiteOrigin(origin) {
+			p.API.LogWarn("Rejected cross-origin browser request", "path", r.URL.Path, "origin", origin, "user_id", r.Header.Get("Mattermost-User-ID"))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSiteOrigin reports whether the origin is the server's site URL. Any origin is accepted when
+// no site URL is configured.
+func (p *Plugin) isSiteOrigin(origin string) bool {
+	config := p.API.GetConfig()
+	if config == nil || config.ServiceSettings.SiteURL == nil || *config.ServiceSettings.SiteURL == "" {
+		return true
+	}
+
+	siteURL, err := url.Parse(*config.ServiceSettings.SiteURL)
+	if err != nil {
+		return false
+	}
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(siteURL.Scheme, originURL.Scheme) && strings.EqualFold(siteURL.Host, originURL.Host)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	customFieldTypeText     = "text"
+	customFieldTypeTextarea = "textarea"
+	customFieldTypeNumber   = "number"
+	customFieldTypeSelect   = "select"
+	customFieldTypeBool     = "bool"
+
+	// customFieldElementPrefix prefixes the names of the dialog elements of custom fields, so that
+	// they never collide with the built-in elements or those of an admin-defined form.
+	customFieldElementPrefix = "custom_"
+)
+
+// customFieldNamePattern restricts custom field names to what fits in dialog element names and
+// export column names.
+var customFieldNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// customField is an extra ticket field defined by the admins of the deployment.
+type customField struct {
+	// Name identifies the field in the ticket record, the REST API and exports.
+	Name string `json:"name"`
+
+	// DisplayName labels the field in the intake dialog and the ticket attachment. It defaults to
+	// the name.
+	DisplayName string `json:"display_name"`
+
+	// Type is one of text, textarea, number, select and bool.
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+
+	// Options are the values a select field accepts.
+	Options []string `json:"options"`
+
+	HelpText string `json:"help_text"`
+}
+
+// parseCustomFields parses CustomFields, a JSON array of field definitions. The fields are never
+// modified once parsed, so they are shared between clones.
+func parseCustomFields(configuration *configuration) ([]*customField, error) {
+	if strings.TrimSpace(configuration.CustomFields) == "" {
+		return nil, nil
+	}
+
+	var fields []*customField
+	if err := json.Unmarshal([]byte(configuration.CustomFields), &fields); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal custom fields")
+	}
+
+	names := make(map[string]bool)
+	for _, field := range fields {
+		if field == nil {
+			return nil, errors.New("invalid custom field definition")
+		}
+		if !customFieldNamePattern.MatchString(field.Name) {
+			return nil, errors.Errorf("invalid custom field name %q, expected lowercase letters, digits or _", field.Name)
+		}
+		if names[field.Name] {
+			return nil, errors.Errorf("duplicate custom field %s", field.Name)
+		}
+		names[field.Name] = true
+
+		if field.DisplayName == "" {
+			field.DisplayName = field.Name
+		}
+
+		switch field.Type {
+		case "":
+			field.Type = customFieldTypeText
+		case customFieldTypeText, customFieldTypeTextarea, customFieldTypeNumber, customFieldTypeBool:
+		case customFieldTypeSelect:
+			if len(field.Options) == 0 {
+				return nil, errors.Errorf("missing options for select custom field %s", field.Name)
+			}
+		default:
+			return nil, errors.Errorf("invalid type %q for custom field %s, expected text, textarea, number, select or bool", field.Type, field.Name)
+		}
+		if field.Type != customFieldTypeSelect && len(field.Options) > 0 {
+			return nil, errors.Errorf("options are only allowed for select custom fields, not %s", field.Name)
+		}
+	}
+
+	return fields, nil
+}
+
+// dialogElement returns the intake dialog element of the field.
+func (f *customField) dialogElement() model.DialogElement {
+	element := model.DialogElement{
+		DisplayName: f.DisplayName,
+		Name:        customFieldElementPrefix + f.Name,
+		Type:        f.Type,
+		HelpText:    f.HelpText,
+		Optional:    !f.Required,
+	}
+
+	switch f.Type {
+	case customFieldTypeNumber:
+		element.Type = "text"
+		element.SubType = "number"
+	case customFieldTypeSelect:
+		for _, option := range f.Options {
+			element.Options = append(element.Options, &model.PostActionOptions{Text: option, Value: option})
+		}
+	}
+
+	return element
+}
+
+// normalize validates a value of the field, returning it in its canonical form.
+func (f *customField) normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if f.Required {
+			return "", errors.Errorf("%s is required", f.DisplayName)
+		}
+		return "", nil
+	}
+
+	switch f.Type {
+	case customFieldTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", errors.Errorf("%s must be a number", f.DisplayName)
+		}
+	case customFieldTypeBool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.Errorf("%s must be true or false", f.DisplayName)
+		}
+		if f.Required && !parsed {
+			return "", errors.Errorf("%s is required", f.DisplayName)
+		}
+		value = strconv.FormatBool(parsed)
+	case customFieldTypeSelect:
+		if !contains(f.Options, value) {
+			return "", errors.Errorf("%s must be one of %s", f.DisplayName, strings.Join(f.Options, ", "))
+		}
+	}
+
+	return value, nil
+}
+
+// validateCustomFields validates the custom field values by field name, returning them normalized
+// without the empty ones, along with the errors by field name. Unless partial, the values of
+// every field are validated, so that missing required fields are reported.
+func (c *configuration) validateCustomFields(values map[string]string, partial bool) (map[string]string, map[string]string) {
+	normalized := make(map[string]string)
+	validationErrors := make(map[string]string)
+
+	known := make(map[string]bool)
+	for _, field := range c.customFields {
+		known[field.Name] = true
+
+		value, ok := values[field.Name]
+		if !ok && partial {
+			continue
+		}
+		value, err := field.normalize(value)
+		if err != nil {
+			validationErrors[field.Name] = err.Error()
+			continue
+		}
+		if value != "" || partial {
+			normalized[field.Name] = value
+		}
+	}
+
+	for name := range values {
+		if !known[name] {
+			validationErrors[name] = fmt.Sprintf("Unknown custom field %s", name)
+		}
+	}
+
+	return normalized, validationErrors
+}
+
+// customFieldsDialogSubmission validates the custom field values submitted in the intake dialog,
+// returning them by field name along with the errors by dialog element name.
+func (c *configuration) customFieldsDialogSubmission(submission map[string]interface{}) (map[string]string, map[string]string) {
+	values := make(map[string]string)
+	for _, field := range c.customFields {
+		switch value := submission[customFieldElementPrefix+field.Name].(type) {
+		case nil:
+		case string:
+			values[field.Name] = value
+		case float64:
+			values[field.Name] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			values[field.Name] = fmt.Sprint(value)
+		}
+	}
+
+	normalized, validationErrors := c.validateCustomFields(values, false)
+
+	elementErrors := make(map[string]string)
+	for name, message := range validationErrors {
+		elementErrors[customFieldElementPrefix+name] = message
+	}
+
+	return normalized, elementErrors
+}
+
+// customFieldsError joins the validation errors of custom fields into a single message, sorted
+// so that it is stable.
+func customFieldsError(validationErrors map[string]string) string {
+	messages := make([]string, 0, len(validationErrors))
+	for _, message := range validationErrors {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+
+	return strings.Join(messages, "; ")
+}
+
+// setTicketCustomFields applies normalized custom field values to the ticket, removing the fields
+// set to an empty value.
+func setTicketCustomFields(ticket *Ticket, values map[string]string) {
+	for name, value := range values {
+		if value == "" {
+			delete(ticket.CustomFields, name)
+			continue
+		}
+		if ticket.CustomFields == nil {
+			ticket.CustomFields = make(map[string]string)
+		}
+		ticket.CustomFields[name] = value
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// dependencyHealthKey is the KV key of the health of the upstream dependencies, as tracked by
+	// the background job.
+	dependencyHealthKey = "dependency_health"
+
+	// dependencyFailureThreshold is the number of consecutive failed checks after which a
+	// dependency failure is considered sustained and responders are alerted.
+	dependencyFailureThreshold = 3
+
+	// dependencyCheckTimeout is how long a dependency may take to respond before it is considered
+	// down.
+	dependencyCheckTimeout = 5 * time.Second
+)
+
+// dependency is an upstream dependency checked by the deps command and the background job.
+type dependency struct {
+	Name           string
+	URL            string
+	ExpectedStatus int
+}
+
+// dependencyResult is the outcome of checking a dependency.
+type dependencyResult struct {
+	Dependency dependency
+	Healthy    bool
+	Status     string
+	Latency    time.Duration
+}
+
+// dependencyHealth tracks the consecutive failures of a dependency across background job runs.
+type dependencyHealth struct {
+	Failures  int   `json:"failures"`
+	AlertedAt int64 `json:"alerted_at,omitempty"`
+}
+
+// parseDependencies parses the comma-separated dependencies, each as "name URL" or
+// "name URL status".
+func parseDependencies(configuration *configuration) ([]dependency, error) {
+	var dependencies []dependency
+	for _, entry := range strings.Split(configuration.Dependencies, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 3 || len(fields) < 2 {
+			return nil, errors.Errorf("invalid dependency %q, expected a name, a URL and an optional status code", strings.TrimSpace(entry))
+		}
+
+		parsedURL, err := url.Parse(fields[1])
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return nil, errors.Errorf("invalid URL %q for dependency %s", fields[1], fields[0])
+		}
+
+		expectedStatus := http.StatusOK
+		if len(fields) == 3 {
+			expectedStatus, err = strconv.Atoi(fields[2])
+			if err != nil || expectedStatus < 100 || expectedStatus > 599 {
+				return nil, errors.Errorf("invalid status code %q for dependency %s", fields[2], fields[0])
+			}
+		}
+
+		dependencies = append(dependencies, dependency{
+			Name:           fields[0],
+			URL:            fields[1],
+			ExpectedStatus: expectedStatus,
+		})
+	}
+
+	return dependencies, nil
+}
+
+// checkDependencies checks every configured dependency concurrently, returning the results in the
+// configured order.
+func (p *Plugin) checkDependencies() []*dependencyResult {
+	configuration := p.getConfiguration()
+	client := newExternalHTTPClient(configuration.allowedNetworks)
+	client.Timeout = dependencyCheckTimeout
+
+	results := make([]*dependencyResult, len(configuration.dependencies))
+
+	var wg sync.WaitGroup
+	for i, dep := range configuration.dependencies {
+		wg.Add(1)
+		go func(i int, dep dependency) {
+			defer wg.Done()
+			results[i] = checkDependency(client, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// checkDependency requests the dependency's URL once, without retrying, so that the result
+// reflects what users of the dependency see.
+func checkDependency(client *http.Client, dep dependency) *dependencyResult {
+	result := &dependencyResult{Dependency: dep}
+
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, dep.URL, nil)
+	if err != nil {
+		result.Status = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	response, err := client.Do(request)
+	result.Latency = time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		result.Status = "unreachable"
+		return result
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	result.Status = response.Status
+	result.Healthy = response.StatusCode == dep.ExpectedStatus
+
+	return result
+}
+
+// dependencyTable renders the results as a markdown table.
+func dependencyTable(results []*dependencyResult) string {
+	var table strings.Builder
+	table.WriteString("| Dependency | Health | Response | Expected | Latency |\n|---|---|---|---|---|\n")
+	for _, result := range results {
+		health := ":white_check_mark: Up"
+		if !result.Healthy {
+			health = ":x: Down"
+		}
+
+		fmt.Fprintf(&table, "| %s | %s | %s | %d | %s |\n", result.Dependency.Name, health, result.Status, result.Dependency.ExpectedStatus, result.Latency)
+	}
+
+	return table.String()
+}
+
+func (p *Plugin) executeCommandDeps() *model.CommandResponse {
+	if len(p.getConfiguration().dependencies) == 0 {
+		return ephemeralResponse("No dependencies are configured.")
+	}
+
+	results := p.checkDependencies()
+
+	return ephemeralResponse(fmt.Sprintf("#### Dependency health, checked %s\n%s", time.Now().UTC().Format(time.RFC1123), dependencyTable(results)))
+}
+
+func (p *Plugin) getDependencyHealth() (map[string]*dependencyHealth, error) {
+	var health map[string]*dependencyHealth
+	if err := p.client.KV.Get(dependencyHealthKey, &health); err != nil {
+		return nil, errors.Wrap(err, "failed to get dependency health")
+	}
+	if health == nil {
+		health = make(map[string]*dependencyHealth)
+	}
+
+	return health, nil
+}
+
+// monitorDependencies checks the dependencies and alerts the SRE channels when a dependency fails
+// dependencyFailureThreshold checks in a row, and again once it recovers.
+func (p *Plugin) monitorDependencies() {
+	if len(p.getConfiguration().dependencies) == 0 {
+		return
+	}
+
+	health, err := p.getDependencyHealth()
+	if err != nil {
+		p.reportJobFailure("BackgroundJob", "Failed to get dependency health", err)
+		return
+	}
+
+	results := p.checkDependencies()
+
+	var down, recovered []*dependencyResult
+	monitored := make(map[string]*dependencyHealth)
+	for _, result := range results {
+		state := health[result.Dependency.Name]
+		if state == nil {
+			state = &dependencyHealth{}
+		}
+
+		if result.Healthy {
+			if state.AlertedAt != 0 {
+				recovered = append(recovered, result)
+			}
+			state = &dependencyHealth{}
+		} else {
+			state.Failures++
+			if state.Failures >= dependencyFailureThreshold && state.AlertedAt == 0 {
+				state.AlertedAt = model.GetMillis()
+				down = append(down, result)
+			}
+		}
+
+		monitored[result.Dependency.Name] = state
+	}
+
+	if _, err := p.client.KV.Set(dependencyHealthKey, monitored); err != nil {
+		p.API.LogError("Failed to save dependency health", "err", err.Error())
+		return
+	}
+
+	if len(down) > 0 {
+		message := fmt.Sprintf(":rotating_light: %d dependencies failed their last %d health checks:\n%s", len(down), dependencyFailureThreshold, dependencyTable(down))
+		if mentions := p.escalationMentions(message); mentions != "" {
+			message += fmt.Sprintf("\n%s please take a look.", mentions)
+		}
+		p.postToSREChannels(message)
+	}
+	if len(recovered) > 0 {
+		p.postToSREChannels(fmt.Sprintf(":white_check_mark: %d dependencies recovered:\n%s", len(recovered), dependencyTable(recovered)))
+	}
+}
+
+// postToSREChannels posts a bot message to the SRE channel of every team.
+func (p *Plugin) postToSREChannels(message string) {
+	for teamID := range p.getConfiguration().demoChannelIDs {
+		if _, err := p.createRoutedPost(teamID, func(channelID string) (*model.Post, error) {
+			return &model.Post{
+				UserId:    p.botID,
+				ChannelId: channelID,
+				Message:   message,
+			}, nil
+		}); err != nil {
+			p.API.LogError("Failed to post to SRE channel", "team_id", teamID, "err", err.Error())
+		}
+	}
+}
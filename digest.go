@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// digestPeriod is the period covered by the weekly digest.
+	digestPeriod = 7 * 24 * time.Hour
+
+	// digestWeekday and digestHour are when the weekly digest is posted, in UTC.
+	digestWeekday = time.Monday
+	digestHour    = 9
+
+	// digestTopSubmitters is the number of submitters listed in the weekly digest.
+	digestTopSubmitters = 5
+)
+
+// nextDigestTime returns the first time the weekly digest is due after the given time.
+func nextDigestTime(after time.Time) time.Time {
+	after = after.UTC()
+	next := time.Date(after.Year(), after.Month(), after.Day(), digestHour, 0, 0, 0, time.UTC)
+	next = next.AddDate(0, 0, int(digestWeekday-next.Weekday()+7)%7)
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 7)
+	}
+
+	return next
+}
+
+// waitForWeeklyDigest schedules the weekly digest job. Unlike the rounded intervals of the other
+// jobs, it never runs immediately on the first activation, but waits for the next digest time.
+func waitForWeeklyDigest(now time.Time, metadata cluster.JobMetadata) time.Duration {
+	after := now
+	if !metadata.LastFinished.IsZero() {
+		after = metadata.LastFinished
+	}
+
+	if wait := nextDigestTime(after).Sub(now); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// ticketDigest summarizes the tickets of a team over a digest period.
+type ticketDigest struct {
+	Opened int
+	Closed int
+
+	// acknowledged and acknowledgeTime are the number of tickets acknowledged during the period
+	// and their total time to acknowledge, from which the mean is computed.
+	acknowledged    int
+	acknowledgeTime time.Duration
+
+	// OpenedByPriority and ClosedByPriority count the tickets by priority code.
+	OpenedByPriority map[string]int
+	ClosedByPriority map[string]int
+
+	// Submitters counts the tickets opened by each reporter.
+	Submitters map[string]int
+
+	// Tickets are the tickets opened, acknowledged or closed during the period, oldest first.
+	Tickets []*Ticket
+}
+
+// buildTicketDigest summarizes the given tickets over the period from since to until.
+// Confidential tickets are left out, as the digest is posted to the public SRE channel.
+func buildTicketDigest(tickets []*Ticket, since, until int64) *ticketDigest {
+	digest := &ticketDigest{
+		OpenedByPriority: make(map[string]int),
+		ClosedByPriority: make(map[string]int),
+		Submitters:       make(map[string]int),
+	}
+
+	within := func(millis int64) bool {
+		return millis >= since && millis < until
+	}
+
+	for _, ticket := range tickets {
+		if ticket.Confidential {
+			continue
+		}
+
+		included := false
+		if within(ticket.CreateAt) {
+			digest.Opened++
+			digest.OpenedByPriority[ticket.Priority]++
+			if !ticket.Anonymous {
+				digest.Submitters[ticket.ReporterID]++
+			}
+			included = true
+		}
+		if ticket.AcknowledgedAt != 0 && within(ticket.AcknowledgedAt) {
+			digest.acknowledged++
+			digest.acknowledgeTime += time.Duration(ticket.AcknowledgedAt-ticket.CreateAt) * time.Millisecond
+			included = true
+		}
+		if ticket.Status == ticketStatusResolved && within(ticket.ResolvedAt) {
+			digest.Closed++
+			digest.ClosedByPriority[ticket.Priority]++
+			included = true
+		}
+
+		if included {
+			digest.Tickets = append(digest.Tickets, ticket)
+		}
+	}
+
+	sort.Slice(digest.Tickets, func(i, j int) bool {
+		return digest.Tickets[i].CreateAt < digest.Tickets[j].CreateAt
+	})
+
+	return digest
+}
+
+// meanTimeToAcknowledge returns the mean time to acknowledge the tickets acknowledged during the
+// period, and false if none were.
+func (d *ticketDigest) meanTimeToAcknowledge() (time.Duration, bool) {
+	if d.acknowledged == 0 {
+		return 0, false
+	}
+
+	return (d.acknowledgeTime / time.Duration(d.acknowledged)).Round(time.Minute), true
+}
+
+// topSubmitters returns the ids of the users who opened the most tickets, most first.
+func (d *ticketDigest) topSubmitters() []string {
+	userIDs := make([]string, 0, len(d.Submitters))
+	for userID := range d.Submitters {
+		userIDs = append(userIDs, userID)
+	}
+
+	sort.Slice(userIDs, func(i, j int) bool {
+		if d.Submitters[userIDs[i]] != d.Submitters[userIDs[j]] {
+			return d.Submitters[userIDs[i]] > d.Submitters[userIDs[j]]
+		}
+		return userIDs[i] < userIDs[j]
+	})
+
+	if len(userIDs) > digestTopSubmitters {
+		userIDs = userIDs[:digestTopSubmitters]
+	}
+
+	return userIDs
+}
+
+// digestMessage renders the digest as markdown tables, with labels in the server's locale.
+func (p *Plugin) digestMessage(digest *ticketDigest, since, until time.Time) string {
+	localizer := p.serverLocalizer()
+
+	meanTimeToAcknowledge := "n/a"
+	if mean, ok := digest.meanTimeToAcknowledge(); ok {
+		meanTimeToAcknowledge = mean.String()
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "#### Weekly SRE request digest: %s to %s\n\n", since.UTC().Format("Jan 2"), until.UTC().Format("Jan 2, 2006"))
+	message.WriteString("| Opened | Closed | Mean time to acknowledge |\n|---|---|---|\n")
+	fmt.Fprintf(&message, "| %d | %d | %s |\n\n", digest.Opened, digest.Closed, meanTimeToAcknowledge)
+
+	message.WriteString("| Priority | Opened | Closed |\n|---|---|---|\n")
+	for _, priority := range []string{ticketPriorityHigh, ticketPriorityMedium, ticketPriorityLow} {
+		fmt.Fprintf(&message, "| %s | %d | %d |\n", localizeLabel(localizer, priorityLabels, priority), digest.OpenedByPriority[priority], digest.ClosedByPriority[priority])
+	}
+
+	if submitters := digest.topSubmitters(); len(submitters) > 0 {
+		message.WriteString("\n| Top submitters | Opened |\n|---|---|\n")
+		for _, userID := range submitters {
+			fmt.Fprintf(&message, "| %s | %d |\n", p.username(userID), digest.Submitters[userID])
+		}
+	}
+
+	return message.String()
+}
+
+// WeeklyDigestJob runs weekly on only one plugin instance at a time. It posts a summary of the
+// past week's tickets to the SRE channel of every team, and sends it to the members of the team
+// preferring direct messages.
+func (p *Plugin) WeeklyDigestJob() {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled {
+		return
+	}
+	if p.sheddingLoad() {
+		p.API.LogWarn("Skipping the weekly digest while the server is under pressure")
+		return
+	}
+
+	tickets, err := p.listTickets()
+	if err != nil {
+		p.reportJobFailure("WeeklyDigestJob", "Failed to list tickets for weekly digest", err)
+		return
+	}
+
+	ticketsByTeam := make(map[string][]*Ticket)
+	for _, ticket := range tickets {
+		ticketsByTeam[ticket.TeamID] = append(ticketsByTeam[ticket.TeamID], ticket)
+	}
+
+	dmUserIDs, err := p.listDirectMessageUsers()
+	if err != nil {
+		p.API.LogError("Failed to list users preferring direct messages", "err", err.Error())
+	}
+
+	until := time.Now()
+	since := until.Add(-digestPeriod)
+	for teamID := range configuration.demoChannelIDs {
+		digest := buildTicketDigest(ticketsByTeam[teamID], since.UnixMilli(), until.UnixMilli())
+		if err := p.postDigest(teamID, digest, since, until); err != nil {
+			p.API.LogError("Failed to post weekly digest", "team_id", teamID, "err", err.Error())
+		}
+
+		for _, userID := range dmUserIDs {
+			if member, appErr := p.API.GetTeamMember(teamID, userID); appErr != nil || member.DeleteAt != 0 {
+				continue
+			}
+			if err := p.sendDirectMessage(userID, p.digestMessage(digest, since, until)); err != nil {
+				p.API.LogWarn("Failed to send weekly digest", "user_id", userID, "err", err.Error())
+			}
+		}
+	}
+}
+
+// postDigest posts the digest to the team's SRE channel, with the tickets it covers attached as
+// CSV.
+func (p *Plugin) postDigest(teamID string, digest *ticketDigest, since, until time.Time) error {
+	var data []byte
+	if len(digest.Tickets) > 0 {
+		var err error
+		if data, err = p.ticketsCSV(digest.Tickets, p.serverLocalizer()); err != nil {
+			return err
+		}
+	}
+
+	_, err := p.createRoutedPost(teamID, func(channelID string) (*model.Post, error) {
+		var fileIDs []string
+		if data != nil {
+			filename := fmt.Sprintf("sre-requests-digest-%s.csv", until.UTC().Format("2006-01-02"))
+			fileInfo, appErr := p.API.UploadFile(data, channelID, filename)
+			if appErr != nil {
+				return nil, errors.Wrap(appErr, "failed to upload digest CSV")
+			}
+			fileIDs = append(fileIDs, fileInfo.Id)
+		}
+
+		return &model.Post{
+			UserId:    p.botID,
+			ChannelId: channelID,
+			Message:   p.digestMessage(digest, since, until),
+			FileIds:   fileIDs,
+		}, nil
+	})
+
+	return err
+}
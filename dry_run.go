@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// maxDryRunPayload bounds the payload quoted in the admin channel for a skipped call.
+const maxDryRunPayload = 4000
+
+// errDryRun is returned by calls to external systems skipped because DryRun is set. Callers
+// treat it as a success with no result rather than as a failure.
+var errDryRun = errors.New("skipped in dry-run mode")
+
+// isDryRun reports whether the error comes from a call skipped in dry-run mode.
+func isDryRun(err error) bool {
+	return errors.Is(err, errDryRun)
+}
+
+// interceptDryRun reports whether DryRun is set, in which case it logs the call to the external
+// system it would have made and posts it to the admin channel instead. Credentials are never part
+// of the payload, since they are sent as headers.
+func (p *Plugin) interceptDryRun(integration, request string, payload []byte) bool {
+	if !p.getConfiguration().DryRun {
+		return false
+	}
+
+	p.API.LogInfo("Skipped external call in dry-run mode", "integration", integration, "request", request)
+
+	body := string(payload)
+	if len(body) > maxDryRunPayload {
+		body = body[:maxDryRunPayload] + "\n…"
+	}
+	message := fmt.Sprintf(":test_tube: **Dry run:** skipped %s call `%s`.", integration, request)
+	if body != "" {
+		message += "\n```\n" + body + "\n```"
+	}
+	p.postAdminNotice(message)
+
+	return true
+}
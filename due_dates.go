@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// dueReminder is a reminder posted in a ticket's thread some time before its due date.
+type dueReminder struct {
+	name   string
+	before time.Duration
+}
+
+// dueReminders are the reminders posted before a ticket is due, latest first.
+var dueReminders = []dueReminder{
+	{name: "1h", before: time.Hour},
+	{name: "24h", before: 24 * time.Hour},
+}
+
+// dueDateLayouts are the formats accepted for due dates, interpreted in UTC.
+var dueDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDueDate parses a due date in one of the accepted formats.
+func parseDueDate(value string) (time.Time, bool) {
+	for _, layout := range dueDateLayouts {
+		if dueDate, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return dueDate, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// formatDueDate formats a due date in milliseconds for display.
+func formatDueDate(dueAt int64) string {
+	return time.UnixMilli(dueAt).UTC().Format("Mon Jan 2 15:04 MST")
+}
+
+// isOverdue reports whether the ticket is unresolved past its due date.
+func (t *Ticket) isOverdue(now int64) bool {
+	return t.DueAt != 0 && t.Status != ticketStatusResolved && now >= t.DueAt
+}
+
+// setTicketDueDate sets or, when dueAt is zero, clears the ticket's due date, resetting its
+// reminders.
+func setTicketDueDate(ticket *Ticket, dueAt int64) {
+	ticket.DueAt = dueAt
+	ticket.DueRemindersSent = nil
+	ticket.OverdueAt = 0
+}
+
+// checkDueDates posts the reminders of tickets approaching their due date and flags the tickets
+// that became overdue.
+func (p *Plugin) checkDueDates() {
+	tickets, err := p.listTickets()
+	if err != nil {
+		p.reportJobFailure("BackgroundJob", "Failed to list tickets for due date check", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, ticket := range tickets {
+		// Reminders are held back while the ticket waits on its reporter.
+		if ticket.DueAt == 0 || ticket.Status == ticketStatusResolved || ticket.Status == ticketStatusWaitingOnReporter || ticket.OverdueAt != 0 {
+			continue
+		}
+
+		if err := p.checkDueDate(ticket, now); err != nil {
+			p.API.LogError("Failed to check ticket due date", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+}
+
+// checkDueDate flags the ticket as overdue or marks its due reminder as sent, then posts it. The
+// ticket is saved first, so that each reminder is posted once even if the ticket changes meanwhile.
+func (p *Plugin) checkDueDate(ticket *Ticket, now int64) error {
+	var overdue bool
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.DueAt == 0 || ticket.Status == ticketStatusResolved || ticket.Status == ticketStatusWaitingOnReporter || ticket.OverdueAt != 0 {
+			return errTicketUnchanged
+		}
+		overdue = ticket.isOverdue(now)
+		if overdue {
+			ticket.OverdueAt = now
+			return nil
+		}
+
+		// Only post the most imminent reminder, marking the earlier ones as sent.
+		for i, due := range dueReminders {
+			if now < ticket.DueAt-due.before.Milliseconds() {
+				continue
+			}
+			if contains(ticket.DueRemindersSent, due.name) {
+				return errTicketUnchanged
+			}
+
+			for _, sent := range dueReminders[i:] {
+				if !contains(ticket.DueRemindersSent, sent.name) {
+					ticket.DueRemindersSent = append(ticket.DueRemindersSent, sent.name)
+				}
+			}
+			return nil
+		}
+
+		return errTicketUnchanged
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if overdue {
+		if err := p.updateTicketPost(ticket); err != nil {
+			p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+		}
+
+		message := fmt.Sprintf("This request is overdue, it was due %s.", formatDueDate(ticket.DueAt))
+		return p.postTicketTransition(ticket, strings.TrimSpace(fmt.Sprintf(":warning: %s %s", p.ticketRecipients(ticket, message), message)))
+	}
+
+	message := fmt.Sprintf("This request is due %s.", formatDueDate(ticket.DueAt))
+	return p.postTicketReply(ticket, strings.TrimSpace(fmt.Sprintf(":alarm_clock: %s %s", p.ticketRecipients(ticket, message), message)))
+}
+
+func (p *Plugin) executeCommandDue(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) < 2 {
+		return ephemeralResponse(commandUsage("due"))
+	}
+
+	ticket, err := p.getTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+	if !p.canEditTicket(args.UserId, ticket) {
+		return ephemeralResponse("You are not allowed to edit this ticket.")
+	}
+
+	var dueAt int64
+	message := fmt.Sprintf("Cleared the due date of ticket %s.", ticket.ID)
+	if value := strings.Join(params[1:], " "); value != "clear" {
+		dueDate, ok := parseDueDate(value)
+		if !ok {
+			return ephemeralResponse(fmt.Sprintf("Invalid due date %q, use YYYY-MM-DD or YYYY-MM-DD HH:MM in UTC.", value))
+		}
+		dueAt = dueDate.UnixMilli()
+		message = fmt.Sprintf("Ticket %s is due %s.", ticket.ID, formatDueDate(dueAt))
+	}
+
+	ticketID := ticket.ID
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		setTicketDueDate(ticket, dueAt)
+		return nil
+	})
+	if err != nil {
+		p.API.LogError("Failed to save ticket", "ticket_id", ticketID, "err", err.Error())
+		return ephemeralResponse(ticketUpdateError(err, "Failed to save the ticket."))
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return ephemeralResponse(message)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"plugin-test/utils"
+)
+
+const (
+	// duplicateSimilarityThreshold is the minimum token overlap between two ticket summaries for
+	// a new ticket to be considered a likely duplicate of an existing one.
+	duplicateSimilarityThreshold = 0.5
+
+	// duplicateLookbackPeriod is how far back tickets are searched for duplicates.
+	duplicateLookbackPeriod = 7 * 24 * time.Hour
+)
+
+// findDuplicateTicket returns the most similar unresolved ticket recently submitted to the same
+// team that the reporter may view, or nil if no ticket is similar enough.
+func (p *Plugin) findDuplicateTicket(ticket *Ticket) (*Ticket, error) {
+	tickets, err := p.listCachedTickets(ticket.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := utils.Tokenize(ticket.Summary)
+	since := model.GetMillis() - duplicateLookbackPeriod.Milliseconds()
+
+	var duplicate *Ticket
+	bestOverlap := duplicateSimilarityThreshold
+	for _, candidate := range tickets {
+		if candidate.TeamID != ticket.TeamID || candidate.Status == ticketStatusResolved || candidate.CreateAt < since {
+			continue
+		}
+
+		overlap := utils.TokenOverlap(tokens, utils.Tokenize(candidate.Summary))
+		if overlap < bestOverlap || (overlap == bestOverlap && duplicate != nil && candidate.CreateAt < duplicate.CreateAt) {
+			continue
+		}
+		if !p.canViewTicket(ticket.ReporterID, candidate) {
+			continue
+		}
+
+		duplicate = candidate
+		bestOverlap = overlap
+	}
+
+	return duplicate, nil
+}
+
+// ticketPermalink returns the permalink of the ticket's root post.
+func (p *Plugin) ticketPermalink(ticket *Ticket) (string, error) {
+	return p.postPermalink(ticket.TeamID, ticket.PostID)
+}
+
+// duplicateTicketError describes the duplicate found for a submitted ticket, linking to its thread.
+func (p *Plugin) duplicateTicketError(duplicate *Ticket) string {
+	link := fmt.Sprintf("%q", duplicate.Summary)
+	if permalink, err := p.ticketPermalink(duplicate); err != nil {
+		p.API.LogWarn("Failed to get duplicate ticket permalink", "ticket_id", duplicate.ID, "err", err.Error())
+	} else {
+		link = fmt.Sprintf("[%s](%s)", duplicate.Summary, permalink)
+	}
+
+	return fmt.Sprintf("This looks like a duplicate of %s. If it is a different problem, close this form and use Submit anyway.", link)
+}
+
+// sendSubmitAnywayPrompt shows the user a button reopening the intake dialog with their submission,
+// flagged to skip duplicate detection.
+func (p *Plugin) sendSubmitAnywayPrompt(request *model.SubmitDialogRequest, duplicate *Ticket) {
+	p.API.SendEphemeralPost(request.UserId, &model.Post{
+		UserId:    p.botID,
+		ChannelId: request.ChannelId,
+		Message:   fmt.Sprintf("Your SRE request looks like a duplicate of %q.", duplicate.Summary),
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{{
+				Actions: []*model.PostAction{{
+					Type: model.PostActionTypeButton,
+					Name: "Submit anyway",
+					Integration: &model.PostActionIntegration{
+						URL: pluginCallbackURL("/interactive/sre/force"),
+						Context: model.StringInterface{
+							"submission": request.Submission,
+							"state":      request.State,
+						},
+					},
+				}},
+			}},
+		},
+	})
+}
+
+// handleSubmitAnyway reopens the intake dialog pre-filled with a submission that was rejected as a
+// likely duplicate, so that the user can confirm it.
+func (p *Plugin) handleSubmitAnyway(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.PostActionIntegrationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode PostActionIntegrationRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	// The submission is replayed for the user of the header, never the one named in the body.
+	if request.UserId != userID {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !p.teamEnabled(request.TeamId) {
+		p.writeJSON(w, &model.PostActionIntegrationResponse{
+			EphemeralText: "The plugin is disabled in this team.",
+		})
+		return
+	}
+
+	submission, _ := request.Context["submission"].(map[string]interface{})
+	encodedState, _ := request.Context["state"].(string)
+
+	state := decodeIntakeState(encodedState)
+	state.Force = true
+
+	dialog := prefillDialog(p.getTicketDialog(state.Category, p.userLocalizer(userID)), submission)
+	dialog.State = state.encode()
+
+	if appErr := p.openTicketDialog(request.TriggerId, request.TeamId, dialog); appErr != nil {
+		p.API.LogError("Failed to open dialog", "err", appErr.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, &model.PostActionIntegrationResponse{})
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+
+	"plugin-test/utils"
+)
+
+const (
+	// emailKeyPrefix prefixes the KV records of the emails converted into tickets, by hash of
+	// their Message-ID, so that an email is never converted twice even if marking it as read
+	// fails.
+	emailKeyPrefix = "email_"
+
+	// emailRetention is how long the records of converted emails are kept.
+	emailRetention = 30 * 24 * time.Hour
+
+	// emailBatchSize is the maximum number of emails converted per poll, so that a flooded
+	// mailbox doesn't hold the job for long.
+	emailBatchSize = 20
+
+	// emailTimeout bounds the IMAP commands run for each email and the delivery of each
+	// acknowledgment.
+	emailTimeout = 30 * time.Second
+
+	defaultEmailMailbox = "INBOX"
+)
+
+// htmlTagPattern matches the tags stripped from HTML-only emails.
+var htmlTagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// inboundEmail is an email parsed for conversion into a ticket.
+type inboundEmail struct {
+	MessageID   string
+	From        *mail.Address
+	Subject     string
+	Body        string
+	Attachments []*emailAttachment
+
+	// Automated is whether the email was sent by an automated system, such as an out-of-office
+	// reply, which is not acknowledged to avoid mail loops.
+	Automated bool
+
+	// AuthenticationResults are the Authentication-Results headers added by the mail servers the
+	// email went through.
+	AuthenticationResults []string
+
+	// html is the HTML body, used when the email has no plain text body.
+	html string
+}
+
+type emailAttachment struct {
+	Name string
+	Data []byte
+}
+
+func emailKey(messageID string) string {
+	hash := sha256.Sum256([]byte(messageID))
+	return emailKeyPrefix + hex.EncodeToString(hash[:])
+}
+
+// isEmailBridgeConfigured reports whether the email bridge is enabled.
+func (c *configuration) isEmailBridgeConfigured() bool {
+	return c.EmailIMAPServer != "" && c.EmailUsername != "" && c.EmailTeam != ""
+}
+
+// emailMailbox returns the mailbox polled for new emails.
+func (c *configuration) emailMailbox() string {
+	if c.EmailMailbox == "" {
+		return defaultEmailMailbox
+	}
+
+	return c.EmailMailbox
+}
+
+// emailAddress returns the address acknowledgments are sent from.
+func (c *configuration) emailAddress() string {
+	if c.EmailAddress == "" {
+		return c.EmailUsername
+	}
+
+	return c.EmailAddress
+}
+
+// EmailBridgeJob runs periodically on only one plugin instance at a time. It converts the unread
+// emails of the configured mailbox into tickets.
+func (p *Plugin) EmailBridgeJob() {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled || !configuration.isEmailBridgeConfigured() {
+		return
+	}
+
+	if err := p.pollMailbox(configuration); err != nil {
+		p.reportJobFailure("EmailBridgeJob", "Failed to poll the email bridge mailbox", err)
+	}
+}
+
+// pollMailbox converts the unread emails of the mailbox into tickets, marking each as read once
+// converted. Emails that fail to convert are left unread, so that the next poll retries them.
+func (p *Plugin) pollMailbox(configuration *configuration) error {
+	team, appErr := p.API.GetTeamByName(strings.ToLower(configuration.EmailTeam))
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to find email bridge team %s", configuration.EmailTeam)
+	}
+	// The emails are left unread until the team is enabled again.
+	if _, ok := configuration.demoChannelIDs[team.Id]; !ok || !p.teamEnabled(team.Id) {
+		p.logger("team_id", team.Id).Debug("Skipped polling the email bridge mailbox of a disabled team")
+		return nil
+	}
+
+	client, err := utils.DialIMAP(configuration.EmailIMAPServer, emailTimeout)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Login(configuration.EmailUsername, configuration.EmailPassword); err != nil {
+		return err
+	}
+	if err := client.Select(configuration.emailMailbox()); err != nil {
+		return err
+	}
+
+	uids, err := client.SearchUnseen()
+	if err != nil {
+		return err
+	}
+	if len(uids) > emailBatchSize {
+		uids = uids[:emailBatchSize]
+	}
+
+	for _, uid := range uids {
+		if err := client.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+			return errors.Wrap(err, "failed to set IMAP deadline")
+		}
+
+		raw, err := client.FetchMessage(uid)
+		if err != nil {
+			return err
+		}
+
+		if err := p.convertEmail(configuration, team.Id, raw); err != nil {
+			p.API.LogError("Failed to convert email into a ticket", "uid", uid, "err", err.Error())
+			continue
+		}
+
+		if err := client.MarkSeen(uid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertEmail opens a ticket for the email, unless it was already converted, and acknowledges it
+// to its sender.
+func (p *Plugin) convertEmail(configuration *configuration, teamID string, raw []byte) error {
+	email, err := parseEmail(raw)
+	if err != nil {
+		return err
+	}
+
+	var converted bool
+	if err := p.client.KV.Get(emailKey(email.MessageID), &converted); err != nil {
+		return errors.Wrap(err, "failed to get email record")
+	}
+	if converted {
+		return nil
+	}
+
+	ticket := &Ticket{
+		TeamID:      teamID,
+		ReporterID:  p.botID,
+		Summary:     email.Subject,
+		Description: email.Body,
+		Priority:    ticketPriorityMedium,
+	}
+	if ticket.Summary == "" {
+		ticket.Summary = "Email from " + email.From.Address
+	}
+	if userID := p.emailReporterID(configuration, teamID, email); userID != "" {
+		ticket.ReporterID = userID
+	} else {
+		ticket.Description = strings.TrimSpace(fmt.Sprintf("Reported by email by %s\n\n%s", emailSender(email.From), ticket.Description))
+	}
+	configuration.suggestTicketPriority(ticket, false)
+
+	if err := p.createTicket(ticket); err != nil {
+		return err
+	}
+	p.countUsage("integration: email")
+
+	if _, err := p.client.KV.Set(emailKey(email.MessageID), true, pluginapi.SetExpiry(emailRetention)); err != nil {
+		p.API.LogWarn("Failed to record converted email", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	if err := p.attachEmailFiles(ticket, email); err != nil {
+		p.API.LogWarn("Failed to attach email files", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		p.API.LogError("Failed to notify responders", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	if configuration.EmailSMTPServer != "" && !email.Automated {
+		if err := p.sendEmailAcknowledgment(configuration, ticket, email); err != nil {
+			p.API.LogWarn("Failed to send email acknowledgment", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// emailReporterID returns the id of the user the email is filed on behalf of, or an empty string to
+// file it on behalf of the bot. Since anyone can forge the From header, the sender is only trusted
+// when the configured mail server authenticated it, and only mapped to members of the team.
+func (p *Plugin) emailReporterID(configuration *configuration, teamID string, email *inboundEmail) string {
+	if !email.senderAuthenticated(configuration.EmailAuthservID) {
+		return ""
+	}
+
+	user, appErr := p.API.GetUserByEmail(email.From.Address)
+	if appErr != nil || user.DeleteAt != 0 || user.IsBot || !p.isTeamMember(teamID, user.Id) {
+		return ""
+	}
+
+	return user.Id
+}
+
+// senderAuthenticated reports whether the Authentication-Results header of the mail server with the
+// given authserv-id reports the email as passing DMARC, which authenticates the domain of its From
+// header. Headers of other servers are ignored, since senders can add their own.
+func (e *inboundEmail) senderAuthenticated(authservID string) bool {
+	if authservID == "" {
+		return false
+	}
+
+	for _, header := range e.AuthenticationResults {
+		results := strings.Split(header, ";")
+		// The authserv-id may be followed by a version number.
+		if fields := strings.Fields(results[0]); len(fields) == 0 || !strings.EqualFold(fields[0], authservID) {
+			continue
+		}
+
+		for _, result := range results[1:] {
+			if method, _, _ := strings.Cut(strings.TrimSpace(result), " "); strings.EqualFold(method, "dmarc=pass") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// attachEmailFiles posts the attachments of the email in the ticket's thread and links them to
+// the ticket. Attachments the server rejects, such as those over its file size limit, are
+// skipped.
+func (p *Plugin) attachEmailFiles(ticket *Ticket, email *inboundEmail) error {
+	if len(email.Attachments) == 0 {
+		return nil
+	}
+
+	var fileIDs []string
+	for _, attachment := range email.Attachments {
+		fileInfo, appErr := p.API.UploadFile(attachment.Data, ticket.ChannelID, attachment.Name)
+		if appErr != nil {
+			p.API.LogWarn("Failed to upload email attachment", "ticket_id", ticket.ID, "name", attachment.Name, "err", appErr.Error())
+			continue
+		}
+		fileIDs = append(fileIDs, fileInfo.Id)
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	post, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: ticket.ChannelID,
+		RootId:    ticket.PostID,
+		Message: localize(p.serverLocalizer(), &i18n.Message{
+			ID:    "email.attachments",
+			Other: ":paperclip: Attachments of the email:",
+		}, nil),
+		FileIds: fileIDs,
+	})
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to post email attachments")
+	}
+
+	// The ticket may have changed while the attachments were uploaded.
+	_, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		p.linkPostFiles(ticket, post)
+		return nil
+	})
+
+	return err
+}
+
+// sendEmailAcknowledgment replies to the email with the key of its ticket and the permalink of the
+// ticket's thread.
+func (p *Plugin) sendEmailAcknowledgment(configuration *configuration, ticket *Ticket, email *inboundEmail) error {
+	permalink, err := p.ticketPermalink(ticket)
+	if err != nil {
+		return err
+	}
+
+	localizer := p.serverLocalizer()
+	subject := localize(localizer, &i18n.Message{
+		ID:    "email.acknowledgment.subject",
+		Other: "[{{.Key}}] Re: {{.Subject}}",
+	}, map[string]interface{}{"Key": ticket.ticketName(), "Subject": ticket.Summary})
+	body := localize(localizer, &i18n.Message{
+		ID:    "email.acknowledgment.body",
+		Other: "Hello,\n\nYour request was received and filed as {{.Key}}. You can follow it at {{.Permalink}}\n\nThis is an automated message.",
+	}, map[string]interface{}{"Key": ticket.ticketName(), "Permalink": permalink})
+
+	from := configuration.emailAddress()
+	header := []string{
+		"From: " + (&mail.Address{Address: from}).String(),
+		"To: " + email.From.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Auto-Submitted: auto-replied",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: base64",
+	}
+	if email.MessageID != "" {
+		header = append(header, "In-Reply-To: "+email.MessageID, "References: "+email.MessageID)
+	}
+
+	message := strings.Join(header, "\r\n") + "\r\n\r\n" + base64.StdEncoding.EncodeToString([]byte(body))
+	if p.interceptDryRun("email", "SMTP "+configuration.EmailSMTPServer+" to "+email.From.Address, []byte(subject+"\n\n"+body)) {
+		return nil
+	}
+
+	return sendEmail(configuration, from, email.From.Address, []byte(message))
+}
+
+// sendEmail delivers the message through the SMTP server, upgrading the connection with STARTTLS
+// when the server supports it and authenticating with the mailbox credentials.
+func sendEmail(configuration *configuration, from, to string, message []byte) error {
+	host, _, err := net.SplitHostPort(configuration.EmailSMTPServer)
+	if err != nil {
+		return errors.Wrapf(err, "invalid SMTP server %q", configuration.EmailSMTPServer)
+	}
+
+	conn, err := net.DialTimeout("tcp", configuration.EmailSMTPServer, emailTimeout)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to SMTP server")
+	}
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		conn.Close()
+		return errors.Wrap(err, "failed to set SMTP deadline")
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "failed to start SMTP session")
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return errors.Wrap(err, "failed to start TLS")
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok && configuration.EmailPassword != "" {
+		if err := client.Auth(smtp.PlainAuth("", configuration.EmailUsername, configuration.EmailPassword, host)); err != nil {
+			return errors.Wrap(err, "failed to authenticate")
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return errors.Wrap(err, "failed to set sender")
+	}
+	if err := client.Rcpt(to); err != nil {
+		return errors.Wrap(err, "failed to set recipient")
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "failed to start message")
+	}
+	if _, err := writer.Write(message); err != nil {
+		return errors.Wrap(err, "failed to write message")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "failed to send message")
+	}
+
+	return client.Quit()
+}
+
+// parseEmail parses a raw email, keeping its first plain text body, or else its HTML body as text,
+// and its attachments. Bodies are assumed to be UTF-8.
+func parseEmail(raw []byte) (*inboundEmail, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse email")
+	}
+
+	from, err := message.Header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return nil, errors.New("email has no sender")
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		subject = message.Header.Get("Subject")
+	}
+
+	email := &inboundEmail{
+		MessageID: strings.TrimSpace(message.Header.Get("Message-ID")),
+		From:      from[0],
+		Subject:   strings.TrimSpace(subject),
+		Automated: isAutomatedEmail(message.Header),
+
+		AuthenticationResults: message.Header[textproto.CanonicalMIMEHeaderKey("Authentication-Results")],
+	}
+	// Emails without a Message-ID are recognized by their content instead.
+	if email.MessageID == "" {
+		hash := sha256.Sum256(raw)
+		email.MessageID = hex.EncodeToString(hash[:])
+	}
+
+	if err := email.addPart(textproto.MIMEHeader(message.Header), message.Body); err != nil {
+		return nil, err
+	}
+
+	if email.Body == "" && email.html != "" {
+		email.Body = strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(email.html, "")))
+	}
+
+	return email, nil
+}
+
+// addPart adds a MIME part of the email to its body or attachments, walking multipart parts.
+func (e *inboundEmail) addPart(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, "failed to read email part")
+			}
+			if err := e.addPart(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read email part")
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = decoded
+	}
+
+	switch {
+	case disposition == "attachment" || name != "":
+		if name == "" {
+			name = "attachment"
+		}
+		e.Attachments = append(e.Attachments, &emailAttachment{Name: name, Data: data})
+	case mediaType == "text/plain" && e.Body == "":
+		e.Body = strings.TrimSpace(string(data))
+	case mediaType == "text/html" && e.html == "":
+		e.html = string(data)
+	}
+
+	return nil
+}
+
+// emailSender renders the sender of an email for display, unlike the encoded form of
+// mail.Address.String.
+func emailSender(from *mail.Address) string {
+	if from.Name == "" {
+		return from.Address
+	}
+
+	return fmt.Sprintf("%s <%s>", from.Name, from.Address)
+}
+
+// isAutomatedEmail reports whether the email headers mark it as sent by an automated system.
+func isAutomatedEmail(header mail.Header) bool {
+	if autoSubmitted := strings.ToLower(header.Get("Auto-Submitted")); autoSubmitted != "" && autoSubmitted != "no" {
+		return true
+	}
+
+	switch strings.ToLower(header.Get("Precedence")) {
+	case "bulk", "junk", "list", "auto_reply":
+		return true
+	}
+
+	return header.Get("List-Id") != ""
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ensureConfidentialChannel returns the group message used as the private escalation path of a
+// confidential ticket. The group is made of the reporter, the assignee, the configured incident
+// commander and the bot, so that the bot can post the ticket and its follow-ups there.
+func (p *Plugin) ensureConfidentialChannel(ticket *Ticket) (string, error) {
+	configuration := p.getConfiguration()
+	if configuration.MinimalPermissions {
+		return "", errMinimalPermissions
+	}
+
+	var userIDs []string
+	for _, userID := range []string{ticket.ReporterID, ticket.AssigneeID, configuration.incidentCommanderID, p.botID} {
+		if userID == "" || contains(userIDs, userID) {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	// A group message requires at least three members, so fall back to a direct message between
+	// the reporter and the bot when nobody else is involved.
+	if len(userIDs) < 3 {
+		channel, appErr := p.API.GetDirectChannel(ticket.ReporterID, p.botID)
+		if appErr != nil {
+			return "", errors.Wrap(appErr, "failed to get direct channel")
+		}
+		return channel.Id, nil
+	}
+
+	channel, appErr := p.API.GetGroupChannel(userIDs)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get group channel")
+	}
+
+	return channel.Id, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
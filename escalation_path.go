@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// escalationStep is a notification the plugin will send about a ticket unless it is acted upon.
+type escalationStep struct {
+	At         int64
+	Reason     string
+	Recipients []string
+}
+
+// escalationPath returns the notifications that will be sent about the ticket if nobody acts on
+// it, soonest first, following the same rules as the background job: responders are notified once
+// business hours start, escalation users once the SLA is breached, the tiers of the escalation
+// policy once they are reached, and the assignee, or else the escalation users, once the due date
+// passes.
+func (p *Plugin) escalationPath(ticket *Ticket, now int64) ([]*escalationStep, error) {
+	if ticket.Status == ticketStatusResolved || ticket.Status == ticketStatusWaitingOnReporter {
+		return nil, nil
+	}
+
+	schedule, err := p.getOnCallSchedule()
+	if err != nil {
+		return nil, err
+	}
+
+	// escalationUsersAt mirrors escalationUserIDs for a future point in time.
+	escalationUsersAt := func(at int64) []string {
+		if userID := schedule.predictOnCallUserID(at); userID != "" {
+			return []string{userID}
+		}
+		return p.configuredEscalationUserIDs()
+	}
+
+	configuration := p.getConfiguration()
+	var steps []*escalationStep
+
+	if ticket.isQueued() && !ticket.Confidential {
+		userIDs, groupNames := p.responders(ticket.Priority)
+		if onCallUserID := schedule.predictOnCallUserID(ticket.QueuedUntil); onCallUserID != "" {
+			userIDs = append([]string{onCallUserID}, userIDs...)
+		}
+		steps = append(steps, &escalationStep{
+			At:         ticket.QueuedUntil,
+			Reason:     "Business hours start",
+			Recipients: p.escalationRecipients(userIDs, groupNames),
+		})
+	}
+
+	if sla, ok := configuration.ticketSLA(ticket); ok && ticket.Status == ticketStatusOpen && ticket.SLABreachedAt == 0 {
+		breachAt := ticket.slaStartAt() + ticket.PausedDuration + sla.Milliseconds()
+		steps = append(steps, &escalationStep{
+			At:         breachAt,
+			Reason:     fmt.Sprintf("Not acknowledged within the SLA of %s", sla),
+			Recipients: p.escalationRecipients(escalationUsersAt(breachAt), configuration.routingGroupNames(ticket)),
+		})
+	}
+
+	if ticket.Status == ticketStatusOpen {
+		tiers := configuration.escalationPolicies[ticket.Priority]
+		for number := ticket.EscalationTier + 1; number <= len(tiers); number++ {
+			tier := tiers[number-1]
+			userIDs, groupNames := p.escalationTierRecipients(tier)
+			steps = append(steps, &escalationStep{
+				At:         ticket.escalationAt(tier),
+				Reason:     fmt.Sprintf("Escalation tier %d, not acknowledged for %s", number, tier.After),
+				Recipients: p.escalationRecipients(userIDs, groupNames),
+			})
+		}
+	}
+
+	if ticket.DueAt != 0 && ticket.OverdueAt == 0 {
+		userIDs := []string{ticket.AssigneeID}
+		if ticket.AssigneeID == "" {
+			userIDs = escalationUsersAt(ticket.DueAt)
+		}
+		steps = append(steps, &escalationStep{
+			At:         ticket.DueAt,
+			Reason:     "Overdue",
+			Recipients: p.escalationRecipients(userIDs, nil),
+		})
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].At < steps[j].At
+	})
+
+	return steps, nil
+}
+
+// escalationRecipients describes how each user will be notified according to their notification
+// preference, followed by the groups, which have no preference.
+func (p *Plugin) escalationRecipients(userIDs, groupNames []string) []string {
+	var recipients []string
+	for _, userID := range userIDs {
+		if userID == "" {
+			continue
+		}
+
+		switch p.getNotificationPreference(userID) {
+		case notificationPreferenceDM:
+			recipients = append(recipients, p.username(userID)+" (direct message)")
+		case notificationPreferenceOff:
+			recipients = append(recipients, p.username(userID)+" (notifications off)")
+		default:
+			recipients = append(recipients, p.username(userID))
+		}
+	}
+	for _, name := range groupNames {
+		recipients = append(recipients, name+" (group)")
+	}
+
+	return recipients
+}
+
+// formatEscalationTime formats when an escalation step happens, relative to now.
+func (c *configuration) formatEscalationTime(at, now int64) string {
+	if at <= now {
+		return "Now, on the next check"
+	}
+
+	in := (time.Duration(at-now) * time.Millisecond).Round(time.Minute)
+	return fmt.Sprintf("%s (in %s)", c.businessHours.formatTime(at), in)
+}
+
+func (p *Plugin) executeCommandPath(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("path"))
+	}
+
+	ticket, err := p.findTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+
+	switch ticket.Status {
+	case ticketStatusResolved:
+		return ephemeralResponse(fmt.Sprintf("%s is resolved, nobody will be notified.", ticket.ticketName()))
+	case ticketStatusWaitingOnReporter:
+		return ephemeralResponse(fmt.Sprintf("%s is waiting on its reporter. Escalations are paused until the reporter replies.", ticket.ticketName()))
+	}
+
+	now := model.GetMillis()
+	steps, err := p.escalationPath(ticket, now)
+	if err != nil {
+		p.API.LogError("Failed to compute escalation path", "ticket_id", ticket.ID, "err", err.Error())
+		return ephemeralResponse("Failed to compute the escalation path.")
+	}
+	if len(steps) == 0 {
+		return ephemeralResponse(fmt.Sprintf("%s has no pending escalations.", ticket.ticketName()))
+	}
+
+	configuration := p.getConfiguration()
+	lines := []string{
+		fmt.Sprintf("#### Escalation path of %s: %s", ticket.ticketName(), ticket.Summary),
+		"| When | Why | Who |",
+		"| --- | --- | --- |",
+	}
+	for _, step := range steps {
+		recipients := "Nobody"
+		if len(step.Recipients) > 0 {
+			recipients = strings.Join(step.Recipients, ", ")
+		}
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s |", configuration.formatEscalationTime(step.At, now), step.Reason, recipients))
+	}
+	if ticket.Status == ticketStatusOpen {
+		lines = append(lines, "", "Acknowledging the request stops the SLA escalation and the escalation policy.")
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
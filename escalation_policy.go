@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// escalationTier is a level of an escalation policy: its users and groups are notified once a
+// ticket has not been acknowledged for After.
+type escalationTier struct {
+	After time.Duration
+	Names []string
+}
+
+// parseEscalationPolicies parses the per-priority escalation policy settings. Priorities without a
+// policy are omitted.
+func parseEscalationPolicies(configuration *configuration) (map[string][]*escalationTier, error) {
+	settings := map[string]string{
+		ticketPriorityHigh:   configuration.HighPriorityEscalationPolicy,
+		ticketPriorityMedium: configuration.MediumPriorityEscalationPolicy,
+		ticketPriorityLow:    configuration.LowPriorityEscalationPolicy,
+	}
+
+	policies := make(map[string][]*escalationTier)
+	for priority, setting := range settings {
+		tiers, err := parseEscalationPolicy(setting)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s priority escalation policy", priority)
+		}
+		if len(tiers) > 0 {
+			policies[priority] = tiers
+		}
+	}
+
+	return policies, nil
+}
+
+// parseEscalationPolicy parses a semicolon-separated list of tiers, each as a duration followed by
+// comma-separated usernames and group names, such as "30m @alice; 1h @sre-leads; 2h @manager".
+// Tiers must be listed in increasing order of duration.
+func parseEscalationPolicy(setting string) ([]*escalationTier, error) {
+	var tiers []*escalationTier
+	for _, text := range strings.Split(setting, ";") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		durationText, names, _ := strings.Cut(text, " ")
+		after, err := time.ParseDuration(durationText)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid tier %q", text)
+		}
+		if after <= 0 {
+			return nil, errors.Errorf("tier %q must start after a positive duration", text)
+		}
+		if len(tiers) > 0 && after <= tiers[len(tiers)-1].After {
+			return nil, errors.Errorf("tier %q must start after the previous tier", text)
+		}
+
+		tier := &escalationTier{After: after, Names: splitUsernames(names)}
+		if len(tier.Names) == 0 {
+			return nil, errors.Errorf("tier %q has nobody to notify", text)
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// escalationAt returns when the ticket reaches the tier. Like the SLA, the time spent waiting on the
+// reporter or for business hours doesn't count.
+func (t *Ticket) escalationAt(tier *escalationTier) int64 {
+	return t.slaStartAt() + t.PausedDuration + tier.After.Milliseconds()
+}
+
+// escalationTierRecipients returns the ids of the existing users and the names of the existing
+// groups of the tier. Names that don't exist are logged and skipped.
+func (p *Plugin) escalationTierRecipients(tier *escalationTier) ([]string, []string) {
+	var userIDs, groupNames []string
+	for _, name := range tier.Names {
+		if user, appErr := p.API.GetUserByUsername(name); appErr == nil {
+			userIDs = append(userIDs, user.Id)
+			continue
+		}
+		if _, appErr := p.API.GetGroupByName(name); appErr == nil {
+			groupNames = append(groupNames, name)
+			continue
+		}
+
+		p.API.LogWarn("Ignoring unknown escalation policy recipient", "name", name)
+	}
+
+	return userIDs, groupNames
+}
+
+// checkEscalationPolicies executes the tiers of the escalation policies reached by unacknowledged
+// tickets. When several tiers were reached since the last check, each of them is executed in order,
+// so that no tier's recipients are skipped.
+func (p *Plugin) checkEscalationPolicies() {
+	configuration := p.getConfiguration()
+	if len(configuration.escalationPolicies) == 0 {
+		return
+	}
+
+	tickets, err := p.listTickets()
+	if err != nil {
+		p.reportJobFailure("BackgroundJob", "Failed to list tickets for escalation policies", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, ticket := range tickets {
+		if ticket.Status != ticketStatusOpen {
+			continue
+		}
+
+		tiers := configuration.escalationPolicies[ticket.Priority]
+		reached := ticket.EscalationTier
+		for reached < len(tiers) && now >= ticket.escalationAt(tiers[reached]) {
+			reached++
+		}
+
+		for number := ticket.EscalationTier + 1; number <= reached; number++ {
+			escalated, err := p.executeEscalationTier(ticket, tiers[number-1], number)
+			if err != nil {
+				if !errors.Is(err, errTicketUnchanged) {
+					p.API.LogError("Failed to execute escalation tier", "ticket_id", ticket.ID, "tier", number, "err", err.Error())
+				}
+				break
+			}
+			ticket = escalated
+		}
+	}
+}
+
+// executeEscalationTier notifies the recipients of the tier in the ticket's thread, and records the
+// escalation in the ticket's activity. The tier is saved before it is announced, so that it is
+// announced once, and it fails with errTicketUnchanged if the ticket no longer reaches the tier. It
+// returns the saved ticket.
+func (p *Plugin) executeEscalationTier(ticket *Ticket, tier *escalationTier, number int) (*Ticket, error) {
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.Status != ticketStatusOpen || ticket.EscalationTier != number-1 {
+			return errTicketUnchanged
+		}
+		ticket.EscalationTier = number
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	localizer := p.serverLocalizer()
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.escalation_tier",
+		Other: ":rotating_light: Escalated to tier {{.Tier}}: this {{.Priority}} priority request has not been acknowledged for {{.After}}.",
+	}, map[string]interface{}{"Tier": number, "Priority": localizeLabel(localizer, priorityLabels, ticket.Priority), "After": tier.After.String()})
+
+	userIDs, groupNames := p.escalationTierRecipients(tier)
+	if mentions := p.notifyRecipients(userIDs, groupNames, p.ticketDirectMessage(ticket, message)); mentions != "" {
+		message += "\n" + localize(localizer, takeALookMessage, map[string]interface{}{"Mentions": mentions})
+	}
+
+	post := &model.Post{Message: message}
+	if err := p.createTicketReply(ticket, post, true); err != nil {
+		return nil, err
+	}
+
+	// The escalation was already posted, so it is recorded even if the ticket changed meanwhile.
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		recordTicketActivity(ticket, timelineEventEscalation, "", fmt.Sprintf("Escalated to tier %d of the escalation policy", number)).PostID = post.Id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.sendTicketEvent(ticketEventEscalated, ticket, "")
+
+	return ticket, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	ticketEventCreated             = "ticket_created"
+	ticketEventAcknowledged        = "ticket_acknowledged"
+	ticketEventResolved            = "ticket_resolved"
+	ticketEventWaiting             = "ticket_waiting_on_reporter"
+	ticketEventResumed             = "ticket_resumed"
+	ticketEventEscalated           = "ticket_escalated"
+	ticketEventPostmortemScheduled = "postmortem_scheduled"
+	ticketEventPostmortemCompleted = "postmortem_completed"
+	ticketEventTest                = "test"
+
+	// eventSignatureHeader carries the hex-encoded HMAC-SHA256 of the event body, computed with the
+	// configured secret.
+	eventSignatureHeader = "X-SRE-Signature"
+)
+
+// TicketEvent is the body POSTed to the event webhook when a ticket changes.
+type TicketEvent struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	CreateAt int64   `json:"create_at"`
+	UserID   string  `json:"user_id,omitempty"`
+	Ticket   *Ticket `json:"ticket,omitempty"`
+}
+
+// sendTicketEvent notifies the event webhook, if configured, that the ticket changed, and mirrors
+// its status to its GitHub issue, if any. Status changes are also sent to the ticket's watchers.
+// Delivery happens in the background. Confidential tickets are never shared with external systems.
+func (p *Plugin) sendTicketEvent(eventType string, ticket *Ticket, userID string) {
+	if ticketStatusEvents[eventType] {
+		p.notifyWatchersOfStatus(ticket, userID)
+	}
+
+	if ticket.Confidential {
+		return
+	}
+
+	if ticket.GitHubIssueNumber != 0 {
+		go p.syncGitHubIssue(ticket.clone())
+	}
+
+	if p.getConfiguration().EventWebhookURL == "" {
+		return
+	}
+
+	event := &TicketEvent{
+		ID:       model.NewId(),
+		Type:     eventType,
+		CreateAt: model.GetMillis(),
+		UserID:   userID,
+		Ticket:   redactedReporter(ticket),
+	}
+
+	go func() {
+		if err := p.deliverEvent(event); err != nil && !isDryRun(err) {
+			p.API.LogError("Failed to deliver ticket event", "event", eventType, "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}()
+}
+
+// deliverEvent POSTs the event to the event webhook, retrying transient failures. The event id is
+// sent as the delivery id so that receivers can deduplicate retried deliveries.
+func (p *Plugin) deliverEvent(event *TicketEvent) error {
+	configuration := p.getConfiguration()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+
+	var signature string
+	if configuration.EventWebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(configuration.EventWebhookSecret))
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	if err := p.validateExternalURL(configuration.EventWebhookURL, "event webhook"); err != nil {
+		return err
+	}
+	if p.interceptDryRun("event webhook", "POST "+configuration.EventWebhookURL, body) {
+		return errDryRun
+	}
+	p.countUsage("integration: event webhook")
+	client := newExternalHTTPClient(configuration.allowedNetworks)
+	response, err := doWithRetry(client, func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPost, configuration.EventWebhookURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Delivery-ID", event.ID)
+		if signature != "" {
+			request.Header.Set(eventSignatureHeader, signature)
+		}
+
+		return request, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to post event")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.Errorf("event webhook responded with %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+)
+
+// exportOptions are the filters and format of a ticket export.
+type exportOptions struct {
+	Status string
+	Labels []string
+	Since  time.Duration
+	Format string
+}
+
+// parseDuration parses a duration, additionally accepting a number of days such as "30d".
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.Errorf("invalid number of days %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+// parseExportOptions parses the --status, --label, --since and --format flags of the export
+// command. The --label flag may be repeated.
+func parseExportOptions(flags commandFlags) (*exportOptions, error) {
+	options := &exportOptions{Format: exportFormatCSV}
+	if flags.has("status") {
+		status, ok := matchStatus(flags.get("status"))
+		if !ok {
+			return nil, errors.Errorf("invalid status %q", flags.get("status"))
+		}
+		options.Status = status
+	}
+	for _, value := range flags["label"] {
+		label, err := normalizeLabel(value)
+		if err != nil {
+			return nil, err
+		}
+		options.Labels = append(options.Labels, label)
+	}
+	if flags.has("since") {
+		since, err := parseDuration(flags.get("since"))
+		if err != nil || since <= 0 {
+			return nil, errors.Errorf("invalid duration %q", flags.get("since"))
+		}
+		options.Since = since
+	}
+	if flags.has("format") {
+		format := strings.ToLower(flags.get("format"))
+		if format != exportFormatCSV && format != exportFormatJSON {
+			return nil, errors.Errorf("invalid format %q", format)
+		}
+		options.Format = format
+	}
+
+	return options, nil
+}
+
+// exportTickets returns the tickets matching the options that the user may view, oldest first.
+func (p *Plugin) exportTickets(userID string, options *exportOptions) ([]*Ticket, error) {
+	tickets, err := p.listVisibleTickets(userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var since int64
+	if options.Since > 0 {
+		since = model.GetMillis() - options.Since.Milliseconds()
+	}
+
+	var exported []*Ticket
+	for _, ticket := range tickets {
+		if options.Status != "" && ticket.Status != options.Status {
+			continue
+		}
+		if !ticket.hasLabels(options.Labels) {
+			continue
+		}
+		if ticket.CreateAt < since {
+			continue
+		}
+
+		exported = append(exported, p.redactReporter(userID, ticket))
+	}
+
+	sort.Slice(exported, func(i, j int) bool {
+		return exported[i].CreateAt < exported[j].CreateAt
+	})
+
+	return exported, nil
+}
+
+// formatExportTime formats a timestamp in milliseconds for exports, or returns an empty string if
+// it is unset.
+func formatExportTime(millis int64) string {
+	if millis == 0 {
+		return ""
+	}
+
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339)
+}
+
+// username returns the username of the given user, falling back to the user id.
+func (p *Plugin) username(userID string) string {
+	if userID == "" {
+		return ""
+	}
+
+	return strings.TrimPrefix(p.mentionUser(userID), "@")
+}
+
+// exportedTicket is a ticket in a JSON export. The priority and status of the ticket are stable
+// codes, while their labels are localized for the user requesting the export.
+type exportedTicket struct {
+	*Ticket
+	PriorityLabel string `json:"priority_label"`
+	StatusLabel   string `json:"status_label"`
+}
+
+// ticketsJSON renders the tickets as JSON, with labels localized by the localizer.
+func (p *Plugin) ticketsJSON(tickets []*Ticket, localizer *i18n.Localizer) ([]byte, error) {
+	exported := make([]*exportedTicket, 0, len(tickets))
+	for _, ticket := range tickets {
+		exported = append(exported, &exportedTicket{
+			Ticket:        ticket,
+			PriorityLabel: localizeLabel(localizer, priorityLabels, ticket.Priority),
+			StatusLabel:   localizeLabel(localizer, statusLabels, ticket.Status),
+		})
+	}
+
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal tickets")
+	}
+
+	return data, nil
+}
+
+// ticketsCSV renders the tickets as CSV, with labels localized by the localizer.
+func (p *Plugin) ticketsCSV(tickets []*Ticket, localizer *i18n.Localizer) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	header := []string{
+		"id", "team_id", "summary", "priority", "priority_label", "status", "status_label", "reporter", "assignee", "confidential",
+		"created_at", "acknowledged_at", "resolved_at", "jira_issue_key", "history", "sla_paused_minutes", "files",
+		"duplicate_of", "labels",
+	}
+	// Custom fields are exported in the order of their definitions, after the built-in columns.
+	customFields := p.getConfiguration().customFields
+	for _, field := range customFields {
+		header = append(header, customFieldElementPrefix+field.Name)
+	}
+
+	records := [][]string{header}
+	now := model.GetMillis()
+	for _, ticket := range tickets {
+		record := []string{
+			ticket.ID,
+			ticket.TeamID,
+			ticket.Summary,
+			ticket.Priority,
+			localizeLabel(localizer, priorityLabels, ticket.Priority),
+			ticket.Status,
+			localizeLabel(localizer, statusLabels, ticket.Status),
+			p.username(ticket.ReporterID),
+			p.username(ticket.AssigneeID),
+			strconv.FormatBool(ticket.Confidential),
+			formatExportTime(ticket.CreateAt),
+			formatExportTime(ticket.AcknowledgedAt),
+			formatExportTime(ticket.ResolvedAt),
+			ticket.JiraIssueKey,
+			p.exportHistory(ticket),
+			strconv.FormatInt(ticket.pausedDuration(now)/time.Minute.Milliseconds(), 10),
+			p.exportFiles(ticket),
+			ticket.DuplicateOf,
+			strings.Join(ticket.Labels, ";"),
+		}
+		for _, field := range customFields {
+			record = append(record, ticket.CustomFields[field.Name])
+		}
+		records = append(records, record)
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return nil, errors.Wrap(err, "failed to write CSV")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// fileLink returns a link downloading the given file.
+func (p *Plugin) fileLink(fileInfo *model.FileInfo) string {
+	return fmt.Sprintf("[%s](%s)", fileInfo.Name, fileDownloadURL(p.siteURL(), fileInfo.Id))
+}
+
+func (p *Plugin) executeCommandExport(args *model.CommandArgs, params []string, flags commandFlags) *model.CommandResponse {
+	if len(params) > 0 {
+		return ephemeralResponse(commandUsage("export"))
+	}
+
+	options, err := parseExportOptions(flags)
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("%s. %s", err.Error(), commandUsage("export")))
+	}
+
+	tickets, err := p.exportTickets(args.UserId, options)
+	if err != nil {
+		p.API.LogError("Failed to list tickets for export", "err", err.Error())
+		return ephemeralResponse("Failed to export tickets.")
+	}
+
+	localizer := p.userLocalizer(args.UserId)
+
+	var data []byte
+	if options.Format == exportFormatJSON {
+		data, err = p.ticketsJSON(tickets, localizer)
+	} else {
+		data, err = p.ticketsCSV(tickets, localizer)
+	}
+	if err != nil {
+		p.API.LogError("Failed to render ticket export", "err", err.Error())
+		return ephemeralResponse("Failed to export tickets.")
+	}
+
+	filename := fmt.Sprintf("sre-requests-%s.%s", time.Now().UTC().Format("2006-01-02"), options.Format)
+	fileInfo, appErr := p.API.UploadFile(data, args.ChannelId, filename)
+	if appErr != nil {
+		p.API.LogError("Failed to upload ticket export", "err", appErr.Error())
+		return ephemeralResponse("Failed to upload the export.")
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Exported %d tickets: %s", len(tickets), p.fileLink(fileInfo)))
+}
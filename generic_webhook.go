@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"plugin-test/utils"
+)
+
+// genericWebhookSourcePattern restricts source names to what fits in the webhook URL.
+var genericWebhookSourcePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// genericWebhook is the configuration of a source of the generic webhook, mapping fields of its
+// payloads to the fields of a ticket with JSONPath-style expressions.
+type genericWebhook struct {
+	// Token must be sent by the source as a bearer token.
+	Token string `json:"token"`
+
+	// Summary, Description and Priority select the ticket fields in the payload. Only Summary is
+	// required.
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+
+	// Priorities maps the values selected by Priority, case-insensitively, to ticket priorities.
+	// Values naming a ticket priority are used as is, and the others default to Low.
+	Priorities map[string]string `json:"priorities"`
+
+	summaryPath     utils.JSONPath
+	descriptionPath utils.JSONPath
+	priorityPath    utils.JSONPath
+}
+
+// parseGenericWebhooks parses GenericWebhooks, a JSON object of the generic webhook sources by
+// name. The sources are never modified once parsed, so they are shared between clones.
+func parseGenericWebhooks(configuration *configuration) (map[string]*genericWebhook, error) {
+	sources := make(map[string]*genericWebhook)
+	if strings.TrimSpace(configuration.GenericWebhooks) == "" {
+		return sources, nil
+	}
+
+	if err := json.Unmarshal([]byte(configuration.GenericWebhooks), &sources); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal generic webhooks")
+	}
+
+	for name, source := range sources {
+		if !genericWebhookSourcePattern.MatchString(name) {
+			return nil, errors.Errorf("invalid generic webhook source %q, expected lowercase letters, digits, - or _", name)
+		}
+		if source == nil || source.Token == "" {
+			return nil, errors.Errorf("missing token for generic webhook source %s", name)
+		}
+
+		var err error
+		if source.summaryPath, err = utils.ParseJSONPath(source.Summary); err != nil {
+			return nil, errors.Wrapf(err, "invalid summary for generic webhook source %s", name)
+		}
+		if source.Description != "" {
+			if source.descriptionPath, err = utils.ParseJSONPath(source.Description); err != nil {
+				return nil, errors.Wrapf(err, "invalid description for generic webhook source %s", name)
+			}
+		}
+		if source.Priority != "" {
+			if source.priorityPath, err = utils.ParseJSONPath(source.Priority); err != nil {
+				return nil, errors.Wrapf(err, "invalid priority for generic webhook source %s", name)
+			}
+		}
+
+		priorities := make(map[string]string, len(source.Priorities))
+		for value, priority := range source.Priorities {
+			matched, ok := matchPriority(priority)
+			if !ok {
+				return nil, errors.Errorf("invalid priority %q for value %q of generic webhook source %s", priority, value, name)
+			}
+			priorities[strings.ToLower(value)] = matched
+		}
+		source.Priorities = priorities
+	}
+
+	return sources, nil
+}
+
+// priority maps the priority selected in a payload to a ticket priority.
+func (s *genericWebhook) priority(value string) string {
+	if priority, ok := s.Priorities[strings.ToLower(value)]; ok {
+		return priority
+	}
+	if priority, ok := matchPriority(value); ok {
+		return priority
+	}
+
+	return ticketPriorityLow
+}
+
+// genericWebhookValue renders the value selected by the path in the payload as text. Objects and
+// arrays are rendered as JSON, and missing or null values as an empty string.
+func genericWebhookValue(payload interface{}, path utils.JSONPath) string {
+	if path == nil {
+		return ""
+	}
+
+	value, ok := path.Lookup(payload)
+	if !ok || value == nil {
+		return ""
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// handleGenericWebhook receives the payloads of the tools the plugin does not natively support,
+// each configured as a source of GenericWebhooks. Every payload opens a ticket in the team named
+// by the team query parameter, with the fields selected by the source's mappings.
+func (p *Plugin) handleGenericWebhook(w http.ResponseWriter, r *http.Request) {
+	sourceName := mux.Vars(r)["source"]
+	source := p.getConfiguration().genericWebhooks[sourceName]
+	if source == nil {
+		http.Error(w, "Unknown webhook source", http.StatusNotFound)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+source.Token)) != 1 {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	team, appErr := p.API.GetTeamByName(r.URL.Query().Get("team"))
+	if appErr != nil {
+		http.Error(w, "Unknown team", http.StatusBadRequest)
+		return
+	}
+	if _, ok := p.getConfiguration().demoChannelIDs[team.Id]; !ok || !p.teamEnabled(team.Id) {
+		http.Error(w, "SRE requests are not enabled in this team", http.StatusBadRequest)
+		return
+	}
+
+	var payload interface{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	summary := genericWebhookValue(payload, source.summaryPath)
+	if summary == "" {
+		http.Error(w, fmt.Sprintf("The payload has no summary at %s", source.Summary), http.StatusBadRequest)
+		return
+	}
+
+	ticket := &Ticket{
+		TeamID:      team.Id,
+		ReporterID:  p.botID,
+		Summary:     fmt.Sprintf("[%s] %s", sourceName, summary),
+		Description: genericWebhookValue(payload, source.descriptionPath),
+		Priority:    source.priority(genericWebhookValue(payload, source.priorityPath)),
+	}
+	if err := p.createTicket(ticket); err != nil {
+		p.API.LogError("Failed to create ticket from generic webhook", "source", sourceName, "err", err.Error())
+		http.Error(w, "Failed to create ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		p.API.LogError("Failed to notify responders", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]string{"ticket_id": ticket.ID}); err != nil {
+		p.API.LogError("Failed to write generic webhook response", "err", err.Error())
+	}
+}
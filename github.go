@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// githubAPIURL is the base URL of the GitHub REST API.
+	githubAPIURL = "https://api.github.com"
+
+	// githubStatusLabelPrefix prefixes the labels mirroring the ticket status on GitHub issues.
+	githubStatusLabelPrefix = "status: "
+
+	// githubIssueKeyPrefix prefixes the KV index from a GitHub issue number to the ticket it
+	// mirrors.
+	githubIssueKeyPrefix = "github_issue_"
+)
+
+func githubIssueKey(issueNumber int) string {
+	return fmt.Sprintf("%s%d", githubIssueKeyPrefix, issueNumber)
+}
+
+// githubStatusLabel returns the label mirroring the ticket status on GitHub issues.
+func githubStatusLabel(status string) string {
+	return githubStatusLabelPrefix + strings.ToLower(status)
+}
+
+// githubIssue is the subset of a GitHub issue used by the integration.
+type githubIssue struct {
+	Number  int    `json:"number"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	Labels  []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// githubAppToken caches the installation access token of the GitHub App, which is valid for an
+// hour.
+type githubAppToken struct {
+	lock           sync.Mutex
+	installationID string
+	token          string
+	expireAt       time.Time
+}
+
+// isGitHubConfigured reports whether the optional GitHub integration is enabled, authenticating
+// either with a personal access token or as a GitHub App installation.
+func (c *configuration) isGitHubConfigured() bool {
+	return c.GitHubRepository != "" && (c.GitHubToken != "" || c.githubAppKey != nil)
+}
+
+// parseGitHubAppKey parses the PEM private key of the GitHub App, returning nil if the App is not
+// configured.
+func parseGitHubAppKey(configuration *configuration) (*rsa.PrivateKey, error) {
+	if configuration.GitHubAppID == "" || configuration.GitHubAppInstallationID == "" || configuration.GitHubAppPrivateKey == "" {
+		return nil, nil
+	}
+
+	block, _ := pem.Decode([]byte(configuration.GitHubAppPrivateKey))
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse GitHub App private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// githubAppJWT returns the short-lived JSON Web Token authenticating as the GitHub App.
+func githubAppJWT(appID string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	// Backdate the token to allow for clock drift, as recommended by GitHub.
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign GitHub App token")
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// githubToken returns the token authenticating GitHub API requests: the personal access token if
+// one is configured, or else an installation access token of the GitHub App.
+func (p *Plugin) githubToken() (string, error) {
+	configuration := p.getConfiguration()
+	if configuration.GitHubToken != "" {
+		return configuration.GitHubToken, nil
+	}
+
+	p.githubAppToken.lock.Lock()
+	defer p.githubAppToken.lock.Unlock()
+
+	// Renew the token a few minutes early, so that it doesn't expire during a request.
+	if p.githubAppToken.installationID == configuration.GitHubAppInstallationID && time.Now().Add(5*time.Minute).Before(p.githubAppToken.expireAt) {
+		return p.githubAppToken.token, nil
+	}
+
+	jwt, err := githubAppJWT(configuration.GitHubAppID, configuration.githubAppKey, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("/app/installations/%s/access_tokens", configuration.GitHubAppInstallationID)
+	if err := p.doGitHubRequest(http.MethodPost, path, "Bearer "+jwt, nil, &token); err != nil {
+		return "", errors.Wrap(err, "failed to get GitHub App installation token")
+	}
+
+	p.githubAppToken.installationID = configuration.GitHubAppInstallationID
+	p.githubAppToken.token = token.Token
+	p.githubAppToken.expireAt = token.ExpiresAt
+
+	return token.Token, nil
+}
+
+// githubRequest sends an authenticated request to the GitHub API, decoding the JSON response into
+// out if it is not nil.
+func (p *Plugin) githubRequest(method, path string, body, out interface{}) error {
+	// Reads have no side effect, so they are made even in dry-run mode.
+	if method != http.MethodGet {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		if p.interceptDryRun("GitHub", method+" "+githubAPIURL+path, data) {
+			return errDryRun
+		}
+	}
+
+	token, err := p.githubToken()
+	if err != nil {
+		return err
+	}
+
+	return p.doGitHubRequest(method, path, "Bearer "+token, body, out)
+}
+
+func (p *Plugin) doGitHubRequest(method, path, authorization string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return errors.Wrap(err, "failed to marshal GitHub request")
+		}
+	}
+
+	url := githubAPIURL + path
+	if err := p.validateExternalURL(url, "GitHub"); err != nil {
+		return err
+	}
+	p.countUsage("integration: github")
+	client := newExternalHTTPClient(p.getConfiguration().allowedNetworks)
+
+	response, err := doWithRetry(client, func() (*http.Request, error) {
+		request, err := http.NewRequest(method, url, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Accept", "application/vnd.github+json")
+		request.Header.Set("Authorization", authorization)
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		return request, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to call GitHub")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return errors.Errorf("GitHub responded with %s: %s", response.Status, responseBody)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+			return errors.Wrap(err, "failed to decode GitHub response")
+		}
+	}
+
+	return nil
+}
+
+// createGitHubIssue creates a GitHub issue mirroring the ticket.
+func (p *Plugin) createGitHubIssue(ticket *Ticket) (*githubIssue, error) {
+	var issue githubIssue
+	if err := p.githubRequest(http.MethodPost, fmt.Sprintf("/repos/%s/issues", p.getConfiguration().GitHubRepository), map[string]interface{}{
+		"title":  ticket.Summary,
+		"body":   fmt.Sprintf("%s\n\nPriority: %s\nReported by: %s", ticket.Description, ticket.Priority, p.reporterMention(ticket)),
+		"labels": []string{githubStatusLabel(ticket.Status)},
+	}, &issue); err != nil {
+		return nil, errors.Wrap(err, "failed to create GitHub issue")
+	}
+
+	return &issue, nil
+}
+
+// linkGitHubIssue creates the GitHub issue of a newly submitted ticket, then records it on the
+// ticket and links it from the ticket's thread. Confidential tickets are never mirrored to GitHub.
+func (p *Plugin) linkGitHubIssue(ticket *Ticket) {
+	if ticket.Confidential || !p.getConfiguration().isGitHubConfigured() {
+		return
+	}
+
+	issue, err := p.createGitHubIssue(ticket)
+	if isDryRun(err) {
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to create GitHub issue", "ticket_id", ticket.ID, "err", err.Error())
+		return
+	}
+
+	if _, err := p.client.KV.Set(githubIssueKey(issue.Number), ticket.ID); err != nil {
+		p.API.LogError("Failed to save GitHub issue index", "ticket_id", ticket.ID, "err", err.Error())
+		return
+	}
+
+	// The ticket may have changed while the issue was being created, so the issue is recorded on
+	// the ticket as it was saved last.
+	ticketID := ticket.ID
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.GitHubIssueNumber = issue.Number
+		ticket.GitHubIssueURL = issue.HTMLURL
+		recordTicketActivity(ticket, timelineEventSync, "", fmt.Sprintf("Mirrored to GitHub issue %s#%d", p.getConfiguration().GitHubRepository, issue.Number))
+		return nil
+	})
+	if err != nil {
+		p.API.LogError("Failed to save GitHub issue", "ticket_id", ticketID, "issue_number", issue.Number, "err", err.Error())
+		return
+	}
+
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	if err := p.postTicketReply(ticket, fmt.Sprintf(":link: Mirrored to GitHub issue [%s#%d](%s).", p.getConfiguration().GitHubRepository, issue.Number, issue.HTMLURL)); err != nil {
+		p.API.LogWarn("Failed to post GitHub issue link", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// The ticket may have changed status while the issue was being created.
+	if ticket.Status != ticketStatusOpen {
+		p.syncGitHubIssue(ticket)
+	}
+}
+
+// syncGitHubIssue mirrors the ticket status to the status label and state of its GitHub issue,
+// keeping the issue's other labels.
+func (p *Plugin) syncGitHubIssue(ticket *Ticket) {
+	if ticket.GitHubIssueNumber == 0 || !p.getConfiguration().isGitHubConfigured() {
+		return
+	}
+
+	path := fmt.Sprintf("/repos/%s/issues/%d", p.getConfiguration().GitHubRepository, ticket.GitHubIssueNumber)
+
+	var issue githubIssue
+	if err := p.githubRequest(http.MethodGet, path, nil, &issue); err != nil {
+		p.API.LogError("Failed to get GitHub issue", "ticket_id", ticket.ID, "err", err.Error())
+		return
+	}
+
+	labels := []string{githubStatusLabel(ticket.Status)}
+	for _, label := range issue.Labels {
+		if !strings.HasPrefix(label.Name, githubStatusLabelPrefix) {
+			labels = append(labels, label.Name)
+		}
+	}
+
+	state := "open"
+	if ticket.Status == ticketStatusResolved {
+		state = "closed"
+	}
+
+	if err := p.githubRequest(http.MethodPatch, path, map[string]interface{}{
+		"state":  state,
+		"labels": labels,
+	}, nil); err != nil && !isDryRun(err) {
+		p.API.LogError("Failed to update GitHub issue", "ticket_id", ticket.ID, "err", err.Error())
+	}
+}
+
+// getGitHubIssueTicket returns the ticket mirrored by the GitHub issue with the given number, or
+// nil if there is none.
+func (p *Plugin) getGitHubIssueTicket(issueNumber int) (*Ticket, error) {
+	var ticketID string
+	if err := p.client.KV.Get(githubIssueKey(issueNumber), &ticketID); err != nil {
+		return nil, errors.Wrap(err, "failed to get GitHub issue index")
+	}
+	if ticketID == "" {
+		return nil, nil
+	}
+
+	return p.getTicket(ticketID)
+}
+
+// githubWebhookPayload is the subset of the body of a GitHub issues webhook used by the
+// integration.
+type githubWebhookPayload struct {
+	Action string      `json:"action"`
+	Issue  githubIssue `json:"issue"`
+	Label  struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// githubStatus returns the ticket status the issues webhook sets, or an empty string if the
+// webhook doesn't change the status.
+func (payload *githubWebhookPayload) githubStatus() string {
+	switch payload.Action {
+	case "closed":
+		return ticketStatusResolved
+	case "reopened":
+		return ticketStatusOpen
+	case "labeled":
+		for _, status := range []string{ticketStatusOpen, ticketStatusAcknowledged, ticketStatusWaitingOnReporter, ticketStatusResolved} {
+			if payload.Label.Name == githubStatusLabel(status) {
+				return status
+			}
+		}
+	}
+
+	return ""
+}
+
+// handleGitHubWebhook receives the issues webhooks of the mirrored repository and applies status
+// changes made on GitHub to the tickets. Since it changes tickets, it only accepts webhooks signed
+// with the WebhookSigningSecret, which verifyWebhookSignature checks.
+func (p *Plugin) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	configuration := p.getConfiguration()
+	if !configuration.isGitHubConfigured() || configuration.WebhookSigningSecret == "" {
+		http.Error(w, "The GitHub webhook is not enabled", http.StatusNotFound)
+		return
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "issues" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var payload githubWebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		p.API.LogError("Failed to decode GitHub webhook payload", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	status := payload.githubStatus()
+	if status == "" || !strings.EqualFold(payload.Repository.FullName, configuration.GitHubRepository) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ticket, err := p.getGitHubIssueTicket(payload.Issue.Number)
+	if err != nil {
+		p.API.LogError("Failed to get ticket for GitHub issue", "issue_number", payload.Issue.Number, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if ticket == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Changes mirrored from the ticket echo back as webhooks, which leave the status unchanged.
+	ticketID := ticket.ID
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.Status == status {
+			return errTicketUnchanged
+		}
+		setTicketStatus(ticket, status, p.botID)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to save ticket", "ticket_id", ticketID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	message := fmt.Sprintf(":link: GitHub issue [#%d](%s) changed the status of this request to %s.", ticket.GitHubIssueNumber, ticket.GitHubIssueURL, status)
+	if err := p.postTicketReply(ticket, message); err != nil {
+		p.API.LogWarn("Failed to post GitHub status change", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Sending the event also replaces the previous status label of the issue.
+	switch status {
+	case ticketStatusOpen:
+		p.sendTicketEvent(ticketEventResumed, ticket, "")
+	case ticketStatusAcknowledged:
+		p.sendTicketEvent(ticketEventAcknowledged, ticket, "")
+	case ticketStatusWaitingOnReporter:
+		p.sendTicketEvent(ticketEventWaiting, ticket, "")
+	case ticketStatusResolved:
+		p.sendTicketEvent(ticketEventResolved, ticket, "")
+		p.proposePostmortemReview(ticket)
+		p.sendSatisfactionSurvey(ticket)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// grafanaStateOK is the state of the notification sent by Grafana once every alert of the group
+// is back to normal.
+const grafanaStateOK = "ok"
+
+// grafanaPayload is the body of a Grafana unified alerting webhook notification, which extends
+// the Alertmanager webhook contract.
+type grafanaPayload struct {
+	Status  string         `json:"status"`
+	State   string         `json:"state"`
+	Title   string         `json:"title"`
+	Message string         `json:"message"`
+	Alerts  []grafanaAlert `json:"alerts"`
+}
+
+// grafanaAlert is an alert of a Grafana notification, with the links to its dashboard and panel
+// and the URL of the panel snapshot, when Grafana is configured to take screenshots.
+type grafanaAlert struct {
+	alertmanagerAlert
+
+	DashboardURL string `json:"dashboardURL"`
+	PanelURL     string `json:"panelURL"`
+	SilenceURL   string `json:"silenceURL"`
+	ImageURL     string `json:"imageURL"`
+	ValueString  string `json:"valueString"`
+}
+
+// verifyGrafanaToken rejects the Grafana webhooks not authenticated with the GrafanaToken. It runs
+// before deliveries are deduplicated, so that unauthenticated requests cannot claim delivery ids.
+func (p *Plugin) verifyGrafanaToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := p.getConfiguration().GrafanaToken
+		if token == "" {
+			http.Error(w, "The Grafana webhook is not enabled", http.StatusNotFound)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGrafana receives Grafana unified alerting webhook notifications. Like Alertmanager alerts,
+// every firing alert opens a ticket, unless its fingerprint is already tracked by an unresolved
+// ticket, and the ticket is resolved once Grafana reports the alert as resolved or the whole
+// notification as OK. Tickets are filed in the team named by the team query parameter.
+func (p *Plugin) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	team, appErr := p.API.GetTeamByName(r.URL.Query().Get("team"))
+	if appErr != nil {
+		http.Error(w, "Unknown team", http.StatusBadRequest)
+		return
+	}
+	if _, ok := p.getConfiguration().demoChannelIDs[team.Id]; !ok || !p.teamEnabled(team.Id) {
+		http.Error(w, "SRE requests are not enabled in this team", http.StatusBadRequest)
+		return
+	}
+
+	var payload grafanaPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		p.API.LogError("Failed to decode Grafana payload", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	allOK := strings.EqualFold(payload.State, grafanaStateOK)
+
+	failed := false
+	for _, alert := range payload.Alerts {
+		if alert.Fingerprint == "" {
+			continue
+		}
+
+		var err error
+		switch {
+		case allOK || alert.Status == alertStatusResolved:
+			err = p.resolveAlertTicket(alert.alertmanagerAlert)
+		case alert.Status == alertStatusFiring:
+			err = p.openAlertTicket(team.Id, alert.alertmanagerAlert, p.grafanaAttachment(alert))
+		}
+		if err != nil {
+			p.API.LogError("Failed to process Grafana alert", "fingerprint", alert.Fingerprint, "err", err.Error())
+			failed = true
+		}
+	}
+
+	// Let Grafana retry the notification, which is safe since alerts are tracked by fingerprint.
+	if failed {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// grafanaAttachment renders the alert like an Alertmanager alert, linking its panel or dashboard
+// and embedding the panel snapshot. Links that are unsafe to render are left out.
+func (p *Plugin) grafanaAttachment(alert grafanaAlert) *model.SlackAttachment {
+	attachment := alertAttachment(alert.alertmanagerAlert)
+
+	if alert.ValueString != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: "Values",
+			Value: alert.ValueString,
+		})
+	}
+
+	for _, link := range []string{alert.PanelURL, alert.DashboardURL} {
+		if link != "" && p.validateExternalURL(link, "Grafana panel") == nil {
+			attachment.TitleLink = link
+			break
+		}
+	}
+
+	if alert.ImageURL != "" && p.validateExternalURL(alert.ImageURL, "Grafana panel snapshot") == nil {
+		attachment.ImageURL = alert.ImageURL
+	}
+
+	return attachment
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ticketFieldPriority = "priority"
+	ticketFieldAssignee = "assignee"
+	ticketFieldStatus   = "status"
+)
+
+// TicketChange is a change of one of a ticket's tracked fields. Assignees are recorded as user ids.
+type TicketChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+	UserID   string `json:"user_id,omitempty"`
+	CreateAt int64  `json:"create_at"`
+
+	// Reason is the justification given for the change, if any.
+	Reason string `json:"reason,omitempty"`
+}
+
+// recordTicketChange appends the change of a field to the ticket's history, unless the value is
+// unchanged.
+func recordTicketChange(ticket *Ticket, field, oldValue, newValue, userID string, createAt int64) {
+	if oldValue == newValue {
+		return
+	}
+
+	ticket.History = append(ticket.History, &TicketChange{
+		Field:    field,
+		OldValue: oldValue,
+		NewValue: newValue,
+		UserID:   userID,
+		CreateAt: createAt,
+	})
+}
+
+// setTicketPriority changes the ticket's priority on behalf of userID, recording the change.
+func setTicketPriority(ticket *Ticket, priority, userID string, now int64) {
+	recordTicketChange(ticket, ticketFieldPriority, ticket.Priority, priority, userID, now)
+	ticket.Priority = priority
+}
+
+// setTicketAssignee changes the ticket's assignee on behalf of userID, recording the change.
+func setTicketAssignee(ticket *Ticket, assigneeID, userID string, now int64) {
+	recordTicketChange(ticket, ticketFieldAssignee, ticket.AssigneeID, assigneeID, userID, now)
+	ticket.AssigneeID = assigneeID
+}
+
+// describeChange renders a change in a sentence, with values formatted by formatValue.
+func (p *Plugin) describeChange(change *TicketChange, formatValue func(field, value string) string) string {
+	actor := "The plugin"
+	if change.UserID != "" && change.UserID != p.botID {
+		actor = p.mentionUser(change.UserID)
+	}
+
+	description := fmt.Sprintf("%s changed %s from %s to %s", actor, change.Field, formatValue(change.Field, change.OldValue), formatValue(change.Field, change.NewValue))
+	if change.Reason != "" {
+		description += fmt.Sprintf(" (%s)", change.Reason)
+	}
+
+	return description
+}
+
+// formatChangeValue formats the value of a change for display, mentioning assignees.
+func (p *Plugin) formatChangeValue(field, value string) string {
+	switch {
+	case value == "":
+		return "_none_"
+	case field == ticketFieldAssignee:
+		return p.mentionUser(value)
+	default:
+		return fmt.Sprintf("**%s**", value)
+	}
+}
+
+// formatExportChangeValue formats the value of a change for exports, naming assignees by username.
+func (p *Plugin) formatExportChangeValue(field, value string) string {
+	switch {
+	case value == "":
+		return "none"
+	case field == ticketFieldAssignee:
+		return "@" + p.username(value)
+	default:
+		return value
+	}
+}
+
+// sendHistoryView shows the user an ephemeral timeline of the ticket's field changes.
+func (p *Plugin) sendHistoryView(ticket *Ticket) (string, error) {
+	if len(ticket.History) == 0 {
+		return "No changes have been made to this request yet.", nil
+	}
+
+	lines := []string{fmt.Sprintf("#### History: %s", ticket.Summary)}
+	for _, change := range ticket.History {
+		lines = append(lines, fmt.Sprintf("- %s: %s.", time.UnixMilli(change.CreateAt).UTC().Format("Mon Jan 2 15:04 MST"), p.describeChange(change, p.formatChangeValue)))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// exportHistory renders the ticket's history on a single line for CSV exports.
+func (p *Plugin) exportHistory(ticket *Ticket) string {
+	var changes []string
+	for _, change := range ticket.History {
+		changes = append(changes, fmt.Sprintf("%s %s", formatExportTime(change.CreateAt), p.describeChange(change, p.formatExportChangeValue)))
+	}
+
+	return strings.Join(changes, "; ")
+}
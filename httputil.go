@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"plugin-test/utils"
+)
+
+const (
+	// httpRetryAttempts is the number of attempts made for requests to external systems.
+	httpRetryAttempts = 4
+
+	// httpRetryBaseDelay is the delay before the first retry, doubled after every attempt.
+	httpRetryBaseDelay = 500 * time.Millisecond
+)
+
+// newExternalHTTPClient returns a client for requests to external systems. It refuses to connect to
+// internal addresses outside of the allowed networks, including hosts that only resolve to an
+// internal address once the request is made.
+func newExternalHTTPClient(allowedNetworks []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !utils.IsAllowedIP(ip, allowedNetworks) {
+				return errors.Errorf("connecting to the internal address %s is not allowed", host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+}
+
+// validateExternalURL checks that a URL provided by a user, an integration or an admin is safe to
+// fetch or render, logging violations.
+func (p *Plugin) validateExternalURL(rawURL, purpose string) error {
+	if err := utils.ValidateURL(rawURL, p.getConfiguration().allowedNetworks); err != nil {
+		p.API.LogWarn("Rejected unsafe URL", "purpose", purpose, "url", rawURL, "err", err.Error())
+		return errors.Wrapf(err, "unsafe %s URL", purpose)
+	}
+
+	return nil
+}
+
+// doWithRetry sends the request built by newRequest, retrying with exponential backoff on network
+// errors and on transient HTTP failures (429 and 5xx). newRequest is called for every attempt so
+// that request bodies can be replayed.
+func doWithRetry(client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	delay := httpRetryBaseDelay
+
+	var lastErr error
+	for attempt := 1; attempt <= httpRetryAttempts; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build request")
+		}
+
+		response, err := client.Do(request)
+		switch {
+		case err != nil:
+			lastErr = err
+		case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError:
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			lastErr = errors.Errorf("transient HTTP failure: %s", response.Status)
+		default:
+			return response, nil
+		}
+
+		if attempt < httpRetryAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return nil, errors.Wrapf(lastErr, "request failed after %d attempts", httpRetryAttempts)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	dialogElementNameCategory = "category"
+
+	intakeCategoryPipeline = "pipeline"
+	intakeCategoryInfra    = "infra"
+	intakeCategoryAccess   = "access"
+)
+
+// intakeCategory is a kind of SRE request, with the fields asked in the second step of the
+// built-in intake flow.
+type intakeCategory struct {
+	Name        string
+	DisplayName string
+	Elements    []model.DialogElement
+}
+
+// intakeCategories are the categories offered by the first step of the built-in intake flow.
+var intakeCategories = []intakeCategory{{
+	Name:        intakeCategoryPipeline,
+	DisplayName: "Pipeline failure",
+	Elements: []model.DialogElement{{
+		DisplayName: "Pipeline URL",
+		Name:        "pipeline_url",
+		Type:        "text",
+		SubType:     "url",
+		Placeholder: "https://ci.example.com/pipelines/123",
+	}, {
+		DisplayName: "Failing stage",
+		Name:        "failing_stage",
+		Type:        "text",
+		Placeholder: "build, test, deploy...",
+		Optional:    true,
+	}, {
+		DisplayName: "Last successful run",
+		Name:        "last_successful_run",
+		Type:        "text",
+		Placeholder: "Link or commit of the last green run",
+		Optional:    true,
+	}},
+}, {
+	Name:        intakeCategoryInfra,
+	DisplayName: "Infrastructure outage",
+	Elements: []model.DialogElement{{
+		DisplayName: "Environment",
+		Name:        "environment",
+		Type:        "select",
+		Default:     "production",
+		Options: []*model.PostActionOptions{
+			{Text: "Production", Value: "production"},
+			{Text: "Staging", Value: "staging"},
+			{Text: "Development", Value: "development"},
+		},
+	}, {
+		DisplayName: "Started at",
+		Name:        "started_at",
+		Type:        "text",
+		Placeholder: "When did it start?",
+		Optional:    true,
+	}},
+}, {
+	Name:        intakeCategoryAccess,
+	DisplayName: "Access request",
+	Elements: []model.DialogElement{{
+		DisplayName: "System",
+		Name:        "system",
+		Type:        "text",
+		Placeholder: "Which system or resource?",
+	}, {
+		DisplayName: "Access level",
+		Name:        "access_level",
+		Type:        "select",
+		Default:     "read",
+		Options: []*model.PostActionOptions{
+			{Text: "Read", Value: "read"},
+			{Text: "Write", Value: "write"},
+			{Text: "Admin", Value: "admin"},
+		},
+	}, {
+		DisplayName: "Justification",
+		Name:        "justification",
+		Type:        "textarea",
+		Placeholder: "Why is the access needed, and for how long?",
+		MaxLength:   1000,
+	}},
+}}
+
+// getIntakeCategory returns the intake category with the given name.
+func getIntakeCategory(name string) (intakeCategory, bool) {
+	for _, category := range intakeCategories {
+		if category.Name == name {
+			return category, true
+		}
+	}
+
+	return intakeCategory{}, false
+}
+
+// intakeState is carried between the steps of the intake flow in the dialog state.
+type intakeState struct {
+	Category string `json:"category,omitempty"`
+	Priority string `json:"priority,omitempty"`
+
+	// Force skips duplicate detection, once the user confirmed the submission.
+	Force bool `json:"force,omitempty"`
+
+	// Prefill holds the values pre-filled in the second step, such as the message a ticket was
+	// suggested for.
+	Prefill map[string]interface{} `json:"prefill,omitempty"`
+}
+
+func (s intakeState) encode() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// decodeIntakeState decodes the state of an intake dialog, ignoring invalid states.
+func decodeIntakeState(state string) intakeState {
+	var decoded intakeState
+	if state != "" {
+		_ = json.Unmarshal([]byte(state), &decoded)
+	}
+
+	return decoded
+}
+
+// getCategoryDialog returns the first step of the built-in intake flow, asking for the category
+// and priority of the request, translated by the localizer.
+func getCategoryDialog(localizer *i18n.Localizer) model.Dialog {
+	var options []*model.PostActionOptions
+	for _, category := range intakeCategories {
+		options = append(options, &model.PostActionOptions{
+			Text:  category.localizedName(localizer),
+			Value: category.Name,
+		})
+	}
+
+	return localizeDialog(localizer, model.Dialog{
+		CallbackId: "srerequest_category",
+		Title:      "SRE Request",
+		Elements: []model.DialogElement{{
+			DisplayName: "Category",
+			Name:        dialogElementNameCategory,
+			Type:        "select",
+			Placeholder: "What kind of request is it?",
+			Options:     options,
+		}, priorityDialogElement()},
+		SubmitLabel: "Next",
+	}, "dialog.srerequest.title")
+}
+
+// localizedName returns the display name of the category in the localizer's language.
+func (c intakeCategory) localizedName(localizer *i18n.Localizer) string {
+	return localize(localizer, &i18n.Message{ID: "intake.category." + c.Name, Other: c.DisplayName}, nil)
+}
+
+// openIntakeDialog starts the intake flow, with the second step pre-filled with the given values.
+// The built-in flow first asks for the category of the request, while admin-defined forms are
+// opened directly.
+func (p *Plugin) openIntakeDialog(triggerID, teamID, userID string, prefill map[string]interface{}) *model.AppError {
+	localizer := p.userLocalizer(userID)
+	if p.getConfiguration().dialog != nil {
+		return p.openTicketDialog(triggerID, teamID, prefillDialog(p.getTicketDialog("", localizer), prefill))
+	}
+
+	dialog := getCategoryDialog(localizer)
+	dialog.State = intakeState{Prefill: prefill}.encode()
+
+	return p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       pluginCallbackURL("/dialog/sre/category"),
+		Dialog:    dialog,
+	})
+}
+
+// handleCategoryDialog receives the first step of the intake flow. Dialog submissions cannot open
+// another dialog, so the user gets a button opening the category-specific second step.
+func (p *Plugin) handleCategoryDialog(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.SubmitDialogRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode SubmitDialogRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if request.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The request names its user, but only the header set by the server can be trusted.
+	if request.UserId != userID {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !p.teamEnabled(request.TeamId) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "The plugin is disabled in this team.",
+		})
+		return
+	}
+
+	if !p.getConfiguration().commandAllowedIn(request.TeamId, request.ChannelId) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: p.commandRedirectMessage(request.TeamId),
+		})
+		return
+	}
+
+	categoryName, _ := request.Submission[dialogElementNameCategory].(string)
+	category, ok := getIntakeCategory(categoryName)
+	if !ok {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameCategory: localize(p.userLocalizer(userID), &i18n.Message{ID: "intake.category_required", Other: "Select the category of the request"}, nil),
+			},
+		})
+		return
+	}
+
+	state := decodeIntakeState(request.State)
+	state.Category = category.Name
+	state.Priority, _ = request.Submission[dialogElementNamePriority].(string)
+
+	localizer := p.userLocalizer(userID)
+	p.API.SendEphemeralPost(userID, &model.Post{
+		UserId:    p.botID,
+		ChannelId: request.ChannelId,
+		Message: localize(localizer, &i18n.Message{
+			ID:    "intake.continue",
+			Other: "Almost done: continue with the details of your {{.Category}}.",
+		}, map[string]interface{}{"Category": category.localizedName(localizer)}),
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{{
+				Actions: []*model.PostAction{{
+					Type:  model.PostActionTypeButton,
+					Name:  localize(localizer, &i18n.Message{ID: "intake.continue_button", Other: "Continue"}, nil),
+					Style: "primary",
+					Integration: &model.PostActionIntegration{
+						URL: pluginCallbackURL("/interactive/sre/details"),
+						Context: model.StringInterface{
+							"state": state.encode(),
+						},
+					},
+				}},
+			}},
+		},
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleIntakeDetails opens the category-specific second step of the intake flow.
+func (p *Plugin) handleIntakeDetails(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.PostActionIntegrationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode PostActionIntegrationRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if request.UserId != userID {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !p.teamEnabled(request.TeamId) {
+		p.writeJSON(w, &model.PostActionIntegrationResponse{
+			EphemeralText: "The plugin is disabled in this team.",
+		})
+		return
+	}
+
+	encodedState, _ := request.Context["state"].(string)
+	state := decodeIntakeState(encodedState)
+
+	dialog := prefillDialog(p.getTicketDialog(state.Category, p.userLocalizer(userID)), state.Prefill)
+	state.Prefill = nil
+	dialog.State = state.encode()
+
+	if appErr := p.openTicketDialog(request.TriggerId, request.TeamId, dialog); appErr != nil {
+		p.API.LogError("Failed to open dialog", "err", appErr.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, &model.PostActionIntegrationResponse{})
+}
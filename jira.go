@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jiraIssueType is the type of the Jira issues created for tickets.
+const jiraIssueType = "Task"
+
+// isJiraConfigured reports whether the optional Jira integration is enabled.
+func (c *configuration) isJiraConfigured() bool {
+	return c.JiraBaseURL != "" && c.JiraProjectKey != "" && c.JiraAPIToken != ""
+}
+
+// jiraIssueURL returns the browse URL of the given Jira issue.
+func (c *configuration) jiraIssueURL(issueKey string) string {
+	return fmt.Sprintf("%s/browse/%s", strings.TrimSuffix(c.JiraBaseURL, "/"), issueKey)
+}
+
+// createJiraIssue creates a Jira issue mirroring the ticket and returns its key.
+func (p *Plugin) createJiraIssue(ticket *Ticket) (string, error) {
+	configuration := p.getConfiguration()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": configuration.JiraProjectKey},
+			"issuetype":   map[string]string{"name": jiraIssueType},
+			"summary":     ticket.Summary,
+			"description": fmt.Sprintf("%s\n\nPriority: %s\nReported by: %s", ticket.Description, ticket.Priority, p.reporterMention(ticket)),
+		},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal Jira issue")
+	}
+
+	url := strings.TrimSuffix(configuration.JiraBaseURL, "/") + "/rest/api/2/issue"
+	if err := p.validateExternalURL(url, "Jira"); err != nil {
+		return "", err
+	}
+	if p.interceptDryRun("Jira", "POST "+url, body) {
+		return "", errDryRun
+	}
+	p.countUsage("integration: jira")
+	client := newExternalHTTPClient(configuration.allowedNetworks)
+
+	response, err := doWithRetry(client, func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		// Jira Cloud authenticates with an account email and API token, while Jira Data Center
+		// accepts personal access tokens as bearer tokens.
+		if configuration.JiraUsername != "" {
+			request.SetBasicAuth(configuration.JiraUsername, configuration.JiraAPIToken)
+		} else {
+			request.Header.Set("Authorization", "Bearer "+configuration.JiraAPIToken)
+		}
+
+		return request, nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create Jira issue")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", errors.Errorf("failed to create Jira issue: %s: %s", response.Status, responseBody)
+	}
+
+	var issue struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&issue); err != nil {
+		return "", errors.Wrap(err, "failed to decode Jira issue")
+	}
+
+	return issue.Key, nil
+}
+
+// linkJiraIssue creates the Jira issue of a newly submitted ticket, then records its key on the
+// ticket and links it from the ticket post. Confidential tickets are never mirrored to Jira.
+func (p *Plugin) linkJiraIssue(ticket *Ticket) {
+	if ticket.Confidential || !p.getConfiguration().isJiraConfigured() {
+		return
+	}
+
+	issueKey, err := p.createJiraIssue(ticket)
+	if isDryRun(err) {
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to create Jira issue", "ticket_id", ticket.ID, "err", err.Error())
+		return
+	}
+
+	// The ticket may have changed while the issue was being created, so the key is recorded on the
+	// ticket as it was saved last.
+	ticketID := ticket.ID
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.JiraIssueKey = issueKey
+		recordTicketActivity(ticket, timelineEventSync, "", fmt.Sprintf("Mirrored to Jira issue %s", issueKey))
+		return nil
+	})
+	if err != nil {
+		p.API.LogError("Failed to save Jira issue key", "ticket_id", ticketID, "issue_key", issueKey, "err", err.Error())
+		return
+	}
+
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogError("Failed to link Jira issue from ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+// BackgroundJob runs periodically on only one plugin instance at a time. It announces the tickets
+// queued outside business hours, escalates tickets that breached their SLA or reached a tier of
+// their escalation policy, reminds responders of
+// due dates and postmortem reviews, alerts on sustained dependency failures and restores the
+// routing of repaired SRE channels.
+func (p *Plugin) BackgroundJob() {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled {
+		return
+	}
+
+	// Checking the load shedder closes it once the server recovered, even when nothing else does.
+	p.sheddingLoad()
+
+	p.announceQueuedTickets()
+	p.checkSLAs()
+	p.checkEscalationPolicies()
+	p.checkDueDates()
+	p.checkPostmortemReviews()
+	p.monitorDependencies()
+	p.repairChannelRoutes()
+}
+
+// RetentionJob runs periodically on only one plugin instance at a time. It purges the tickets whose
+// trash retention period expired and the expired usage counters.
+func (p *Plugin) RetentionJob() {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled {
+		return
+	}
+
+	p.purgeTrash()
+	p.cleanUpUsageCounters()
+}
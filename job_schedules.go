@@ -0,0 +1,300 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// minJobInterval is the shortest interval a job may be scheduled at, which is also the
+	// resolution of cron expressions.
+	minJobInterval = time.Minute
+
+	// cronSearchHorizon bounds how far ahead the next time matching a cron expression is searched,
+	// so that expressions matching no date, such as February 30th, never loop forever.
+	cronSearchHorizon = 5 * 366 * 24 * time.Hour
+)
+
+// jobSchedule is the schedule of a background job configured in JobSchedules.
+type jobSchedule struct {
+	// spec is the schedule as configured, either a duration such as "5m" or a cron expression.
+	spec string
+	wait cluster.NextWaitInterval
+}
+
+// parseJobSchedules parses JobSchedules, a list of "job=schedule" entries separated by semicolons
+// or newlines, into the schedules by job key. Jobs are named as in the jobs command, and schedules
+// are either a duration, such as "5m", or a cron expression in UTC, such as "0 2 * * *".
+func parseJobSchedules(configuration *configuration) (map[string]*jobSchedule, error) {
+	schedules := make(map[string]*jobSchedule)
+	for _, entry := range strings.FieldsFunc(configuration.JobSchedules, func(r rune) bool { return r == ';' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid job schedule %q, expected job=schedule", entry)
+		}
+		job := findBackgroundJob(strings.TrimSpace(name))
+		if job == nil {
+			return nil, errors.Errorf("unknown job %q", strings.TrimSpace(name))
+		}
+		if _, ok := schedules[job.Key]; ok {
+			return nil, errors.Errorf("duplicate schedule for job %s", job.Name)
+		}
+
+		schedule, err := parseJobSchedule(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule for job %s", job.Name)
+		}
+		schedules[job.Key] = schedule
+	}
+
+	return schedules, nil
+}
+
+// parseJobSchedule parses a duration or a cron expression.
+func parseJobSchedule(spec string) (*jobSchedule, error) {
+	if len(strings.Fields(spec)) == 5 {
+		cron, err := parseCronExpression(spec)
+		if err != nil {
+			return nil, err
+		}
+		return &jobSchedule{spec: spec, wait: cron.waitInterval}, nil
+	}
+
+	interval, err := time.ParseDuration(spec)
+	if err != nil {
+		return nil, errors.Errorf("%q is neither a duration nor a cron expression", spec)
+	}
+	if interval < minJobInterval {
+		return nil, errors.Errorf("interval %s is shorter than %s", interval, minJobInterval)
+	}
+
+	return &jobSchedule{spec: spec, wait: cluster.MakeWaitForRoundedInterval(interval)}, nil
+}
+
+// cronExpression is a standard five-field cron expression: minute, hour, day of month, month and
+// day of week, evaluated in UTC.
+type cronExpression struct {
+	minutes, hours, days, months, weekdays map[int]bool
+
+	// anyDay and anyWeekday are set when the day of month or day of week field is *. As in cron,
+	// a day matches either field when both are restricted.
+	anyDay, anyWeekday bool
+}
+
+// parseCronExpression parses the five fields of a cron expression. Fields accept *, values,
+// ranges such as 1-5, steps such as */15 or 0-30/10, and comma-separated lists of them.
+func parseCronExpression(spec string) (*cronExpression, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("cron expression %q must have 5 fields", spec)
+	}
+
+	bounds := []struct {
+		name     string
+		min, max int
+	}{
+		{"minute", 0, 59},
+		{"hour", 0, 23},
+		{"day of month", 1, 31},
+		{"month", 1, 12},
+		{"day of week", 0, 7},
+	}
+
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s field", bounds[i].name)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is either 0 or 7.
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	return &cronExpression{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return nil, errors.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return nil, errors.Errorf("invalid value %q", lowPart)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return nil, errors.Errorf("invalid value %q", highPart)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, errors.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			set[value] = true
+		}
+	}
+
+	return set, nil
+}
+
+// matchesDay reports whether the expression's day fields match the day of t.
+func (c *cronExpression) matchesDay(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// next returns the first minute matching the expression after the given time, or the zero time if
+// none matches within cronSearchHorizon.
+func (c *cronExpression) next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	for limit := t.Add(cronSearchHorizon); t.Before(limit); {
+		switch {
+		case !c.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !c.hours[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// waitInterval schedules a cluster job at the times matching the expression. A run missed while
+// no instance was up runs right away.
+func (c *cronExpression) waitInterval(now time.Time, metadata cluster.JobMetadata) time.Duration {
+	after := metadata.LastFinished
+	if after.IsZero() {
+		after = now
+	}
+
+	next := c.next(after)
+	if next.IsZero() {
+		// The expression matches no date, so check again once a day rather than never.
+		return 24 * time.Hour
+	}
+	if wait := next.Sub(now); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+// scheduledJob is a background job scheduled across the cluster.
+type scheduledJob struct {
+	job *cluster.Job
+
+	// schedule is the schedule the job was scheduled with, or an empty string for its default.
+	schedule string
+}
+
+// jobsScheduled reports whether the background jobs were scheduled since the plugin was activated.
+func (p *Plugin) jobsScheduled() bool {
+	p.jobsLock.Lock()
+	defer p.jobsLock.Unlock()
+
+	return p.jobs != nil
+}
+
+// scheduleJobs schedules the background jobs on the schedules of the configuration, rescheduling
+// the jobs already scheduled whose schedule changed. A rescheduled job keeps the time it last ran,
+// so the new schedule applies from then on.
+func (p *Plugin) scheduleJobs(configuration *configuration) error {
+	p.jobsLock.Lock()
+	defer p.jobsLock.Unlock()
+
+	if p.jobs == nil {
+		p.jobs = make(map[string]*scheduledJob)
+	}
+
+	for _, job := range backgroundJobs {
+		schedule, wait := "", job.defaultWait
+		if jobSchedule, ok := configuration.jobSchedules[job.Key]; ok {
+			schedule, wait = jobSchedule.spec, jobSchedule.wait
+		}
+
+		if scheduled, ok := p.jobs[job.Key]; ok {
+			if scheduled.schedule == schedule {
+				continue
+			}
+			if err := scheduled.job.Close(); err != nil {
+				return errors.Wrapf(err, "failed to close %s job", job.Name)
+			}
+			delete(p.jobs, job.Key)
+		}
+
+		run := job.run
+		clusterJob, err := cluster.Schedule(p.API, job.Key, wait, p.trackedJob(job.Key, func() { run(p) }))
+		if err != nil {
+			return errors.Wrapf(err, "failed to schedule %s job", job.Name)
+		}
+		p.jobs[job.Key] = &scheduledJob{job: clusterJob, schedule: schedule}
+	}
+
+	return nil
+}
+
+// closeJobs stops the scheduled background jobs.
+func (p *Plugin) closeJobs() {
+	p.jobsLock.Lock()
+	defer p.jobsLock.Unlock()
+
+	for key, scheduled := range p.jobs {
+		if err := scheduled.job.Close(); err != nil {
+			p.API.LogError("Failed to close job", "job", key, "err", err.Error())
+		}
+	}
+	p.jobs = nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// jobStatusKeyPrefix prefixes the KV key of the status of each background job.
+	jobStatusKeyPrefix = "job_status_"
+
+	// jobMutexKeyPrefix prefixes the cluster mutex serializing the runs of each background job, so
+	// that manual runs never overlap scheduled ones.
+	jobMutexKeyPrefix = "job_run_"
+)
+
+// backgroundJob describes a job scheduled across the cluster, as listed and run by the jobs
+// command.
+type backgroundJob struct {
+	// Name identifies the job in the jobs command, and Key is its cluster job key, which also
+	// identifies it in failure reports.
+	Name        string
+	Key         string
+	Description string
+
+	// DefaultSchedule describes defaultWait, the schedule of the job unless JobSchedules overrides
+	// it.
+	DefaultSchedule string
+	defaultWait     cluster.NextWaitInterval
+
+	run func(p *Plugin)
+}
+
+// backgroundJobs are the jobs scheduled when the plugin is activated. Ticket reminders run once
+// each, so they are not listed.
+var backgroundJobs = []*backgroundJob{
+	{Name: "sla", Key: "BackgroundJob", Description: "Checks SLAs, escalation policies, due dates and the business hours queue.", DefaultSchedule: "1m", defaultWait: cluster.MakeWaitForRoundedInterval(time.Minute), run: (*Plugin).BackgroundJob},
+	{Name: "email", Key: "EmailBridgeJob", Description: "Polls the email bridge mailbox.", DefaultSchedule: "1m", defaultWait: cluster.MakeWaitForRoundedInterval(time.Minute), run: (*Plugin).EmailBridgeJob},
+	{Name: "rotation", Key: "OnCallRotationJob", Description: "Hands over to the next user of the on-call rotation.", DefaultSchedule: "1h", defaultWait: cluster.MakeWaitForRoundedInterval(time.Hour), run: (*Plugin).OnCallRotationJob},
+	{Name: "digest", Key: "WeeklyDigestJob", Description: "Posts the weekly digest of the tickets.", DefaultSchedule: "weekly", defaultWait: waitForWeeklyDigest, run: (*Plugin).WeeklyDigestJob},
+	{Name: "stats", Key: "StatsAggregationJob", Description: "Aggregates the ticket statistics.", DefaultSchedule: "24h", defaultWait: cluster.MakeWaitForRoundedInterval(24 * time.Hour), run: (*Plugin).StatsAggregationJob},
+	{Name: "retention", Key: "RetentionJob", Description: "Purges the trash and old usage counters.", DefaultSchedule: "1h", defaultWait: cluster.MakeWaitForRoundedInterval(time.Hour), run: (*Plugin).RetentionJob},
+	{Name: "consistency", Key: "StoreConsistencyJob", Description: "Compares the ticket stores while migrating between them.", DefaultSchedule: "1h", defaultWait: cluster.MakeWaitForRoundedInterval(time.Hour), run: (*Plugin).StoreConsistencyJob},
+}
+
+// jobArgument adds the name of a background job to the autocomplete data of the jobs subcommands.
+func jobArgument(data *model.AutocompleteData) {
+	items := make([]model.AutocompleteListItem, 0, len(backgroundJobs))
+	for _, job := range backgroundJobs {
+		items = append(items, model.AutocompleteListItem{Item: job.Name, HelpText: job.Description})
+	}
+	data.AddStaticListArgument("Name of the job", true, items)
+}
+
+// findBackgroundJob returns the job with the given name or key, or nil if there is none.
+func findBackgroundJob(name string) *backgroundJob {
+	for _, job := range backgroundJobs {
+		if strings.EqualFold(job.Name, name) || strings.EqualFold(job.Key, name) {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// jobStatus records the runs of a background job across the cluster.
+type jobStatus struct {
+	LastStartAt  int64 `json:"last_start_at,omitempty"`
+	LastFinishAt int64 `json:"last_finish_at,omitempty"`
+
+	// LastError is the last failure reported by the job, at LastErrorAt.
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt int64  `json:"last_error_at,omitempty"`
+
+	// Paused jobs are skipped when scheduled, but may still be run manually.
+	Paused   bool   `json:"paused,omitempty"`
+	PausedBy string `json:"paused_by,omitempty"`
+}
+
+func jobStatusKey(key string) string {
+	return jobStatusKeyPrefix + key
+}
+
+func (p *Plugin) getJobStatus(key string) (*jobStatus, error) {
+	var status *jobStatus
+	if err := p.client.KV.Get(jobStatusKey(key), &status); err != nil {
+		return nil, errors.Wrap(err, "failed to get job status")
+	}
+	if status == nil {
+		status = &jobStatus{}
+	}
+
+	return status, nil
+}
+
+// updateJobStatus applies the update to the stored status of the job.
+func (p *Plugin) updateJobStatus(key string, update func(status *jobStatus)) error {
+	status, err := p.getJobStatus(key)
+	if err != nil {
+		return err
+	}
+	update(status)
+
+	if _, err := p.client.KV.Set(jobStatusKey(key), status); err != nil {
+		return errors.Wrap(err, "failed to save job status")
+	}
+
+	return nil
+}
+
+// recordJobFailure stores the failure reported by a job in its status.
+func (p *Plugin) recordJobFailure(key, message string) {
+	if err := p.updateJobStatus(key, func(status *jobStatus) {
+		status.LastError = message
+		status.LastErrorAt = model.GetMillis()
+	}); err != nil {
+		p.API.LogWarn("Failed to record job failure", "job", key, "err", err.Error())
+	}
+}
+
+// trackedJob returns the callback scheduling the job with the given key, recording its runs and
+// skipping them while the job is paused.
+func (p *Plugin) trackedJob(key string, run func()) func() {
+	return func() {
+		if _, err := p.runJob(key, run, false); err != nil {
+			p.API.LogError("Failed to run job", "job", key, "err", err.Error())
+		}
+	}
+}
+
+// runJob runs the job, unless it is paused and not run manually, recording when it started and
+// finished. It returns the status of the job once it ran.
+func (p *Plugin) runJob(key string, run func(), manual bool) (*jobStatus, error) {
+	mutex, err := cluster.NewMutex(p.API, jobMutexKeyPrefix+key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create job mutex")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	status, err := p.getJobStatus(key)
+	if err != nil {
+		p.API.LogWarn("Failed to get job status", "job", key, "err", err.Error())
+	} else if status.Paused && !manual {
+		return status, nil
+	}
+
+	startAt := model.GetMillis()
+	if err := p.updateJobStatus(key, func(status *jobStatus) {
+		status.LastStartAt = startAt
+	}); err != nil {
+		p.API.LogWarn("Failed to record job start", "job", key, "err", err.Error())
+	}
+
+	run()
+
+	// The status is read again, since failures are recorded while the job runs.
+	if err := p.updateJobStatus(key, func(status *jobStatus) {
+		status.LastFinishAt = model.GetMillis()
+	}); err != nil {
+		return nil, err
+	}
+
+	return p.getJobStatus(key)
+}
+
+// formatJobTime renders a time of a job's status, or "never" if it is unset.
+func formatJobTime(millis int64) string {
+	if millis == 0 {
+		return "never"
+	}
+
+	return time.UnixMilli(millis).UTC().Format(time.RFC1123)
+}
+
+func (p *Plugin) executeCommandJobsList() *model.CommandResponse {
+	schedules := p.getConfiguration().jobSchedules
+	lines := []string{"| Job | Description | Schedule | Last run | Duration | Last error | State |", "| --- | --- | --- | --- | --- | --- | --- |"}
+	for _, job := range backgroundJobs {
+		status, err := p.getJobStatus(job.Key)
+		if err != nil {
+			p.API.LogError("Failed to get job status", "job", job.Key, "err", err.Error())
+			return ephemeralResponse("Failed to get the status of the jobs.")
+		}
+
+		duration := ""
+		if status.LastFinishAt >= status.LastStartAt && status.LastStartAt > 0 {
+			duration = (time.Duration(status.LastFinishAt-status.LastStartAt) * time.Millisecond).String()
+		} else if status.LastStartAt > 0 {
+			duration = "running"
+		}
+
+		lastError := ""
+		if status.LastError != "" {
+			lastError = fmt.Sprintf("%s: %s", formatJobTime(status.LastErrorAt), strings.ReplaceAll(status.LastError, "|", "\\|"))
+		}
+
+		schedule := job.DefaultSchedule
+		if jobSchedule, ok := schedules[job.Key]; ok {
+			schedule = jobSchedule.spec
+		}
+
+		state := "scheduled"
+		if status.Paused {
+			state = fmt.Sprintf("paused by %s", p.mentionUser(status.PausedBy))
+		}
+
+		lines = append(lines, fmt.Sprintf("| `%s` | %s | `%s` | %s | %s | %s | %s |", job.Name, job.Description, schedule, formatJobTime(status.LastStartAt), duration, lastError, state))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
+
+// executeCommandJobsRun runs a job right away on this instance, even if it is paused.
+func (p *Plugin) executeCommandJobsRun(params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("jobs", "run"))
+	}
+	job := findBackgroundJob(params[0])
+	if job == nil {
+		return ephemeralResponse(fmt.Sprintf("Unknown job %s. Run `/%s jobs list` to list the jobs.", params[0], commandTriggerSRERequest))
+	}
+
+	startAt := model.GetMillis()
+	status, err := p.runJob(job.Key, func() { job.run(p) }, true)
+	if err != nil {
+		p.API.LogError("Failed to run job", "job", job.Key, "err", err.Error())
+		return ephemeralResponse(fmt.Sprintf("Failed to run the %s job.", job.Name))
+	}
+
+	duration := time.Duration(model.GetMillis()-startAt) * time.Millisecond
+	if status.LastErrorAt >= startAt {
+		return ephemeralResponse(fmt.Sprintf("The %s job failed after %s: %s", job.Name, duration, status.LastError))
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Ran the %s job in %s.", job.Name, duration))
+}
+
+// executeCommandJobsPause pauses or resumes the scheduled runs of a job across the cluster.
+func (p *Plugin) executeCommandJobsPause(args *model.CommandArgs, params []string, pause bool) *model.CommandResponse {
+	subcommand := "resume"
+	if pause {
+		subcommand = "pause"
+	}
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("jobs", subcommand))
+	}
+	job := findBackgroundJob(params[0])
+	if job == nil {
+		return ephemeralResponse(fmt.Sprintf("Unknown job %s. Run `/%s jobs list` to list the jobs.", params[0], commandTriggerSRERequest))
+	}
+
+	if err := p.updateJobStatus(job.Key, func(status *jobStatus) {
+		status.Paused = pause
+		status.PausedBy = ""
+		if pause {
+			status.PausedBy = args.UserId
+		}
+	}); err != nil {
+		p.API.LogError("Failed to update job status", "job", job.Key, "err", err.Error())
+		return ephemeralResponse(fmt.Sprintf("Failed to %s the %s job.", subcommand, job.Name))
+	}
+
+	if pause {
+		p.postAdminNotice(fmt.Sprintf(":pause_button: %s paused the %s job.", p.mentionUser(args.UserId), job.Name))
+		return ephemeralResponse(fmt.Sprintf("Paused the %s job. It can still be run manually, and resumed with `/%s jobs resume %s`.", job.Name, commandTriggerSRERequest, job.Name))
+	}
+
+	p.postAdminNotice(fmt.Sprintf(":arrow_forward: %s resumed the %s job.", p.mentionUser(args.UserId), job.Name))
+	return ephemeralResponse(fmt.Sprintf("Resumed the %s job.", job.Name))
+}
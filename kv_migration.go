@@ -0,0 +1,144 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// kvSchemaVersionKey is the KV key recording the version of the schema the stored data was
+	// migrated to.
+	kvSchemaVersionKey = "schemaversion"
+
+	// kvMigrationMutexKey serializes the KV migrations across the cluster, since every node runs
+	// them when the plugin is activated.
+	kvMigrationMutexKey = "kv_migration"
+)
+
+// kvMigration upgrades the data stored in the KV store from one version of the schema to the
+// next. Migrations must be idempotent, since a node may stop in the middle of one, which then runs
+// again on the next activation.
+type kvMigration struct {
+	name    string
+	migrate func(p *Plugin) error
+}
+
+// kvMigrations are the migrations of the KV schema, in order. Data at version n has had the first
+// n migrations applied.
+var kvMigrations = []kvMigration{
+	{name: "backfill ticket keys", migrate: (*Plugin).backfillTicketKeys},
+	{name: "index tickets for search", migrate: (*Plugin).indexTicketsForSearch},
+	{name: "namespace the search index by team", migrate: (*Plugin).rebuildSearchIndex},
+}
+
+// backfillTicketKeys gives the tickets created before ticket keys existed a key, in creation
+// order, and indexes them by key.
+func (p *Plugin) backfillTicketKeys() error {
+	tickets, err := p.listTickets()
+	if err != nil {
+		return err
+	}
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i].CreateAt < tickets[j].CreateAt
+	})
+
+	for _, ticket := range tickets {
+		if ticket.Key != "" {
+			continue
+		}
+
+		key, err := p.nextTicketKey()
+		if err != nil {
+			return err
+		}
+		ticket.Key = key
+
+		// The index is saved first, so that a ticket saved with its key is always indexed.
+		if err := p.saveTicketReference(ticket); err != nil {
+			return err
+		}
+		if err := p.saveTicket(ticket); err != nil {
+			return err
+		}
+		if err := p.updateTicketPost(ticket); err != nil {
+			p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// indexTicketsForSearch indexes the tickets created before the search index existed.
+func (p *Plugin) indexTicketsForSearch() error {
+	tickets, err := p.listTickets()
+	if err != nil {
+		return err
+	}
+
+	for _, ticket := range tickets {
+		if err := p.indexTicket(ticket); err != nil {
+			return errors.Wrapf(err, "failed to index ticket %s", ticket.ID)
+		}
+	}
+
+	return nil
+}
+
+// rebuildSearchIndex deletes the search index, including the index shared by every team before it
+// was namespaced by team, and indexes every ticket again.
+func (p *Plugin) rebuildSearchIndex() error {
+	for _, prefix := range []string{searchTermKeyPrefix, searchDocKeyPrefix} {
+		for {
+			keys, err := p.client.KV.ListKeys(0, kvListPerPage, pluginapi.WithPrefix(prefix))
+			if err != nil {
+				return errors.Wrap(err, "failed to list search index keys")
+			}
+			if len(keys) == 0 {
+				break
+			}
+
+			for _, key := range keys {
+				if err := p.client.KV.Delete(key); err != nil {
+					return errors.Wrap(err, "failed to delete search index key")
+				}
+			}
+		}
+	}
+
+	return p.indexTicketsForSearch()
+}
+
+// migrateKVSchema brings the data stored in the KV store to the current schema. It runs when the
+// plugin is activated, once the ticket store is configured, and records the version reached after
+// each migration, so that a failed migration resumes where it stopped.
+func (p *Plugin) migrateKVSchema() error {
+	mutex, err := cluster.NewMutex(p.API, kvMigrationMutexKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to create KV migration mutex")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var version int
+	if err := p.client.KV.Get(kvSchemaVersionKey, &version); err != nil {
+		return errors.Wrap(err, "failed to get KV schema version")
+	}
+
+	for ; version < len(kvMigrations); version++ {
+		migration := kvMigrations[version]
+		p.API.LogInfo("Migrating KV schema", "version", version+1, "migration", migration.name)
+		if err := migration.migrate(p); err != nil {
+			return errors.Wrapf(err, "failed to %s", migration.name)
+		}
+
+		if _, err := p.client.KV.Set(kvSchemaVersionKey, version+1); err != nil {
+			return errors.Wrap(err, "failed to save KV schema version")
+		}
+	}
+
+	return nil
+}
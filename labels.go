@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// i18nPath is the directory of the plugin bundle holding the translation files.
+const i18nPath = "assets/i18n"
+
+// priorityLabels are the display names of the ticket priorities, keyed by priority code.
+var priorityLabels = map[string]*i18n.Message{
+	ticketPriorityHigh:   {ID: "ticket.priority.high", Other: "High"},
+	ticketPriorityMedium: {ID: "ticket.priority.medium", Other: "Medium"},
+	ticketPriorityLow:    {ID: "ticket.priority.low", Other: "Low"},
+}
+
+// statusLabels are the display names of the ticket statuses, keyed by status code.
+var statusLabels = map[string]*i18n.Message{
+	ticketStatusOpen:              {ID: "ticket.status.open", Other: "Open"},
+	ticketStatusAcknowledged:      {ID: "ticket.status.acknowledged", Other: "Acknowledged"},
+	ticketStatusWaitingOnReporter: {ID: "ticket.status.waiting_on_reporter", Other: "Waiting on reporter"},
+	ticketStatusResolved:          {ID: "ticket.status.resolved", Other: "Resolved"},
+}
+
+// userLocalizer returns a localizer for the user's locale, falling back to the server language, or
+// nil if no translations are loaded.
+func (p *Plugin) userLocalizer(userID string) *i18n.Localizer {
+	if p.i18nBundle == nil {
+		return nil
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get user locale", "user_id", userID, "err", appErr.Error())
+		return p.serverLocalizer()
+	}
+
+	return p.newLocalizer(user.Locale, p.serverLanguage())
+}
+
+// serverLocalizer returns a localizer for the server language, used for messages shown to every
+// user, or nil if no translations are loaded.
+func (p *Plugin) serverLocalizer() *i18n.Localizer {
+	if p.i18nBundle == nil {
+		return nil
+	}
+
+	return p.newLocalizer(p.serverLanguage())
+}
+
+// localizeLabel returns the display name of a code in the localizer's language, falling back to
+// the English display name, or to the code itself if it has no display name.
+func localizeLabel(localizer *i18n.Localizer, labels map[string]*i18n.Message, code string) string {
+	message, ok := labels[code]
+	if !ok {
+		return code
+	}
+
+	return localize(localizer, message, nil)
+}
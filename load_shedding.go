@@ -0,0 +1,208 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	// loadSheddingWindow is the period over which Mattermost API calls are measured.
+	loadSheddingWindow = time.Minute
+
+	// loadSheddingMinCalls is how many calls a window needs before the breaker may trip, so that a
+	// few failures on an idle server don't degrade the plugin.
+	loadSheddingMinCalls = 20
+
+	// loadSheddingFailureRate and loadSheddingSlowRate are the rates of failed and slow calls in a
+	// window above which the breaker trips.
+	loadSheddingFailureRate = 0.25
+	loadSheddingSlowRate    = 0.5
+
+	// loadSheddingSlowCall is the latency above which a call counts as slow.
+	loadSheddingSlowCall = 2 * time.Second
+
+	// loadSheddingCooldown is how long non-essential features stay degraded after the last
+	// unhealthy window.
+	loadSheddingCooldown = 2 * time.Minute
+)
+
+// loadShedder is a circuit breaker over the Mattermost API calls of this node. When their error
+// rate or latency spikes, it trips and non-essential features, such as ticket post refreshes and
+// digests, are degraded until the calls are healthy again, while ticket creation and paging keep
+// working. The zero value is a closed breaker.
+type loadShedder struct {
+	lock sync.Mutex
+
+	windowStart time.Time
+	calls       int
+	failures    int
+	slowCalls   int
+
+	// sheddingUntil is when non-essential features recover, or zero while they are enabled.
+	sheddingUntil time.Time
+
+	// deferredRefreshes are the ids of the tickets whose post refresh was skipped while shedding.
+	deferredRefreshes map[string]bool
+}
+
+// record measures a call, returning true if it tripped the breaker.
+func (s *loadShedder) record(now time.Time, latency time.Duration, failed bool) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if now.Sub(s.windowStart) > loadSheddingWindow {
+		s.windowStart = now
+		s.calls, s.failures, s.slowCalls = 0, 0, 0
+	}
+
+	s.calls++
+	if failed {
+		s.failures++
+	}
+	if latency > loadSheddingSlowCall {
+		s.slowCalls++
+	}
+
+	if s.calls < loadSheddingMinCalls ||
+		(float64(s.failures)/float64(s.calls) < loadSheddingFailureRate && float64(s.slowCalls)/float64(s.calls) < loadSheddingSlowRate) {
+		return false
+	}
+
+	// Calls keep being unhealthy while shedding, which extends the cooldown.
+	tripped := s.sheddingUntil.IsZero()
+	s.sheddingUntil = now.Add(loadSheddingCooldown)
+
+	return tripped
+}
+
+// shedding reports whether non-essential features are degraded. Once the cooldown passed, the
+// breaker closes and returns the ids of the tickets whose post refresh was deferred.
+func (s *loadShedder) shedding(now time.Time) (bool, []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.sheddingUntil.IsZero() {
+		return false, nil
+	}
+	if now.Before(s.sheddingUntil) {
+		return true, nil
+	}
+
+	s.sheddingUntil = time.Time{}
+	deferred := make([]string, 0, len(s.deferredRefreshes))
+	for ticketID := range s.deferredRefreshes {
+		deferred = append(deferred, ticketID)
+	}
+	s.deferredRefreshes = nil
+
+	return false, deferred
+}
+
+// deferRefresh records that the ticket's post must be refreshed once the breaker closes.
+func (s *loadShedder) deferRefresh(ticketID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.deferredRefreshes == nil {
+		s.deferredRefreshes = make(map[string]bool)
+	}
+	s.deferredRefreshes[ticketID] = true
+}
+
+// monitoredAPI measures the post and KV calls made to the Mattermost API, which dominate the
+// plugin's load on the server, and feeds them to the load shedder.
+type monitoredAPI struct {
+	plugin.API
+	plugin *Plugin
+}
+
+func (a *monitoredAPI) measure(start time.Time, appErr *model.AppError) {
+	// Client errors, such as missing posts, say nothing about the server's health.
+	failed := appErr != nil && appErr.StatusCode >= http.StatusInternalServerError
+	a.plugin.recordAPICall(time.Since(start), failed)
+}
+
+func (a *monitoredAPI) CreatePost(post *model.Post) (*model.Post, *model.AppError) {
+	start := time.Now()
+	created, appErr := a.API.CreatePost(post)
+	a.measure(start, appErr)
+	return created, appErr
+}
+
+func (a *monitoredAPI) UpdatePost(post *model.Post) (*model.Post, *model.AppError) {
+	start := time.Now()
+	updated, appErr := a.API.UpdatePost(post)
+	a.measure(start, appErr)
+	return updated, appErr
+}
+
+func (a *monitoredAPI) GetPost(postID string) (*model.Post, *model.AppError) {
+	start := time.Now()
+	post, appErr := a.API.GetPost(postID)
+	a.measure(start, appErr)
+	return post, appErr
+}
+
+func (a *monitoredAPI) KVGet(key string) ([]byte, *model.AppError) {
+	start := time.Now()
+	value, appErr := a.API.KVGet(key)
+	a.measure(start, appErr)
+	return value, appErr
+}
+
+func (a *monitoredAPI) KVSetWithOptions(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+	start := time.Now()
+	written, appErr := a.API.KVSetWithOptions(key, value, options)
+	a.measure(start, appErr)
+	return written, appErr
+}
+
+// monitorAPI wraps the plugin API so that its calls are measured by the load shedder.
+func (p *Plugin) monitorAPI() {
+	if _, ok := p.API.(*monitoredAPI); !ok {
+		p.API = &monitoredAPI{API: p.API, plugin: p}
+	}
+}
+
+func (p *Plugin) recordAPICall(latency time.Duration, failed bool) {
+	if p.loadShedder.record(time.Now(), latency, failed) {
+		p.API.LogWarn("Mattermost API calls are failing or slow, degrading non-essential features", "cooldown", loadSheddingCooldown.String())
+	}
+}
+
+// sheddingLoad reports whether non-essential features should be skipped to relieve the server.
+// When the breaker closes, it refreshes the ticket posts that were skipped in the meantime.
+func (p *Plugin) sheddingLoad() bool {
+	shedding, deferred := p.loadShedder.shedding(time.Now())
+	if shedding {
+		return true
+	}
+
+	if len(deferred) > 0 {
+		p.API.LogInfo("Mattermost API calls recovered, restoring non-essential features", "deferred_refreshes", len(deferred))
+		go p.refreshDeferredTicketPosts(deferred)
+	}
+
+	return false
+}
+
+func (p *Plugin) refreshDeferredTicketPosts(ticketIDs []string) {
+	for _, ticketID := range ticketIDs {
+		ticket, err := p.getTicket(ticketID)
+		if err != nil {
+			p.API.LogWarn("Failed to get ticket for deferred refresh", "ticket_id", ticketID, "err", err.Error())
+			continue
+		}
+		if ticket == nil {
+			continue
+		}
+
+		if err := p.updateTicketPost(ticket); err != nil {
+			p.API.LogWarn("Failed to update ticket post", "ticket_id", ticketID, "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"text/template"
+
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// localize renders the message in the localizer's language with the template data, falling back
+// to English when the localizer is nil or the message has no translation.
+func localize(localizer *i18n.Localizer, message *i18n.Message, data map[string]interface{}) string {
+	if localizer != nil {
+		// Missing translations are rendered from the English default along with an error, which is
+		// expected for partially translated languages.
+		if localized, err := localizer.Localize(&i18n.LocalizeConfig{DefaultMessage: message, TemplateData: data}); err == nil || localized != "" {
+			return localized
+		}
+	}
+
+	return renderDefaultMessage(message, data)
+}
+
+// renderDefaultMessage renders the English text of the message with the template data.
+func renderDefaultMessage(message *i18n.Message, data map[string]interface{}) string {
+	if data == nil || !strings.Contains(message.Other, "{{") {
+		return message.Other
+	}
+
+	tmpl, err := template.New(message.ID).Parse(message.Other)
+	if err != nil {
+		return message.Other
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return message.Other
+	}
+
+	return buffer.String()
+}
+
+// serverLanguage returns the language of messages shown to every user: the DefaultLanguage
+// setting, or else the default locale of the server.
+func (p *Plugin) serverLanguage() string {
+	if language := strings.TrimSpace(p.getConfiguration().DefaultLanguage); language != "" {
+		return language
+	}
+
+	if locale := p.API.GetConfig().LocalizationSettings.DefaultServerLocale; locale != nil {
+		return *locale
+	}
+
+	return "en"
+}
+
+// newLocalizer returns a localizer for the given languages, in order of preference, or nil if no
+// translations are loaded.
+func (p *Plugin) newLocalizer(languages ...string) *i18n.Localizer {
+	if p.i18nBundle == nil {
+		return nil
+	}
+
+	return goi18n.NewLocalizer(p.i18nBundle.Bundle, languages...)
+}
+
+// localizeDialog translates the title, submit label and elements of a built-in dialog. Messages
+// are identified by the element names, while titleID identifies the title, which may vary for the
+// same dialog.
+func localizeDialog(localizer *i18n.Localizer, dialog model.Dialog, titleID string) model.Dialog {
+	dialog.Title = localize(localizer, &i18n.Message{ID: titleID, Other: dialog.Title}, nil)
+	dialog.SubmitLabel = localize(localizer, &i18n.Message{ID: "dialog." + dialog.CallbackId + ".submit", Other: dialog.SubmitLabel}, nil)
+
+	dialog.Elements = append([]model.DialogElement(nil), dialog.Elements...)
+	for i, element := range dialog.Elements {
+		prefix := "dialog.element." + element.Name
+		localized := &dialog.Elements[i]
+		localized.DisplayName = localize(localizer, &i18n.Message{ID: prefix + ".display_name", Other: element.DisplayName}, nil)
+		if element.Placeholder != "" {
+			localized.Placeholder = localize(localizer, &i18n.Message{ID: prefix + ".placeholder", Other: element.Placeholder}, nil)
+		}
+		if element.HelpText != "" {
+			localized.HelpText = localize(localizer, &i18n.Message{ID: prefix + ".help_text", Other: element.HelpText}, nil)
+		}
+
+		if element.Name == dialogElementNamePriority {
+			localized.Options = localizeOptions(element.Options, func(option *model.PostActionOptions) string {
+				return localizeLabel(localizer, priorityLabels, option.Value)
+			})
+		} else if len(element.Options) > 0 {
+			localized.Options = localizeOptions(element.Options, func(option *model.PostActionOptions) string {
+				return localize(localizer, &i18n.Message{ID: prefix + ".option." + option.Value, Other: option.Text}, nil)
+			})
+		}
+	}
+
+	return dialog
+}
+
+// localizeOptions copies the options of a dialog element with their texts rendered by text.
+func localizeOptions(options []*model.PostActionOptions, text func(option *model.PostActionOptions) string) []*model.PostActionOptions {
+	localized := make([]*model.PostActionOptions, 0, len(options))
+	for _, option := range options {
+		localized = append(localized, &model.PostActionOptions{Text: text(option), Value: option.Value})
+	}
+
+	return localized
+}
+
+// localizeAutocomplete translates the help texts of a command's autocomplete data, its arguments
+// and its subcommands. Messages are identified by the path of the command, such as
+// "command.sre-request.oncall.set.help".
+func localizeAutocomplete(localizer *i18n.Localizer, data *model.AutocompleteData, path string) {
+	path += "." + data.Trigger
+	data.HelpText = localize(localizer, &i18n.Message{ID: path + ".help", Other: data.HelpText}, nil)
+
+	for i, argument := range data.Arguments {
+		argumentID := path + ".arg" + strconv.Itoa(i+1)
+		argument.HelpText = localize(localizer, &i18n.Message{ID: argumentID + ".help", Other: argument.HelpText}, nil)
+
+		if list, ok := argument.Data.(*model.AutocompleteStaticListArg); ok {
+			for j, item := range list.PossibleArguments {
+				list.PossibleArguments[j].HelpText = localize(localizer, &i18n.Message{ID: argumentID + "." + item.Item + ".help", Other: item.HelpText}, nil)
+			}
+		}
+	}
+
+	for _, subCommand := range data.SubCommands {
+		localizeAutocomplete(localizer, subCommand, path)
+	}
+}
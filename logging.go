@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	logLevelDebug = "debug"
+	logLevelInfo  = "info"
+	logLevelWarn  = "warn"
+
+	// requestIDHeader carries the id of a request, both from clients and proxies that already
+	// assigned one, and back to the client in the response.
+	requestIDHeader = "X-Request-ID"
+
+	// debugLogsKey stores until when, in milliseconds, debug logs were turned on by the debug
+	// command.
+	debugLogsKey = "debug_logs_until"
+
+	// debugLogsDuration is how long debug logs stay on, so that a forgotten toggle doesn't flood the
+	// server logs.
+	debugLogsDuration = time.Hour
+
+	// debugLogsCacheTTL bounds how long a plugin instance may miss a debug logs toggle made on
+	// another instance of the cluster.
+	debugLogsCacheTTL = time.Minute
+)
+
+// parseLogLevel validates the LogLevel setting.
+func parseLogLevel(configuration *configuration) (string, error) {
+	switch level := strings.ToLower(strings.TrimSpace(configuration.LogLevel)); level {
+	case "":
+		return logLevelInfo, nil
+	case logLevelDebug, logLevelInfo, logLevelWarn:
+		return level, nil
+	default:
+		return "", errors.Errorf("invalid log level %q, expected %s, %s or %s", configuration.LogLevel, logLevelDebug, logLevelInfo, logLevelWarn)
+	}
+}
+
+// debugLogsCache is an in-memory copy of the debug logs toggle, saving every log line a KV store
+// round trip. The zero value is an empty cache.
+type debugLogsCache struct {
+	lock     sync.Mutex
+	until    int64
+	expireAt time.Time
+}
+
+// get returns until when debug logs are on, or false as second value if the cache is empty or
+// expired.
+func (c *debugLogsCache) get() (int64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.expireAt.IsZero() || time.Now().After(c.expireAt) {
+		return 0, false
+	}
+
+	return c.until, true
+}
+
+// replace fills the cache with the given toggle.
+func (c *debugLogsCache) replace(until int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.until = until
+	c.expireAt = time.Now().Add(debugLogsCacheTTL)
+}
+
+// debugLogsOn returns whether debug logs were turned on by the debug command and haven't expired.
+func (p *Plugin) debugLogsOn() bool {
+	until, ok := p.debugLogs.get()
+	if !ok {
+		if err := p.client.KV.Get(debugLogsKey, &until); err != nil {
+			p.API.LogWarn("Failed to check if debug logs are on", "err", err.Error())
+			return false
+		}
+		p.debugLogs.replace(until)
+	}
+
+	return model.GetMillis() < until
+}
+
+// setDebugLogs turns debug logs on for debugLogsDuration, or off, for every instance of the
+// cluster.
+func (p *Plugin) setDebugLogs(on bool) (int64, error) {
+	var until int64
+	if on {
+		until = model.GetMillis() + debugLogsDuration.Milliseconds()
+	}
+
+	if _, err := p.client.KV.Set(debugLogsKey, until); err != nil {
+		return 0, errors.Wrap(err, "failed to save debug logs toggle")
+	}
+	p.debugLogs.replace(until)
+
+	return until, nil
+}
+
+// pluginLogger writes log lines carrying the context they were written in, such as the request id,
+// handler, user and ticket, and drops the lines below the configured log level.
+type pluginLogger struct {
+	p      *Plugin
+	fields []interface{}
+}
+
+// logger returns a logger attaching the given key value pairs to every line.
+func (p *Plugin) logger(keyValuePairs ...interface{}) *pluginLogger {
+	return &pluginLogger{p: p, fields: keyValuePairs}
+}
+
+// with returns a copy of the logger also attaching the given key value pairs to every line.
+func (l *pluginLogger) with(keyValuePairs ...interface{}) *pluginLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyValuePairs))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyValuePairs...)
+
+	return &pluginLogger{p: l.p, fields: fields}
+}
+
+// withTicket returns a copy of the logger also attaching the id and key of the ticket.
+func (l *pluginLogger) withTicket(ticket *Ticket) *pluginLogger {
+	return l.with("ticket_id", ticket.ID, "ticket_key", ticket.Key)
+}
+
+func (l *pluginLogger) keyValuePairs(keyValuePairs []interface{}) []interface{} {
+	return append(append([]interface{}(nil), l.fields...), keyValuePairs...)
+}
+
+// Debug logs the line if the log level is debug, or debug logs were turned on by the debug
+// command. The server drops debug lines unless its own log level is debug, so lines logged while
+// debug logs are on are written at the info level instead, flagged as debug.
+func (l *pluginLogger) Debug(message string, keyValuePairs ...interface{}) {
+	if l.p.debugLogsOn() {
+		l.p.API.LogInfo(message, append(l.keyValuePairs(keyValuePairs), "debug", true)...)
+		return
+	}
+	if l.p.getConfiguration().logLevel == logLevelDebug {
+		l.p.API.LogDebug(message, l.keyValuePairs(keyValuePairs)...)
+	}
+}
+
+// Info logs the line unless the log level is warn.
+func (l *pluginLogger) Info(message string, keyValuePairs ...interface{}) {
+	if l.p.getConfiguration().logLevel == logLevelWarn {
+		return
+	}
+	l.p.API.LogInfo(message, l.keyValuePairs(keyValuePairs)...)
+}
+
+// Warn logs the line.
+func (l *pluginLogger) Warn(message string, keyValuePairs ...interface{}) {
+	l.p.API.LogWarn(message, l.keyValuePairs(keyValuePairs)...)
+}
+
+// Error logs the line.
+func (l *pluginLogger) Error(message string, keyValuePairs ...interface{}) {
+	l.p.API.LogError(message, l.keyValuePairs(keyValuePairs)...)
+}
+
+type requestLoggerKey struct{}
+
+// withRequestLogger assigns an id to every request, reusing the id given by the client if valid,
+// returns it in the X-Request-ID header and attaches a logger carrying it, the handler and the user
+// to the request context.
+func (p *Plugin) withRequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !model.IsValidId(requestID) {
+			requestID = model.NewId()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		// Report the route rather than the path, which may contain ids.
+		handler := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				handler = template
+			}
+		}
+
+		logger := p.logger("request_id", requestID, "handler", r.Method+" "+handler)
+		if userID := r.Header.Get("Mattermost-User-ID"); userID != "" {
+			logger = logger.with("user_id", userID)
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestLoggerKey{}, logger)))
+		logger.Debug("Handled request", "duration", time.Since(start).String())
+	})
+}
+
+// requestLogger returns the logger of the request, as attached by withRequestLogger.
+func (p *Plugin) requestLogger(r *http.Request) *pluginLogger {
+	if logger, ok := r.Context().Value(requestLoggerKey{}).(*pluginLogger); ok {
+		return logger
+	}
+
+	return p.logger()
+}
+
+// commandLogger returns a logger carrying a new request id, the subcommand and the user of a slash
+// command.
+func (p *Plugin) commandLogger(args *model.CommandArgs, subcommand string) *pluginLogger {
+	return p.logger("request_id", model.NewId(), "handler", "command: "+subcommand, "user_id", args.UserId, "team_id", args.TeamId)
+}
+
+// executeCommandDebug turns debug logs on or off for every instance of the cluster.
+func (p *Plugin) executeCommandDebug(params []string, logger *pluginLogger) *model.CommandResponse {
+	if len(params) != 2 || params[0] != "logs" || (params[1] != "on" && params[1] != "off") {
+		return ephemeralResponse(commandUsage("debug"))
+	}
+
+	on := params[1] == "on"
+	until, err := p.setDebugLogs(on)
+	if err != nil {
+		logger.Error("Failed to toggle debug logs", "err", err.Error())
+		return ephemeralResponse("Failed to toggle debug logs.")
+	}
+	logger.Info("Toggled debug logs", "on", on)
+
+	if !on {
+		return ephemeralResponse(fmt.Sprintf("Debug logs are off. The log level is back to %s.", p.getConfiguration().logLevel))
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Debug logs are on until %s, and written at the info level so that they show in the server logs.", time.UnixMilli(until).UTC().Format("15:04 MST")))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// longCommandThreshold is how long a command may run before its response is replaced by a
+	// progress post, so that the command doesn't block the client.
+	longCommandThreshold = 2 * time.Second
+
+	// typingInterval is how often the bot is shown typing while a long command runs. Typing
+	// indicators expire after a few seconds on the client.
+	typingInterval = 3 * time.Second
+)
+
+// runLongCommand runs a command that may take a while. If it completes within
+// longCommandThreshold, its response is returned as is. Otherwise, the user immediately gets an
+// ephemeral progress post, which is replaced with the command's response once it completes.
+func (p *Plugin) runLongCommand(args *model.CommandArgs, command func() *model.CommandResponse) *model.CommandResponse {
+	done := make(chan *model.CommandResponse, 1)
+	go func() {
+		// The command runs outside of ExecuteCommand, which can't recover from its panics.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				p.reportPanic(commandSource(args), recovered)
+				done <- ephemeralResponse(commandPanicMessage)
+			}
+		}()
+
+		done <- command()
+	}()
+
+	select {
+	case response := <-done:
+		return response
+	case <-time.After(longCommandThreshold):
+	}
+
+	post := p.API.SendEphemeralPost(args.UserId, &model.Post{
+		UserId:    p.botID,
+		ChannelId: args.ChannelId,
+		RootId:    args.RootId,
+		Message:   ":hourglass_flowing_sand: Working on it…",
+	})
+
+	go func() {
+		typing := time.NewTicker(typingInterval)
+		defer typing.Stop()
+
+		for {
+			if appErr := p.API.PublishUserTyping(p.botID, args.ChannelId, args.RootId); appErr != nil {
+				p.API.LogWarn("Failed to publish bot typing indicator", "err", appErr.Error())
+			}
+
+			select {
+			case response := <-done:
+				post.Message = response.Text
+				p.API.UpdateEphemeralPost(args.UserId, post)
+				return
+			case <-typing.C:
+			}
+		}
+	}()
+
+	return &model.CommandResponse{}
+}
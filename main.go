@@ -1,26 +1,39 @@
 package main
 
 import (
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/gorilla/mux"
-	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
 	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
-	
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
 
-	root "github.com/mattermost/mattermost-plugin-demo"
+	"plugin-test/utils"
 )
 
-var (
-	manifest model.Manifest = root.Manifest
+const (
+	dialogElementNameNumber = "somenumber"
+	dialogElementNameEmail  = "someemail"
+
+	dialogStateRelativeCallbackURL = "relativecallbackstate"
 )
 
+func main() {
+	plugin.ClientMain(&Plugin{})
+}
+
 // Helper method for the demo plugin. Posts a message to the "demo" channel
 // for the team specified. If the teamID specified is empty, the method
 // will post the message to the "demo" channel for each team.
@@ -31,10 +44,7 @@ func (p *Plugin) postPluginMessage(teamID, msg string) *model.AppError {
 		return nil
 	}
 
-	if configuration.EnableMentionUser {
-		msg = fmt.Sprintf("tag @%s | %s", configuration.MentionUser, msg)
-	}
-	msg = fmt.Sprintf("%s%s%s", configuration.TextStyle, msg, configuration.TextStyle)
+	msg = configuration.formatPluginMessage(msg)
 
 	if teamID != "" {
 		_, err := p.API.CreatePost(&model.Post{
@@ -59,6 +69,15 @@ func (p *Plugin) postPluginMessage(teamID, msg string) *model.AppError {
 	return nil
 }
 
+// formatPluginMessage applies the mention and text style settings to a message of the demo user.
+func (c *configuration) formatPluginMessage(msg string) string {
+	if c.EnableMentionUser {
+		msg = fmt.Sprintf("tag @%s | %s", c.MentionUser, msg)
+	}
+
+	return fmt.Sprintf("%s%s%s", c.TextStyle, msg, c.TextStyle)
+}
+
 // configuration captures the plugin's external configuration as exposed in the Mattermost server
 // configuration, as well as values computed from the configuration. Any public fields will be
 // deserialized from the Mattermost server configuration in OnConfigurationChange.
@@ -99,6 +118,289 @@ type configuration struct {
 	// It's useful for testing.
 	IntegrationRequestDelay int
 
+	// EnableCrashSimulation enables the crash command, which panics on purpose to exercise the
+	// panic recovery. It is meant for debugging only.
+	EnableCrashSimulation bool
+
+	// IncidentCommander is the username of the incident commander added to the group message of
+	// confidential SRE requests.
+	IncidentCommander string
+
+	// HighPrioritySLA, MediumPrioritySLA and LowPrioritySLA are the durations (e.g. "1h", "4h")
+	// within which a ticket of the given priority must be acknowledged. Empty disables the SLA.
+	HighPrioritySLA   string
+	MediumPrioritySLA string
+	LowPrioritySLA    string
+
+	// HighPriorityResponders, MediumPriorityResponders and LowPriorityResponders are
+	// comma-separated lists of usernames and group names (e.g. "@sre-oncall") mentioned when a
+	// ticket of the given priority is submitted.
+	HighPriorityResponders   string
+	MediumPriorityResponders string
+	LowPriorityResponders    string
+
+	// HighPriorityEscalationPolicy, MediumPriorityEscalationPolicy and LowPriorityEscalationPolicy
+	// are semicolon-separated lists of escalation tiers for tickets of the given priority, each as
+	// a duration followed by comma-separated usernames and group names, such as
+	// "30m @sre-tier1; 1h @sre-tier2; 2h @sre-manager". Each tier is notified in the ticket's thread
+	// once the ticket has not been acknowledged for its duration. Empty disables the policy.
+	HighPriorityEscalationPolicy   string
+	MediumPriorityEscalationPolicy string
+	LowPriorityEscalationPolicy    string
+
+	// EscalationUsers is a comma-separated list of usernames mentioned when a ticket breaches its SLA.
+	EscalationUsers string
+
+	// DialogDefinition is an optional JSON form definition replacing the built-in intake dialog.
+	// It must contain a "summary" element; invalid definitions fall back to the built-in form.
+	DialogDefinition string
+
+	// CustomFields is an optional JSON array of extra ticket fields, each with a "name", a "type"
+	// among text, textarea, number, select and bool, whether it is "required", the "options" of
+	// select fields, and an optional "display_name" and "help_text". The fields are added to the
+	// intake dialog, stored on tickets, and included in exports and the REST API.
+	CustomFields string
+
+	// TicketStore selects where tickets are stored: "kv" (the default), "sql", or "dual" to write
+	// to both while migrating between the KV store and the SQL store.
+	TicketStore string
+
+	// TicketStorePrimary selects the store serving reads in dual mode, "kv" (the default) when
+	// migrating from the KV store to the SQL store, or "sql" when migrating back. The other store
+	// is the shadow store.
+	TicketStorePrimary string
+
+	// MaxDescriptionLength is the number of characters of a ticket description shown in the ticket
+	// post. Longer descriptions are truncated and attached in full as a file. Defaults to 4000.
+	MaxDescriptionLength int
+
+	// JiraBaseURL, JiraProjectKey and JiraAPIToken enable the optional Jira integration, which
+	// creates a Jira issue for every submitted ticket. JiraUsername is the account email used with
+	// the API token on Jira Cloud; leave it empty to use the token as a Jira Data Center PAT.
+	JiraBaseURL    string
+	JiraProjectKey string
+	JiraUsername   string
+	JiraAPIToken   string
+
+	// GitHubRepository, as "owner/repo", enables the optional GitHub integration, which mirrors
+	// every submitted ticket into an issue of the repository and keeps its status label in sync.
+	// It authenticates with the personal access token GitHubToken or, when empty, as the
+	// installation GitHubAppInstallationID of the GitHub App GitHubAppID with the PEM private key
+	// GitHubAppPrivateKey. Status changes made on GitHub are received by the GitHub webhook, which
+	// requires WebhookSigningSecret.
+	GitHubRepository        string
+	GitHubToken             string
+	GitHubAppID             string
+	GitHubAppInstallationID string
+	GitHubAppPrivateKey     string
+
+	// EventWebhookURL is an optional URL notified of ticket events. When EventWebhookSecret is set,
+	// every event is signed with an HMAC-SHA256 of its body.
+	EventWebhookURL    string
+	EventWebhookSecret string
+
+	// AlertmanagerToken enables the Alertmanager webhook, which must be called with it as a bearer
+	// token.
+	AlertmanagerToken string
+
+	// GrafanaToken enables the Grafana alerting webhook, which must be called with it as a bearer
+	// token.
+	GrafanaToken string
+
+	// SentryClientSecret enables the Sentry webhook, whose deliveries must be signed with it. It is
+	// the client secret of the Sentry internal integration sending issue alerts.
+	SentryClientSecret string
+
+	// GenericWebhooks is an optional JSON object configuring the generic webhook sources by name,
+	// for the tools without a native integration. Each source is called at
+	// /webhook/generic/{source} with its "token" as a bearer token, and maps fields of its payloads
+	// to the "summary", "description" and "priority" of tickets with JSONPath-style expressions,
+	// such as "$.alert.title". Its optional "priorities" object maps the values selected by
+	// "priority" to ticket priorities.
+	GenericWebhooks string
+
+	// EmailIMAPServer, as "host:port", enables the optional email bridge, which converts the unread
+	// emails of the EmailMailbox, INBOX by default, into tickets of the team named by EmailTeam. The
+	// mailbox is read over IMAP with implicit TLS, signing in with EmailUsername and EmailPassword.
+	// When EmailSMTPServer, as "host:port", is set, every email is acknowledged from EmailAddress,
+	// which defaults to EmailUsername, with the key and the link of its ticket.
+	EmailIMAPServer string
+	EmailSMTPServer string
+	EmailUsername   string
+	EmailPassword   string
+	EmailMailbox    string
+	EmailTeam       string
+	EmailAddress    string
+
+	// EmailAuthservID is the authserv-id of the mail server receiving the emails of the bridge,
+	// whose Authentication-Results headers are trusted. Emails it reports as passing DMARC are filed
+	// on behalf of the team member with the sender's address, while the others are filed by the bot.
+	// When empty, every email is filed by the bot.
+	EmailAuthservID string
+
+	// SlackSigningSecret enables the Slack-compatible slash command endpoint,
+	// /compat/slack/command, for tooling moved from Slack. Requests must be signed with it as Slack
+	// signs them, so it is the signing secret of the Slack app being migrated.
+	SlackSigningSecret string
+
+	// SlackUserLinks is a comma-separated list of Slack user ids linked to Mattermost usernames,
+	// such as "U024BE7LH=alice, U0G9QF9C6=bob". Slack commands of linked users are filed on their
+	// behalf, while the others are filed by the bot.
+	SlackUserLinks string
+
+	// AllowedInternalNetworks is a comma-separated list of CIDRs, such as "10.0.0.0/8", that the
+	// plugin may fetch or link to. Other internal addresses are always rejected.
+	AllowedInternalNetworks string
+
+	// WebhookAllowedIPs is a comma-separated list of CIDRs or addresses allowed to call the inbound
+	// webhooks. WebhookClientCertFingerprints is a comma-separated list of SHA-256 fingerprints of
+	// the client certificates accepted by the inbound webhooks, for deployments where the proxy
+	// terminating mutual TLS, listed in WebhookTrustedProxies, forwards the client certificate.
+	// Empty settings allow any caller.
+	WebhookAllowedIPs             string
+	WebhookClientCertFingerprints string
+
+	// WebhookTrustedProxies is a comma-separated list of CIDRs or addresses of the reverse proxies
+	// in front of the server. The client address forwarded by the proxy, in X-Forwarded-For or the
+	// server's trusted proxy header, is only honored for requests relayed by these proxies, since
+	// any client can set the header. When empty, the forwarded address is ignored.
+	WebhookTrustedProxies string
+
+	// WebhookSigningSecret is the secret shared with the senders of inbound webhooks. Every inbound
+	// webhook must carry the HMAC-SHA256 of its body in the X-Signature header, except the
+	// Alertmanager, Grafana and Sentry webhooks, which are authenticated with their own tokens and
+	// signatures. When empty, these webhooks are rejected unless AllowUnsignedWebhooks is set.
+	WebhookSigningSecret string
+
+	// AllowUnsignedWebhooks accepts the outgoing and generic webhooks without verifying their
+	// signature when WebhookSigningSecret is empty. The GitHub and commits webhooks always require
+	// the secret.
+	AllowUnsignedWebhooks bool
+
+	// SuggestionChannels is a comma-separated list of channel names in which the bot suggests
+	// opening an SRE request when a message contains one of the SuggestionPhrases, a
+	// comma-separated list of trigger phrases. Default phrases are used if none are configured.
+	SuggestionChannels string
+	SuggestionPhrases  string
+
+	// CommandChannels is a comma-separated list of the channels, given as "team/channel" by name,
+	// in which /sre-request and the intake dialog may be used, or of whole teams given by name or
+	// as "team/*". When empty, they may be used anywhere. Channels offering ticket suggestions
+	// should be listed too, since suggestions open the intake dialog.
+	CommandChannels string
+
+	// DefaultLanguage is the language, such as "de", of the messages posted to channels and of the
+	// command help, and the fallback for users whose language has no translation. It defaults to
+	// the default locale of the server. The command help follows it once the plugin restarts.
+	DefaultLanguage string
+
+	// ThreadingMode is either "threaded", the default, to keep every bot follow-up in the ticket's
+	// thread, or "broadcast" to also post important transitions, such as acknowledgements,
+	// escalations and resolutions, to the channel root.
+	ThreadingMode string
+
+	// LogLevel is the verbosity of the plugin logs: "debug", "info", the default, or "warn". Errors
+	// are always logged. The debug command turns debug logs on temporarily.
+	LogLevel string
+
+	// SeverityRules is a semicolon-separated list of rules suggesting the priority of a ticket from
+	// its text, each as "priority weight pattern", such as "High 3 outage; Low 2 /how do i/".
+	// Default rules are used if none are configured.
+	SeverityRules string
+
+	// RoutingRules is an ordered list of rules, separated by semicolons or newlines, routing the
+	// tickets matching their conditions, such as
+	// `category == "pipeline" AND priority >= Medium -> channel pipeline-support, group pipeline-oncall, sla 2h`.
+	// Conditions compare the category, priority, summary or description of tickets, and the first
+	// matching rule posts the ticket in a channel of its team instead of the SRE channel, mentions
+	// groups when the ticket is submitted or escalated, and overrides the SLA of its priority.
+	RoutingRules string
+
+	// AcknowledgeEmoji and ResolveEmoji are the names of the emojis that acknowledge or resolve a
+	// ticket when added to its root post. They default to "eyes" and "white_check_mark".
+	AcknowledgeEmoji string
+	ResolveEmoji     string
+
+	// PostmortemLeadTime is how long after a SEV1 resolves the postmortem review slots start, such
+	// as "48h" or "3d". Defaults to 48 hours.
+	PostmortemLeadTime string
+
+	// Dependencies is a comma-separated list of upstream dependencies checked by the deps command
+	// and monitored by the background job, each as "name URL" or "name URL status", where status
+	// is the expected HTTP status code, 200 by default.
+	Dependencies string
+
+	// AdminChannel is the channel receiving operational messages, such as configuration changes,
+	// background job failures and store drift reports, given as "team/channel" by name. When
+	// empty, configuration changes are posted to the SRE channels and the rest is only logged.
+	AdminChannel string
+
+	// StatusPageChannel is the external-facing channel receiving customer updates, given as
+	// "team/channel" by name. StatuspagePageID and StatuspageAPIKey enable the optional Statuspage
+	// integration, which mirrors customer updates into incidents of the page. Updates are rendered
+	// with StatusUpdateTemplate, a Go template of the fields .Title, .Status, .Impact and .Message,
+	// and reviewed before they are posted.
+	StatusPageChannel    string
+	StatuspagePageID     string
+	StatuspageAPIKey     string
+	StatusUpdateTemplate string
+
+	// EnableReporterConfirmation sends reporters a direct message confirming each submission,
+	// rendered with ReporterConfirmationTemplate, a Go template of the fields .Key, .Summary,
+	// .Priority, .ExpectedResponse and .Permalink.
+	EnableReporterConfirmation   bool
+	ReporterConfirmationTemplate string
+
+	// DryRun skips the calls to external systems, such as Jira, GitHub, Statuspage, the event
+	// webhook and email replies, logging them and posting them to the admin channel instead, so
+	// that routing rules and templates can be validated safely in staging.
+	DryRun bool
+
+	// VaultS3Bucket, VaultS3Region, VaultS3AccessKeyID and VaultS3SecretAccessKey enable the secure
+	// vault, an S3 bucket receiving the sensitive artifacts of tickets, such as core dumps or
+	// customer data, through presigned upload URLs so that they never land in Mattermost file
+	// storage. VaultS3Endpoint optionally selects an S3-compatible service instead of AWS S3, and
+	// VaultLinkExpiry, such as "30m", is how long vault links stay valid, an hour by default.
+	VaultS3Bucket          string
+	VaultS3Region          string
+	VaultS3AccessKeyID     string
+	VaultS3SecretAccessKey string
+	VaultS3Endpoint        string
+	VaultLinkExpiry        string
+
+	// Teams is a comma-separated list of the names of the teams the plugin is enabled in. When
+	// empty, the plugin is enabled in every team of the server.
+	Teams string
+
+	// BusinessHours, as "HH:MM-HH:MM", are the hours of the BusinessDays, such as "Mon-Fri" or
+	// "Mon,Wed,Fri", during which Low and Medium priority tickets notify their responders, in the
+	// BusinessHoursTimezone, UTC by default. Tickets submitted outside business hours are announced
+	// once they start, while High priority tickets always notify their responders immediately.
+	// Empty BusinessHours disables the business hours.
+	BusinessHours         string
+	BusinessDays          string
+	BusinessHoursTimezone string
+
+	// SREAdmins is a comma-separated list of usernames (e.g. "@alice") and role names (e.g.
+	// "system_user_manager") allowed to run destructive actions, such as deleting or resolving
+	// tickets, in addition to system admins.
+	SREAdmins string
+
+	// MinimalPermissions stops the plugin from creating or modifying users and channels, for
+	// locked-down servers. The bot account must be created beforehand, and the capabilities that
+	// require provisioning are disabled.
+	MinimalPermissions bool
+
+	// FallbackChannelName is the name of the channel receiving the SRE posts of a team whose SRE
+	// channel was archived or deleted. Defaults to the team's town square.
+	FallbackChannelName string
+
+	// JobSchedules overrides the schedules of the background jobs, as "job=schedule" entries
+	// separated by semicolons or newlines, where jobs are named as in the jobs command and
+	// schedules are either an interval, such as "5m", or a cron expression in UTC, such as
+	// "0 2 * * *". Jobs are rescheduled as soon as the setting changes.
+	JobSchedules string
+
 	// disabled tracks whether or not the plugin has been disabled after activation. It always starts enabled.
 	disabled bool
 
@@ -107,6 +409,118 @@ type configuration struct {
 
 	// demoChannelIDs maps team ids to the channels created for each using the channel name above.
 	demoChannelIDs map[string]string
+
+	// incidentCommanderID is the id of the incident commander specified above.
+	incidentCommanderID string
+
+	// slaDurations maps ticket priorities to the SLA durations parsed from the settings above.
+	slaDurations map[string]time.Duration
+
+	// jobSchedules maps the keys of the background jobs to the schedules parsed from the setting
+	// above. The schedules are never modified once parsed, so they are shared between clones.
+	jobSchedules map[string]*jobSchedule
+
+	// escalationPolicies maps ticket priorities to the escalation tiers parsed from the settings
+	// above. The tiers are never modified once parsed, so they are shared between clones.
+	escalationPolicies map[string][]*escalationTier
+
+	// dialog is the intake dialog parsed from the dialog definition above, or nil to use the
+	// built-in form. It is never modified once parsed.
+	dialog *model.Dialog
+
+	// ticketStore is the store selected by the setting above.
+	ticketStore TicketStore
+
+	// sqlStore is the SQL ticket store, kept once created so its table is only set up once.
+	sqlStore *sqlTicketStore
+
+	// suggestionChannels and suggestionPhrases are the lowercased channel names and trigger
+	// phrases parsed from the settings above.
+	suggestionChannels map[string]bool
+	suggestionPhrases  []string
+
+	// threadingMode is the validated ThreadingMode.
+	threadingMode string
+
+	// logLevel is the validated LogLevel.
+	logLevel string
+
+	// customFields are parsed from CustomFields. They are never modified once parsed, so they are
+	// shared between clones.
+	customFields []*customField
+
+	// routingRules are parsed from RoutingRules. They are never modified once parsed, so they are
+	// shared between clones.
+	routingRules []*routingRule
+
+	// severityClassifier suggests ticket priorities according to SeverityRules.
+	severityClassifier severityClassifier
+
+	// allowedNetworks are the internal networks parsed from AllowedInternalNetworks.
+	allowedNetworks []*net.IPNet
+
+	// webhookAllowedNetworks and webhookCertFingerprints are parsed from the webhook access
+	// settings above.
+	webhookAllowedNetworks  []*net.IPNet
+	webhookCertFingerprints map[string]bool
+
+	// webhookTrustedProxies are the proxies parsed from WebhookTrustedProxies.
+	webhookTrustedProxies []*net.IPNet
+
+	// postmortemLeadTime is parsed from PostmortemLeadTime.
+	postmortemLeadTime time.Duration
+
+	// dependencies are parsed from Dependencies.
+	dependencies []dependency
+
+	// genericWebhooks are the sources parsed from GenericWebhooks.
+	genericWebhooks map[string]*genericWebhook
+
+	// githubAppKey is the private key parsed from GitHubAppPrivateKey, or nil if the GitHub App is
+	// not configured. It is never modified, so it is shared between clones.
+	githubAppKey *rsa.PrivateKey
+
+	// vaultLinkExpiry is parsed from VaultLinkExpiry.
+	vaultLinkExpiry time.Duration
+
+	// businessHours are parsed from BusinessHours, or nil if they are disabled. They are never
+	// modified, so they are shared between clones.
+	businessHours *businessHours
+
+	// statusPageChannelID is the id of the channel named by StatusPageChannel.
+	statusPageChannelID string
+
+	// statusUpdateTemplate is parsed from StatusUpdateTemplate. Templates are safe for concurrent
+	// use, so it is shared between clones.
+	statusUpdateTemplate *template.Template
+
+	// reporterConfirmationTemplate is parsed from ReporterConfirmationTemplate, and shared between
+	// clones like statusUpdateTemplate.
+	reporterConfirmationTemplate *template.Template
+
+	// scopedTeamNames are the lowercase team names parsed from Teams, or nil for every team.
+	scopedTeamNames map[string]bool
+
+	// sreAdminIDs and sreAdminRoles are the users and roles parsed from SREAdmins.
+	sreAdminIDs   map[string]bool
+	sreAdminRoles map[string]bool
+
+	// adminChannelID is the id of the channel named by AdminChannel.
+	adminChannelID string
+
+	// commandChannelTeams maps the channels listed by CommandChannels to their team, and
+	// commandTeamIDs are the teams it allows entirely.
+	commandChannelTeams map[string]string
+	commandTeamIDs      map[string]bool
+
+	// slackUserLinks maps the Slack user ids listed by SlackUserLinks to Mattermost usernames.
+	slackUserLinks map[string]string
+
+	// disabledCapabilities describes the capabilities disabled by the minimal-permission mode.
+	disabledCapabilities []string
+
+	// postPriorityEnabled is whether the server supports message priorities and has them enabled.
+	postPriorityEnabled bool
 }
 
 func PrettyJSON(in interface{}) (string, error) {
@@ -117,7 +531,6 @@ func PrettyJSON(in interface{}) (string, error) {
 	return string(bb), nil
 }
 
-
 // Clone deep copies the configuration. Your implementation may only require a shallow copy if
 // your configuration has no reference types.
 func (c *configuration) Clone() *configuration {
@@ -127,20 +540,213 @@ func (c *configuration) Clone() *configuration {
 		demoChannelIDs[key] = value
 	}
 
+	// Deep copy escalationPolicies, a reference type.
+	escalationPolicies := make(map[string][]*escalationTier)
+	for key, value := range c.escalationPolicies {
+		escalationPolicies[key] = append([]*escalationTier(nil), value...)
+	}
+
+	// Deep copy slaDurations, a reference type.
+	slaDurations := make(map[string]time.Duration)
+	for key, value := range c.slaDurations {
+		slaDurations[key] = value
+	}
+
+	// Deep copy jobSchedules, a reference type.
+	jobSchedules := make(map[string]*jobSchedule)
+	for key, value := range c.jobSchedules {
+		jobSchedules[key] = value
+	}
+
+	// Deep copy suggestionChannels, a reference type.
+	suggestionChannels := make(map[string]bool)
+	for key, value := range c.suggestionChannels {
+		suggestionChannels[key] = value
+	}
+
+	// Deep copy scopedTeamNames, a reference type, preserving nil for every team.
+	var scopedTeamNames map[string]bool
+	if c.scopedTeamNames != nil {
+		scopedTeamNames = make(map[string]bool)
+		for key, value := range c.scopedTeamNames {
+			scopedTeamNames[key] = value
+		}
+	}
+
+	// Deep copy sreAdminIDs, a reference type.
+	sreAdminIDs := make(map[string]bool)
+	for key, value := range c.sreAdminIDs {
+		sreAdminIDs[key] = value
+	}
+
+	// Deep copy sreAdminRoles, a reference type.
+	sreAdminRoles := make(map[string]bool)
+	for key, value := range c.sreAdminRoles {
+		sreAdminRoles[key] = value
+	}
+
+	// Deep copy commandChannelTeams, a reference type.
+	commandChannelTeams := make(map[string]string)
+	for key, value := range c.commandChannelTeams {
+		commandChannelTeams[key] = value
+	}
+
+	// Deep copy commandTeamIDs, a reference type.
+	commandTeamIDs := make(map[string]bool)
+	for key, value := range c.commandTeamIDs {
+		commandTeamIDs[key] = value
+	}
+
+	// Deep copy slackUserLinks, a reference type.
+	slackUserLinks := make(map[string]string)
+	for key, value := range c.slackUserLinks {
+		slackUserLinks[key] = value
+	}
+
+	// Deep copy genericWebhooks, a reference type. The sources themselves are never modified.
+	genericWebhooks := make(map[string]*genericWebhook)
+	for key, value := range c.genericWebhooks {
+		genericWebhooks[key] = value
+	}
+
+	// Deep copy webhookCertFingerprints, a reference type.
+	webhookCertFingerprints := make(map[string]bool)
+	for key, value := range c.webhookCertFingerprints {
+		webhookCertFingerprints[key] = value
+	}
+
 	return &configuration{
-		Username:                c.Username,
-		ChannelName:             c.ChannelName,
-		LastName:                c.LastName,
-		TextStyle:               c.TextStyle,
-		RandomSecret:            c.RandomSecret,
-		SecretMessage:           c.SecretMessage,
-		EnableMentionUser:       c.EnableMentionUser,
-		MentionUser:             c.MentionUser,
-		SecretNumber:            c.SecretNumber,
-		IntegrationRequestDelay: c.IntegrationRequestDelay,
-		disabled:                c.disabled,
-		demoUserID:              c.demoUserID,
-		demoChannelIDs:          demoChannelIDs,
+		Username:                       c.Username,
+		ChannelName:                    c.ChannelName,
+		LastName:                       c.LastName,
+		TextStyle:                      c.TextStyle,
+		RandomSecret:                   c.RandomSecret,
+		SecretMessage:                  c.SecretMessage,
+		EnableMentionUser:              c.EnableMentionUser,
+		MentionUser:                    c.MentionUser,
+		SecretNumber:                   c.SecretNumber,
+		IntegrationRequestDelay:        c.IntegrationRequestDelay,
+		EnableCrashSimulation:          c.EnableCrashSimulation,
+		IncidentCommander:              c.IncidentCommander,
+		HighPrioritySLA:                c.HighPrioritySLA,
+		MediumPrioritySLA:              c.MediumPrioritySLA,
+		LowPrioritySLA:                 c.LowPrioritySLA,
+		HighPriorityResponders:         c.HighPriorityResponders,
+		MediumPriorityResponders:       c.MediumPriorityResponders,
+		LowPriorityResponders:          c.LowPriorityResponders,
+		HighPriorityEscalationPolicy:   c.HighPriorityEscalationPolicy,
+		MediumPriorityEscalationPolicy: c.MediumPriorityEscalationPolicy,
+		LowPriorityEscalationPolicy:    c.LowPriorityEscalationPolicy,
+		EscalationUsers:                c.EscalationUsers,
+		DialogDefinition:               c.DialogDefinition,
+		CustomFields:                   c.CustomFields,
+		TicketStore:                    c.TicketStore,
+		TicketStorePrimary:             c.TicketStorePrimary,
+		MaxDescriptionLength:           c.MaxDescriptionLength,
+		JiraBaseURL:                    c.JiraBaseURL,
+		GitHubRepository:               c.GitHubRepository,
+		GitHubToken:                    c.GitHubToken,
+		GitHubAppID:                    c.GitHubAppID,
+		GitHubAppInstallationID:        c.GitHubAppInstallationID,
+		GitHubAppPrivateKey:            c.GitHubAppPrivateKey,
+		JiraProjectKey:                 c.JiraProjectKey,
+		JiraUsername:                   c.JiraUsername,
+		JiraAPIToken:                   c.JiraAPIToken,
+		EventWebhookURL:                c.EventWebhookURL,
+		EventWebhookSecret:             c.EventWebhookSecret,
+		AlertmanagerToken:              c.AlertmanagerToken,
+		GrafanaToken:                   c.GrafanaToken,
+		SentryClientSecret:             c.SentryClientSecret,
+		GenericWebhooks:                c.GenericWebhooks,
+		SlackSigningSecret:             c.SlackSigningSecret,
+		SlackUserLinks:                 c.SlackUserLinks,
+		EmailIMAPServer:                c.EmailIMAPServer,
+		EmailSMTPServer:                c.EmailSMTPServer,
+		EmailUsername:                  c.EmailUsername,
+		EmailPassword:                  c.EmailPassword,
+		EmailMailbox:                   c.EmailMailbox,
+		EmailTeam:                      c.EmailTeam,
+		EmailAddress:                   c.EmailAddress,
+		EmailAuthservID:                c.EmailAuthservID,
+		AllowedInternalNetworks:        c.AllowedInternalNetworks,
+		WebhookAllowedIPs:              c.WebhookAllowedIPs,
+		WebhookTrustedProxies:          c.WebhookTrustedProxies,
+		WebhookClientCertFingerprints:  c.WebhookClientCertFingerprints,
+		WebhookSigningSecret:           c.WebhookSigningSecret,
+		AllowUnsignedWebhooks:          c.AllowUnsignedWebhooks,
+		SuggestionChannels:             c.SuggestionChannels,
+		SuggestionPhrases:              c.SuggestionPhrases,
+		CommandChannels:                c.CommandChannels,
+		SeverityRules:                  c.SeverityRules,
+		RoutingRules:                   c.RoutingRules,
+		ThreadingMode:                  c.ThreadingMode,
+		LogLevel:                       c.LogLevel,
+		DefaultLanguage:                c.DefaultLanguage,
+		AcknowledgeEmoji:               c.AcknowledgeEmoji,
+		ResolveEmoji:                   c.ResolveEmoji,
+		PostmortemLeadTime:             c.PostmortemLeadTime,
+		Dependencies:                   c.Dependencies,
+		FallbackChannelName:            c.FallbackChannelName,
+		JobSchedules:                   c.JobSchedules,
+		MinimalPermissions:             c.MinimalPermissions,
+		AdminChannel:                   c.AdminChannel,
+		SREAdmins:                      c.SREAdmins,
+		Teams:                          c.Teams,
+		BusinessHours:                  c.BusinessHours,
+		BusinessDays:                   c.BusinessDays,
+		BusinessHoursTimezone:          c.BusinessHoursTimezone,
+		StatusPageChannel:              c.StatusPageChannel,
+		StatuspagePageID:               c.StatuspagePageID,
+		StatuspageAPIKey:               c.StatuspageAPIKey,
+		StatusUpdateTemplate:           c.StatusUpdateTemplate,
+		EnableReporterConfirmation:     c.EnableReporterConfirmation,
+		DryRun:                         c.DryRun,
+		ReporterConfirmationTemplate:   c.ReporterConfirmationTemplate,
+		VaultS3Bucket:                  c.VaultS3Bucket,
+		VaultS3Region:                  c.VaultS3Region,
+		VaultS3AccessKeyID:             c.VaultS3AccessKeyID,
+		VaultS3SecretAccessKey:         c.VaultS3SecretAccessKey,
+		VaultS3Endpoint:                c.VaultS3Endpoint,
+		VaultLinkExpiry:                c.VaultLinkExpiry,
+		disabled:                       c.disabled,
+		demoUserID:                     c.demoUserID,
+		demoChannelIDs:                 demoChannelIDs,
+		incidentCommanderID:            c.incidentCommanderID,
+		slaDurations:                   slaDurations,
+		jobSchedules:                   jobSchedules,
+		escalationPolicies:             escalationPolicies,
+		dialog:                         c.dialog,
+		ticketStore:                    c.ticketStore,
+		sqlStore:                       c.sqlStore,
+		suggestionChannels:             suggestionChannels,
+		webhookAllowedNetworks:         append([]*net.IPNet(nil), c.webhookAllowedNetworks...),
+		webhookTrustedProxies:          append([]*net.IPNet(nil), c.webhookTrustedProxies...),
+		webhookCertFingerprints:        webhookCertFingerprints,
+		allowedNetworks:                append([]*net.IPNet(nil), c.allowedNetworks...),
+		suggestionPhrases:              append([]string(nil), c.suggestionPhrases...),
+		severityClassifier:             c.severityClassifier,
+		routingRules:                   append([]*routingRule(nil), c.routingRules...),
+		customFields:                   append([]*customField(nil), c.customFields...),
+		threadingMode:                  c.threadingMode,
+		logLevel:                       c.logLevel,
+		postmortemLeadTime:             c.postmortemLeadTime,
+		dependencies:                   append([]dependency(nil), c.dependencies...),
+		postPriorityEnabled:            c.postPriorityEnabled,
+		disabledCapabilities:           append([]string(nil), c.disabledCapabilities...),
+		adminChannelID:                 c.adminChannelID,
+		commandChannelTeams:            commandChannelTeams,
+		commandTeamIDs:                 commandTeamIDs,
+		slackUserLinks:                 slackUserLinks,
+		scopedTeamNames:                scopedTeamNames,
+		genericWebhooks:                genericWebhooks,
+		githubAppKey:                   c.githubAppKey,
+		businessHours:                  c.businessHours,
+		vaultLinkExpiry:                c.vaultLinkExpiry,
+		statusPageChannelID:            c.statusPageChannelID,
+		statusUpdateTemplate:           c.statusUpdateTemplate,
+		reporterConfirmationTemplate:   c.reporterConfirmationTemplate,
+		sreAdminIDs:                    sreAdminIDs,
+		sreAdminRoles:                  sreAdminRoles,
 	}
 }
 
@@ -214,7 +820,13 @@ func (p *Plugin) diffConfiguration(newConfiguration *configuration) {
 		return
 	}
 
-	teams, err := p.API.GetTeams()
+	// Keep configuration changes out of the SRE channels when an admin channel is configured.
+	if newConfiguration.adminChannelID != "" {
+		p.postConfigurationDiff(newConfiguration, newConfiguration.adminChannelID, configurationDiff)
+		return
+	}
+
+	teams, err := p.listScopedTeams(newConfiguration)
 	if err != nil {
 		p.API.LogWarn("Failed to query teams OnConfigChange", "err", err)
 		return
@@ -227,30 +839,70 @@ func (p *Plugin) diffConfiguration(newConfiguration *configuration) {
 			continue
 		}
 
-		newConfigurationData, jsonErr := json.Marshal(newConfiguration)
-		if jsonErr != nil {
-			p.API.LogWarn("Failed to marshal new configuration", "err", err)
+		if !p.postConfigurationDiff(newConfiguration, demoChannelID, configurationDiff) {
 			return
 		}
+	}
+}
 
-		fileInfo, err := p.API.UploadFile(newConfigurationData, demoChannelID, "configuration.json")
-		if err != nil {
-			p.API.LogWarn("Failed to attach new configuration", "err", err)
-			return
-		}
+// postConfigurationDiff posts the changed settings to the channel, with the new configuration
+// attached. It returns false if posting failed.
+func (p *Plugin) postConfigurationDiff(newConfiguration *configuration, channelID string, configurationDiff map[string]interface{}) bool {
+	newConfigurationData, jsonErr := json.Marshal(newConfiguration.redacted())
+	if jsonErr != nil {
+		p.API.LogWarn("Failed to marshal new configuration", "err", jsonErr)
+		return false
+	}
 
-		if _, err := p.API.CreatePost(&model.Post{
-			UserId:    p.botID,
-			ChannelId: demoChannelID,
-			Message:   "OnConfigChange: loading new configuration",
-			Type:      "custom_demo_plugin",
-			Props:     configurationDiff,
-			FileIds:   model.StringArray{fileInfo.Id},
-		}); err != nil {
-			p.API.LogWarn("Failed to post OnConfigChange message", "err", err)
-			return
+	fileInfo, err := p.API.UploadFile(newConfigurationData, channelID, "configuration.json")
+	if err != nil {
+		p.API.LogWarn("Failed to attach new configuration", "err", err)
+		return false
+	}
+
+	if _, err := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: channelID,
+		Message:   "OnConfigChange: loading new configuration",
+		Type:      "custom_demo_plugin",
+		Props:     configurationDiff,
+		FileIds:   model.StringArray{fileInfo.Id},
+	}); err != nil {
+		p.API.LogWarn("Failed to post OnConfigChange message", "err", err)
+		return false
+	}
+
+	return true
+}
+
+// redacted returns a copy of the configuration safe to share with channel members, with the
+// secret settings replaced by "<HIDDEN>".
+func (c *configuration) redacted() *configuration {
+	redacted := c.Clone()
+
+	for _, secret := range []*string{
+		&redacted.RandomSecret,
+		&redacted.JiraAPIToken,
+		&redacted.GitHubToken,
+		&redacted.GitHubAppPrivateKey,
+		&redacted.EventWebhookSecret,
+		&redacted.AlertmanagerToken,
+		&redacted.GrafanaToken,
+		&redacted.SentryClientSecret,
+		// The generic webhook sources embed their tokens.
+		&redacted.GenericWebhooks,
+		&redacted.EmailPassword,
+		&redacted.SlackSigningSecret,
+		&redacted.WebhookSigningSecret,
+		&redacted.StatuspageAPIKey,
+		&redacted.VaultS3SecretAccessKey,
+	} {
+		if *secret != "" {
+			*secret = "<HIDDEN>"
 		}
 	}
+
+	return redacted
 }
 
 // OnConfigurationChange is invoked when configuration changes may have been made.
@@ -264,37 +916,181 @@ func (p *Plugin) OnConfigurationChange() error {
 
 	configuration := p.getConfiguration().Clone()
 
+	// Teams may have been created or renamed since the cache was filled.
+	p.teamCache.invalidate()
+
 	// Load the public configuration fields from the Mattermost server configuration.
 	if loadConfigErr := p.API.LoadPluginConfiguration(configuration); loadConfigErr != nil {
 		return errors.Wrap(loadConfigErr, "failed to load plugin configuration")
 	}
 
-	demoUserID, err := p.ensureDemoUser(configuration)
+	configuration.scopedTeamNames = parseScopedTeams(configuration)
+
+	var err error
+	configuration.disabledCapabilities = nil
+	if configuration.MinimalPermissions {
+		if err = p.setupMinimalPermissions(configuration); err != nil {
+			return err
+		}
+	} else {
+		demoUserID, err := p.ensureDemoUser(configuration)
+		if err != nil {
+			return errors.Wrap(err, "failed to ensure demo user")
+		}
+		configuration.demoUserID = demoUserID
+
+		botID, ensureBotError := p.client.Bot.EnsureBot(&model.Bot{
+			Username:    botUsername,
+			DisplayName: "Demo Plugin Bot",
+			Description: "A bot account created by the demo plugin.",
+		}, pluginapi.ProfileImagePath("/assets/icon.png"))
+		if ensureBotError != nil {
+			return errors.Wrap(ensureBotError, "failed to ensure demo bot")
+		}
+
+		p.botID = botID
+
+		configuration.demoChannelIDs, err = p.ensureDemoChannels(configuration)
+		if err != nil {
+			return errors.Wrap(err, "failed to ensure demo channels")
+		}
+	}
+
+	configuration.incidentCommanderID = p.lookupUserID(configuration.IncidentCommander)
+
+	configuration.adminChannelID = p.resolveChannelSetting("AdminChannel", configuration.AdminChannel)
+	configuration.statusPageChannelID = p.resolveChannelSetting("StatusPageChannel", configuration.StatusPageChannel)
+	configuration.commandChannelTeams, configuration.commandTeamIDs = p.parseCommandChannels(configuration)
+
+	configuration.statusUpdateTemplate, err = parseStatusUpdateTemplate(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse status update template")
+	}
+
+	configuration.reporterConfirmationTemplate, err = parseReporterConfirmationTemplate(configuration)
 	if err != nil {
-		return errors.Wrap(err, "failed to ensure demo user")
+		return errors.Wrap(err, "failed to parse reporter confirmation template")
 	}
-	configuration.demoUserID = demoUserID
 
-	botID, ensureBotError := p.client.Bot.EnsureBot(&model.Bot{
-		Username:    "demoplugin",
-		DisplayName: "Demo Plugin Bot",
-		Description: "A bot account created by the demo plugin.",
-	}, pluginapi.ProfileImagePath("/assets/icon.png"))
-	if ensureBotError != nil {
-		return errors.Wrap(ensureBotError, "failed to ensure demo bot")
+	configuration.githubAppKey, err = parseGitHubAppKey(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse GitHub App settings")
+	}
+
+	configuration.businessHours, err = parseBusinessHours(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse business hours")
+	}
+
+	configuration.vaultLinkExpiry, err = parseVaultLinkExpiry(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse vault settings")
 	}
 
-	p.botID = botID
+	configuration.sreAdminIDs, configuration.sreAdminRoles = p.parseSREAdmins(configuration)
 
-	configuration.demoChannelIDs, err = p.ensureDemoChannels(configuration)
+	configuration.slaDurations, err = parseSLADurations(configuration)
 	if err != nil {
-		return errors.Wrap(err, "failed to ensure demo channels")
+		return errors.Wrap(err, "failed to parse SLA durations")
+	}
+
+	configuration.escalationPolicies, err = parseEscalationPolicies(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse escalation policies")
+	}
+
+	configuration.jobSchedules, err = parseJobSchedules(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse job schedules")
+	}
+
+	configuration.slackUserLinks, err = parseSlackUserLinks(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Slack user links")
+	}
+
+	configuration.ticketStore, err = p.newTicketStore(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize ticket store")
+	}
+
+	configuration.allowedNetworks, err = utils.ParseNetworks(configuration.AllowedInternalNetworks)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse allowed internal networks")
+	}
+
+	if err = parseWebhookAccessSettings(configuration); err != nil {
+		return errors.Wrap(err, "failed to parse webhook access settings")
+	}
+
+	configuration.suggestionChannels, configuration.suggestionPhrases = parseSuggestionSettings(configuration)
+
+	configuration.postmortemLeadTime, err = parsePostmortemLeadTime(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse postmortem lead time")
+	}
+
+	configuration.dependencies, err = parseDependencies(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse dependencies")
+	}
+
+	configuration.genericWebhooks, err = parseGenericWebhooks(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse generic webhooks")
+	}
+
+	configuration.severityClassifier, err = parseSeverityRules(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse severity rules")
+	}
+
+	configuration.customFields, err = parseCustomFields(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse custom fields")
+	}
+
+	configuration.routingRules, err = parseRoutingRules(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse routing rules")
+	}
+
+	configuration.threadingMode, err = parseThreadingMode(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse threading mode")
+	}
+
+	configuration.logLevel, err = parseLogLevel(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse log level")
+	}
+
+	// The server configuration may enable or disable message priorities at any time, which also
+	// triggers this hook.
+	configuration.postPriorityEnabled = p.detectPostPriority()
+
+	configuration.dialog = nil
+	if strings.TrimSpace(configuration.DialogDefinition) != "" {
+		dialog, dialogErr := parseDialogDefinition(configuration.DialogDefinition)
+		if dialogErr != nil {
+			p.API.LogWarn("Invalid dialog definition, falling back to the built-in form", "err", dialogErr.Error())
+		} else {
+			configuration.dialog = dialog
+		}
 	}
 
 	p.diffConfiguration(configuration)
 
 	p.setConfiguration(configuration)
 
+	// The jobs are first scheduled once the plugin is activated, and rescheduled here without a
+	// restart whenever their schedules change.
+	if p.jobsScheduled() {
+		if err := p.scheduleJobs(configuration); err != nil {
+			p.API.LogError("Failed to reschedule jobs", "err", err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -305,7 +1101,8 @@ func (p *Plugin) OnConfigurationChange() error {
 // Minimum server version: 8.0
 //
 // This demo implementation logs a message to the demo channel whenever config
-// is going to be saved.
+// is going to be saved. The message is posted in the background, so that slow
+// posts never delay saving the configuration.
 // If the Username config option is set to "invalid" an error will be
 // returned, resulting in the config not getting saved.
 // If the Username config option is set to "replaceme" the config value will be
@@ -316,15 +1113,6 @@ func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config,
 		return nil, nil
 	}
 
-	teams, appErr := p.API.GetTeams()
-	if appErr != nil {
-		p.API.LogError(
-			"Failed to query teams ConfigurationWillBeSaved",
-			"error", appErr.Error(),
-		)
-		return nil, nil
-	}
-
 	msg := "Configuration will be saved"
 
 	configData := newCfg.PluginSettings.Plugins[manifest.Id]
@@ -337,7 +1125,9 @@ func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config,
 		return nil, nil
 	}
 
-	if err := json.Unmarshal(js, &cfg); err != nil {
+	// Decode the new settings into their own struct, since the active configuration is shared.
+	var savedCfg configuration
+	if err := json.Unmarshal(js, &savedCfg); err != nil {
 		p.API.LogError(
 			"Failed to unmarshal config data ConfigurationWillBeSaved",
 			"error", err.Error(),
@@ -345,12 +1135,8 @@ func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config,
 		return nil, nil
 	}
 
-	if cfg == nil {
-		return newCfg, nil
-	}
-
-	invalidUsernameUsed := cfg.Username == "invalid"
-	replaceUsernameUsed := cfg.Username == "replaceme"
+	invalidUsernameUsed := savedCfg.Username == "invalid"
+	replaceUsernameUsed := savedCfg.Username == "replaceme"
 
 	if invalidUsernameUsed {
 		msg = "Configuration won't be saved, invalid Username value used"
@@ -358,14 +1144,11 @@ func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config,
 		msg = "Configuration will be save, replacing Username value"
 	}
 
-	for _, team := range teams {
-		if err := p.postPluginMessage(team.Id, msg); err != nil {
-			p.API.LogError(
-				"Failed to post ConfigurationWillBeSaved message",
-				"channel_id", cfg.demoChannelIDs[team.Id],
-				"error", err.Error(),
-			)
-		}
+	// Keep configuration changes out of the SRE channels when an admin channel is configured.
+	if cfg.adminChannelID != "" {
+		go p.postAdminNotice(msg)
+	} else {
+		p.postConfigurationNotice(cfg, msg)
 	}
 
 	if invalidUsernameUsed {
@@ -380,6 +1163,43 @@ func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config,
 	return nil, nil
 }
 
+// configurationNoticeWorkers bounds the configuration notices posted concurrently to the SRE
+// channels.
+const configurationNoticeWorkers = 4
+
+// postConfigurationNotice posts the message to the SRE channel of every team that has one, in the
+// background with at most configurationNoticeWorkers posts in flight. The message is formatted
+// once for every channel.
+func (p *Plugin) postConfigurationNotice(configuration *configuration, msg string) {
+	message := configuration.formatPluginMessage(msg)
+
+	channelIDs := make(chan string)
+	for i := 0; i < configurationNoticeWorkers; i++ {
+		go func() {
+			for channelID := range channelIDs {
+				if _, appErr := p.API.CreatePost(&model.Post{
+					UserId:    p.botID,
+					ChannelId: channelID,
+					Message:   message,
+				}); appErr != nil {
+					p.API.LogError(
+						"Failed to post ConfigurationWillBeSaved message",
+						"channel_id", channelID,
+						"error", appErr.Error(),
+					)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(channelIDs)
+		for _, channelID := range configuration.demoChannelIDs {
+			channelIDs <- channelID
+		}
+	}()
+}
+
 func (p *Plugin) ensureDemoUser(configuration *configuration) (string, error) {
 	user, err := p.API.GetUserByUsername(configuration.Username)
 	if err != nil {
@@ -413,7 +1233,7 @@ func (p *Plugin) ensureDemoUser(configuration *configuration) (string, error) {
 		}
 	}
 
-	teams, err := p.API.GetTeams()
+	teams, err := p.listScopedTeams(configuration)
 	if err != nil {
 		return "", err
 	}
@@ -429,40 +1249,70 @@ func (p *Plugin) ensureDemoUser(configuration *configuration) (string, error) {
 }
 
 func (p *Plugin) ensureDemoChannels(configuration *configuration) (map[string]string, error) {
-	teams, err := p.API.GetTeams()
+	teams, err := p.listScopedTeams(configuration)
 	if err != nil {
 		return nil, err
 	}
 
 	demoChannelIDs := make(map[string]string)
 	for _, team := range teams {
-		// Check for the configured channel. Ignore any error, since it's hard to
-		// distinguish runtime errors from a channel simply not existing.
-		channel, _ := p.API.GetChannelByNameForTeamName(team.Name, configuration.ChannelName, false)
-
-		// Ensure the configured channel exists.
-		if channel == nil {
-			channel, err = p.API.CreateChannel(&model.Channel{
-				TeamId:      team.Id,
-				Type:        model.ChannelTypeOpen,
-				DisplayName: "Demo Plugin",
-				Name:        configuration.ChannelName,
-				Header:      "The channel used by the demo plugin.",
-				Purpose:     "This channel was created by a plugin for testing.",
-			})
-
-			if err != nil {
-				return nil, err
-			}
+		channelID, err := p.ensureDemoChannel(configuration, team)
+		if err != nil {
+			return nil, err
 		}
 
 		// Save the ids for later use.
-		demoChannelIDs[team.Id] = channel.Id
+		demoChannelIDs[team.Id] = channelID
 	}
 
 	return demoChannelIDs, nil
 }
 
+// ensureDemoChannel ensures the configured channel exists in the team, returning its id.
+func (p *Plugin) ensureDemoChannel(configuration *configuration, team *model.Team) (string, *model.AppError) {
+	// Check for the configured channel. Ignore any error, since it's hard to
+	// distinguish runtime errors from a channel simply not existing. Archived channels are
+	// included, since their name cannot be reused, and posts routed to them fall back to the
+	// fallback channel until they are restored.
+	channel, _ := p.API.GetChannelByNameForTeamName(team.Name, configuration.ChannelName, true)
+
+	// Ensure the configured channel exists.
+	if channel == nil {
+		var err *model.AppError
+		channel, err = p.API.CreateChannel(&model.Channel{
+			TeamId:      team.Id,
+			Type:        model.ChannelTypeOpen,
+			DisplayName: "Demo Plugin",
+			Name:        configuration.ChannelName,
+			Header:      "The channel used by the demo plugin.",
+			Purpose:     "This channel was created by a plugin for testing.",
+		})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return channel.Id, nil
+}
+
+// lookupUserID returns the id of the user with the given username, or an empty string if the
+// username is empty or the user cannot be found.
+func (p *Plugin) lookupUserID(username string) string {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	if username == "" {
+		return ""
+	}
+
+	user, appErr := p.API.GetUserByUsername(username)
+	if appErr != nil {
+		p.API.LogWarn("Failed to find user", "username", username, "err", appErr.Error())
+		return ""
+	}
+
+	return user.Id
+}
+
 // setEnabled wraps setConfiguration to configure if the plugin is enabled.
 func (p *Plugin) setEnabled(enabled bool) {
 	var configuration = p.getConfiguration().Clone()
@@ -487,21 +1337,81 @@ type Plugin struct {
 	// BotId of the created bot account.
 	botID string
 
-	// backgroundJob is a job that executes periodically on only one plugin instance at a time
-	backgroundJob *cluster.Job
+	// jobsLock synchronizes access to jobs.
+	jobsLock sync.Mutex
+
+	// jobs holds the scheduled background jobs by key, each executing periodically on only one
+	// plugin instance at a time, or is nil while the plugin is not activated.
+	jobs map[string]*scheduledJob
+
+	// i18nBundle holds the translations of bot messages, dialogs and command help, or nil if they
+	// could not be loaded.
+	i18nBundle *i18n.Bundle
+
+	// ticketCache holds the tickets served to list and search requests.
+	ticketCache ticketCache
+
+	// githubAppToken caches the installation access token of the GitHub App.
+	githubAppToken githubAppToken
+
+	// teamCache holds the teams looked up by the configuration hooks and ticket permalinks.
+	teamCache teamCache
+
+	// disabledTeams holds the teams the plugin was disabled in with the enable and disable commands.
+	disabledTeams disabledTeamsCache
+
+	// debugLogs holds the debug logs toggle of the debug command.
+	debugLogs debugLogsCache
+
+	// stopTeamCacheRefresh stops the background refresh of the team cache.
+	stopTeamCacheRefresh chan struct{}
+
+	// jobFailureReports rate limits the job failures reported to the admin channel.
+	jobFailureReports jobFailureReports
+
+	// loadShedder degrades non-essential features while the Mattermost API is failing or slow.
+	loadShedder loadShedder
+
+	// statusBannerLock serializes the updates of the status banners of the SRE channel headers.
+	statusBannerLock sync.Mutex
+
+	// reminderScheduler runs the ticket reminders once across the cluster, or is nil if it failed to
+	// start.
+	reminderScheduler *cluster.JobOnceScheduler
 }
 
 func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
 	p.router.ServeHTTP(w, r)
 }
 
+func (p *Plugin) writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		p.API.LogError("Failed to marshal JSON response", "error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(b); err != nil {
+		p.API.LogError("Failed to write JSON response", "error", err.Error())
+	}
+}
+
 func (p *Plugin) handleStatus(w http.ResponseWriter, r *http.Request) {
 	configuration := p.getConfiguration()
 
+	disabledTeams, err := p.disabledTeamNames()
+	if err != nil {
+		p.API.LogError("Failed to list disabled teams", "err", err.Error())
+	}
+
 	var response = struct {
-		Enabled bool `json:"enabled"`
+		Enabled       bool     `json:"enabled"`
+		DisabledTeams []string `json:"disabled_teams"`
 	}{
-		Enabled: !configuration.disabled,
+		Enabled:       !configuration.disabled,
+		DisabledTeams: disabledTeams,
 	}
 
 	responseJSON, _ := json.Marshal(response)
@@ -735,6 +1645,8 @@ func (p *Plugin) handleDialogWithError(w http.ResponseWriter, r *http.Request) {
 
 func (p *Plugin) initializeAPI() {
 	router := mux.NewRouter()
+	router.Use(p.recoverPanics)
+	router.Use(p.withRequestLogger)
 
 	router.HandleFunc("/status", p.handleStatus)
 	router.HandleFunc("/hello", p.handleHello)
@@ -743,22 +1655,61 @@ func (p *Plugin) initializeAPI() {
 
 	webhook := router.PathPrefix("/webhook").Subrouter()
 	webhook.Use(p.withDelay)
+	webhook.Use(p.countWebhookUsage)
+	webhook.Use(p.webhookAccessControl)
+	webhook.Use(p.verifyWebhookSignature)
+	webhook.Use(p.deduplicateDeliveries)
 	webhook.HandleFunc("/outgoing", p.handleOutgoingWebhook).Methods(http.MethodPost)
+	webhook.HandleFunc("/generic/{source}", p.handleGenericWebhook).Methods(http.MethodPost)
+	webhook.HandleFunc("/github", p.handleGitHubWebhook).Methods(http.MethodPost)
+	webhook.HandleFunc("/commits", p.handleCommitsWebhook).Methods(http.MethodPost)
+
+	// Alertmanager, Grafana and Sentry cannot sign their deliveries with WebhookSigningSecret, so
+	// their endpoints verify their own bearer tokens and signatures instead, before deliveries are
+	// deduplicated.
+	integrations := router.PathPrefix("/webhook").Subrouter()
+	integrations.Use(p.withDelay)
+	integrations.Use(p.countWebhookUsage)
+	integrations.Use(p.webhookAccessControl)
+	integrations.Handle("/alertmanager", p.verifyAlertmanagerToken(p.deduplicateDeliveries(http.HandlerFunc(p.handleAlertmanager)))).Methods(http.MethodPost)
+	integrations.Handle("/grafana", p.verifyGrafanaToken(p.deduplicateDeliveries(http.HandlerFunc(p.handleGrafana)))).Methods(http.MethodPost)
+	integrations.Handle("/sentry", p.verifySentrySignature(p.deduplicateDeliveries(http.HandlerFunc(p.handleSentry)))).Methods(http.MethodPost)
+
+	// Slack-compatible endpoints are verified with Slack's own signatures rather than
+	// WebhookSigningSecret.
+	compat := router.PathPrefix("/compat").Subrouter()
+	compat.Use(p.withDelay)
+	compat.Use(p.webhookAccessControl)
+	compat.HandleFunc("/slack/command", p.handleSlackCommand).Methods(http.MethodPost)
 
 	interativeRouter := router.PathPrefix("/interactive").Subrouter()
 	interativeRouter.Use(p.withDelay)
 	interativeRouter.HandleFunc("/button/1", p.handleInteractiveAction)
+	interativeRouter.HandleFunc("/ticket/{action}", p.handleTicketAction).Methods(http.MethodPost)
+	interativeRouter.HandleFunc("/sre/force", p.handleSubmitAnyway).Methods(http.MethodPost)
+	interativeRouter.HandleFunc("/sre/suggest", p.handleSuggestTicket).Methods(http.MethodPost)
+	interativeRouter.HandleFunc("/sre/details", p.handleIntakeDetails).Methods(http.MethodPost)
 
 	dialogRouter := router.PathPrefix("/dialog").Subrouter()
 	dialogRouter.Use(p.withDelay)
 	dialogRouter.HandleFunc("/1", p.handleDialog1)
 	dialogRouter.HandleFunc("/2", p.handleDialog2)
 	dialogRouter.HandleFunc("/error", p.handleDialogWithError)
+	dialogRouter.HandleFunc("/sre", p.handleDialog)
+	dialogRouter.HandleFunc("/sre/category", p.handleCategoryDialog)
+	dialogRouter.HandleFunc("/sre/statuspage", p.handleStatusUpdateDialog)
+	dialogRouter.HandleFunc("/sre/priority", p.handlePriorityChangeDialog)
+	dialogRouter.HandleFunc("/sre/satisfaction", p.handleSatisfactionDialog)
+
+	autocompleteRouter := router.PathPrefix("/autocomplete").Subrouter()
+	autocompleteRouter.Use(p.mattermostAuthorizationRequired)
+	autocompleteRouter.HandleFunc("/tickets", p.handleAutocompleteTickets).Methods(http.MethodGet)
+
+	p.initializeTicketAPI(router)
 
 	p.router = router
 }
 
-
 func (p *Plugin) handleDialog1(w http.ResponseWriter, r *http.Request) {
 	var request model.SubmitDialogRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -828,4 +1779,4 @@ func (p *Plugin) handleDialog1(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-}
\ No newline at end of file
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// configurationDiffTestAPI records the files uploaded with configuration changes.
+type configurationDiffTestAPI struct {
+	plugin.API
+	uploaded []byte
+}
+
+func (a *configurationDiffTestAPI) UploadFile(data []byte, channelID, filename string) (*model.FileInfo, *model.AppError) {
+	a.uploaded = data
+	return &model.FileInfo{Id: model.NewId(), ChannelId: channelID, Name: filename}, nil
+}
+
+func (a *configurationDiffTestAPI) CreatePost(post *model.Post) (*model.Post, *model.AppError) {
+	return post, nil
+}
+
+func TestPostConfigurationDiffHidesSecrets(t *testing.T) {
+	manifestData, err := os.ReadFile("plugin.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pluginManifest struct {
+		SettingsSchema struct {
+			Settings []struct {
+				Key    string `json:"key"`
+				Secret bool   `json:"secret"`
+			} `json:"settings"`
+		} `json:"settings_schema"`
+	}
+	if err = json.Unmarshal(manifestData, &pluginManifest); err != nil {
+		t.Fatal(err)
+	}
+
+	newConfiguration := &configuration{
+		RandomSecret:    "random-secret-value",
+		GenericWebhooks: `{"uptime": {"token": "generic-token-value", "summary": "$.title"}}`,
+	}
+	secrets := []string{newConfiguration.RandomSecret, "generic-token-value"}
+
+	value := reflect.ValueOf(newConfiguration).Elem()
+	for _, setting := range pluginManifest.SettingsSchema.Settings {
+		if !setting.Secret {
+			continue
+		}
+
+		field := value.FieldByName(setting.Key)
+		if !field.IsValid() || field.Kind() != reflect.String {
+			t.Fatalf("secret setting %s is not a string field of the configuration", setting.Key)
+		}
+
+		secret := setting.Key + "-value"
+		field.SetString(secret)
+		secrets = append(secrets, secret)
+	}
+
+	api := &configurationDiffTestAPI{}
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	if !p.postConfigurationDiff(newConfiguration, model.NewId(), map[string]interface{}{"username": "demo"}) {
+		t.Fatal("failed to post the configuration diff")
+	}
+
+	for _, secret := range secrets {
+		if bytes.Contains(api.uploaded, []byte(secret)) {
+			t.Errorf("uploaded configuration contains the secret %q", secret)
+		}
+	}
+
+	var uploaded configuration
+	if err = json.Unmarshal(api.uploaded, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.JiraAPIToken != "<HIDDEN>" {
+		t.Errorf("uploaded configuration has the Jira API token %q instead of <HIDDEN>", uploaded.JiraAPIToken)
+	}
+}
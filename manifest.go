@@ -0,0 +1,20 @@
+package main
+
+import (
+	_ "embed" // Embeds the plugin manifest.
+	"encoding/json"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+//go:embed plugin.json
+var manifestJSON []byte
+
+// manifest is the manifest of this plugin, whose id names its URLs and settings.
+var manifest model.Manifest
+
+func init() {
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		panic("invalid plugin.json: " + err.Error())
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// ticketLink links the ticket's thread, labeled with its key, or returns its key if its permalink
+// cannot be built.
+func (p *Plugin) ticketLink(ticket *Ticket) string {
+	permalink, err := p.ticketPermalink(ticket)
+	if err != nil {
+		p.API.LogWarn("Failed to get ticket permalink", "ticket_id", ticket.ID, "err", err.Error())
+		return ticket.ticketName()
+	}
+
+	return fmt.Sprintf("[%s](%s)", ticket.ticketName(), permalink)
+}
+
+// mergeTicket marks the duplicate as a duplicate of the canonical ticket and resolves it, moving
+// its watchers and earlier duplicates to the canonical ticket. Both threads link to each other.
+// It returns a message for the user, or an error if the tickets could not be updated.
+func (p *Plugin) mergeTicket(duplicate, canonical *Ticket, userID string) (string, error) {
+	switch {
+	case duplicate.ID == canonical.ID:
+		return "A ticket cannot be merged into itself.", nil
+	case duplicate.DuplicateOf != "":
+		return fmt.Sprintf("%s is already merged into another ticket.", duplicate.ticketName()), nil
+	case canonical.DuplicateOf != "":
+		return fmt.Sprintf("%s is itself a duplicate. Merge into the ticket it duplicates instead.", canonical.ticketName()), nil
+	case duplicate.TeamID != canonical.TeamID:
+		return "Only tickets of the same team can be merged.", nil
+	case duplicate.Confidential || canonical.Confidential:
+		return "Confidential tickets cannot be merged.", nil
+	}
+
+	// The duplicate is saved first, so that concurrent merges of the same ticket cannot both
+	// succeed. Its watchers and earlier duplicates then move to the canonical ticket.
+	var resolved bool
+	var watcherIDs, earlierIDs []string
+	duplicateName := duplicate.ticketName()
+	duplicate, err := p.updateTicket(duplicate, func(ticket *Ticket) error {
+		if ticket.DuplicateOf != "" {
+			return errTicketUnchanged
+		}
+		watcherIDs = ticket.Watchers
+		earlierIDs = ticket.DuplicateIDs
+		ticket.DuplicateIDs = nil
+		ticket.DuplicateOf = canonical.ID
+		resolved = ticket.Status != ticketStatusResolved
+		if resolved {
+			setTicketStatus(ticket, ticketStatusResolved, userID)
+		}
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return fmt.Sprintf("%s is already merged into another ticket.", duplicateName), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Earlier duplicates of the duplicate now duplicate the canonical ticket, so that the
+	// relationship never chains.
+	duplicateIDs := []string{duplicate.ID}
+	for _, earlierID := range earlierIDs {
+		earlier, err := p.getTicket(earlierID)
+		if err != nil {
+			return "", err
+		}
+		if earlier == nil {
+			continue
+		}
+		if _, err := p.updateTicket(earlier, func(ticket *Ticket) error {
+			ticket.DuplicateOf = canonical.ID
+			return nil
+		}); err != nil {
+			return "", err
+		}
+		duplicateIDs = append(duplicateIDs, earlierID)
+	}
+
+	canonical, err = p.updateTicket(canonical, func(ticket *Ticket) error {
+		for _, watcherID := range watcherIDs {
+			if !ticket.isWatching(watcherID) {
+				ticket.Watchers = append(ticket.Watchers, watcherID)
+			}
+		}
+		for _, duplicateID := range duplicateIDs {
+			if !contains(ticket.DuplicateIDs, duplicateID) {
+				ticket.DuplicateIDs = append(ticket.DuplicateIDs, duplicateID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, ticket := range []*Ticket{duplicate, canonical} {
+		if err := p.updateTicketPost(ticket); err != nil {
+			p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+	if resolved {
+		p.sendTicketEvent(ticketEventResolved, duplicate, userID)
+	}
+
+	localizer := p.serverLocalizer()
+	if err := p.postTicketTransition(duplicate, localize(localizer, &i18n.Message{
+		ID:    "ticket.merged_duplicate",
+		Other: ":link: {{.User}} closed this request as a duplicate of {{.Canonical}}.",
+	}, map[string]interface{}{"User": p.mentionUser(userID), "Canonical": p.ticketLink(canonical)})); err != nil {
+		p.API.LogWarn("Failed to post merge in the duplicate thread", "ticket_id", duplicate.ID, "err", err.Error())
+	}
+	if err := p.postTicketReply(canonical, localize(localizer, &i18n.Message{
+		ID:    "ticket.merged_canonical",
+		Other: ":link: {{.User}} merged {{.Duplicate}} into this request as a duplicate.",
+	}, map[string]interface{}{"User": p.mentionUser(userID), "Duplicate": p.ticketLink(duplicate)})); err != nil {
+		p.API.LogWarn("Failed to post merge in the canonical thread", "ticket_id", canonical.ID, "err", err.Error())
+	}
+
+	return fmt.Sprintf("Merged %s into %s.", duplicate.ticketName(), canonical.ticketName()), nil
+}
+
+// duplicateOfValue renders the ticket a duplicate was merged into for the ticket attachment.
+func (p *Plugin) duplicateOfValue(ticket *Ticket) string {
+	canonical, err := p.getTicket(ticket.DuplicateOf)
+	if err != nil || canonical == nil {
+		return ticket.DuplicateOf
+	}
+
+	return p.ticketLink(canonical)
+}
+
+func (p *Plugin) executeCommandMerge(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 2 {
+		return ephemeralResponse(commandUsage("merge"))
+	}
+
+	var tickets []*Ticket
+	for _, reference := range params {
+		ticket, err := p.findTicket(reference)
+		if err != nil {
+			p.API.LogError("Failed to get ticket", "err", err.Error())
+			return ephemeralResponse("Failed to get the ticket.")
+		}
+		if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+			return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", reference))
+		}
+		tickets = append(tickets, ticket)
+	}
+	duplicate, canonical := tickets[0], tickets[1]
+
+	if !p.canEditTicket(args.UserId, duplicate) {
+		return ephemeralResponse("Only the reporter, the assignee, the incident commander or a system admin can merge this ticket.")
+	}
+
+	message, err := p.mergeTicket(duplicate, canonical, args.UserId)
+	if err != nil {
+		p.API.LogError("Failed to merge tickets", "duplicate_id", duplicate.ID, "canonical_id", canonical.ID, "err", err.Error())
+		return ephemeralResponse(ticketUpdateError(err, "Failed to merge the tickets."))
+	}
+
+	return ephemeralResponse(message)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// defaultSuggestionPhrases are the trigger phrases used when none are configured.
+var defaultSuggestionPhrases = []string{
+	"prod is down",
+	"production is down",
+	"pipeline failing",
+	"pipeline is failing",
+	"deploy failed",
+	"outage",
+}
+
+// parseSuggestionSettings parses the channel names and trigger phrases of the ticket suggestions.
+func parseSuggestionSettings(configuration *configuration) (map[string]bool, []string) {
+	channels := make(map[string]bool)
+	for _, name := range strings.Split(configuration.SuggestionChannels, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			channels[name] = true
+		}
+	}
+
+	var phrases []string
+	for _, phrase := range strings.Split(configuration.SuggestionPhrases, ",") {
+		if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+	if len(phrases) == 0 {
+		phrases = defaultSuggestionPhrases
+	}
+
+	return channels, phrases
+}
+
+// MessageHasBeenPosted is invoked after the message has been committed to the database.
+//
+// This implementation records replies in ticket threads as ticket comments, linking their files to
+// the ticket, and suggests opening an SRE request when a message posted in one of the configured
+// channels contains a trigger phrase.
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled || post.UserId == p.botID || post.IsSystemMessage() {
+		return
+	}
+
+	if post.RootId != "" {
+		p.recordTicketComment(post)
+	}
+
+	p.suggestTicket(configuration, post)
+}
+
+// recordTicketComment stores the post as a comment of the ticket whose thread it replies to, if
+// any, and links its files to the ticket.
+func (p *Plugin) recordTicketComment(post *model.Post) {
+	ticket, err := p.getTicketByPostID(post.RootId)
+	if err != nil {
+		p.API.LogError("Failed to get ticket for comment", "post_id", post.Id, "err", err.Error())
+		return
+	}
+	if ticket == nil || !p.teamEnabled(ticket.TeamID) {
+		return
+	}
+
+	// Replies posted at the same time race to save the ticket, so the losers are saved again.
+	var reply string
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.Comments = append(ticket.Comments, &TicketComment{
+			PostID:   post.Id,
+			UserID:   post.UserId,
+			Message:  post.Message,
+			CreateAt: post.CreateAt,
+		})
+		p.linkPostFiles(ticket, post)
+		reply = p.answerCustomFieldPrompt(ticket, post)
+		return nil
+	})
+	if err != nil {
+		p.API.LogError("Failed to save ticket comment", "ticket_id", ticket.ID, "err", err.Error())
+		return
+	}
+
+	if reply != "" {
+		if err := p.postTicketReply(ticket, reply); err != nil {
+			p.API.LogWarn("Failed to reply to custom field answer", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+
+	p.notifyWatchersOfComment(ticket, post)
+	p.resumeOnReporterReply(ticket, post)
+}
+
+// suggestTicket suggests opening an SRE request if the post was made in one of the configured
+// channels and contains a trigger phrase.
+func (p *Plugin) suggestTicket(configuration *configuration, post *model.Post) {
+	if len(configuration.suggestionChannels) == 0 {
+		return
+	}
+
+	message := strings.ToLower(post.Message)
+	matched := false
+	for _, phrase := range configuration.suggestionPhrases {
+		if strings.Contains(message, phrase) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return
+	}
+
+	channel, appErr := p.API.GetChannel(post.ChannelId)
+	if appErr != nil {
+		p.API.LogError("Failed to get channel for ticket suggestion", "channel_id", post.ChannelId, "err", appErr.Error())
+		return
+	}
+	if !configuration.suggestionChannels[strings.ToLower(channel.Name)] || !p.teamEnabled(channel.TeamId) {
+		return
+	}
+
+	p.API.SendEphemeralPost(post.UserId, &model.Post{
+		UserId:    p.botID,
+		ChannelId: post.ChannelId,
+		RootId:    post.RootId,
+		Message:   "It looks like something is broken. Do you want to open an SRE request?",
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{{
+				Actions: []*model.PostAction{{
+					Type:  model.PostActionTypeButton,
+					Name:  "Create SRE request",
+					Style: "primary",
+					Integration: &model.PostActionIntegration{
+						URL: pluginCallbackURL("/interactive/sre/suggest"),
+						Context: model.StringInterface{
+							"message": post.Message,
+						},
+					},
+				}},
+			}},
+		},
+	})
+}
+
+// handleSuggestTicket opens the intake dialog pre-filled with the message that triggered a ticket
+// suggestion.
+func (p *Plugin) handleSuggestTicket(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.PostActionIntegrationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode PostActionIntegrationRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	// A forged user id would open the intake dialog on behalf of someone else.
+	if request.UserId != userID {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	message, _ := request.Context["message"].(string)
+
+	if appErr := p.openIntakeDialog(request.TriggerId, request.TeamId, userID, messagePrefill(message, "")); appErr != nil {
+		p.API.LogError("Failed to open dialog", "err", appErr.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, &model.PostActionIntegrationResponse{})
+}
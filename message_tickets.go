@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// maxPrefillSummaryLength bounds the summary pre-filled from a message.
+const maxPrefillSummaryLength = 150
+
+// messagePrefill returns the intake dialog values pre-filled from a message: its first line as the
+// summary and the whole message, followed by the given context, as the description.
+func messagePrefill(message, context string) map[string]interface{} {
+	summary := strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+	if runes := []rune(summary); len(runes) > maxPrefillSummaryLength {
+		summary = string(runes[:maxPrefillSummaryLength])
+	}
+
+	description := message
+	if context != "" {
+		description = strings.TrimSpace(description + "\n\n" + context)
+	}
+
+	return map[string]interface{}{
+		dialogElementNameSummary:     summary,
+		dialogElementNameDescription: description,
+	}
+}
+
+// parsePostReference returns the id of the post named by a permalink or a post id, or an empty
+// string if the reference names no post.
+func parsePostReference(reference string) string {
+	if _, postID, ok := strings.Cut(reference, "/pl/"); ok {
+		reference = strings.Trim(postID, "/")
+	}
+	if !model.IsValidId(reference) {
+		return ""
+	}
+
+	return reference
+}
+
+// postPermalink returns the permalink of a post of the given team.
+func (p *Plugin) postPermalink(teamID, postID string) (string, error) {
+	team, appErr := p.getCachedTeam(teamID)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get team")
+	}
+
+	return fmt.Sprintf("%s/%s/pl/%s", p.siteURL(), team.Name, postID), nil
+}
+
+// executeCommandFromMessage opens the intake dialog pre-filled with the content, author and
+// permalink of a message the user can read.
+func (p *Plugin) executeCommandFromMessage(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("from-message"))
+	}
+
+	if _, ok := p.getConfiguration().demoChannelIDs[args.TeamId]; !ok {
+		return ephemeralResponse("SRE requests are not enabled in this team.")
+	}
+
+	postID := parsePostReference(params[0])
+	if postID == "" {
+		return ephemeralResponse(fmt.Sprintf("Invalid message link %q. Use **Copy Link** on the message to get it.", params[0]))
+	}
+
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil || !p.API.HasPermissionToChannel(args.UserId, post.ChannelId, model.PermissionReadChannel) {
+		return ephemeralResponse("Message not found.")
+	}
+
+	context := fmt.Sprintf("Reported from a message by %s", p.mentionUser(post.UserId))
+	if permalink, err := p.postPermalink(args.TeamId, post.Id); err != nil {
+		p.API.LogWarn("Failed to build message permalink", "post_id", post.Id, "err", err.Error())
+	} else {
+		context += ": " + permalink
+	}
+
+	if appErr := p.openIntakeDialog(args.TriggerId, args.TeamId, args.UserId, messagePrefill(post.Message, context)); appErr != nil {
+		p.API.LogError("Failed to open dialog", "err", appErr.Error())
+		return ephemeralResponse("Failed to open the SRE request dialog.")
+	}
+
+	return &model.CommandResponse{}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// botUsername is the username of the plugin's bot account.
+const botUsername = "demoplugin"
+
+// errMinimalPermissions is returned by the features that would provision users or channels while
+// the minimal-permission mode is enabled.
+var errMinimalPermissions = errors.New("unavailable in minimal-permission mode")
+
+// setupMinimalPermissions looks up the resources the plugin would otherwise provision, without
+// creating or modifying any of them, and records the capabilities disabled by missing resources.
+// Only the bot account is required.
+func (p *Plugin) setupMinimalPermissions(configuration *configuration) error {
+	bot, appErr := p.API.GetUserByUsername(botUsername)
+	if appErr != nil || !bot.IsBot {
+		return errors.Errorf("minimal-permission mode requires a pre-created bot account named %q", botUsername)
+	}
+	p.botID = bot.Id
+
+	configuration.disabledCapabilities = []string{
+		"Confidential requests, which require creating group messages.",
+		"Direct messages to system admins, which require creating direct message channels. Admin notifications are logged instead.",
+		"The status banner of the SRE channel headers, which requires modifying channels.",
+	}
+
+	configuration.demoUserID = ""
+	if user, appErr := p.API.GetUserByUsername(configuration.Username); appErr == nil {
+		configuration.demoUserID = user.Id
+	} else {
+		configuration.disabledCapabilities = append(configuration.disabledCapabilities, fmt.Sprintf("The demo user, since @%s does not exist.", configuration.Username))
+	}
+
+	teams, appErr := p.listScopedTeams(configuration)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to list teams")
+	}
+
+	configuration.demoChannelIDs = make(map[string]string)
+	for _, team := range teams {
+		channel, appErr := p.API.GetChannelByNameForTeamName(team.Name, configuration.ChannelName, true)
+		if appErr != nil {
+			configuration.disabledCapabilities = append(configuration.disabledCapabilities, fmt.Sprintf("SRE requests in the %s team, since it has no ~%s channel.", team.DisplayName, configuration.ChannelName))
+			continue
+		}
+		configuration.demoChannelIDs[team.Id] = channel.Id
+	}
+
+	p.API.LogWarn("Minimal-permission mode is enabled, some capabilities are disabled", "disabled", strings.Join(configuration.disabledCapabilities, " "))
+
+	return nil
+}
+
+func (p *Plugin) executeCommandCapabilities() *model.CommandResponse {
+	configuration := p.getConfiguration()
+	if !configuration.MinimalPermissions {
+		return ephemeralResponse("All capabilities are enabled.")
+	}
+
+	return ephemeralResponse("Minimal-permission mode is enabled. These capabilities are disabled:\n- " + strings.Join(configuration.disabledCapabilities, "\n- "))
+}
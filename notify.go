@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// notificationPreferenceChannel mentions the user in the SRE channel, the default.
+	notificationPreferenceChannel = "channel"
+
+	// notificationPreferenceDM sends the user a direct message from the bot instead of a mention.
+	notificationPreferenceDM = "dm"
+
+	// notificationPreferenceOff neither mentions nor messages the user.
+	notificationPreferenceOff = "off"
+
+	// notificationPreferenceKeyPrefix prefixes the KV key of a user's notification preference.
+	notificationPreferenceKeyPrefix = "notifypref_"
+)
+
+func notificationPreferenceKey(userID string) string {
+	return notificationPreferenceKeyPrefix + userID
+}
+
+// getNotificationPreference returns how the user wants to be notified, falling back to channel
+// mentions.
+func (p *Plugin) getNotificationPreference(userID string) string {
+	var preference string
+	if err := p.client.KV.Get(notificationPreferenceKey(userID), &preference); err != nil {
+		p.API.LogWarn("Failed to get notification preference", "user_id", userID, "err", err.Error())
+	}
+	if preference == "" {
+		return notificationPreferenceChannel
+	}
+
+	return preference
+}
+
+func (p *Plugin) setNotificationPreference(userID, preference string) error {
+	if preference == notificationPreferenceChannel {
+		if err := p.client.KV.Delete(notificationPreferenceKey(userID)); err != nil {
+			return errors.Wrap(err, "failed to delete notification preference")
+		}
+		return nil
+	}
+
+	if _, err := p.client.KV.Set(notificationPreferenceKey(userID), preference); err != nil {
+		return errors.Wrap(err, "failed to save notification preference")
+	}
+
+	return nil
+}
+
+// listDirectMessageUsers returns the ids of the users who prefer direct messages.
+func (p *Plugin) listDirectMessageUsers() ([]string, error) {
+	var userIDs []string
+	for page := 0; ; page++ {
+		keys, err := p.client.KV.ListKeys(page, kvListPerPage, pluginapi.WithPrefix(notificationPreferenceKeyPrefix))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list notification preferences")
+		}
+
+		for _, key := range keys {
+			userID := strings.TrimPrefix(key, notificationPreferenceKeyPrefix)
+			if p.getNotificationPreference(userID) == notificationPreferenceDM {
+				userIDs = append(userIDs, userID)
+			}
+		}
+
+		if len(keys) < kvListPerPage {
+			return userIDs, nil
+		}
+	}
+}
+
+// sendDirectMessage sends the message to the user in their direct channel with the bot.
+func (p *Plugin) sendDirectMessage(userID, message string) error {
+	channel, appErr := p.API.GetDirectChannel(userID, p.botID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get direct channel")
+	}
+
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+		Message:   message,
+	}); appErr != nil {
+		return errors.Wrap(appErr, "failed to send direct message")
+	}
+
+	return nil
+}
+
+// ticketDirectMessage appends the link to the ticket to a notification sent by direct message,
+// since it is read outside of the ticket's thread.
+func (p *Plugin) ticketDirectMessage(ticket *Ticket, message string) string {
+	permalink, err := p.ticketPermalink(ticket)
+	if err != nil {
+		p.API.LogWarn("Failed to get ticket permalink", "ticket_id", ticket.ID, "err", err.Error())
+		return fmt.Sprintf("%s\nTicket: %s", message, ticket.Summary)
+	}
+
+	return fmt.Sprintf("%s\nTicket: [%s](%s)", message, ticket.Summary, permalink)
+}
+
+// notifyUser notifies the user according to their preference and returns how to refer to them in
+// the channel message: an @-mention for users preferring channel mentions, or else their plain
+// username. Users preferring direct messages are sent dmMessage.
+func (p *Plugin) notifyUser(userID, dmMessage string) string {
+	switch p.getNotificationPreference(userID) {
+	case notificationPreferenceDM:
+		if err := p.sendDirectMessage(userID, dmMessage); err != nil {
+			p.API.LogWarn("Failed to notify user by direct message", "user_id", userID, "err", err.Error())
+		}
+		return p.username(userID)
+	case notificationPreferenceOff:
+		return p.username(userID)
+	default:
+		return p.mentionUser(userID)
+	}
+}
+
+// notifyRecipients notifies each user according to their preference and returns the mentions of
+// the channel message: the users preferring channel mentions and the groups, which have no
+// preference.
+func (p *Plugin) notifyRecipients(userIDs, groupNames []string, dmMessage string) string {
+	var mentions []string
+	notified := make(map[string]bool)
+	for _, userID := range userIDs {
+		if notified[userID] {
+			continue
+		}
+		notified[userID] = true
+
+		if reference := p.notifyUser(userID, dmMessage); strings.HasPrefix(reference, "@") {
+			mentions = append(mentions, reference)
+		}
+	}
+	for _, name := range groupNames {
+		mentions = append(mentions, "@"+name)
+	}
+
+	return strings.Join(mentions, " ")
+}
+
+func (p *Plugin) executeCommandNotify(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) == 0 {
+		return ephemeralResponse(fmt.Sprintf("Your notification preference is %s. %s", p.getNotificationPreference(args.UserId), commandUsage("notify")))
+	}
+
+	preference := strings.ToLower(params[0])
+	var confirmation string
+	switch preference {
+	case notificationPreferenceChannel:
+		confirmation = "You will be mentioned in the SRE channel."
+	case notificationPreferenceDM:
+		confirmation = "You will be notified by direct message instead of channel mentions."
+	case notificationPreferenceOff:
+		confirmation = "You will no longer be notified of escalations, assignments and digests."
+	default:
+		return ephemeralResponse(commandUsage("notify"))
+	}
+
+	if err := p.setNotificationPreference(args.UserId, preference); err != nil {
+		p.API.LogError("Failed to set notification preference", "user_id", args.UserId, "err", err.Error())
+		return ephemeralResponse("Failed to save your notification preference.")
+	}
+
+	return ephemeralResponse(confirmation)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// onCallScheduleKey is the KV key of the on-call schedule.
+	onCallScheduleKey = "oncall_schedule"
+
+	// onCallRotationPeriod is how long each user of the rotation stays on call.
+	onCallRotationPeriod = 7 * 24 * time.Hour
+
+	// defaultOnCallOverride is how long an override lasts when no duration is given.
+	defaultOnCallOverride = 24 * time.Hour
+)
+
+// OnCallSchedule is the on-call rotation. The user at Current is on call until the rotation is
+// advanced, unless an override is active.
+type OnCallSchedule struct {
+	UserIDs   []string `json:"user_ids"`
+	Current   int      `json:"current"`
+	RotatedAt int64    `json:"rotated_at"`
+
+	// OverrideUserID is on call instead of the rotation until OverrideUntil.
+	OverrideUserID string `json:"override_user_id,omitempty"`
+	OverrideUntil  int64  `json:"override_until,omitempty"`
+}
+
+// onCallUserID returns the id of the user currently on call, or an empty string if nobody is.
+func (s *OnCallSchedule) onCallUserID(now int64) string {
+	if s.OverrideUserID != "" && now < s.OverrideUntil {
+		return s.OverrideUserID
+	}
+	if len(s.UserIDs) == 0 {
+		return ""
+	}
+
+	return s.UserIDs[s.Current%len(s.UserIDs)]
+}
+
+// predictOnCallUserID returns the id of the user expected to be on call at the given time, assuming
+// the rotation keeps advancing every rotation period, or an empty string if nobody will be.
+func (s *OnCallSchedule) predictOnCallUserID(at int64) string {
+	if s.OverrideUserID != "" && at < s.OverrideUntil {
+		return s.OverrideUserID
+	}
+	if len(s.UserIDs) == 0 {
+		return ""
+	}
+
+	current := s.Current
+	if len(s.UserIDs) > 1 && at > s.RotatedAt {
+		current += int((at - s.RotatedAt) / onCallRotationPeriod.Milliseconds())
+	}
+
+	return s.UserIDs[current%len(s.UserIDs)]
+}
+
+func (p *Plugin) getOnCallSchedule() (*OnCallSchedule, error) {
+	var schedule *OnCallSchedule
+	if err := p.client.KV.Get(onCallScheduleKey, &schedule); err != nil {
+		return nil, errors.Wrap(err, "failed to get on-call schedule")
+	}
+	if schedule == nil {
+		schedule = &OnCallSchedule{}
+	}
+
+	return schedule, nil
+}
+
+func (p *Plugin) saveOnCallSchedule(schedule *OnCallSchedule) error {
+	if _, err := p.client.KV.Set(onCallScheduleKey, schedule); err != nil {
+		return errors.Wrap(err, "failed to save on-call schedule")
+	}
+
+	return nil
+}
+
+// onCallUserID returns the id of the user currently on call, or an empty string if nobody is on
+// call.
+func (p *Plugin) onCallUserID() string {
+	schedule, err := p.getOnCallSchedule()
+	if err != nil {
+		p.API.LogError("Failed to get on-call schedule", "err", err.Error())
+		return ""
+	}
+
+	return schedule.onCallUserID(model.GetMillis())
+}
+
+// OnCallRotationJob runs periodically on only one plugin instance at a time. It hands over to the
+// next user of the rotation once the current user has been on call for a rotation period.
+func (p *Plugin) OnCallRotationJob() {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled {
+		return
+	}
+
+	schedule, err := p.getOnCallSchedule()
+	if err != nil {
+		p.reportJobFailure("OnCallRotationJob", "Failed to get on-call schedule", err)
+		return
+	}
+
+	now := model.GetMillis()
+	if len(schedule.UserIDs) < 2 || now < schedule.RotatedAt+onCallRotationPeriod.Milliseconds() {
+		return
+	}
+
+	schedule.Current = (schedule.Current + 1) % len(schedule.UserIDs)
+	schedule.RotatedAt = now
+	if schedule.OverrideUntil <= now {
+		schedule.OverrideUserID = ""
+		schedule.OverrideUntil = 0
+	}
+
+	if err := p.saveOnCallSchedule(schedule); err != nil {
+		p.reportJobFailure("OnCallRotationJob", "Failed to rotate on-call schedule", err)
+		return
+	}
+
+	p.API.LogInfo("Rotated on-call schedule", "user_id", schedule.UserIDs[schedule.Current])
+}
+
+func (p *Plugin) executeCommandOnCallShow() *model.CommandResponse {
+	schedule, err := p.getOnCallSchedule()
+	if err != nil {
+		p.API.LogError("Failed to get on-call schedule", "err", err.Error())
+		return ephemeralResponse("Failed to get the on-call schedule.")
+	}
+
+	now := model.GetMillis()
+	userID := schedule.onCallUserID(now)
+	if userID == "" {
+		return ephemeralResponse("No on-call rotation is configured.")
+	}
+
+	lines := []string{fmt.Sprintf("%s is on call.", p.mentionUser(userID))}
+	if schedule.OverrideUserID == userID && now < schedule.OverrideUntil {
+		lines[0] = fmt.Sprintf("%s is on call until %s (override).", p.mentionUser(userID), time.UnixMilli(schedule.OverrideUntil).UTC().Format(time.RFC1123))
+	}
+
+	if len(schedule.UserIDs) > 0 {
+		var rotation []string
+		for i, rotationUserID := range schedule.UserIDs {
+			mention := p.mentionUser(rotationUserID)
+			if i == schedule.Current%len(schedule.UserIDs) {
+				mention = fmt.Sprintf("**%s**", mention)
+			}
+			rotation = append(rotation, mention)
+		}
+
+		nextRotation := time.UnixMilli(schedule.RotatedAt).Add(onCallRotationPeriod).UTC()
+		lines = append(lines, fmt.Sprintf("Rotation: %s. Next handover: %s.", strings.Join(rotation, ", "), nextRotation.Format(time.RFC1123)))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
+
+func (p *Plugin) executeCommandOnCallSet(usernames []string) *model.CommandResponse {
+	if len(usernames) == 0 {
+		return ephemeralResponse(commandUsage("oncall", "set"))
+	}
+
+	var userIDs []string
+	for _, username := range usernames {
+		for _, name := range splitUsernames(username) {
+			userID := p.lookupUserID(name)
+			if userID == "" {
+				return ephemeralResponse(fmt.Sprintf("User %s not found.", name))
+			}
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	schedule, err := p.getOnCallSchedule()
+	if err != nil {
+		p.API.LogError("Failed to get on-call schedule", "err", err.Error())
+		return ephemeralResponse("Failed to get the on-call schedule.")
+	}
+
+	schedule.UserIDs = userIDs
+	schedule.Current = 0
+	schedule.RotatedAt = model.GetMillis()
+	if err := p.saveOnCallSchedule(schedule); err != nil {
+		p.API.LogError("Failed to save on-call schedule", "err", err.Error())
+		return ephemeralResponse("Failed to save the on-call schedule.")
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Set the on-call rotation. %s is on call.", p.mentionUser(userIDs[0])))
+}
+
+func (p *Plugin) executeCommandOnCallOverride(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) == 0 || len(params) > 2 {
+		return ephemeralResponse(commandUsage("oncall", "override"))
+	}
+
+	duration := defaultOnCallOverride
+	if len(params) == 2 {
+		var err error
+		if duration, err = time.ParseDuration(params[1]); err != nil || duration <= 0 {
+			return ephemeralResponse(fmt.Sprintf("Invalid duration %q, use a duration such as 12h.", params[1]))
+		}
+	}
+
+	userID := p.lookupUserID(params[0])
+	if userID == "" {
+		return ephemeralResponse(fmt.Sprintf("User %s not found.", params[0]))
+	}
+
+	schedule, err := p.getOnCallSchedule()
+	if err != nil {
+		p.API.LogError("Failed to get on-call schedule", "err", err.Error())
+		return ephemeralResponse("Failed to get the on-call schedule.")
+	}
+
+	// The user on call may hand over to someone else, e.g. to swap shifts.
+	now := model.GetMillis()
+	if !p.isSystemAdmin(args.UserId) && args.UserId != schedule.onCallUserID(now) {
+		return ephemeralResponse("Only system admins and the user on call can override the on-call rotation.")
+	}
+
+	schedule.OverrideUserID = userID
+	schedule.OverrideUntil = now + duration.Milliseconds()
+	if err := p.saveOnCallSchedule(schedule); err != nil {
+		p.API.LogError("Failed to save on-call schedule", "err", err.Error())
+		return ephemeralResponse("Failed to save the on-call schedule.")
+	}
+
+	return ephemeralResponse(fmt.Sprintf("%s is on call for the next %s.", p.mentionUser(userID), duration))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+)
+
+// parseSREAdmins splits the SREAdmins setting into the ids of the listed users and the listed role
+// names. Entries starting with @ name users, while the other entries name roles.
+func (p *Plugin) parseSREAdmins(configuration *configuration) (map[string]bool, map[string]bool) {
+	userIDs := make(map[string]bool)
+	roles := make(map[string]bool)
+	for _, entry := range strings.Split(configuration.SREAdmins, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "@"):
+			if userID := p.lookupUserID(entry); userID != "" {
+				userIDs[userID] = true
+			}
+		default:
+			roles[entry] = true
+		}
+	}
+
+	return userIDs, roles
+}
+
+// isSREAdmin reports whether the user may run destructive actions: system admins and the users
+// listed in, or holding a role listed in, SREAdmins.
+func (p *Plugin) isSREAdmin(userID string) bool {
+	if p.isSystemAdmin(userID) {
+		return true
+	}
+
+	configuration := p.getConfiguration()
+	if configuration.sreAdminIDs[userID] {
+		return true
+	}
+	if len(configuration.sreAdminRoles) == 0 {
+		return false
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get user for permission check", "user_id", userID, "err", appErr.Error())
+		return false
+	}
+	for _, role := range strings.Fields(user.Roles) {
+		if configuration.sreAdminRoles[role] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorizeDestructiveAction reports whether the user may run the destructive action, recording
+// an audit entry in the server log when the user is denied.
+func (p *Plugin) authorizeDestructiveAction(userID, action string, keyValuePairs ...interface{}) bool {
+	if p.isSREAdmin(userID) {
+		return true
+	}
+
+	p.API.LogWarn("Audit: denied destructive action", append([]interface{}{"action", action, "user_id", userID}, keyValuePairs...)...)
+
+	return false
+}
+
+// authorizeSystemAdminAction reports whether the user may run the destructive action reserved to
+// system admins, recording an audit entry in the server log when the user is denied.
+func (p *Plugin) authorizeSystemAdminAction(userID, action string, keyValuePairs ...interface{}) bool {
+	if p.isSystemAdmin(userID) {
+		return true
+	}
+
+	p.API.LogWarn("Audit: denied destructive action", append([]interface{}{"action", action, "user_id", userID}, keyValuePairs...)...)
+
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// postPriorityMinMajor and postPriorityMinMinor are the first server version supporting message
+// priorities.
+const (
+	postPriorityMinMajor = 7
+	postPriorityMinMinor = 7
+)
+
+// detectPostPriority reports whether the server supports message priorities and has them enabled.
+func (p *Plugin) detectPostPriority() bool {
+	var major, minor int
+	if _, err := fmt.Sscanf(p.API.GetServerVersion(), "%d.%d", &major, &minor); err != nil {
+		p.API.LogWarn("Failed to parse server version, message priorities are disabled", "version", p.API.GetServerVersion())
+		return false
+	}
+	if major < postPriorityMinMajor || (major == postPriorityMinMajor && minor < postPriorityMinMinor) {
+		return false
+	}
+
+	config := p.API.GetConfig()
+	return config != nil && (config.ServiceSettings.PostPriority == nil || *config.ServiceSettings.PostPriority)
+}
+
+// isUrgentTicket reports whether the ticket's post is marked urgent: only SEV1 tickets, of the
+// highest priority, are.
+func isUrgentTicket(ticket *Ticket) bool {
+	return ticket.Priority == ticketPriorityHigh
+}
+
+// setTicketPostPriority marks the root post of an urgent ticket as urgent, requesting
+// acknowledgements, when the server supports message priorities. Older servers rely on the label
+// added to the message instead.
+func (c *configuration) setTicketPostPriority(post *model.Post, ticket *Ticket) {
+	if !c.postPriorityEnabled || !isUrgentTicket(ticket) {
+		return
+	}
+
+	if post.Metadata == nil {
+		post.Metadata = &model.PostMetadata{}
+	}
+	post.Metadata.Priority = &model.PostPriority{
+		Priority:     model.NewString(model.PostPriorityUrgent),
+		RequestedAck: model.NewBool(true),
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	ticketActionPostmortemSchedule = "postmortem_schedule"
+	ticketActionPostmortemDone     = "postmortem_done"
+
+	// defaultPostmortemLeadTime is how long after a SEV1 resolves the first postmortem review slot
+	// is proposed, when no lead time is configured.
+	defaultPostmortemLeadTime = 48 * time.Hour
+
+	// postmortemSlotCount is the number of review slots proposed.
+	postmortemSlotCount = 3
+
+	// postmortemReminder is how long before the review the reminder is posted.
+	postmortemReminder = time.Hour
+
+	// postmortemReviewDuration is the length of the review in the calendar invitation.
+	postmortemReviewDuration = time.Hour
+)
+
+// postmortemSlotHours are the hours, in UTC, at which review slots are proposed on business days.
+var postmortemSlotHours = []int{10, 15}
+
+// parsePostmortemLeadTime parses the lead time before the first postmortem review slot.
+func parsePostmortemLeadTime(configuration *configuration) (time.Duration, error) {
+	value := strings.TrimSpace(configuration.PostmortemLeadTime)
+	if value == "" {
+		return defaultPostmortemLeadTime, nil
+	}
+
+	leadTime, err := parseDuration(value)
+	if err != nil || leadTime < 0 {
+		return 0, errors.Errorf("invalid postmortem lead time %q", value)
+	}
+
+	return leadTime, nil
+}
+
+// needsPostmortem reports whether the ticket is a SEV1, the highest priority, whose postmortem
+// review has not been scheduled yet.
+func (t *Ticket) needsPostmortem() bool {
+	return t.Priority == ticketPriorityHigh && t.PostmortemReviewAt == 0 && t.PostmortemCompletedAt == 0
+}
+
+// postmortemSlots returns the review slots proposed for a postmortem, the first business-day slots
+// after the given time.
+func postmortemSlots(after time.Time) []time.Time {
+	after = after.UTC()
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, time.UTC)
+
+	var slots []time.Time
+	for len(slots) < postmortemSlotCount {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			for _, hour := range postmortemSlotHours {
+				if slot := day.Add(time.Duration(hour) * time.Hour); !slot.Before(after) && len(slots) < postmortemSlotCount {
+					slots = append(slots, slot)
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return slots
+}
+
+// formatReviewTime formats a review time in milliseconds for display.
+func formatReviewTime(reviewAt int64) string {
+	return time.UnixMilli(reviewAt).UTC().Format("Mon Jan 2 15:04 MST")
+}
+
+// proposePostmortemReview posts review slots in the thread of a resolved SEV1 ticket, for
+// responders to schedule its postmortem review.
+func (p *Plugin) proposePostmortemReview(ticket *Ticket) {
+	if !ticket.needsPostmortem() {
+		return
+	}
+
+	resolvedAt := time.UnixMilli(ticket.ResolvedAt)
+	var actions []*model.PostAction
+	for _, slot := range postmortemSlots(resolvedAt.Add(p.getConfiguration().postmortemLeadTime)) {
+		actions = append(actions, &model.PostAction{
+			Type: model.PostActionTypeButton,
+			Name: formatReviewTime(slot.UnixMilli()),
+			Integration: &model.PostActionIntegration{
+				URL: ticketActionURL(ticketActionPostmortemSchedule),
+				Context: model.StringInterface{
+					"review_at": slot.UnixMilli(),
+				},
+			},
+		})
+	}
+	actions = append(actions, &model.PostAction{
+		Type:        model.PostActionTypeButton,
+		Name:        "Mark postmortem done",
+		Style:       "primary",
+		Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionPostmortemDone)},
+	})
+
+	if err := p.createTicketReply(ticket, &model.Post{
+		Message: ":memo: This SEV1 is resolved. Pick a slot for its postmortem review:",
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{{Actions: actions}},
+		},
+	}, false); err != nil {
+		p.API.LogError("Failed to propose postmortem review", "ticket_id", ticket.ID, "err", err.Error())
+	}
+}
+
+// schedulePostmortemReview schedules the ticket's postmortem review, attaching a calendar
+// invitation to the thread and notifying the event webhook so that calendars can be updated.
+func (p *Plugin) schedulePostmortemReview(ticket *Ticket, reviewAt int64, userID string) (string, error) {
+	if !p.canEditTicket(userID, ticket) {
+		return "You are not allowed to edit this ticket.", nil
+	}
+	if ticket.PostmortemCompletedAt != 0 {
+		return "The postmortem of this request is already done.", nil
+	}
+	if reviewAt <= model.GetMillis() {
+		return "This review slot has passed.", nil
+	}
+
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.PostmortemCompletedAt != 0 {
+			return errTicketUnchanged
+		}
+		ticket.PostmortemReviewAt = reviewAt
+		ticket.PostmortemReminderSent = false
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return "The postmortem of this request is already done.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	p.sendTicketEvent(ticketEventPostmortemScheduled, ticket, userID)
+
+	fileInfo, appErr := p.API.UploadFile(postmortemInvitation(ticket), ticket.ChannelID, fmt.Sprintf("postmortem-%s.ics", ticket.ID))
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to upload postmortem invitation")
+	}
+
+	if err := p.createTicketReply(ticket, &model.Post{
+		Message: fmt.Sprintf(":calendar: %s scheduled the postmortem review for %s.", p.mentionUser(userID), formatReviewTime(reviewAt)),
+		FileIds: []string{fileInfo.Id},
+	}, false); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// completePostmortem records that the ticket's postmortem review is done.
+func (p *Plugin) completePostmortem(ticket *Ticket, userID string) (string, error) {
+	if !p.canEditTicket(userID, ticket) {
+		return "You are not allowed to edit this ticket.", nil
+	}
+	if ticket.PostmortemCompletedAt != 0 {
+		return "The postmortem of this request is already done.", nil
+	}
+
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.PostmortemCompletedAt != 0 {
+			return errTicketUnchanged
+		}
+		ticket.PostmortemCompletedAt = model.GetMillis()
+		ticket.PostmortemCompletedBy = userID
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return "The postmortem of this request is already done.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	p.sendTicketEvent(ticketEventPostmortemCompleted, ticket, userID)
+
+	if err := p.postTicketReply(ticket, fmt.Sprintf(":memo: %s marked the postmortem as done.", p.mentionUser(userID))); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// checkPostmortemReviews reminds responders of the postmortem reviews about to start.
+func (p *Plugin) checkPostmortemReviews() {
+	tickets, err := p.listTickets()
+	if err != nil {
+		p.reportJobFailure("BackgroundJob", "Failed to list tickets for postmortem check", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, ticket := range tickets {
+		if ticket.PostmortemReviewAt == 0 || ticket.PostmortemCompletedAt != 0 || ticket.PostmortemReminderSent {
+			continue
+		}
+		if now < ticket.PostmortemReviewAt-postmortemReminder.Milliseconds() {
+			continue
+		}
+
+		if err := p.remindPostmortemReview(ticket); err != nil {
+			p.API.LogError("Failed to remind postmortem review", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+}
+
+// remindPostmortemReview marks the reminder as sent, then posts it, so that it is posted once even
+// if the ticket changes meanwhile.
+func (p *Plugin) remindPostmortemReview(ticket *Ticket) error {
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.PostmortemReviewAt == 0 || ticket.PostmortemCompletedAt != 0 || ticket.PostmortemReminderSent {
+			return errTicketUnchanged
+		}
+		ticket.PostmortemReminderSent = true
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("The postmortem review of this request starts %s.", formatReviewTime(ticket.PostmortemReviewAt))
+	return p.postTicketReply(ticket, strings.TrimSpace(fmt.Sprintf(":alarm_clock: %s %s", p.ticketRecipients(ticket, message), message)))
+}
+
+// postmortemInvitation renders an iCalendar invitation for the ticket's postmortem review.
+func postmortemInvitation(ticket *Ticket) []byte {
+	const layout = "20060102T150405Z"
+	escaper := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//" + manifest.Id + "//EN",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:postmortem-%s@%s", ticket.ID, manifest.Id),
+		"DTSTAMP:" + time.Now().UTC().Format(layout),
+		"DTSTART:" + time.UnixMilli(ticket.PostmortemReviewAt).UTC().Format(layout),
+		"DTEND:" + time.UnixMilli(ticket.PostmortemReviewAt).Add(postmortemReviewDuration).UTC().Format(layout),
+		"SUMMARY:" + escaper.Replace("Postmortem review: "+ticket.Summary),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	ticketActionChangePriority = "change_priority"
+
+	dialogElementNameJustification = "justification"
+)
+
+// priorityChangeOptions are the priorities offered by the priority menu of ticket posts.
+var priorityChangeOptions = []*model.PostActionOptions{
+	{Text: "High", Value: ticketPriorityHigh},
+	{Text: "Medium", Value: ticketPriorityMedium},
+	{Text: "Low", Value: ticketPriorityLow},
+}
+
+// priorityChangeState is the state of the priority change dialog.
+type priorityChangeState struct {
+	TicketID string `json:"ticket_id"`
+	Priority string `json:"priority"`
+}
+
+// openPriorityChangeDialog asks the user for the justification of changing the ticket's priority.
+// The change is made once the dialog is submitted.
+func (p *Plugin) openPriorityChangeDialog(ticket *Ticket, priority, userID, triggerID string) (string, error) {
+	if !p.canEditTicket(userID, ticket) {
+		return "You are not allowed to change the priority of this request.", nil
+	}
+	if !isValidPriority(priority) {
+		return "No priority was selected.", nil
+	}
+	if priority == ticket.Priority {
+		return fmt.Sprintf("This request is already %s priority.", priority), nil
+	}
+
+	state, err := json.Marshal(&priorityChangeState{TicketID: ticket.ID, Priority: priority})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal priority change state")
+	}
+
+	if appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       pluginCallbackURL("/dialog/sre/priority"),
+		Dialog: model.Dialog{
+			Title:            "Change Priority",
+			IntroductionText: fmt.Sprintf("Change the priority of %s from %s to %s. Its SLA and escalations start over for the new priority, and its responders are notified.", ticket.ticketName(), ticket.Priority, priority),
+			Elements: []model.DialogElement{{
+				DisplayName: "Justification",
+				Name:        dialogElementNameJustification,
+				Type:        "textarea",
+				Placeholder: "Why does the priority change?",
+				MaxLength:   1000,
+			}},
+			SubmitLabel: "Change",
+			State:       string(state),
+		},
+	}); appErr != nil {
+		return "", errors.Wrap(appErr, "failed to open priority change dialog")
+	}
+
+	return "", nil
+}
+
+// handlePriorityChangeDialog changes the priority of the ticket once the user justified it.
+func (p *Plugin) handlePriorityChangeDialog(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		p.API.LogError("Failed to decode SubmitDialogRequest", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if request.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	justification, _ := request.Submission[dialogElementNameJustification].(string)
+	justification = strings.TrimSpace(justification)
+	if justification == "" {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameJustification: "A justification is required",
+			},
+		})
+		return
+	}
+
+	var state priorityChangeState
+	if err := json.Unmarshal([]byte(request.State), &state); err != nil {
+		p.API.LogError("Failed to decode priority change state", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ticket, err := p.getTicket(state.TicketID)
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "ticket_id", state.TicketID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if ticket == nil || !p.canViewTicket(userID, ticket) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "This request no longer exists.",
+		})
+		return
+	}
+	if !p.teamEnabled(ticket.TeamID) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "The plugin is disabled in this team.",
+		})
+		return
+	}
+	// The state comes back from the client, so the ticket it names is checked again.
+	if !p.canEditTicket(userID, ticket) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "You are not allowed to change the priority of this request.",
+		})
+		return
+	}
+
+	_, message, err := p.changeTicketPriority(ticket, state.Priority, userID, justification)
+	if err != nil {
+		p.API.LogError("Failed to change ticket priority", "ticket_id", ticket.ID, "err", err.Error())
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: ticketUpdateError(err, "Failed to change the priority. Please try again later."),
+		})
+		return
+	}
+	if message != "" {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: message,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// restartTicketEscalations starts the SLA and the escalation policy of an open ticket over after
+// its priority changed, so that they follow the SLA and the tiers of the new priority. The time
+// the ticket has already been open counts towards them.
+func restartTicketEscalations(ticket *Ticket) {
+	if ticket.Status != ticketStatusOpen {
+		return
+	}
+
+	ticket.SLABreachedAt = 0
+	ticket.EscalationTier = 0
+}
+
+// notifyPriorityResponders mentions the responders of the ticket's new priority in its thread.
+func (p *Plugin) notifyPriorityResponders(ticket *Ticket) error {
+	if ticket.Confidential || ticket.Status == ticketStatusResolved {
+		return nil
+	}
+
+	localizer := p.serverLocalizer()
+	priority := strings.ToLower(localizeLabel(localizer, priorityLabels, ticket.Priority))
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.priority_changed",
+		Other: "This request is now {{.Priority}} priority and needs your attention.",
+	}, map[string]interface{}{"Priority": priority})
+	mentions := p.responderMentions(ticket, p.ticketDirectMessage(ticket, message))
+	if mentions == "" {
+		return nil
+	}
+
+	return p.postTicketReply(ticket, localize(localizer, &i18n.Message{
+		ID:    "ticket.priority_changed_mentions",
+		Other: "{{.Mentions}} this request is now {{.Priority}} priority and needs your attention.",
+	}, map[string]interface{}{"Mentions": mentions, "Priority": priority}))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// executeCommandQuick opens a ticket straight from the command line, without the intake dialog.
+// The text following the priority is both the summary and the description of the ticket. The
+// reporter is asked in the ticket's thread for the required custom fields, since the command
+// cannot collect them.
+func (p *Plugin) executeCommandQuick(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) < 2 {
+		return ephemeralResponse(commandUsage("quick"))
+	}
+
+	priority, ok := matchPriority(params[0])
+	if !ok {
+		return ephemeralResponse(fmt.Sprintf("Invalid priority %q, expected High, Medium or Low. %s", params[0], commandUsage("quick")))
+	}
+
+	configuration := p.getConfiguration()
+	if _, ok := configuration.demoChannelIDs[args.TeamId]; !ok {
+		return ephemeralResponse("SRE requests are not enabled in this team.")
+	}
+
+	text := strings.Join(params[1:], " ")
+	ticket := &Ticket{
+		TeamID:      args.TeamId,
+		ReporterID:  args.UserId,
+		Summary:     text,
+		Description: text,
+		Priority:    priority,
+	}
+	for _, field := range configuration.customFields {
+		if field.Required {
+			ticket.PendingCustomFields = append(ticket.PendingCustomFields, field.Name)
+		}
+	}
+	configuration.suggestTicketPriority(ticket, true)
+
+	if err := p.createTicket(ticket); err != nil {
+		p.API.LogError("Failed to create ticket", "err", err.Error())
+		return ephemeralResponse("Failed to submit the SRE request. Please try again later.")
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		p.API.LogError("Failed to notify responders", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	if err := p.promptForCustomField(ticket); err != nil {
+		p.API.LogWarn("Failed to prompt for custom field", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	if err := p.promptForFiles(ticket); err != nil {
+		p.API.LogWarn("Failed to prompt for ticket files", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	name := ticket.ticketName()
+	if permalink, err := p.ticketPermalink(ticket); err == nil {
+		name = fmt.Sprintf("[%s](%s)", name, permalink)
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Opened %s: %s", name, ticket.Summary))
+}
+
+// pendingCustomField returns the definition of the first custom field the reporter is still asked
+// for, dropping the fields no longer defined in the configuration.
+func (c *configuration) pendingCustomField(ticket *Ticket) *customField {
+	for len(ticket.PendingCustomFields) > 0 {
+		for _, field := range c.customFields {
+			if field.Name == ticket.PendingCustomFields[0] {
+				return field
+			}
+		}
+		ticket.PendingCustomFields = ticket.PendingCustomFields[1:]
+	}
+
+	return nil
+}
+
+// promptForCustomField asks the reporter, in the ticket's thread, for the first custom field the
+// ticket is missing.
+func (p *Plugin) promptForCustomField(ticket *Ticket) error {
+	message := p.customFieldPrompt(ticket)
+	if message == "" {
+		return nil
+	}
+
+	return p.postTicketReply(ticket, message)
+}
+
+// customFieldPrompt returns the message asking the reporter for the first custom field the ticket
+// is missing, or an empty string if it misses none.
+func (p *Plugin) customFieldPrompt(ticket *Ticket) string {
+	field := p.getConfiguration().pendingCustomField(ticket)
+	if field == nil {
+		return ""
+	}
+
+	localizer := p.serverLocalizer()
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.custom_field_prompt",
+		Other: ":pencil: {{.Reporter}}, reply in this thread with the **{{.Field}}** of the request.",
+	}, map[string]interface{}{"Reporter": p.mentionUser(ticket.ReporterID), "Field": field.DisplayName})
+	if field.HelpText != "" {
+		message += " " + field.HelpText
+	}
+	switch field.Type {
+	case customFieldTypeSelect:
+		message += " " + localize(localizer, &i18n.Message{
+			ID:    "ticket.custom_field_prompt.options",
+			Other: "One of: {{.Options}}.",
+		}, map[string]interface{}{"Options": strings.Join(field.Options, ", ")})
+	case customFieldTypeBool:
+		message += " " + localize(localizer, &i18n.Message{
+			ID:    "ticket.custom_field_prompt.bool",
+			Other: "Reply true or false.",
+		}, nil)
+	}
+
+	return message
+}
+
+// answerCustomFieldPrompt sets the custom field the reporter was asked for to the value of their
+// reply, and returns the message to post in the thread once the ticket is saved: the next prompt,
+// a confirmation once every field is set, or why the value was rejected. It returns an empty
+// message if the post doesn't answer a prompt.
+func (p *Plugin) answerCustomFieldPrompt(ticket *Ticket, post *model.Post) string {
+	if post.UserId != ticket.ReporterID || len(ticket.PendingCustomFields) == 0 {
+		return ""
+	}
+
+	field := p.getConfiguration().pendingCustomField(ticket)
+	if field == nil {
+		return ""
+	}
+
+	value, err := field.normalize(post.Message)
+	if err != nil {
+		return localize(p.serverLocalizer(), &i18n.Message{
+			ID:    "ticket.custom_field_prompt.invalid",
+			Other: ":warning: {{.Error}}. Please reply again with the **{{.Field}}** of the request.",
+		}, map[string]interface{}{"Error": err.Error(), "Field": field.DisplayName})
+	}
+
+	setTicketCustomFields(ticket, map[string]string{field.Name: value})
+	ticket.PendingCustomFields = ticket.PendingCustomFields[1:]
+	if prompt := p.customFieldPrompt(ticket); prompt != "" {
+		return prompt
+	}
+
+	return localize(p.serverLocalizer(), &i18n.Message{
+		ID:    "ticket.custom_field_prompt.done",
+		Other: ":white_check_mark: Thanks, the request has every required field.",
+	}, nil)
+}
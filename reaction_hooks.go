@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	defaultAcknowledgeEmoji = "eyes"
+	defaultResolveEmoji     = "white_check_mark"
+)
+
+// reactionEmojis returns the names of the emojis acknowledging and resolving tickets.
+func (c *configuration) reactionEmojis() (string, string) {
+	acknowledgeEmoji := strings.Trim(strings.TrimSpace(c.AcknowledgeEmoji), ":")
+	if acknowledgeEmoji == "" {
+		acknowledgeEmoji = defaultAcknowledgeEmoji
+	}
+
+	resolveEmoji := strings.Trim(strings.TrimSpace(c.ResolveEmoji), ":")
+	if resolveEmoji == "" {
+		resolveEmoji = defaultResolveEmoji
+	}
+
+	return acknowledgeEmoji, resolveEmoji
+}
+
+// ReactionHasBeenAdded is invoked after the reaction has been committed to the database.
+//
+// Note that this method will be called for reactions added by plugins, including the plugin that
+// added the reaction.
+//
+// This implementation acknowledges or resolves a ticket when the configured emoji is added to the
+// ticket's root post.
+func (p *Plugin) ReactionHasBeenAdded(c *plugin.Context, reaction *model.Reaction) {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled || reaction.UserId == p.botID {
+		return
+	}
+
+	acknowledgeEmoji, resolveEmoji := configuration.reactionEmojis()
+	if reaction.EmojiName != acknowledgeEmoji && reaction.EmojiName != resolveEmoji {
+		return
+	}
+
+	ticket, err := p.getTicketByPostID(reaction.PostId)
+	if err != nil {
+		p.API.LogError("Failed to get ticket for reaction", "post_id", reaction.PostId, "err", err.Error())
+		return
+	}
+	if ticket == nil || !p.teamEnabled(ticket.TeamID) || !p.canViewTicket(reaction.UserId, ticket) {
+		return
+	}
+
+	switch reaction.EmojiName {
+	case acknowledgeEmoji:
+		_, err = p.acknowledgeTicket(ticket, reaction.UserId)
+	case resolveEmoji:
+		if !p.authorizeDestructiveAction(reaction.UserId, "resolve_ticket", "ticket_id", ticket.ID) {
+			p.API.SendEphemeralPost(reaction.UserId, &model.Post{
+				UserId:    p.botID,
+				ChannelId: reaction.ChannelId,
+				RootId:    reaction.PostId,
+				Message:   "You are not authorized to resolve SRE requests.",
+			})
+			return
+		}
+		_, err = p.resolveTicket(ticket, reaction.UserId)
+	}
+	if err != nil {
+		p.API.LogError("Failed to update ticket from reaction", "ticket_id", ticket.ID, "err", err.Error())
+	}
+}
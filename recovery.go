@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// commandPanicMessage is the response of a command that panicked.
+const commandPanicMessage = "Something went wrong while running this command. The SRE admins have been notified."
+
+// reportPanic logs a recovered panic with its stack trace and reports it to the admin channel, at
+// most once per jobFailureReportInterval for each source.
+func (p *Plugin) reportPanic(source string, recovered interface{}) {
+	p.API.LogError("Recovered from panic", "source", source, "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+
+	if p.jobFailureReports.shouldReport("panic: " + source) {
+		p.postAdminNotice(fmt.Sprintf(":boom: Recovered from a panic in %s: %v\nSee the server logs for the stack trace.", source, recovered))
+	}
+}
+
+// commandSource names the command being run, for panic reports.
+func commandSource(args *model.CommandArgs) string {
+	fields := strings.Fields(args.Command)
+	if len(fields) > 2 {
+		fields = fields[:2]
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// recoverPanics recovers from panics of HTTP handlers, reporting them and responding with an
+// internal server error instead of taking the plugin down.
+func (p *Plugin) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			// Report the route rather than the path, which may contain ids.
+			source := r.URL.Path
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					source = template
+				}
+			}
+			p.reportPanic(r.Method+" "+source, recovered)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// executeCommandCrash panics on purpose, to exercise the panic recovery. It is only available
+// when EnableCrashSimulation is set, to SRE admins.
+func (p *Plugin) executeCommandCrash(args *model.CommandArgs) *model.CommandResponse {
+	if !p.getConfiguration().EnableCrashSimulation {
+		return ephemeralResponse("Unknown command: crash")
+	}
+
+	panic("simulated crash requested by user " + args.UserId)
+}
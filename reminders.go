@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	ticketActionSnooze = "snooze"
+
+	// reminderJobKeyPrefix prefixes the keys of the one-shot reminder jobs, followed by the ticket
+	// id. A ticket has at most one pending reminder, which a new reminder replaces.
+	reminderJobKeyPrefix = "ticket_reminder_"
+)
+
+// snoozeOptions are the durations offered by the snooze menu of ticket posts.
+var snoozeOptions = []*model.PostActionOptions{
+	{Text: "1 hour", Value: "1h"},
+	{Text: "4 hours", Value: "4h"},
+	{Text: "1 day", Value: "1d"},
+	{Text: "1 week", Value: "7d"},
+}
+
+// reminderProps are the props of a reminder job, stored with the job so that reminders survive
+// plugin restarts.
+type reminderProps struct {
+	TicketID string `json:"ticket_id"`
+	UserID   string `json:"user_id"`
+}
+
+// reminderJobKey returns the key of the reminder job of the ticket.
+func reminderJobKey(ticketID string) string {
+	return reminderJobKeyPrefix + ticketID
+}
+
+// scheduleReminder schedules a reminder of the ticket after the duration, on behalf of userID,
+// replacing the pending reminder of the ticket, if any. The reminder runs once across the cluster.
+func (p *Plugin) scheduleReminder(ticket *Ticket, duration time.Duration, userID string) (string, error) {
+	if ticket.Status == ticketStatusResolved {
+		return "This request is already resolved.", nil
+	}
+	if p.reminderScheduler == nil {
+		return "Reminders are unavailable, the reminder scheduler failed to start.", nil
+	}
+
+	key := reminderJobKey(ticket.ID)
+	p.reminderScheduler.Cancel(key)
+
+	remindAt := time.Now().Add(duration)
+	if _, err := p.reminderScheduler.ScheduleOnce(key, remindAt, &reminderProps{
+		TicketID: ticket.ID,
+		UserID:   userID,
+	}); err != nil {
+		return "", errors.Wrap(err, "failed to schedule reminder")
+	}
+
+	return fmt.Sprintf("%s will be pinged in this thread on %s.", ticketReminderTarget(ticket), remindAt.UTC().Format("Mon Jan 2 15:04 MST")), nil
+}
+
+// ticketReminderTarget names who is pinged by reminders of the ticket.
+func ticketReminderTarget(ticket *Ticket) string {
+	if ticket.AssigneeID == "" {
+		return "You"
+	}
+
+	return "The assignee"
+}
+
+// handleJobOnce runs the one-shot jobs scheduled by the plugin once they are due.
+func (p *Plugin) handleJobOnce(key string, props any) {
+	if !strings.HasPrefix(key, reminderJobKeyPrefix) {
+		p.API.LogWarn("Unknown one-shot job", "key", key)
+		return
+	}
+
+	// Props are decoded from JSON when the job was loaded from the KV store, so they are converted
+	// back into reminderProps either way.
+	data, err := json.Marshal(props)
+	if err != nil {
+		p.API.LogError("Failed to marshal reminder props", "key", key, "err", err.Error())
+		return
+	}
+	var reminder reminderProps
+	if err := json.Unmarshal(data, &reminder); err != nil {
+		p.API.LogError("Failed to unmarshal reminder props", "key", key, "err", err.Error())
+		return
+	}
+
+	if err := p.sendReminder(&reminder); err != nil {
+		p.API.LogError("Failed to send ticket reminder", "ticket_id", reminder.TicketID, "err", err.Error())
+	}
+}
+
+// sendReminder pings the assignee of the reminded ticket in its thread, or the user who asked for
+// the reminder if the ticket is unassigned. Reminders of resolved or deleted tickets are dropped.
+func (p *Plugin) sendReminder(reminder *reminderProps) error {
+	if p.getConfiguration().disabled {
+		return nil
+	}
+
+	ticket, err := p.getTicket(reminder.TicketID)
+	if err != nil {
+		return err
+	}
+	if ticket == nil || ticket.Status == ticketStatusResolved {
+		return nil
+	}
+
+	targetID := ticket.AssigneeID
+	if targetID == "" {
+		targetID = reminder.UserID
+	}
+
+	message := fmt.Sprintf(":alarm_clock: Reminder from %s about this request.", p.username(reminder.UserID))
+	mention := p.notifyUser(targetID, p.ticketDirectMessage(ticket, message))
+
+	return p.postTicketReply(ticket, fmt.Sprintf(":alarm_clock: %s, reminder from %s about this request.", mention, p.username(reminder.UserID)))
+}
+
+func (p *Plugin) executeCommandRemind(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 2 {
+		return ephemeralResponse(commandUsage("remind"))
+	}
+
+	duration, err := parseDuration(params[1])
+	if err != nil || duration <= 0 {
+		return ephemeralResponse(fmt.Sprintf("Invalid duration %q, use a duration such as 4h or 2d.", params[1]))
+	}
+
+	ticket, err := p.findTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+
+	message, err := p.scheduleReminder(ticket, duration, args.UserId)
+	if err != nil {
+		p.API.LogError("Failed to schedule reminder", "ticket_id", ticket.ID, "err", err.Error())
+		return ephemeralResponse("Failed to schedule the reminder.")
+	}
+
+	return ephemeralResponse(message)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"plugin-test/utils"
+)
+
+const (
+	// repeatSubmissionSimilarityThreshold is the minimum token overlap between two summaries for a
+	// submission to be considered a repeat of the reporter's previous ticket.
+	repeatSubmissionSimilarityThreshold = 0.9
+
+	// repeatSubmissionWindow is how long after a reporter's last submission a near-identical
+	// submission is collapsed into the same ticket.
+	repeatSubmissionWindow = 10 * time.Minute
+)
+
+// lastSubmittedAt returns when the ticket was last submitted, including repeated submissions.
+func (t *Ticket) lastSubmittedAt() int64 {
+	if t.LastRepeatAt > t.CreateAt {
+		return t.LastRepeatAt
+	}
+
+	return t.CreateAt
+}
+
+// findRepeatedSubmission returns the unresolved ticket of the same reporter that the ticket
+// repeats, or nil if the reporter submitted nothing near-identical within repeatSubmissionWindow.
+func (p *Plugin) findRepeatedSubmission(ticket *Ticket) (*Ticket, error) {
+	tickets, err := p.listCachedTickets(ticket.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := utils.Tokenize(ticket.Summary)
+	since := model.GetMillis() - repeatSubmissionWindow.Milliseconds()
+
+	var repeated *Ticket
+	for _, candidate := range tickets {
+		if candidate.ReporterID != ticket.ReporterID || candidate.TeamID != ticket.TeamID || candidate.Status == ticketStatusResolved {
+			continue
+		}
+		if candidate.lastSubmittedAt() < since || (repeated != nil && candidate.lastSubmittedAt() < repeated.lastSubmittedAt()) {
+			continue
+		}
+		if utils.TokenOverlap(tokens, utils.Tokenize(candidate.Summary)) < repeatSubmissionSimilarityThreshold {
+			continue
+		}
+
+		repeated = candidate
+	}
+
+	return repeated, nil
+}
+
+// collapseRepeatedSubmission counts a repeated submission on the ticket it repeats instead of
+// posting a new ticket, and tells the reporter ephemerally.
+func (p *Plugin) collapseRepeatedSubmission(ticket *Ticket, channelID string) error {
+	// The cached ticket may be stale, so update the stored one.
+	ticket, err := p.getTicket(ticket.ID)
+	if err != nil {
+		return err
+	}
+	if ticket == nil {
+		return errors.New("repeated ticket no longer exists")
+	}
+
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.RepeatCount++
+		ticket.LastRepeatAt = model.GetMillis()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	link := fmt.Sprintf("%q", ticket.Summary)
+	if permalink, err := p.ticketPermalink(ticket); err == nil {
+		link = fmt.Sprintf("[%s](%s)", ticket.Summary, permalink)
+	}
+
+	p.API.SendEphemeralPost(ticket.ReporterID, &model.Post{
+		UserId:    p.botID,
+		ChannelId: channelID,
+		Message: fmt.Sprintf(
+			"You already submitted this request as %s, so it was counted as a repeat instead of being posted again. It has been submitted %d times.",
+			link, ticket.RepeatCount+1,
+		),
+	})
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// defaultReporterConfirmationTemplate renders the confirmations sent to reporters when no template
+// is configured.
+const defaultReporterConfirmationTemplate = "Thanks! Your SRE request **{{.Key}}** was submitted: {{.Summary}}\n" +
+	"{{if .ExpectedResponse}}Expected response for {{.Priority}} priority: {{.ExpectedResponse}}\n{{end}}" +
+	"Follow it in [its thread]({{.Permalink}})."
+
+// reporterConfirmation holds the fields available to the reporter confirmation template.
+type reporterConfirmation struct {
+	// Key is the ticket key, such as "SRE-123", or its id if it has no key.
+	Key string
+
+	Summary  string
+	Priority string
+
+	// ExpectedResponse describes when the ticket is expected to be acknowledged given its SLA and
+	// the business hours, such as "By Mon 09:30 UTC", or is empty if nothing is expected.
+	ExpectedResponse string
+
+	// Permalink links to the ticket's thread.
+	Permalink string
+}
+
+// parseReporterConfirmationTemplate parses the configured reporter confirmation template, falling
+// back to the default template.
+func parseReporterConfirmationTemplate(configuration *configuration) (*template.Template, error) {
+	text := configuration.ReporterConfirmationTemplate
+	if strings.TrimSpace(text) == "" {
+		text = defaultReporterConfirmationTemplate
+	}
+
+	return template.New("reporter_confirmation").Parse(text)
+}
+
+// sendReporterConfirmation sends the reporter of a new ticket a direct message confirming its
+// submission, if enabled. Tickets reported by the bot on behalf of integrations are skipped.
+func (p *Plugin) sendReporterConfirmation(ticket *Ticket) error {
+	configuration := p.getConfiguration()
+	if !configuration.EnableReporterConfirmation || ticket.ReporterID == p.botID {
+		return nil
+	}
+
+	permalink, err := p.ticketPermalink(ticket)
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if err := configuration.reporterConfirmationTemplate.Execute(&buffer, &reporterConfirmation{
+		Key:              ticket.ticketName(),
+		Summary:          ticket.Summary,
+		Priority:         ticket.Priority,
+		ExpectedResponse: configuration.expectedResponse(ticket),
+		Permalink:        permalink,
+	}); err != nil {
+		return errors.Wrap(err, "failed to render reporter confirmation")
+	}
+
+	return p.sendDirectMessage(ticket.ReporterID, buffer.String())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// responderNames returns the usernames and group names of the responders of the given priority.
+func (c *configuration) responderNames(priority string) []string {
+	switch priority {
+	case ticketPriorityHigh:
+		return splitUsernames(c.HighPriorityResponders)
+	case ticketPriorityMedium:
+		return splitUsernames(c.MediumPriorityResponders)
+	case ticketPriorityLow:
+		return splitUsernames(c.LowPriorityResponders)
+	default:
+		return nil
+	}
+}
+
+// responders returns the ids of the existing users and the names of the existing groups responding
+// to tickets of the given priority. Responders that don't exist are logged and skipped.
+func (p *Plugin) responders(priority string) ([]string, []string) {
+	var userIDs, groupNames []string
+	for _, name := range p.getConfiguration().responderNames(priority) {
+		if user, appErr := p.API.GetUserByUsername(name); appErr == nil {
+			userIDs = append(userIDs, user.Id)
+			continue
+		}
+		if _, appErr := p.API.GetGroupByName(name); appErr == nil {
+			groupNames = append(groupNames, name)
+			continue
+		}
+
+		p.API.LogWarn("Ignoring unknown responder", "priority", priority, "name", name)
+	}
+
+	return userIDs, groupNames
+}
+
+// responderMentions notifies the user on call, the responders of the ticket's priority and the
+// groups of its routing rule according to their notification preferences, sending dmMessage to
+// those preferring direct messages, and returns the mentions of the others.
+func (p *Plugin) responderMentions(ticket *Ticket, dmMessage string) string {
+	userIDs, groupNames := p.responders(ticket.Priority)
+	for _, name := range p.getConfiguration().routingGroupNames(ticket) {
+		if !contains(groupNames, name) {
+			groupNames = append(groupNames, name)
+		}
+	}
+	if onCallUserID := p.onCallUserID(); onCallUserID != "" {
+		userIDs = append([]string{onCallUserID}, userIDs...)
+	}
+
+	return p.notifyRecipients(userIDs, groupNames, dmMessage)
+}
+
+// notifyResponders mentions the user on call, the responders of the ticket's priority and the
+// groups of its routing rule in the ticket's thread.
+// Confidential tickets are never shared with responders beyond their participants, and tickets
+// queued outside business hours are announced once business hours start instead.
+func (p *Plugin) notifyResponders(ticket *Ticket) error {
+	if ticket.Confidential || ticket.isQueued() {
+		return nil
+	}
+
+	localizer := p.serverLocalizer()
+	priority := strings.ToLower(localizeLabel(localizer, priorityLabels, ticket.Priority))
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.needs_attention",
+		Other: "A new {{.Priority}} priority request needs your attention.",
+	}, map[string]interface{}{"Priority": priority})
+	mentions := p.responderMentions(ticket, p.ticketDirectMessage(ticket, message))
+	if mentions == "" {
+		return nil
+	}
+
+	return p.postTicketReply(ticket, localize(localizer, &i18n.Message{
+		ID:    "ticket.needs_attention_mentions",
+		Other: "{{.Mentions}} a new {{.Priority}} priority request needs your attention.",
+	}, map[string]interface{}{"Mentions": mentions, "Priority": priority}))
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// channelRepairsKey is the KV key of the SRE channels found archived or deleted, by team id.
+const channelRepairsKey = "channel_repairs"
+
+// ChannelRepair marks the SRE channel of a team as archived or deleted. Until the channel is
+// restored or the configuration routes the team to another channel, posts for the team go to the
+// fallback channel.
+type ChannelRepair struct {
+	TeamID     string `json:"team_id"`
+	ChannelID  string `json:"channel_id"`
+	DetectedAt int64  `json:"detected_at"`
+}
+
+// fallbackChannelName returns the name of the channel receiving the posts of teams whose SRE
+// channel is archived or deleted.
+func (c *configuration) fallbackChannelName() string {
+	if c.FallbackChannelName == "" {
+		return model.DefaultChannelName
+	}
+
+	return c.FallbackChannelName
+}
+
+func (p *Plugin) getChannelRepairs() (map[string]*ChannelRepair, error) {
+	var repairs map[string]*ChannelRepair
+	if err := p.client.KV.Get(channelRepairsKey, &repairs); err != nil {
+		return nil, errors.Wrap(err, "failed to get channel repairs")
+	}
+	if repairs == nil {
+		repairs = make(map[string]*ChannelRepair)
+	}
+
+	return repairs, nil
+}
+
+func (p *Plugin) saveChannelRepairs(repairs map[string]*ChannelRepair) error {
+	if _, err := p.client.KV.Set(channelRepairsKey, repairs); err != nil {
+		return errors.Wrap(err, "failed to save channel repairs")
+	}
+
+	return nil
+}
+
+// isChannelGone reports whether the channel was archived or deleted.
+func (p *Plugin) isChannelGone(channelID string) bool {
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return appErr.StatusCode == http.StatusNotFound
+	}
+
+	return channel.DeleteAt != 0
+}
+
+// fallbackChannelID returns the id of the team's fallback channel.
+func (p *Plugin) fallbackChannelID(teamID string) (string, error) {
+	team, appErr := p.getCachedTeam(teamID)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get team")
+	}
+
+	channel, appErr := p.API.GetChannelByNameForTeamName(team.Name, p.getConfiguration().fallbackChannelName(), false)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get fallback channel")
+	}
+
+	return channel.Id, nil
+}
+
+// createRoutedPost creates the post built by newPost in the team's SRE channel. If the channel is
+// archived or deleted, the post goes to the fallback channel instead and the channel is marked for
+// repair, notifying the system admins. newPost may be called for both channels.
+func (p *Plugin) createRoutedPost(teamID string, newPost func(channelID string) (*model.Post, error)) (*model.Post, error) {
+	channelID, ok := p.getConfiguration().demoChannelIDs[teamID]
+	if !ok {
+		return nil, errors.Errorf("no SRE channel for team %s", teamID)
+	}
+
+	repairs, err := p.getChannelRepairs()
+	if err != nil {
+		return nil, err
+	}
+
+	if repair, ok := repairs[teamID]; !ok || repair.ChannelID != channelID {
+		post, err := newPost(channelID)
+		if err != nil {
+			return nil, err
+		}
+
+		created, appErr := p.API.CreatePost(post)
+		if appErr == nil {
+			return created, nil
+		}
+		if !p.isChannelGone(channelID) {
+			return nil, errors.Wrap(appErr, "failed to create post")
+		}
+
+		p.markChannelForRepair(repairs, teamID, channelID)
+	}
+
+	fallbackChannelID, err := p.fallbackChannelID(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := newPost(fallbackChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	created, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to create post in the fallback channel")
+	}
+
+	return created, nil
+}
+
+// markChannelForRepair records that the team's SRE channel is gone and notifies the system admins.
+func (p *Plugin) markChannelForRepair(repairs map[string]*ChannelRepair, teamID, channelID string) {
+	p.API.LogWarn("SRE channel is archived or deleted, routing posts to the fallback channel", "team_id", teamID, "channel_id", channelID)
+
+	repairs[teamID] = &ChannelRepair{
+		TeamID:     teamID,
+		ChannelID:  channelID,
+		DetectedAt: model.GetMillis(),
+	}
+	if err := p.saveChannelRepairs(repairs); err != nil {
+		p.API.LogError("Failed to mark SRE channel for repair", "team_id", teamID, "err", err.Error())
+	}
+
+	teamName := teamID
+	if team, appErr := p.getCachedTeam(teamID); appErr == nil {
+		teamName = team.DisplayName
+	}
+
+	message := fmt.Sprintf(
+		":warning: The SRE channel of the %s team was archived or deleted. SRE posts for the team go to ~%s until the channel is restored or the channel setting is changed.",
+		teamName, p.getConfiguration().fallbackChannelName(),
+	)
+	p.notifySystemAdmins(message)
+	p.postAdminNotice(message)
+}
+
+// notifySystemAdmins sends a direct message from the bot to every system admin, or only logs the
+// message in minimal-permission mode.
+func (p *Plugin) notifySystemAdmins(message string) {
+	if p.getConfiguration().MinimalPermissions {
+		p.API.LogWarn("System admin notification", "message", message)
+		return
+	}
+
+	for page := 0; ; page++ {
+		admins, err := p.client.User.List(&model.UserGetOptions{
+			Role:    model.SystemAdminRoleId,
+			Page:    page,
+			PerPage: 100,
+		})
+		if err != nil {
+			p.API.LogError("Failed to list system admins", "err", err.Error())
+			return
+		}
+
+		for _, admin := range admins {
+			if err := p.client.Post.DM(p.botID, admin.Id, &model.Post{Message: message}); err != nil {
+				p.API.LogError("Failed to notify system admin", "user_id", admin.Id, "err", err.Error())
+			}
+		}
+
+		if len(admins) < 100 {
+			return
+		}
+	}
+}
+
+// repairChannelRoutes clears the repair marks of SRE channels that were restored, or replaced by a
+// configuration change.
+func (p *Plugin) repairChannelRoutes() {
+	repairs, err := p.getChannelRepairs()
+	if err != nil {
+		p.API.LogError("Failed to get channel repairs", "err", err.Error())
+		return
+	}
+	if len(repairs) == 0 {
+		return
+	}
+
+	configuration := p.getConfiguration()
+	repaired := false
+	for teamID, repair := range repairs {
+		if channelID, ok := configuration.demoChannelIDs[teamID]; ok && channelID == repair.ChannelID && p.isChannelGone(channelID) {
+			continue
+		}
+
+		delete(repairs, teamID)
+		repaired = true
+		p.API.LogInfo("SRE channel repaired, routing posts to it again", "team_id", teamID)
+	}
+
+	if repaired {
+		if err := p.saveChannelRepairs(repairs); err != nil {
+			p.API.LogError("Failed to save channel repairs", "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	routingFieldCategory    = "category"
+	routingFieldPriority    = "priority"
+	routingFieldSummary     = "summary"
+	routingFieldDescription = "description"
+
+	routingOperatorContains = "contains"
+)
+
+// routingConditionPattern matches a condition of a routing rule, such as priority >= Medium.
+var routingConditionPattern = regexp.MustCompile(`(?i)^(\w+)\s*(==|!=|>=|<=|>|<|\s` + routingOperatorContains + `\s)\s*(.+)$`)
+
+// routingAndPattern separates the conditions of a routing rule.
+var routingAndPattern = regexp.MustCompile(`(?i)\s+AND\s+`)
+
+// priorityRanks orders the priorities for the comparisons of routing rules.
+var priorityRanks = map[string]int{
+	ticketPriorityLow:    1,
+	ticketPriorityMedium: 2,
+	ticketPriorityHigh:   3,
+}
+
+// routingRule routes the tickets matching all of its conditions: their root post goes to a
+// channel of their team, groups are mentioned when they are submitted or escalated, and their SLA
+// overrides the SLA of their priority. Each action is optional.
+type routingRule struct {
+	text       string
+	conditions []routingCondition
+
+	channelName string
+	groupNames  []string
+	sla         time.Duration
+}
+
+type routingCondition struct {
+	field    string
+	operator string
+	value    string
+}
+
+// parseRoutingRules parses RoutingRules, a list of rules separated by semicolons or newlines, each
+// as "conditions -> actions". Conditions are joined with AND, such as
+// `category == "pipeline" AND priority >= Medium`, and actions are separated by commas, such as
+// "channel pipeline-support, group pipeline-oncall, sla 2h".
+func parseRoutingRules(configuration *configuration) ([]*routingRule, error) {
+	var rules []*routingRule
+	for _, entry := range strings.FieldsFunc(configuration.RoutingRules, func(r rune) bool { return r == ';' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		rule, err := parseRoutingRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func parseRoutingRule(entry string) (*routingRule, error) {
+	conditions, actions, ok := strings.Cut(strings.ReplaceAll(entry, "→", "->"), "->")
+	if !ok {
+		return nil, errors.Errorf("invalid routing rule %q, expected conditions and actions separated by ->", entry)
+	}
+
+	rule := &routingRule{text: entry}
+	for _, condition := range routingAndPattern.Split(strings.TrimSpace(conditions), -1) {
+		parsed, err := parseRoutingCondition(condition)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid routing rule %q", entry)
+		}
+		rule.conditions = append(rule.conditions, parsed)
+	}
+
+	for _, action := range strings.Split(actions, ",") {
+		fields := strings.Fields(action)
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "tag") {
+			fields = fields[1:]
+		}
+		if len(fields) != 2 {
+			return nil, errors.Errorf("invalid action %q in routing rule %q, expected channel, group or sla followed by a value", strings.TrimSpace(action), entry)
+		}
+
+		value := strings.Trim(fields[1], `"`)
+		switch strings.ToLower(fields[0]) {
+		case "channel":
+			rule.channelName = strings.TrimPrefix(value, "~")
+		case "group":
+			rule.groupNames = append(rule.groupNames, strings.TrimPrefix(value, "@"))
+		case "sla":
+			sla, err := time.ParseDuration(value)
+			if err != nil || sla <= 0 {
+				return nil, errors.Errorf("invalid SLA %q in routing rule %q", value, entry)
+			}
+			rule.sla = sla
+		default:
+			return nil, errors.Errorf("unknown action %q in routing rule %q, expected channel, group or sla", fields[0], entry)
+		}
+	}
+
+	return rule, nil
+}
+
+func parseRoutingCondition(condition string) (routingCondition, error) {
+	match := routingConditionPattern.FindStringSubmatch(strings.TrimSpace(condition))
+	if match == nil {
+		return routingCondition{}, errors.Errorf("invalid condition %q, expected a field, an operator and a value", condition)
+	}
+
+	parsed := routingCondition{
+		field:    strings.ToLower(match[1]),
+		operator: strings.ToLower(strings.TrimSpace(match[2])),
+		value:    strings.Trim(strings.TrimSpace(match[3]), `"`),
+	}
+
+	switch parsed.field {
+	case routingFieldPriority:
+		priority, ok := matchPriority(parsed.value)
+		if !ok {
+			return routingCondition{}, errors.Errorf("invalid priority %q in condition %q", parsed.value, condition)
+		}
+		parsed.value = priority
+		if parsed.operator == routingOperatorContains {
+			return routingCondition{}, errors.Errorf("invalid operator %q for the priority in condition %q", parsed.operator, condition)
+		}
+	case routingFieldCategory, routingFieldSummary, routingFieldDescription:
+		switch parsed.operator {
+		case "==", "!=", routingOperatorContains:
+		default:
+			return routingCondition{}, errors.Errorf("invalid operator %q for the %s in condition %q, expected ==, != or contains", parsed.operator, parsed.field, condition)
+		}
+	default:
+		return routingCondition{}, errors.Errorf("unknown field %q in condition %q, expected category, priority, summary or description", parsed.field, condition)
+	}
+
+	return parsed, nil
+}
+
+// matches reports whether the ticket matches the condition. Text is compared case-insensitively.
+func (c routingCondition) matches(ticket *Ticket) bool {
+	if c.field == routingFieldPriority {
+		rank, value := priorityRanks[ticket.Priority], priorityRanks[c.value]
+		switch c.operator {
+		case "==":
+			return rank == value
+		case "!=":
+			return rank != value
+		case ">=":
+			return rank >= value
+		case "<=":
+			return rank <= value
+		case ">":
+			return rank > value
+		case "<":
+			return rank < value
+		}
+		return false
+	}
+
+	var text string
+	switch c.field {
+	case routingFieldCategory:
+		text = ticket.Category
+	case routingFieldSummary:
+		text = ticket.Summary
+	case routingFieldDescription:
+		text = ticket.Description
+	}
+
+	switch c.operator {
+	case "==":
+		return strings.EqualFold(text, c.value)
+	case "!=":
+		return !strings.EqualFold(text, c.value)
+	case routingOperatorContains:
+		return strings.Contains(strings.ToLower(text), strings.ToLower(c.value))
+	}
+
+	return false
+}
+
+func (r *routingRule) matches(ticket *Ticket) bool {
+	for _, condition := range r.conditions {
+		if !condition.matches(ticket) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeActions lists the actions of the rule for the rules command.
+func (r *routingRule) describeActions() string {
+	var actions []string
+	if r.channelName != "" {
+		actions = append(actions, "post in ~"+r.channelName)
+	}
+	for _, name := range r.groupNames {
+		actions = append(actions, "mention @"+name)
+	}
+	if r.sla != 0 {
+		actions = append(actions, "SLA of "+r.sla.String())
+	}
+	if len(actions) == 0 {
+		return "no action"
+	}
+
+	return strings.Join(actions, ", ")
+}
+
+// matchRoutingRule returns the first routing rule matching the ticket, and its 1-based position,
+// or nil if none does.
+func (c *configuration) matchRoutingRule(ticket *Ticket) (*routingRule, int) {
+	for i, rule := range c.routingRules {
+		if rule.matches(ticket) {
+			return rule, i + 1
+		}
+	}
+
+	return nil, 0
+}
+
+// ticketSLA returns the SLA of the ticket: the SLA of its routing rule, if any, or else the SLA of
+// its priority.
+func (c *configuration) ticketSLA(ticket *Ticket) (time.Duration, bool) {
+	if rule, _ := c.matchRoutingRule(ticket); rule != nil && rule.sla != 0 {
+		return rule.sla, true
+	}
+
+	sla, ok := c.slaDurations[ticket.Priority]
+	return sla, ok
+}
+
+// routingGroupNames returns the groups mentioned when the ticket is submitted or escalated,
+// according to its routing rule.
+func (c *configuration) routingGroupNames(ticket *Ticket) []string {
+	if rule, _ := c.matchRoutingRule(ticket); rule != nil {
+		return rule.groupNames
+	}
+
+	return nil
+}
+
+// createTicketRootPost creates the root post of a public ticket in the channel of its routing
+// rule, if any, or else in the SRE channel of its team. Tickets whose rule channel cannot be found
+// or posted in fall back to the SRE channel.
+func (p *Plugin) createTicketRootPost(ticket *Ticket, newPost func(channelID string) (*model.Post, error)) (*model.Post, error) {
+	rule, position := p.getConfiguration().matchRoutingRule(ticket)
+	if rule == nil || rule.channelName == "" {
+		return p.createRoutedPost(ticket.TeamID, newPost)
+	}
+
+	post, err := p.createRuleChannelPost(ticket, rule, newPost)
+	if err != nil {
+		p.API.LogWarn("Failed to post ticket in the channel of its routing rule, falling back to the SRE channel", "ticket_id", ticket.ID, "rule", position, "channel", rule.channelName, "err", err.Error())
+		return p.createRoutedPost(ticket.TeamID, newPost)
+	}
+
+	return post, nil
+}
+
+func (p *Plugin) createRuleChannelPost(ticket *Ticket, rule *routingRule, newPost func(channelID string) (*model.Post, error)) (*model.Post, error) {
+	team, appErr := p.getCachedTeam(ticket.TeamID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get team")
+	}
+
+	channel, appErr := p.API.GetChannelByNameForTeamName(team.Name, rule.channelName, false)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get channel")
+	}
+
+	post, err := newPost(channel.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	created, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to create post")
+	}
+
+	return created, nil
+}
+
+func (p *Plugin) executeCommandRulesList() *model.CommandResponse {
+	rules := p.getConfiguration().routingRules
+	if len(rules) == 0 {
+		return ephemeralResponse("No routing rules are configured. Tickets go to the SRE channel of their team.")
+	}
+
+	lines := []string{"Routing rules, evaluated in order until one matches:", ""}
+	for i, rule := range rules {
+		lines = append(lines, fmt.Sprintf("%d. `%s`: %s", i+1, rule.text, rule.describeActions()))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
+
+// executeCommandRulesTest shows the routing rule matching an existing ticket, or a ticket with the
+// given category, priority, summary and description, such as "category=pipeline priority=High".
+func (p *Plugin) executeCommandRulesTest(args *model.CommandArgs, params []string) *model.CommandResponse {
+	usage := commandUsage("rules", "test")
+	if len(params) == 0 {
+		return ephemeralResponse(usage)
+	}
+
+	ticket := &Ticket{Priority: ticketPriorityMedium}
+	if len(params) == 1 && !strings.Contains(params[0], "=") {
+		found, err := p.findTicket(params[0])
+		if err != nil {
+			p.API.LogError("Failed to get ticket", "err", err.Error())
+			return ephemeralResponse("Failed to get the ticket.")
+		}
+		if found == nil || !p.canViewTicket(args.UserId, found) {
+			return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+		}
+		ticket = found
+	} else {
+		for _, param := range params {
+			field, value, ok := strings.Cut(param, "=")
+			if !ok {
+				return ephemeralResponse(usage)
+			}
+
+			switch strings.ToLower(field) {
+			case routingFieldCategory:
+				ticket.Category = value
+			case routingFieldPriority:
+				priority, ok := matchPriority(value)
+				if !ok {
+					return ephemeralResponse(fmt.Sprintf("Unknown priority %q, expected high, medium or low.", value))
+				}
+				ticket.Priority = priority
+			case routingFieldSummary:
+				ticket.Summary = value
+			case routingFieldDescription:
+				ticket.Description = value
+			default:
+				return ephemeralResponse(fmt.Sprintf("Unknown field %q, expected category, priority, summary or description.", field))
+			}
+		}
+	}
+
+	configuration := p.getConfiguration()
+	rule, position := configuration.matchRoutingRule(ticket)
+	if rule == nil {
+		message := "No routing rule matches. The ticket goes to the SRE channel of its team"
+		if sla, ok := configuration.ticketSLA(ticket); ok {
+			message += fmt.Sprintf(", with the %s priority SLA of %s", ticket.Priority, sla)
+		}
+		return ephemeralResponse(message + ".")
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Rule %d matches: `%s`\nActions: %s.", position, rule.text, rule.describeActions()))
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	ticketActionRate = "rate"
+
+	dialogElementNameSatisfactionComment = "satisfaction_comment"
+
+	satisfactionGood    = "good"
+	satisfactionNeutral = "neutral"
+	satisfactionBad     = "bad"
+)
+
+// satisfactionRating is a rating offered by the satisfaction survey.
+type satisfactionRating struct {
+	Rating string
+	Emoji  string
+}
+
+// satisfactionRatings are the ratings offered by the satisfaction survey, from best to worst.
+var satisfactionRatings = []*satisfactionRating{
+	{Rating: satisfactionGood, Emoji: "😀"},
+	{Rating: satisfactionNeutral, Emoji: "😐"},
+	{Rating: satisfactionBad, Emoji: "😞"},
+}
+
+// findSatisfactionRating returns the rating with the given name, or nil if there is none.
+func findSatisfactionRating(rating string) *satisfactionRating {
+	for _, satisfactionRating := range satisfactionRatings {
+		if satisfactionRating.Rating == rating {
+			return satisfactionRating
+		}
+	}
+
+	return nil
+}
+
+// satisfactionCommentState is the state of the satisfaction comment dialog.
+type satisfactionCommentState struct {
+	TicketID string `json:"ticket_id"`
+}
+
+// sendSatisfactionSurvey asks the reporter of a resolved ticket, by direct message, to rate how it
+// was handled. Each ticket is surveyed once, even if it is reopened and resolved again, and tickets
+// reported by the bot on behalf of integrations are skipped.
+func (p *Plugin) sendSatisfactionSurvey(ticket *Ticket) {
+	if ticket.Status != ticketStatusResolved || ticket.SatisfactionSurveyPostID != "" || ticket.ReporterID == "" || ticket.ReporterID == p.botID {
+		return
+	}
+
+	channel, appErr := p.API.GetDirectChannel(ticket.ReporterID, p.botID)
+	if appErr != nil {
+		p.API.LogError("Failed to get direct channel for satisfaction survey", "ticket_id", ticket.ID, "err", appErr.Error())
+		return
+	}
+
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+	}
+	p.renderSatisfactionSurvey(ticket, post)
+
+	post, appErr = p.API.CreatePost(post)
+	if appErr != nil {
+		p.API.LogError("Failed to send satisfaction survey", "ticket_id", ticket.ID, "err", appErr.Error())
+		return
+	}
+
+	if _, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.SatisfactionSurveyPostID = post.Id
+		return nil
+	}); err != nil {
+		p.API.LogError("Failed to save satisfaction survey", "ticket_id", ticket.ID, "err", err.Error())
+	}
+}
+
+// renderSatisfactionSurvey renders the survey of the ticket into the post, with a button per
+// rating, showing the rating given so far, if any.
+func (p *Plugin) renderSatisfactionSurvey(ticket *Ticket, post *model.Post) {
+	localizer := p.userLocalizer(ticket.ReporterID)
+
+	actions := make([]*model.PostAction, 0, len(satisfactionRatings))
+	for _, rating := range satisfactionRatings {
+		actions = append(actions, &model.PostAction{
+			Id:   ticketActionRate + rating.Rating,
+			Type: model.PostActionTypeButton,
+			Name: rating.Emoji,
+			Integration: &model.PostActionIntegration{
+				URL: ticketActionURL(ticketActionRate),
+				Context: model.StringInterface{
+					"ticket_id": ticket.ID,
+					"rating":    rating.Rating,
+				},
+			},
+		})
+	}
+
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.satisfaction_survey",
+		Other: "Your SRE request **{{.Key}}** was resolved. How satisfied are you with how it was handled?",
+	}, map[string]interface{}{"Key": ticket.ticketName()})
+	if rating := findSatisfactionRating(ticket.SatisfactionRating); rating != nil {
+		message += "\n" + localize(localizer, &i18n.Message{
+			ID:    "ticket.satisfaction_survey.rated",
+			Other: "You rated it {{.Rating}}. Thanks for your feedback!",
+		}, map[string]interface{}{"Rating": rating.Emoji})
+	}
+
+	post.Message = p.ticketDirectMessage(ticket, message)
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{Actions: actions}})
+}
+
+// rateTicket records the reporter's satisfaction rating of the ticket and opens the dialog for an
+// optional comment. The reporter may change their rating with the other buttons of the survey.
+func (p *Plugin) rateTicket(ticket *Ticket, rating, userID, triggerID string) (string, error) {
+	if userID != ticket.ReporterID {
+		return "Only the reporter of this request can rate it.", nil
+	}
+	if findSatisfactionRating(rating) == nil {
+		return "No rating was selected.", nil
+	}
+
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.SatisfactionRating = rating
+		ticket.SatisfactionRatedAt = model.GetMillis()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if post, appErr := p.API.GetPost(ticket.SatisfactionSurveyPostID); appErr != nil {
+		p.API.LogWarn("Failed to get satisfaction survey", "ticket_id", ticket.ID, "err", appErr.Error())
+	} else {
+		p.renderSatisfactionSurvey(ticket, post)
+		if _, appErr := p.API.UpdatePost(post); appErr != nil {
+			p.API.LogWarn("Failed to update satisfaction survey", "ticket_id", ticket.ID, "err", appErr.Error())
+		}
+	}
+
+	if err := p.openSatisfactionCommentDialog(ticket, triggerID); err != nil {
+		p.API.LogWarn("Failed to open satisfaction comment dialog", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return "", nil
+}
+
+// openSatisfactionCommentDialog asks the reporter for an optional comment on their rating.
+func (p *Plugin) openSatisfactionCommentDialog(ticket *Ticket, triggerID string) error {
+	state, err := json.Marshal(&satisfactionCommentState{TicketID: ticket.ID})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal satisfaction comment state")
+	}
+
+	localizer := p.userLocalizer(ticket.ReporterID)
+	if appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       pluginCallbackURL("/dialog/sre/satisfaction"),
+		Dialog: model.Dialog{
+			Title: localize(localizer, &i18n.Message{ID: "satisfaction_dialog.title", Other: "Thanks for your feedback"}, nil),
+			IntroductionText: localize(localizer, &i18n.Message{
+				ID:    "satisfaction_dialog.introduction",
+				Other: "Anything else you would like to tell us about how {{.Key}} was handled? This is optional.",
+			}, map[string]interface{}{"Key": ticket.ticketName()}),
+			Elements: []model.DialogElement{{
+				DisplayName: localize(localizer, &i18n.Message{ID: "satisfaction_dialog.comment", Other: "Comment"}, nil),
+				Name:        dialogElementNameSatisfactionComment,
+				Type:        "textarea",
+				Default:     ticket.SatisfactionComment,
+				Optional:    true,
+				MaxLength:   1000,
+			}},
+			SubmitLabel: localize(localizer, &i18n.Message{ID: "satisfaction_dialog.submit", Other: "Send"}, nil),
+			State:       string(state),
+		},
+	}); appErr != nil {
+		return errors.Wrap(appErr, "failed to open satisfaction comment dialog")
+	}
+
+	return nil
+}
+
+// handleSatisfactionDialog records the comment the reporter added to their rating.
+func (p *Plugin) handleSatisfactionDialog(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		p.API.LogError("Failed to decode SubmitDialogRequest", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	comment, _ := request.Submission[dialogElementNameSatisfactionComment].(string)
+	comment = strings.TrimSpace(comment)
+	if request.Cancelled || comment == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var state satisfactionCommentState
+	if err := json.Unmarshal([]byte(request.State), &state); err != nil {
+		p.API.LogError("Failed to decode satisfaction comment state", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ticket, err := p.getTicket(state.TicketID)
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "ticket_id", state.TicketID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if ticket == nil || ticket.ReporterID != userID {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "This request no longer exists.",
+		})
+		return
+	}
+	if !p.teamEnabled(ticket.TeamID) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "The plugin is disabled in this team.",
+		})
+		return
+	}
+
+	if _, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.SatisfactionComment = comment
+		return nil
+	}); err != nil {
+		p.API.LogError("Failed to save satisfaction comment", "ticket_id", ticket.ID, "err", err.Error())
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "Failed to save your comment. Please try again later.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// satisfactionScore counts the satisfaction ratings of a period.
+type satisfactionScore struct {
+	Good    int `json:"good"`
+	Neutral int `json:"neutral"`
+	Bad     int `json:"bad"`
+
+	// Score is the percentage of good ratings, omitted when there are none.
+	Score *int `json:"score,omitempty"`
+}
+
+// add counts the given number of ratings by rating.
+func (s *satisfactionScore) add(ratings map[string]int) {
+	s.Good += ratings[satisfactionGood]
+	s.Neutral += ratings[satisfactionNeutral]
+	s.Bad += ratings[satisfactionBad]
+
+	s.Score = nil
+	if total := s.Good + s.Neutral + s.Bad; total > 0 {
+		score := s.Good * 100 / total
+		s.Score = &score
+	}
+}
+
+// weeklySatisfaction are the satisfaction ratings of a week, starting on Monday in UTC.
+type weeklySatisfaction struct {
+	Week string `json:"week"`
+	satisfactionScore
+}
+
+// statsWeek returns the Monday starting the week of the day.
+func statsWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// satisfactionTableHeader returns the header of a table of satisfaction scores grouped by the
+// given column.
+func satisfactionTableHeader(column string) string {
+	header := "| " + column
+	for _, rating := range satisfactionRatings {
+		header += " | " + rating.Emoji
+	}
+
+	return header + " | Score |\n|---|---|---|---|---|\n"
+}
+
+// satisfactionTableRow returns the row of a table of satisfaction scores.
+func satisfactionTableRow(name string, score *satisfactionScore) string {
+	value := "n/a"
+	if score.Score != nil {
+		value = fmt.Sprintf("%d%%", *score.Score)
+	}
+
+	return fmt.Sprintf("| %s | %d | %d | %d | %s |\n", name, score.Good, score.Neutral, score.Bad, value)
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// searchTermKeyPrefix prefixes the KV key of the inverted index from a term to the ids of the
+	// tickets of a team containing it. The index is namespaced by team, so that searches only read
+	// the index of the teams they are scoped to.
+	searchTermKeyPrefix = "searchterm_"
+
+	// searchDocKeyPrefix prefixes the KV key of the terms a ticket was last indexed with, so that
+	// terms removed from the ticket can be removed from the index.
+	searchDocKeyPrefix = "searchdoc_"
+
+	// maxSearchTermLength bounds the length of indexed terms, keeping their keys within the KV key
+	// length limit.
+	maxSearchTermLength = 64
+
+	// maxSearchCommandResults bounds how many results the search command lists.
+	maxSearchCommandResults = 20
+
+	searchDateLayout = "2006-01-02"
+)
+
+func searchTermKey(teamID, term string) string {
+	return searchTermKeyPrefix + teamID + "_" + term
+}
+
+func searchDocKey(ticketID string) string {
+	return searchDocKeyPrefix + ticketID
+}
+
+// searchTerms splits the text into its distinct lowercase words, dropping single characters.
+func searchTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 2 || len(word) > maxSearchTermLength || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	sort.Strings(terms)
+
+	return terms
+}
+
+// ticketSearchTerms returns the terms a ticket is indexed with: the words of its summary and
+// description, and its labels.
+func ticketSearchTerms(ticket *Ticket) []string {
+	return append(searchTerms(ticket.Summary+" "+ticket.Description), labelSearchTerms(ticket.Labels)...)
+}
+
+// updateSearchTerm adds the ticket to, or removes it from, the tickets of the team containing the
+// term.
+func (p *Plugin) updateSearchTerm(teamID, term, ticketID string, add bool) error {
+	return p.client.KV.SetAtomicWithRetries(searchTermKey(teamID, term), func(oldValue []byte) (interface{}, error) {
+		var ticketIDs []string
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &ticketIDs); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal search term")
+			}
+		}
+
+		updated := make([]string, 0, len(ticketIDs)+1)
+		for _, id := range ticketIDs {
+			if id != ticketID {
+				updated = append(updated, id)
+			}
+		}
+		if add {
+			updated = append(updated, ticketID)
+		}
+		if len(updated) == 0 {
+			return nil, nil
+		}
+
+		return updated, nil
+	})
+}
+
+// indexTicket updates the inverted index with the ticket's current summary, description and
+// labels.
+func (p *Plugin) indexTicket(ticket *Ticket) error {
+	var oldTerms []string
+	if err := p.client.KV.Get(searchDocKey(ticket.ID), &oldTerms); err != nil {
+		return errors.Wrap(err, "failed to get indexed search terms")
+	}
+
+	return p.reindexTicket(ticket.TeamID, ticket.ID, oldTerms, ticketSearchTerms(ticket))
+}
+
+// unindexTicket removes the ticket from the inverted index.
+func (p *Plugin) unindexTicket(ticket *Ticket) error {
+	var oldTerms []string
+	if err := p.client.KV.Get(searchDocKey(ticket.ID), &oldTerms); err != nil {
+		return errors.Wrap(err, "failed to get indexed search terms")
+	}
+
+	return p.reindexTicket(ticket.TeamID, ticket.ID, oldTerms, nil)
+}
+
+func (p *Plugin) reindexTicket(teamID, ticketID string, oldTerms, newTerms []string) error {
+	indexed := make(map[string]bool, len(oldTerms))
+	for _, term := range oldTerms {
+		indexed[term] = true
+	}
+
+	changed := false
+	for _, term := range newTerms {
+		if indexed[term] {
+			delete(indexed, term)
+			continue
+		}
+		if err := p.updateSearchTerm(teamID, term, ticketID, true); err != nil {
+			return errors.Wrapf(err, "failed to index search term %s", term)
+		}
+		changed = true
+	}
+	for term := range indexed {
+		if err := p.updateSearchTerm(teamID, term, ticketID, false); err != nil {
+			return errors.Wrapf(err, "failed to unindex search term %s", term)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if len(newTerms) == 0 {
+		if err := p.client.KV.Delete(searchDocKey(ticketID)); err != nil {
+			return errors.Wrap(err, "failed to delete indexed search terms")
+		}
+		return nil
+	}
+	if _, err := p.client.KV.Set(searchDocKey(ticketID), newTerms); err != nil {
+		return errors.Wrap(err, "failed to save indexed search terms")
+	}
+
+	return nil
+}
+
+// ticketSearch is a full-text search over the tickets' summaries and descriptions. Tickets must
+// contain every term, have every label and match every set filter.
+type ticketSearch struct {
+	Terms      []string
+	Labels     []string
+	TeamID     string
+	Status     string
+	Priority   string
+	AssigneeID string
+
+	// From and To bound the creation time of the tickets, in milliseconds. Zero means unbounded.
+	From int64
+	To   int64
+}
+
+// parseSearchDate parses a date filter, returning the start of the day or, for the upper bound of
+// a date range, its end.
+func parseSearchDate(value string, endOfDay bool) (int64, error) {
+	date, err := time.Parse(searchDateLayout, value)
+	if err != nil {
+		return 0, errors.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		date = date.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+
+	return date.UnixMilli(), nil
+}
+
+// matchStatus returns the status named by value, accepting closed as an alias of Resolved.
+func matchStatus(value string) (string, bool) {
+	if strings.EqualFold(value, "closed") {
+		return ticketStatusResolved, true
+	}
+	for _, status := range []string{ticketStatusOpen, ticketStatusAcknowledged, ticketStatusWaitingOnReporter, ticketStatusResolved} {
+		if strings.EqualFold(value, status) {
+			return status, true
+		}
+	}
+
+	return "", false
+}
+
+// matchPriority returns the priority named by value.
+func matchPriority(value string) (string, bool) {
+	for _, priority := range []string{ticketPriorityHigh, ticketPriorityMedium, ticketPriorityLow} {
+		if strings.EqualFold(value, priority) {
+			return priority, true
+		}
+	}
+
+	return "", false
+}
+
+// setFilter sets the named filter of the search. Assignees are user ids.
+func (s *ticketSearch) setFilter(name, value string) error {
+	var ok bool
+	var err error
+	switch name {
+	case "status":
+		if s.Status, ok = matchStatus(value); !ok {
+			return errors.Errorf("invalid status %q", value)
+		}
+	case "priority":
+		if s.Priority, ok = matchPriority(value); !ok {
+			return errors.Errorf("invalid priority %q", value)
+		}
+	case "assignee":
+		s.AssigneeID = value
+	case "team_id":
+		s.TeamID = value
+	case "label":
+		label, labelErr := normalizeLabel(value)
+		if labelErr != nil {
+			return labelErr
+		}
+		if !contains(s.Labels, label) {
+			s.Labels = append(s.Labels, label)
+		}
+	case "from":
+		s.From, err = parseSearchDate(value, false)
+	case "to":
+		s.To, err = parseSearchDate(value, true)
+	default:
+		return errors.Errorf("unknown filter %q", name)
+	}
+
+	return err
+}
+
+func (s *ticketSearch) matches(ticket *Ticket) bool {
+	return (s.Status == "" || ticket.Status == s.Status) &&
+		(s.Priority == "" || ticket.Priority == s.Priority) &&
+		(s.AssigneeID == "" || ticket.AssigneeID == s.AssigneeID) &&
+		ticket.hasLabels(s.Labels) &&
+		(s.From == 0 || ticket.CreateAt >= s.From) &&
+		(s.To == 0 || ticket.CreateAt <= s.To)
+}
+
+// searchTickets returns the tickets matching the search that the user may view, newest first.
+func (p *Plugin) searchTickets(userID string, search *ticketSearch) ([]*Ticket, error) {
+	tickets, err := p.listVisibleTickets(userID, search.TeamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var teamIDs []string
+	for _, ticket := range tickets {
+		if !contains(teamIDs, ticket.TeamID) {
+			teamIDs = append(teamIDs, ticket.TeamID)
+		}
+	}
+
+	// Intersect the tickets containing each term and label, starting from every ticket. Only the
+	// index of the teams of the visible tickets is read.
+	var candidates map[string]bool
+	for _, term := range append(append([]string(nil), search.Terms...), labelSearchTerms(search.Labels)...) {
+		matching := make(map[string]bool)
+		for _, teamID := range teamIDs {
+			var ticketIDs []string
+			if err := p.client.KV.Get(searchTermKey(teamID, term), &ticketIDs); err != nil {
+				return nil, errors.Wrapf(err, "failed to get search term %s", term)
+			}
+
+			for _, ticketID := range ticketIDs {
+				if candidates == nil || candidates[ticketID] {
+					matching[ticketID] = true
+				}
+			}
+		}
+		candidates = matching
+	}
+
+	results := []*Ticket{}
+	for _, ticket := range tickets {
+		if candidates != nil && !candidates[ticket.ID] {
+			continue
+		}
+		if !search.matches(ticket) {
+			continue
+		}
+
+		results = append(results, ticket)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreateAt > results[j].CreateAt
+	})
+
+	return results, nil
+}
+
+// handleSearchTickets serves GET /api/v1/tickets/search?q=, accepting the status, priority,
+// assignee, label, from and to filters as query parameters. The label filter may be repeated.
+func (p *Plugin) handleSearchTickets(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	query := r.URL.Query()
+
+	search := &ticketSearch{Terms: searchTerms(query.Get("q"))}
+	for _, name := range []string{"team_id", "status", "priority", "assignee", "label", "from", "to"} {
+		for _, value := range query[name] {
+			if value == "" {
+				continue
+			}
+			if err := search.setFilter(name, value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	tickets, err := p.searchTickets(userID, search)
+	if err != nil {
+		p.API.LogError("Failed to search tickets", "err", err.Error())
+		http.Error(w, "Failed to search tickets", http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, tickets)
+}
+
+// parseSearchCommand parses the words of the search command, where words such as status:open,
+// assignee:@user or label:database are filters and the other words are searched for.
+func (p *Plugin) parseSearchCommand(params []string) (*ticketSearch, error) {
+	search := &ticketSearch{}
+	var text []string
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, ":")
+		if !ok || value == "" {
+			text = append(text, param)
+			continue
+		}
+
+		name = strings.ToLower(name)
+		if name == "assignee" {
+			user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(value, "@"))
+			if appErr != nil {
+				return nil, errors.Errorf("unknown user %q", value)
+			}
+			value = user.Id
+		}
+		if err := search.setFilter(name, value); err != nil {
+			return nil, err
+		}
+	}
+	search.Terms = searchTerms(strings.Join(text, " "))
+
+	return search, nil
+}
+
+func (p *Plugin) executeCommandSearch(args *model.CommandArgs, params []string) *model.CommandResponse {
+	usage := commandUsage("search")
+	if len(params) == 0 {
+		return ephemeralResponse(usage)
+	}
+
+	search, err := p.parseSearchCommand(params)
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("%s.\n%s", err.Error(), usage))
+	}
+
+	tickets, err := p.searchTickets(args.UserId, search)
+	if err != nil {
+		p.API.LogError("Failed to search tickets", "err", err.Error())
+		return ephemeralResponse("Failed to search the tickets.")
+	}
+	if len(tickets) == 0 {
+		return ephemeralResponse("No ticket matches your search.")
+	}
+
+	lines := []string{fmt.Sprintf("Found %d tickets.", len(tickets)), "", "| Ticket | Summary | Priority | Status | Created |", "| --- | --- | --- | --- | --- |"}
+	for i, ticket := range tickets {
+		if i == maxSearchCommandResults {
+			lines = append(lines, "", fmt.Sprintf("Showing the %d most recent tickets, refine your search to see the others.", maxSearchCommandResults))
+			break
+		}
+
+		summary := ticket.Summary
+		if permalink, err := p.ticketPermalink(ticket); err == nil {
+			summary = fmt.Sprintf("[%s](%s)", ticket.Summary, permalink)
+		}
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s | %s | %s |", ticket.ID, summary, ticket.Priority, ticket.Status, time.UnixMilli(ticket.CreateAt).UTC().Format(searchDateLayout)))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// sentryKeyPrefix prefixes the KV index from a Sentry issue id to the ticket tracking it.
+	sentryKeyPrefix = "sentry_"
+
+	// sentrySignatureHeader carries the hex-encoded HMAC-SHA256 of the body, computed by Sentry
+	// with the client secret of the integration. sentryResourceHeader names the kind of webhook.
+	sentrySignatureHeader = "Sentry-Hook-Signature"
+	sentryResourceHeader  = "Sentry-Hook-Resource"
+
+	// sentryResourceEventAlert is the resource of issue alert webhooks.
+	sentryResourceEventAlert = "event_alert"
+
+	// sentryMaxFrames is the number of innermost stack frames shown for each exception.
+	sentryMaxFrames = 10
+)
+
+// sentryID is an id sent by Sentry, which may be encoded as a JSON string or number.
+type sentryID string
+
+func (id *sentryID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = ""
+		return nil
+	}
+
+	*id = sentryID(strings.Trim(string(data), `"`))
+	return nil
+}
+
+// sentryWebhook is the body of a Sentry issue alert webhook.
+type sentryWebhook struct {
+	Action string `json:"action"`
+	Data   struct {
+		Event         sentryEvent `json:"event"`
+		TriggeredRule string      `json:"triggered_rule"`
+	} `json:"data"`
+}
+
+type sentryEvent struct {
+	EventID  string     `json:"event_id"`
+	IssueID  sentryID   `json:"issue_id"`
+	Title    string     `json:"title"`
+	Culprit  string     `json:"culprit"`
+	Level    string     `json:"level"`
+	Platform string     `json:"platform"`
+	Datetime string     `json:"datetime"`
+	WebURL   string     `json:"web_url"`
+	Tags     [][]string `json:"tags"`
+
+	Exception *struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace *struct {
+		Frames []sentryFrame `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+type sentryFrame struct {
+	Filename string `json:"filename"`
+	Function string `json:"function"`
+	LineNo   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+func sentryKey(issueID string) string {
+	return sentryKeyPrefix + issueID
+}
+
+// sentryPriority maps the level of a Sentry event to a ticket priority.
+func sentryPriority(event *sentryEvent) string {
+	switch strings.ToLower(event.Level) {
+	case "fatal":
+		return ticketPriorityHigh
+	case "error":
+		return ticketPriorityMedium
+	default:
+		return ticketPriorityLow
+	}
+}
+
+// tag returns the value of the event's tag with the given key, or an empty string.
+func (e *sentryEvent) tag(key string) string {
+	for _, tag := range e.Tags {
+		if len(tag) == 2 && tag[0] == key {
+			return tag[1]
+		}
+	}
+
+	return ""
+}
+
+// verifySentrySignature rejects the Sentry webhooks not signed with the SentryClientSecret. It runs
+// before deliveries are deduplicated, so that unauthenticated requests cannot claim delivery ids.
+func (p *Plugin) verifySentrySignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := p.getConfiguration().SentryClientSecret
+		if secret == "" {
+			http.Error(w, "The Sentry webhook is not enabled", http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !validWebhookSignature(secret, body, r.Header.Get(sentrySignatureHeader)) {
+			p.API.LogWarn("Rejected Sentry webhook with an invalid signature", "ip", p.clientIP(r).String())
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleSentry receives Sentry issue alert webhooks. The first alert of a Sentry issue opens a
+// ticket, while the next ones are threaded under it as occurrences until the ticket is resolved.
+// Tickets are filed in the team named by the team query parameter.
+func (p *Plugin) handleSentry(w http.ResponseWriter, r *http.Request) {
+	// Sentry also sends installation and issue webhooks, which are acknowledged and ignored.
+	if r.Header.Get(sentryResourceHeader) != sentryResourceEventAlert {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	team, appErr := p.API.GetTeamByName(r.URL.Query().Get("team"))
+	if appErr != nil {
+		http.Error(w, "Unknown team", http.StatusBadRequest)
+		return
+	}
+	if _, ok := p.getConfiguration().demoChannelIDs[team.Id]; !ok || !p.teamEnabled(team.Id) {
+		http.Error(w, "SRE requests are not enabled in this team", http.StatusBadRequest)
+		return
+	}
+
+	var payload sentryWebhook
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		p.API.LogError("Failed to decode Sentry payload", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if payload.Data.Event.IssueID == "" {
+		http.Error(w, "Missing Sentry issue id", http.StatusBadRequest)
+		return
+	}
+
+	// Let Sentry retry the delivery, which is safe since occurrences are tracked by issue.
+	if err := p.processSentryEvent(team.Id, &payload.Data.Event); err != nil {
+		p.API.LogError("Failed to process Sentry event", "issue_id", string(payload.Data.Event.IssueID), "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getSentryTicket returns the ticket tracking the Sentry issue with the given id, or nil if there
+// is none.
+func (p *Plugin) getSentryTicket(issueID string) (*Ticket, error) {
+	var ticketID string
+	if err := p.client.KV.Get(sentryKey(issueID), &ticketID); err != nil {
+		return nil, errors.Wrap(err, "failed to get Sentry index")
+	}
+	if ticketID == "" {
+		return nil, nil
+	}
+
+	return p.getTicket(ticketID)
+}
+
+// processSentryEvent opens a ticket for the event's issue, or records the event as an occurrence
+// of the unresolved ticket tracking the issue. Events of an issue are processed one at a time
+// across the cluster, so that bursts of events open a single ticket.
+func (p *Plugin) processSentryEvent(teamID string, event *sentryEvent) error {
+	issueID := string(event.IssueID)
+
+	mutex, err := cluster.NewMutex(p.API, sentryKey(issueID))
+	if err != nil {
+		return errors.Wrap(err, "failed to create Sentry issue mutex")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	existing, err := p.getSentryTicket(issueID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Status != ticketStatusResolved {
+		return p.recordSentryOccurrence(existing, event)
+	}
+
+	return p.openSentryTicket(teamID, event)
+}
+
+func (p *Plugin) openSentryTicket(teamID string, event *sentryEvent) error {
+	summary := event.Title
+	if summary == "" {
+		summary = "Sentry issue " + string(event.IssueID)
+	}
+	description := event.Culprit
+	if event.WebURL != "" && p.validateExternalURL(event.WebURL, "Sentry event") == nil {
+		description = strings.TrimSpace(fmt.Sprintf("%s\n\n[View in Sentry](%s)", description, event.WebURL))
+	}
+
+	ticket := &Ticket{
+		TeamID:            teamID,
+		ReporterID:        p.botID,
+		Summary:           fmt.Sprintf("[Sentry] %s", summary),
+		Description:       description,
+		Priority:          sentryPriority(event),
+		SentryIssueID:     string(event.IssueID),
+		SentryOccurrences: 1,
+	}
+	if err := p.createTicket(ticket); err != nil {
+		return err
+	}
+
+	if _, err := p.client.KV.Set(sentryKey(ticket.SentryIssueID), ticket.ID); err != nil {
+		return errors.Wrap(err, "failed to save Sentry index")
+	}
+
+	if err := p.createTicketReply(ticket, &model.Post{
+		Props: model.StringInterface{
+			"attachments": []*model.SlackAttachment{sentryAttachment(event)},
+		},
+	}, false); err != nil {
+		p.API.LogWarn("Failed to post Sentry event details", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		p.API.LogError("Failed to notify responders", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	return nil
+}
+
+// recordSentryOccurrence counts another event of the ticket's Sentry issue, and notes it in the
+// ticket's thread rather than in the channel.
+func (p *Plugin) recordSentryOccurrence(ticket *Ticket, event *sentryEvent) error {
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.SentryOccurrences++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	message := fmt.Sprintf(":repeat: The Sentry issue occurred again, %d occurrences so far.", ticket.SentryOccurrences)
+	if event.WebURL != "" && p.validateExternalURL(event.WebURL, "Sentry event") == nil {
+		message += fmt.Sprintf(" [View the event](%s)", event.WebURL)
+	}
+
+	return p.postTicketReply(ticket, message)
+}
+
+// sentryAttachment renders the event and the innermost frames of its stack traces. The stack
+// traces are in the attachment text, which clients collapse when it is long.
+func sentryAttachment(event *sentryEvent) *model.SlackAttachment {
+	fields := []*model.SlackAttachmentField{{
+		Title: "Level",
+		Value: event.Level,
+		Short: true,
+	}, {
+		Title: "Issue",
+		Value: string(event.IssueID),
+		Short: true,
+	}}
+	if environment := event.tag("environment"); environment != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Environment",
+			Value: environment,
+			Short: true,
+		})
+	}
+	if release := event.tag("release"); release != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Release",
+			Value: release,
+			Short: true,
+		})
+	}
+
+	return &model.SlackAttachment{
+		Color:  "#362d59",
+		Title:  "Stack trace",
+		Text:   formatSentryStacktrace(event),
+		Fields: fields,
+		Footer: event.Culprit,
+	}
+}
+
+// formatSentryStacktrace renders the exceptions of the event, innermost frame first, in a code
+// block.
+func formatSentryStacktrace(event *sentryEvent) string {
+	if event.Exception == nil || len(event.Exception.Values) == 0 {
+		return "_No stack trace_"
+	}
+
+	var lines []string
+	for _, exception := range event.Exception.Values {
+		lines = append(lines, fmt.Sprintf("%s: %s", exception.Type, exception.Value))
+		if exception.Stacktrace == nil {
+			continue
+		}
+
+		frames := exception.Stacktrace.Frames
+		for i := len(frames) - 1; i >= 0 && i >= len(frames)-sentryMaxFrames; i-- {
+			frame := frames[i]
+			marker := " "
+			if frame.InApp {
+				marker = "*"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s in %s:%d", marker, frame.Function, frame.Filename, frame.LineNo))
+		}
+		if len(frames) > sentryMaxFrames {
+			lines = append(lines, fmt.Sprintf("  ... %d more frames", len(frames)-sentryMaxFrames))
+		}
+	}
+
+	return "```\n" + strings.Join(lines, "\n") + "\n```"
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// serviceCatalogKey is the KV key of the service catalog offered by the intake dialog.
+	serviceCatalogKey = "service_catalog"
+
+	// maxAssigneeOptions is the number of SRE channel members above which the assignee element
+	// searches all users instead of listing the members.
+	maxAssigneeOptions = 100
+)
+
+func (p *Plugin) getServiceCatalog() ([]string, error) {
+	var services []string
+	if err := p.client.KV.Get(serviceCatalogKey, &services); err != nil {
+		return nil, errors.Wrap(err, "failed to get service catalog")
+	}
+
+	return services, nil
+}
+
+func (p *Plugin) saveServiceCatalog(services []string) error {
+	sort.Strings(services)
+	if _, err := p.client.KV.Set(serviceCatalogKey, services); err != nil {
+		return errors.Wrap(err, "failed to save service catalog")
+	}
+
+	return nil
+}
+
+// populateDialog returns a copy of the dialog whose dynamic elements list their options as of
+// now: the affected service element lists the service catalog, and the assignee element lists the
+// members of the team's SRE channel. Elements are left as is when their options are unavailable.
+func (p *Plugin) populateDialog(dialog model.Dialog, teamID string) model.Dialog {
+	dialog.Elements = append([]model.DialogElement(nil), dialog.Elements...)
+	for i, element := range dialog.Elements {
+		switch element.Name {
+		case dialogElementNameService:
+			if options := p.serviceOptions(); len(options) > 0 {
+				dialog.Elements[i].Type = "select"
+				dialog.Elements[i].SubType = ""
+				dialog.Elements[i].Options = options
+			}
+		case dialogElementNameAssignee:
+			if options := p.assigneeOptions(teamID); len(options) > 0 {
+				dialog.Elements[i].DataSource = ""
+				dialog.Elements[i].Options = options
+			}
+		}
+	}
+
+	return dialog
+}
+
+func (p *Plugin) serviceOptions() []*model.PostActionOptions {
+	services, err := p.getServiceCatalog()
+	if err != nil {
+		p.API.LogWarn("Failed to get service catalog for the intake dialog", "err", err.Error())
+		return nil
+	}
+
+	var options []*model.PostActionOptions
+	for _, service := range services {
+		options = append(options, &model.PostActionOptions{Text: service, Value: service})
+	}
+
+	return options
+}
+
+func (p *Plugin) assigneeOptions(teamID string) []*model.PostActionOptions {
+	channelID, ok := p.getConfiguration().demoChannelIDs[teamID]
+	if !ok {
+		return nil
+	}
+
+	members, err := p.client.User.ListInChannel(channelID, model.ChannelSortByUsername, 0, maxAssigneeOptions+1)
+	if err != nil {
+		p.API.LogWarn("Failed to list SRE channel members for the intake dialog", "channel_id", channelID, "err", err.Error())
+		return nil
+	}
+	if len(members) > maxAssigneeOptions {
+		return nil
+	}
+
+	var options []*model.PostActionOptions
+	for _, member := range members {
+		if member.IsBot || member.DeleteAt != 0 {
+			continue
+		}
+		options = append(options, &model.PostActionOptions{Text: "@" + member.Username, Value: member.Id})
+	}
+
+	return options
+}
+
+func (p *Plugin) executeCommandServicesList() *model.CommandResponse {
+	services, err := p.getServiceCatalog()
+	if err != nil {
+		p.API.LogError("Failed to get service catalog", "err", err.Error())
+		return ephemeralResponse("Failed to get the service catalog.")
+	}
+	if len(services) == 0 {
+		return ephemeralResponse("The service catalog is empty.")
+	}
+
+	return ephemeralResponse("Service catalog:\n- " + strings.Join(services, "\n- "))
+}
+
+// executeCommandServicesEdit adds the named service to the catalog, or removes it.
+func (p *Plugin) executeCommandServicesEdit(params []string, add bool) *model.CommandResponse {
+	name := strings.TrimSpace(strings.Join(params, " "))
+	if name == "" {
+		if add {
+			return ephemeralResponse(commandUsage("services", "add"))
+		}
+		return ephemeralResponse(commandUsage("services", "remove"))
+	}
+
+	services, err := p.getServiceCatalog()
+	if err != nil {
+		p.API.LogError("Failed to get service catalog", "err", err.Error())
+		return ephemeralResponse("Failed to get the service catalog.")
+	}
+
+	if add {
+		if contains(services, name) {
+			return ephemeralResponse(fmt.Sprintf("%s is already in the service catalog.", name))
+		}
+		services = append(services, name)
+	} else {
+		if !contains(services, name) {
+			return ephemeralResponse(fmt.Sprintf("%s is not in the service catalog.", name))
+		}
+		remaining := services[:0]
+		for _, service := range services {
+			if service != name {
+				remaining = append(remaining, service)
+			}
+		}
+		services = remaining
+	}
+
+	if err := p.saveServiceCatalog(services); err != nil {
+		p.API.LogError("Failed to save service catalog", "err", err.Error())
+		return ephemeralResponse("Failed to save the service catalog.")
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Updated the service catalog, which now has %d services.", len(services)))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// maxSettingsLookupResults bounds the options returned by the settings lookup endpoints.
+const maxSettingsLookupResults = 50
+
+// settingsOption is an option of a dynamic select of the plugin settings page. Value is written to
+// the setting as is, in the format the setting expects.
+type settingsOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// handleSettingsChannels lists the channels matching the term query parameter, in the scoped teams
+// or in the team of the team_id query parameter, for the channel settings given as "team/channel",
+// such as AdminChannel and StatusPageChannel. Only system admins may look up channels.
+func (p *Plugin) handleSettingsChannels(w http.ResponseWriter, r *http.Request) {
+	if !p.isSystemAdmin(r.Header.Get("Mattermost-User-ID")) {
+		http.Error(w, "Not authorized to look up settings values", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	term := strings.TrimSpace(query.Get("term"))
+
+	teams, appErr := p.listScopedTeams(p.getConfiguration())
+	if appErr != nil {
+		p.requestLogger(r).Error("Failed to list teams for settings lookup", "err", appErr.Error())
+		http.Error(w, "Failed to list teams", http.StatusInternalServerError)
+		return
+	}
+
+	options := []*settingsOption{}
+	for _, team := range teams {
+		if teamID := query.Get("team_id"); teamID != "" && team.Id != teamID {
+			continue
+		}
+
+		channels, appErr := p.API.SearchChannels(team.Id, term)
+		if appErr != nil {
+			p.requestLogger(r).Warn("Failed to search channels for settings lookup", "team_id", team.Id, "err", appErr.Error())
+			continue
+		}
+		for _, channel := range channels {
+			if channel.Type != model.ChannelTypeOpen && channel.Type != model.ChannelTypePrivate {
+				continue
+			}
+			options = append(options, &settingsOption{
+				Value: team.Name + "/" + channel.Name,
+				Label: team.DisplayName + " / " + channel.DisplayName,
+			})
+		}
+	}
+
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Label < options[j].Label
+	})
+	if len(options) > maxSettingsLookupResults {
+		options = options[:maxSettingsLookupResults]
+	}
+
+	p.writeJSON(w, options)
+}
+
+// handleSettingsUsers lists the active users matching the term query parameter by username, for
+// the user settings given as usernames, such as the responders, EscalationUsers and
+// IncidentCommander. Only system admins may look up users.
+func (p *Plugin) handleSettingsUsers(w http.ResponseWriter, r *http.Request) {
+	if !p.isSystemAdmin(r.Header.Get("Mattermost-User-ID")) {
+		http.Error(w, "Not authorized to look up settings values", http.StatusForbidden)
+		return
+	}
+
+	users, appErr := p.API.SearchUsers(&model.UserSearch{
+		Term:  strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("term")), "@"),
+		Limit: maxSettingsLookupResults,
+	})
+	if appErr != nil {
+		p.requestLogger(r).Error("Failed to search users for settings lookup", "err", appErr.Error())
+		http.Error(w, "Failed to search users", http.StatusInternalServerError)
+		return
+	}
+
+	options := []*settingsOption{}
+	for _, user := range users {
+		if user.IsBot {
+			continue
+		}
+
+		label := "@" + user.Username
+		if fullName := user.GetFullName(); fullName != "" {
+			label += " - " + fullName
+		}
+		options = append(options, &settingsOption{
+			Value: user.Username,
+			Label: label,
+		})
+	}
+
+	p.writeJSON(w, options)
+}
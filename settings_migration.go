@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// settingsVersionKey is the plugin setting recording the version of the settings schema the
+	// stored settings were migrated to.
+	settingsVersionKey = "settingsversion"
+
+	// settingsMigrationMutexKey serializes the settings migration across the cluster, since every
+	// node migrates the settings when the plugin is activated.
+	settingsMigrationMutexKey = "settings_migration"
+)
+
+// settingsMigration converts the stored plugin settings from one version of the settings schema
+// to the next. It modifies settings in place, returning false if there was nothing to convert.
+type settingsMigration func(settings map[string]interface{}) bool
+
+// settingsMigrations are the migrations of the settings schema, in order. Settings at version n
+// have had the first n migrations applied.
+var settingsMigrations = []settingsMigration{
+	migrateTagUsers,
+}
+
+// lookupSetting returns the key and value of the named setting, whose key may have been stored in
+// any case.
+func lookupSetting(settings map[string]interface{}, name string) (string, interface{}, bool) {
+	for key, value := range settings {
+		if strings.EqualFold(key, name) {
+			return key, value, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// settingString returns the named setting as a string, or an empty string if it is not set.
+func settingString(settings map[string]interface{}, name string) string {
+	_, value, _ := lookupSetting(settings, name)
+	s, _ := value.(string)
+
+	return strings.TrimSpace(s)
+}
+
+// migrateTagUsers converts the legacy TagUsers setting, a single list of users tagged on every
+// ticket, into the responders of each priority, keeping responders already configured.
+func migrateTagUsers(settings map[string]interface{}) bool {
+	key, _, ok := lookupSetting(settings, "TagUsers")
+	if !ok {
+		return false
+	}
+
+	if tagUsers := settingString(settings, "TagUsers"); tagUsers != "" {
+		for _, name := range []string{"HighPriorityResponders", "MediumPriorityResponders", "LowPriorityResponders"} {
+			if settingString(settings, name) == "" {
+				respondersKey, _, found := lookupSetting(settings, name)
+				if !found {
+					respondersKey = strings.ToLower(name)
+				}
+				settings[respondersKey] = tagUsers
+			}
+		}
+	}
+	delete(settings, key)
+
+	return true
+}
+
+// migrateSettings brings the stored plugin settings to the current settings schema, converting
+// legacy settings so that the admin configuration is preserved across upgrades. It runs before
+// the configuration is loaded, and saves the settings only if they are behind the current schema.
+func (p *Plugin) migrateSettings() error {
+	mutex, err := cluster.NewMutex(p.API, settingsMigrationMutexKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to create settings migration mutex")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	settings := p.API.GetPluginConfig()
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+
+	version := 0
+	if _, value, ok := lookupSetting(settings, settingsVersionKey); ok {
+		// Numbers are decoded from the server configuration as float64.
+		switch number := value.(type) {
+		case float64:
+			version = int(number)
+		case int:
+			version = number
+		}
+	}
+	if version >= len(settingsMigrations) {
+		return nil
+	}
+
+	for i := version; i < len(settingsMigrations); i++ {
+		if settingsMigrations[i](settings) {
+			p.API.LogInfo("Migrated plugin settings", "version", i+1)
+		}
+	}
+
+	// Fresh installs have nothing to convert, but their version is recorded all the same.
+	settings[settingsVersionKey] = len(settingsMigrations)
+	if appErr := p.API.SavePluginConfig(settings); appErr != nil {
+		return errors.Wrap(appErr, "failed to save migrated plugin settings")
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	ticketActionPriority = "priority"
+
+	// minSeverityScore is the minimum score of a priority for it to be suggested, so that a single
+	// weak signal doesn't contradict the reporter.
+	minSeverityScore = 3
+)
+
+// defaultSeverityRules are the severity rules used when none are configured.
+const defaultSeverityRules = "High 3 outage; High 3 down; High 3 /data (loss|corruption)/; High 2 security; High 2 production; " +
+	"High 2 /all (users|customers)/; Medium 2 degraded; Medium 2 slow; Medium 1 error; Medium 1 failing; " +
+	"Low 2 question; Low 2 /request(ing)? access/; Low 2 /when you (have|get) (a chance|time)/; Low 1 typo"
+
+// severityClassifier suggests the priority of a ticket from its text.
+type severityClassifier interface {
+	// classify returns the suggested priority and the signals it is based on, or an empty
+	// priority if the text gives no clear signal.
+	classify(text string) (string, []string)
+}
+
+// severityRule adds its weight to the score of its priority when its pattern matches.
+type severityRule struct {
+	priority string
+	weight   int
+	name     string
+	pattern  *regexp.Regexp
+}
+
+// ruleSeverityClassifier scores each priority by the weights of its matching rules and suggests
+// the priority with the highest score.
+type ruleSeverityClassifier struct {
+	rules []severityRule
+}
+
+// parseSeverityRules parses the semicolon-separated severity rules, each as
+// "priority weight pattern". Patterns between slashes are case-insensitive regular expressions,
+// while other patterns match case-insensitive whole words.
+func parseSeverityRules(configuration *configuration) (severityClassifier, error) {
+	setting := configuration.SeverityRules
+	if strings.TrimSpace(setting) == "" {
+		setting = defaultSeverityRules
+	}
+
+	classifier := &ruleSeverityClassifier{}
+	for _, entry := range strings.Split(setting, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.SplitN(entry, " ", 3)
+		if len(fields) != 3 || strings.TrimSpace(fields[2]) == "" {
+			return nil, errors.Errorf("invalid severity rule %q, expected a priority, a weight and a pattern", entry)
+		}
+
+		priority, ok := matchPriority(fields[0])
+		if !ok {
+			return nil, errors.Errorf("invalid priority %q in severity rule %q", fields[0], entry)
+		}
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil || weight <= 0 {
+			return nil, errors.Errorf("invalid weight %q in severity rule %q", fields[1], entry)
+		}
+
+		name := strings.TrimSpace(fields[2])
+		expression := `(?i)\b` + regexp.QuoteMeta(name) + `\b`
+		if len(name) > 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/") {
+			name = name[1 : len(name)-1]
+			expression = "(?i)" + name
+		}
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern in severity rule %q", entry)
+		}
+
+		classifier.rules = append(classifier.rules, severityRule{
+			priority: priority,
+			weight:   weight,
+			name:     name,
+			pattern:  pattern,
+		})
+	}
+
+	return classifier, nil
+}
+
+func (c *ruleSeverityClassifier) classify(text string) (string, []string) {
+	scores := make(map[string]int)
+	signals := make(map[string][]string)
+	for _, rule := range c.rules {
+		if !rule.pattern.MatchString(text) {
+			continue
+		}
+
+		scores[rule.priority] += rule.weight
+		signals[rule.priority] = append(signals[rule.priority], rule.name)
+	}
+
+	// Ties go to the most severe priority.
+	suggested := ""
+	for _, priority := range []string{ticketPriorityHigh, ticketPriorityMedium, ticketPriorityLow} {
+		if scores[priority] >= minSeverityScore && scores[priority] > scores[suggested] {
+			suggested = priority
+		}
+	}
+
+	return suggested, signals[suggested]
+}
+
+// suggestTicketPriority records the priority suggested by the ticket's text when the reporter
+// didn't pick one, or picked one inconsistent with the text, for triagers to review.
+func (c *configuration) suggestTicketPriority(ticket *Ticket, picked bool) {
+	if c.severityClassifier == nil {
+		return
+	}
+
+	suggested, signals := c.severityClassifier.classify(ticket.Summary + "\n" + ticket.Description)
+	if suggested == "" || (picked && suggested == ticket.Priority) {
+		return
+	}
+
+	ticket.SuggestedPriority = suggested
+	ticket.SuggestedPrioritySignals = signals
+}
+
+// hasPrioritySuggestion reports whether the ticket has a suggested priority differing from its
+// current one.
+func (t *Ticket) hasPrioritySuggestion() bool {
+	return t.SuggestedPriority != "" && t.SuggestedPriority != t.Priority
+}
+
+// prioritySuggestion describes the ticket's suggested priority and what it is based on.
+func (t *Ticket) prioritySuggestion() string {
+	if len(t.SuggestedPrioritySignals) == 0 {
+		return t.SuggestedPriority
+	}
+
+	return fmt.Sprintf("%s (mentions %s)", t.SuggestedPriority, strings.Join(t.SuggestedPrioritySignals, ", "))
+}
+
+// prioritySuggestionAttachment renders the ticket's suggested priority with a button applying it.
+func prioritySuggestionAttachment(ticket *Ticket) *model.SlackAttachment {
+	return &model.SlackAttachment{
+		Text: fmt.Sprintf("The description of this request suggests a priority of %s instead of %s.", ticket.prioritySuggestion(), ticket.Priority),
+		Actions: []*model.PostAction{{
+			Type: model.PostActionTypeButton,
+			Name: fmt.Sprintf("Set priority to %s", ticket.SuggestedPriority),
+			Integration: &model.PostActionIntegration{
+				URL: ticketActionURL(ticketActionPriority),
+				Context: model.StringInterface{
+					"ticket_id": ticket.ID,
+					"priority":  ticket.SuggestedPriority,
+				},
+			},
+		}},
+	}
+}
+
+// changeTicketPriority changes the ticket's priority on behalf of userID, recording the required
+// justification with the change. The SLA and escalations start over for the new priority, and its
+// responders are notified. Callers check that the user may edit the ticket, since API tokens edit
+// tickets on behalf of the bot. It returns the saved ticket, or nil with the message to show the user
+// if the priority cannot be changed.
+func (p *Plugin) changeTicketPriority(ticket *Ticket, priority, userID, justification string) (*Ticket, string, error) {
+	if !isValidPriority(priority) {
+		return nil, "No priority was selected.", nil
+	}
+	if priority == ticket.Priority {
+		return nil, fmt.Sprintf("This request is already %s priority.", priority), nil
+	}
+	justification = strings.TrimSpace(justification)
+	if justification == "" {
+		return nil, "A justification is required to change the priority.", nil
+	}
+
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if priority == ticket.Priority {
+			return errTicketUnchanged
+		}
+		setTicketPriority(ticket, priority, userID, model.GetMillis())
+		ticket.History[len(ticket.History)-1].Reason = justification
+		restartTicketEscalations(ticket)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return nil, fmt.Sprintf("This request is already %s priority.", priority), nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return nil, "", err
+	}
+
+	message := fmt.Sprintf("%s changed the priority of this request to %s.", p.mentionUser(userID), priority)
+	message += "\n> " + strings.ReplaceAll(justification, "\n", "\n> ")
+	if err := p.postTicketTransition(ticket, message); err != nil {
+		return nil, "", err
+	}
+
+	if err := p.notifyPriorityResponders(ticket); err != nil {
+		p.API.LogWarn("Failed to notify responders of new priority", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return ticket, "", nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// errSiteURLUnset is returned when the server has no site URL configured.
+var errSiteURLUnset = errors.New("ServiceSettings.SiteURL is not set")
+
+// pluginCallbackURL returns the URL of the plugin endpoint at the given path, such as
+// "/dialog/sre", for dialog and post action callbacks. The server resolves relative plugin URLs
+// itself, so callbacks keep working whatever the site URL, or when it isn't set.
+func pluginCallbackURL(path string) string {
+	return "/plugins/" + manifest.Id + path
+}
+
+// siteURL returns the configured site URL of the server without a trailing slash, or an empty
+// string if none is set. Links leaving the plugin's callbacks, such as permalinks and file
+// downloads, are built from it.
+func (p *Plugin) siteURL() string {
+	if config := p.API.GetConfig(); config != nil && config.ServiceSettings.SiteURL != nil {
+		return strings.TrimRight(*config.ServiceSettings.SiteURL, "/")
+	}
+
+	return ""
+}
+
+// validateSiteURL checks that the site URL is an absolute http or https URL.
+func validateSiteURL(siteURL string) error {
+	if siteURL == "" {
+		return errSiteURLUnset
+	}
+
+	parsed, err := url.Parse(siteURL)
+	if err != nil {
+		return errors.Wrap(err, "ServiceSettings.SiteURL is invalid")
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.Errorf("ServiceSettings.SiteURL %q must be an absolute http or https URL", siteURL)
+	}
+
+	return nil
+}
+
+// checkSiteURL warns the admins when the site URL is unset or invalid, since ticket permalinks
+// and file links are then relative, and won't open from direct message notifications, emails,
+// exports or integrations.
+func (p *Plugin) checkSiteURL() {
+	err := validateSiteURL(p.siteURL())
+	if err == nil {
+		return
+	}
+
+	p.API.LogWarn("Ticket links will not open outside of Mattermost", "err", err.Error())
+	p.postAdminNotice(":warning: " + err.Error() + ". Ticket permalinks and file links are relative until it is set, so they won't open from emails, exports or integrations. Set the Site URL in the System Console, under Environment > Web Server.")
+}
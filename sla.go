@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// parseSLADurations parses the per-priority SLA settings. Priorities without an SLA are omitted.
+func parseSLADurations(configuration *configuration) (map[string]time.Duration, error) {
+	settings := map[string]string{
+		ticketPriorityHigh:   configuration.HighPrioritySLA,
+		ticketPriorityMedium: configuration.MediumPrioritySLA,
+		ticketPriorityLow:    configuration.LowPrioritySLA,
+	}
+
+	slaDurations := make(map[string]time.Duration)
+	for priority, setting := range settings {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(setting)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s priority SLA %q", priority, setting)
+		}
+		if duration <= 0 {
+			return nil, errors.Errorf("%s priority SLA must be positive", priority)
+		}
+
+		slaDurations[priority] = duration
+	}
+
+	return slaDurations, nil
+}
+
+// splitUsernames splits a comma-separated list of usernames, dropping any leading @.
+func splitUsernames(list string) []string {
+	var usernames []string
+	for _, username := range strings.Split(list, ",") {
+		username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+		if username != "" {
+			usernames = append(usernames, username)
+		}
+	}
+
+	return usernames
+}
+
+// checkSLAs escalates every unacknowledged ticket that has been open for longer than the SLA of
+// its priority.
+func (p *Plugin) checkSLAs() {
+	configuration := p.getConfiguration()
+	if len(configuration.slaDurations) == 0 {
+		return
+	}
+
+	tickets, err := p.listTickets()
+	if err != nil {
+		p.reportJobFailure("BackgroundJob", "Failed to list tickets for SLA check", err)
+		return
+	}
+
+	now := model.GetMillis()
+	for _, ticket := range tickets {
+		if ticket.Status != ticketStatusOpen || ticket.SLABreachedAt != 0 {
+			continue
+		}
+
+		// Time spent waiting on the reporter or for business hours doesn't count towards the SLA.
+		sla, ok := configuration.ticketSLA(ticket)
+		if !ok || now < ticket.slaStartAt()+ticket.PausedDuration+sla.Milliseconds() {
+			continue
+		}
+
+		if err := p.escalateSLABreach(ticket, sla); err != nil {
+			p.API.LogError("Failed to escalate SLA breach", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+}
+
+// escalateSLABreach records the SLA breach, then announces it. The breach is saved first so that
+// it is announced once, even when several nodes check the SLAs or the ticket changes meanwhile.
+func (p *Plugin) escalateSLABreach(ticket *Ticket, sla time.Duration) error {
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.Status != ticketStatusOpen || ticket.SLABreachedAt != 0 {
+			return errTicketUnchanged
+		}
+		ticket.SLABreachedAt = model.GetMillis()
+		recordTicketActivity(ticket, timelineEventEscalation, "", fmt.Sprintf("Escalated for breaching the SLA of %s", sla))
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	localizer := p.serverLocalizer()
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.sla_breached",
+		Other: ":rotating_light: This {{.Priority}} priority request has not been acknowledged within its SLA of {{.SLA}}.",
+	}, map[string]interface{}{"Priority": localizeLabel(localizer, priorityLabels, ticket.Priority), "SLA": sla.String()})
+	if mentions := p.ticketEscalationMentions(ticket, p.ticketDirectMessage(ticket, message)); mentions != "" {
+		message += "\n" + localize(localizer, takeALookMessage, map[string]interface{}{"Mentions": mentions})
+	}
+
+	if err := p.postTicketTransition(ticket, message); err != nil {
+		return err
+	}
+	p.sendTicketEvent(ticketEventEscalated, ticket, "")
+
+	return nil
+}
+
+// takeALookMessage asks the mentioned escalation users to look at an escalated ticket.
+var takeALookMessage = &i18n.Message{ID: "ticket.take_a_look", Other: "{{.Mentions}} please take a look."}
+
+// escalationUserIDs returns the id of the user on call, falling back to the configured escalation
+// users when no on-call rotation is set up.
+func (p *Plugin) escalationUserIDs() []string {
+	if userID := p.onCallUserID(); userID != "" {
+		return []string{userID}
+	}
+
+	return p.configuredEscalationUserIDs()
+}
+
+// configuredEscalationUserIDs returns the ids of the existing configured escalation users. Users
+// that don't exist are logged and skipped.
+func (p *Plugin) configuredEscalationUserIDs() []string {
+	var userIDs []string
+	for _, username := range splitUsernames(p.getConfiguration().EscalationUsers) {
+		user, appErr := p.API.GetUserByUsername(username)
+		if appErr != nil {
+			p.API.LogWarn("Ignoring unknown escalation user", "username", username)
+			continue
+		}
+		userIDs = append(userIDs, user.Id)
+	}
+
+	return userIDs
+}
+
+// escalationMentions notifies the escalation users according to their notification preferences,
+// sending dmMessage to those preferring direct messages, and returns the mentions of the others.
+func (p *Plugin) escalationMentions(dmMessage string) string {
+	return p.notifyRecipients(p.escalationUserIDs(), nil, dmMessage)
+}
+
+// ticketEscalationMentions notifies the escalation users of an escalated ticket like
+// escalationMentions, also mentioning the groups of the ticket's routing rule.
+func (p *Plugin) ticketEscalationMentions(ticket *Ticket, dmMessage string) string {
+	return p.notifyRecipients(p.escalationUserIDs(), p.getConfiguration().routingGroupNames(ticket), dmMessage)
+}
+
+// ticketRecipients notifies the assignee of the ticket, or the escalation users if it is
+// unassigned, and returns how to refer to them in the ticket's thread.
+func (p *Plugin) ticketRecipients(ticket *Ticket, message string) string {
+	dmMessage := p.ticketDirectMessage(ticket, message)
+	if ticket.AssigneeID != "" {
+		if reference := p.notifyUser(ticket.AssigneeID, dmMessage); strings.HasPrefix(reference, "@") {
+			return reference
+		}
+		return ""
+	}
+
+	return p.escalationMentions(dmMessage)
+}
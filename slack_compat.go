@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// slackSignatureHeader carries "v0=" followed by the hex-encoded HMAC-SHA256 of
+	// "v0:{timestamp}:{body}", computed by Slack with the signing secret of the app and the
+	// timestamp sent in slackTimestampHeader.
+	slackSignatureHeader = "X-Slack-Signature"
+	slackTimestampHeader = "X-Slack-Request-Timestamp"
+
+	// slackSignatureMaxAge bounds the age of signed requests, so that captured requests cannot be
+	// replayed later.
+	slackSignatureMaxAge = 5 * time.Minute
+)
+
+// slackCommandResponse is the body of a response to a Slack slash command.
+type slackCommandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// validSlackSignature reports whether the request was signed by Slack with the secret, recently.
+func validSlackSignature(secret string, body []byte, timestamp, signature string, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > slackSignatureMaxAge || age < -slackSignatureMaxAge {
+		return false
+	}
+
+	version, hash, ok := strings.Cut(signature, "=")
+	if !ok || version != "v0" {
+		return false
+	}
+
+	return validWebhookSignature(secret, []byte("v0:"+timestamp+":"+string(body)), hash)
+}
+
+// parseSlackUserLinks parses the SlackUserLinks setting into a map of Slack user ids to Mattermost
+// usernames.
+func parseSlackUserLinks(configuration *configuration) (map[string]string, error) {
+	links := make(map[string]string)
+	for _, entry := range strings.Split(configuration.SlackUserLinks, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		slackUserID, username, ok := strings.Cut(entry, "=")
+		slackUserID = strings.TrimSpace(slackUserID)
+		username = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+		if !ok || slackUserID == "" || username == "" {
+			return nil, errors.Errorf("invalid Slack user link %q", entry)
+		}
+		links[slackUserID] = username
+	}
+
+	return links, nil
+}
+
+// parseSlackCommandText parses the text of the slash command into a ticket: an optional priority,
+// followed by the summary on the first line and the description on the next ones, such as
+// "high Checkout is down".
+func parseSlackCommandText(text string) (summary, description, priority string) {
+	summary, description, _ = strings.Cut(strings.TrimSpace(text), "\n")
+
+	if first, rest, ok := strings.Cut(summary, " "); ok {
+		if matched, isPriority := matchPriority(first); isPriority {
+			priority = matched
+			summary = rest
+		}
+	}
+
+	return strings.TrimSpace(summary), strings.TrimSpace(description), priority
+}
+
+// handleSlackCommand accepts the slash command payloads of Slack, so that the tooling of teams
+// moving from Slack can keep opening SRE requests. The command text opens a ticket in the team
+// named by the team query parameter, or else in the team named like the Slack workspace domain.
+// Requests must be signed with SlackSigningSecret, as Slack signs them.
+func (p *Plugin) handleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	secret := p.getConfiguration().SlackSigningSecret
+	if secret == "" {
+		http.Error(w, "The Slack command endpoint is not enabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validSlackSignature(secret, body, r.Header.Get(slackTimestampHeader), r.Header.Get(slackSignatureHeader), time.Now()) {
+		p.API.LogWarn("Rejected Slack command with an invalid signature", "ip", p.clientIP(r).String())
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	p.countUsage("compat: slack command")
+
+	teamName := r.URL.Query().Get("team")
+	if teamName == "" {
+		teamName = form.Get("team_domain")
+	}
+	team, appErr := p.API.GetTeamByName(strings.ToLower(teamName))
+	if appErr != nil {
+		p.writeSlackCommandResponse(w, fmt.Sprintf("No Mattermost team is named %q.", teamName))
+		return
+	}
+	if _, ok := p.getConfiguration().demoChannelIDs[team.Id]; !ok || !p.teamEnabled(team.Id) {
+		p.writeSlackCommandResponse(w, "SRE requests are not enabled in this team.")
+		return
+	}
+
+	summary, description, priority := parseSlackCommandText(form.Get("text"))
+	if summary == "" {
+		p.writeSlackCommandResponse(w, fmt.Sprintf("Usage: %s [high|medium|low] summary, with the description on the next lines.", form.Get("command")))
+		return
+	}
+
+	ticket := &Ticket{
+		TeamID:      team.Id,
+		ReporterID:  p.botID,
+		Summary:     summary,
+		Description: description,
+		Priority:    priority,
+	}
+	if ticket.Priority == "" {
+		ticket.Priority = ticketPriorityMedium
+	}
+	p.getConfiguration().suggestTicketPriority(ticket, priority != "")
+
+	if reporterID := p.slackReporterID(team.Id, form.Get("user_id")); reporterID != "" {
+		ticket.ReporterID = reporterID
+	} else if userName := form.Get("user_name"); userName != "" {
+		ticket.Description = strings.TrimSpace(fmt.Sprintf("Reported from Slack by %s (%s)\n\n%s", userName, form.Get("user_id"), ticket.Description))
+	}
+
+	if err := p.createTicket(ticket); err != nil {
+		p.API.LogError("Failed to create ticket from Slack command", "err", err.Error())
+		http.Error(w, "Failed to create ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		p.API.LogError("Failed to notify responders", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	text := fmt.Sprintf("Opened %s: %s", ticket.ticketName(), ticket.Summary)
+	if permalink, err := p.ticketPermalink(ticket); err == nil {
+		// Slack links are written as <url|text>.
+		text = fmt.Sprintf("Opened <%s|%s>: %s", permalink, ticket.ticketName(), ticket.Summary)
+	}
+	p.writeSlackCommandResponse(w, text)
+}
+
+// slackReporterID returns the id of the user the Slack command is filed on behalf of, or an empty
+// string to file it on behalf of the bot. Slack usernames are chosen by their users and may match
+// anyone's Mattermost username, so only the Slack user ids explicitly linked by SlackUserLinks are
+// trusted, and only mapped to members of the team.
+func (p *Plugin) slackReporterID(teamID, slackUserID string) string {
+	username, ok := p.getConfiguration().slackUserLinks[slackUserID]
+	if !ok {
+		return ""
+	}
+
+	user, appErr := p.API.GetUserByUsername(username)
+	if appErr != nil || user.DeleteAt != 0 || user.IsBot || !p.isTeamMember(teamID, user.Id) {
+		return ""
+	}
+
+	return user.Id
+}
+
+// writeSlackCommandResponse responds to the Slack command with an ephemeral message, which Slack
+// expects even for errors of the command.
+func (p *Plugin) writeSlackCommandResponse(w http.ResponseWriter, text string) {
+	p.writeJSON(w, &slackCommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         text,
+	})
+}
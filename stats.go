@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// statsKeyPrefix prefixes the KV key of the aggregated statistics of a day, named by its date.
+	statsKeyPrefix = "stats_"
+
+	// statsCursorKey records the last day whose statistics were aggregated.
+	statsCursorKey = "statscursor"
+
+	// statsDateFormat names the days of the aggregated statistics.
+	statsDateFormat = "2006-01-02"
+
+	// statsRetentionDays is how many days of statistics are kept, which bounds the longest period
+	// the statistics cover.
+	statsRetentionDays = 90
+
+	// defaultStatsPeriod is the period the statistics cover by default, in days.
+	defaultStatsPeriod = 30
+
+	// statsUncategorized counts the tickets submitted without a category.
+	statsUncategorized = "uncategorized"
+
+	// statsUnassigned counts the satisfaction ratings of tickets resolved without an assignee.
+	statsUnassigned = "unassigned"
+)
+
+// statsPeriods are the periods, in days, the statistics can cover.
+var statsPeriods = []int{7, 30, 90}
+
+// dailyStats are the ticket statistics of a day, in UTC, aggregated by the nightly statistics job.
+// Mean times are computed from the totals, so that days sum up into any period.
+type dailyStats struct {
+	Date             string         `json:"date"`
+	Opened           int            `json:"opened"`
+	OpenedByPriority map[string]int `json:"opened_by_priority"`
+	OpenedByCategory map[string]int `json:"opened_by_category"`
+
+	// Acknowledged and Resolved count the tickets acknowledged and resolved during the day, and
+	// AcknowledgeTime and ResolveTime their total time since creation, in milliseconds.
+	Acknowledged    int   `json:"acknowledged"`
+	AcknowledgeTime int64 `json:"acknowledge_time"`
+	Resolved        int   `json:"resolved"`
+	ResolveTime     int64 `json:"resolve_time"`
+
+	// Satisfaction counts the satisfaction ratings given by reporters during the day by rating, and
+	// SatisfactionByAssignee counts them by the assignee of the rated tickets.
+	Satisfaction           map[string]int            `json:"satisfaction,omitempty"`
+	SatisfactionByAssignee map[string]map[string]int `json:"satisfaction_by_assignee,omitempty"`
+}
+
+// ticketStats are the statistics of a period, as returned by GET /api/v1/stats. Mean times are in
+// seconds, and omitted when no ticket was acknowledged or resolved.
+type ticketStats struct {
+	Period           string         `json:"period"`
+	From             string         `json:"from"`
+	To               string         `json:"to"`
+	Opened           int            `json:"opened"`
+	Acknowledged     int            `json:"acknowledged"`
+	Resolved         int            `json:"resolved"`
+	MTTA             *int64         `json:"mtta_seconds,omitempty"`
+	MTTR             *int64         `json:"mttr_seconds,omitempty"`
+	OpenedByPriority map[string]int `json:"opened_by_priority"`
+	OpenedByCategory map[string]int `json:"opened_by_category"`
+	Days             []*statsDay    `json:"days"`
+
+	// Satisfaction sums the satisfaction ratings of the period, which are also broken down by week
+	// and by assignee id.
+	Satisfaction           *satisfactionScore            `json:"satisfaction"`
+	SatisfactionByWeek     []*weeklySatisfaction         `json:"satisfaction_by_week"`
+	SatisfactionByAssignee map[string]*satisfactionScore `json:"satisfaction_by_assignee"`
+}
+
+// statsDay is the series point of a day of a period, for plotting.
+type statsDay struct {
+	Date         string `json:"date"`
+	Opened       int    `json:"opened"`
+	Acknowledged int    `json:"acknowledged"`
+	Resolved     int    `json:"resolved"`
+	MTTA         *int64 `json:"mtta_seconds,omitempty"`
+	MTTR         *int64 `json:"mttr_seconds,omitempty"`
+}
+
+func statsKey(day time.Time) string {
+	return statsKeyPrefix + day.Format(statsDateFormat)
+}
+
+// meanSeconds returns the mean of a total time in milliseconds over count, in seconds, or nil if
+// count is zero.
+func meanSeconds(total int64, count int) *int64 {
+	if count == 0 {
+		return nil
+	}
+
+	mean := total / int64(count) / 1000
+	return &mean
+}
+
+// aggregateDailyStats computes the statistics of the given days, which must be consecutive, from
+// a single pass over the tickets.
+func aggregateDailyStats(tickets []*Ticket, days []time.Time) []*dailyStats {
+	if len(days) == 0 {
+		return nil
+	}
+
+	stats := make(map[string]*dailyStats, len(days))
+	for _, day := range days {
+		date := day.Format(statsDateFormat)
+		stats[date] = &dailyStats{
+			Date:                   date,
+			OpenedByPriority:       make(map[string]int),
+			OpenedByCategory:       make(map[string]int),
+			Satisfaction:           make(map[string]int),
+			SatisfactionByAssignee: make(map[string]map[string]int),
+		}
+	}
+	dayOf := func(millis int64) *dailyStats {
+		if millis == 0 {
+			return nil
+		}
+		return stats[time.UnixMilli(millis).UTC().Format(statsDateFormat)]
+	}
+
+	for _, ticket := range tickets {
+		if day := dayOf(ticket.CreateAt); day != nil {
+			category := ticket.Category
+			if category == "" {
+				category = statsUncategorized
+			}
+			day.Opened++
+			day.OpenedByPriority[ticket.Priority]++
+			day.OpenedByCategory[category]++
+		}
+		if day := dayOf(ticket.AcknowledgedAt); day != nil {
+			day.Acknowledged++
+			day.AcknowledgeTime += ticket.AcknowledgedAt - ticket.CreateAt
+		}
+		if day := dayOf(ticket.ResolvedAt); day != nil && ticket.Status == ticketStatusResolved {
+			day.Resolved++
+			day.ResolveTime += ticket.ResolvedAt - ticket.CreateAt
+		}
+		if day := dayOf(ticket.SatisfactionRatedAt); day != nil && ticket.SatisfactionRating != "" {
+			assignee := ticket.AssigneeID
+			if assignee == "" {
+				assignee = statsUnassigned
+			}
+			if day.SatisfactionByAssignee[assignee] == nil {
+				day.SatisfactionByAssignee[assignee] = make(map[string]int)
+			}
+			day.Satisfaction[ticket.SatisfactionRating]++
+			day.SatisfactionByAssignee[assignee][ticket.SatisfactionRating]++
+		}
+	}
+
+	result := make([]*dailyStats, 0, len(days))
+	for _, day := range days {
+		result = append(result, stats[day.Format(statsDateFormat)])
+	}
+
+	return result
+}
+
+// StatsAggregationJob runs nightly on only one plugin instance at a time. It aggregates the
+// statistics of the days completed since it last ran, up to statsRetentionDays back, so that the
+// statistics are served without scanning the tickets, and deletes the expired statistics.
+func (p *Plugin) StatsAggregationJob() {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled {
+		return
+	}
+
+	if err := p.aggregateStats(time.Now()); err != nil {
+		p.reportJobFailure("StatsAggregationJob", "Failed to aggregate ticket statistics", err)
+	}
+	p.cleanUpStats(time.Now())
+}
+
+// aggregateStats aggregates the statistics of the days completed before now that were not
+// aggregated yet.
+func (p *Plugin) aggregateStats(now time.Time) error {
+	today := now.UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -statsRetentionDays)
+
+	var cursor string
+	if err := p.client.KV.Get(statsCursorKey, &cursor); err != nil {
+		return errors.Wrap(err, "failed to get statistics cursor")
+	}
+	if cursor != "" {
+		last, err := time.Parse(statsDateFormat, cursor)
+		if err != nil {
+			return errors.Wrapf(err, "invalid statistics cursor %q", cursor)
+		}
+		if next := last.AddDate(0, 0, 1); next.After(first) {
+			first = next
+		}
+	}
+
+	var days []time.Time
+	for day := first; day.Before(today); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	if len(days) == 0 {
+		return nil
+	}
+
+	tickets, err := p.listTickets()
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range aggregateDailyStats(tickets, days) {
+		if _, err := p.client.KV.Set(statsKeyPrefix+stats.Date, stats); err != nil {
+			return errors.Wrap(err, "failed to save daily statistics")
+		}
+		if _, err := p.client.KV.Set(statsCursorKey, stats.Date); err != nil {
+			return errors.Wrap(err, "failed to save statistics cursor")
+		}
+	}
+
+	return nil
+}
+
+// cleanUpStats deletes the statistics older than the retention period.
+func (p *Plugin) cleanUpStats(now time.Time) {
+	cutoff := statsKey(now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -statsRetentionDays))
+
+	keys, err := p.client.KV.ListKeys(0, 1000, pluginapi.WithPrefix(statsKeyPrefix))
+	if err != nil {
+		p.API.LogError("Failed to list daily statistics", "err", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		if key >= cutoff {
+			continue
+		}
+		if err := p.client.KV.Delete(key); err != nil {
+			p.API.LogError("Failed to delete daily statistics", "key", key, "err", err.Error())
+		}
+	}
+}
+
+// getTicketStats sums the aggregated statistics of the given number of days completed before now.
+// Days not aggregated yet count as empty.
+func (p *Plugin) getTicketStats(periodDays int, now time.Time) (*ticketStats, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -periodDays)
+
+	stats := &ticketStats{
+		Period:           fmt.Sprintf("%dd", periodDays),
+		From:             from.Format(statsDateFormat),
+		To:               today.AddDate(0, 0, -1).Format(statsDateFormat),
+		OpenedByPriority: make(map[string]int),
+		OpenedByCategory: make(map[string]int),
+		Days:             make([]*statsDay, 0, periodDays),
+
+		Satisfaction:           &satisfactionScore{},
+		SatisfactionByWeek:     []*weeklySatisfaction{},
+		SatisfactionByAssignee: make(map[string]*satisfactionScore),
+	}
+
+	var acknowledgeTime, resolveTime int64
+	for day := from; day.Before(today); day = day.AddDate(0, 0, 1) {
+		var daily dailyStats
+		if err := p.client.KV.Get(statsKey(day), &daily); err != nil {
+			return nil, errors.Wrap(err, "failed to get daily statistics")
+		}
+
+		stats.Opened += daily.Opened
+		stats.Acknowledged += daily.Acknowledged
+		stats.Resolved += daily.Resolved
+		acknowledgeTime += daily.AcknowledgeTime
+		resolveTime += daily.ResolveTime
+		for priority, count := range daily.OpenedByPriority {
+			stats.OpenedByPriority[priority] += count
+		}
+		for category, count := range daily.OpenedByCategory {
+			stats.OpenedByCategory[category] += count
+		}
+
+		stats.Satisfaction.add(daily.Satisfaction)
+		week := statsWeek(day).Format(statsDateFormat)
+		if len(stats.SatisfactionByWeek) == 0 || stats.SatisfactionByWeek[len(stats.SatisfactionByWeek)-1].Week != week {
+			stats.SatisfactionByWeek = append(stats.SatisfactionByWeek, &weeklySatisfaction{Week: week})
+		}
+		stats.SatisfactionByWeek[len(stats.SatisfactionByWeek)-1].add(daily.Satisfaction)
+		for assignee, ratings := range daily.SatisfactionByAssignee {
+			if stats.SatisfactionByAssignee[assignee] == nil {
+				stats.SatisfactionByAssignee[assignee] = &satisfactionScore{}
+			}
+			stats.SatisfactionByAssignee[assignee].add(ratings)
+		}
+
+		stats.Days = append(stats.Days, &statsDay{
+			Date:         day.Format(statsDateFormat),
+			Opened:       daily.Opened,
+			Acknowledged: daily.Acknowledged,
+			Resolved:     daily.Resolved,
+			MTTA:         meanSeconds(daily.AcknowledgeTime, daily.Acknowledged),
+			MTTR:         meanSeconds(daily.ResolveTime, daily.Resolved),
+		})
+	}
+	stats.MTTA = meanSeconds(acknowledgeTime, stats.Acknowledged)
+	stats.MTTR = meanSeconds(resolveTime, stats.Resolved)
+
+	return stats, nil
+}
+
+// parseStatsPeriod parses a statistics period such as "30d" into a number of days.
+func parseStatsPeriod(value string) (int, error) {
+	if value == "" {
+		return defaultStatsPeriod, nil
+	}
+
+	for _, days := range statsPeriods {
+		if value == fmt.Sprintf("%dd", days) {
+			return days, nil
+		}
+	}
+
+	return 0, errors.Errorf("invalid period %q, use one of 7d, 30d or 90d", value)
+}
+
+// formatMeanSeconds renders a mean time for the statistics report.
+func formatMeanSeconds(seconds *int64) string {
+	if seconds == nil {
+		return "n/a"
+	}
+
+	return (time.Duration(*seconds) * time.Second).Round(time.Minute).String()
+}
+
+// statsMessage renders the statistics as markdown tables, with labels in the server's locale.
+func (p *Plugin) statsMessage(stats *ticketStats) string {
+	localizer := p.serverLocalizer()
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "#### SRE request statistics: %s to %s\n\n", stats.From, stats.To)
+	message.WriteString("| Opened | Acknowledged | Resolved | MTTA | MTTR |\n|---|---|---|---|---|\n")
+	fmt.Fprintf(&message, "| %d | %d | %d | %s | %s |\n\n", stats.Opened, stats.Acknowledged, stats.Resolved, formatMeanSeconds(stats.MTTA), formatMeanSeconds(stats.MTTR))
+
+	message.WriteString("| Priority | Opened |\n|---|---|\n")
+	for _, priority := range []string{ticketPriorityHigh, ticketPriorityMedium, ticketPriorityLow} {
+		fmt.Fprintf(&message, "| %s | %d |\n", localizeLabel(localizer, priorityLabels, priority), stats.OpenedByPriority[priority])
+	}
+
+	if len(stats.OpenedByCategory) > 0 {
+		categories := make([]string, 0, len(stats.OpenedByCategory))
+		for category := range stats.OpenedByCategory {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+
+		message.WriteString("\n| Category | Opened |\n|---|---|\n")
+		for _, category := range categories {
+			name := "Uncategorized"
+			if intakeCategory, ok := getIntakeCategory(category); ok {
+				name = intakeCategory.localizedName(localizer)
+			} else if category != statsUncategorized {
+				name = category
+			}
+			fmt.Fprintf(&message, "| %s | %d |\n", name, stats.OpenedByCategory[category])
+		}
+	}
+
+	if stats.Satisfaction.Score != nil {
+		message.WriteString("\n" + satisfactionTableHeader("Week"))
+		for _, week := range stats.SatisfactionByWeek {
+			if week.Score != nil {
+				message.WriteString(satisfactionTableRow("Week of "+week.Week, &week.satisfactionScore))
+			}
+		}
+		message.WriteString(satisfactionTableRow("**Total**", stats.Satisfaction))
+
+		assignees := make([]string, 0, len(stats.SatisfactionByAssignee))
+		names := make(map[string]string, len(stats.SatisfactionByAssignee))
+		for assignee := range stats.SatisfactionByAssignee {
+			names[assignee] = "Unassigned"
+			if assignee != statsUnassigned {
+				names[assignee] = p.mentionUser(assignee)
+			}
+			assignees = append(assignees, assignee)
+		}
+		sort.Slice(assignees, func(i, j int) bool { return names[assignees[i]] < names[assignees[j]] })
+
+		message.WriteString("\n" + satisfactionTableHeader("Assignee"))
+		for _, assignee := range assignees {
+			message.WriteString(satisfactionTableRow(names[assignee], stats.SatisfactionByAssignee[assignee]))
+		}
+	}
+
+	message.WriteString("\n_Statistics are aggregated nightly and cover complete days, in UTC._")
+
+	return message.String()
+}
+
+func (p *Plugin) executeCommandStats(params []string) *model.CommandResponse {
+	if len(params) > 1 {
+		return ephemeralResponse(commandUsage("stats"))
+	}
+
+	var period string
+	if len(params) == 1 {
+		period = params[0]
+	}
+	periodDays, err := parseStatsPeriod(period)
+	if err != nil {
+		return ephemeralResponse(err.Error() + ".")
+	}
+
+	stats, err := p.getTicketStats(periodDays, time.Now())
+	if err != nil {
+		p.API.LogError("Failed to get ticket statistics", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket statistics.")
+	}
+
+	return ephemeralResponse(p.statsMessage(stats))
+}
+
+func (p *Plugin) handleStats(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if !p.isSREAdmin(userID) && !requestTokenHasScope(r, apiTokenScopeAdmin) {
+		http.Error(w, "Not authorized to view the ticket statistics", http.StatusForbidden)
+		return
+	}
+
+	periodDays, err := parseStatsPeriod(r.URL.Query().Get("period"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := p.getTicketStats(periodDays, time.Now())
+	if err != nil {
+		p.API.LogError("Failed to get ticket statistics", "err", err.Error())
+		http.Error(w, "Failed to get the ticket statistics", http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, stats)
+}
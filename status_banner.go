@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	// statusBannerKeyPrefix prefixes the KV record of the banner last set in the header of a
+	// team's SRE channel, so that it can be replaced without touching the rest of the header.
+	statusBannerKeyPrefix = "statusbanner_"
+
+	// statusBannerSeparator separates the banner from the header set by the channel members.
+	statusBannerSeparator = " | "
+)
+
+func statusBannerKey(channelID string) string {
+	return statusBannerKeyPrefix + channelID
+}
+
+// statusBanner renders the banner of the SRE channel header for the number of open High-priority
+// tickets, or returns an empty string if there are none.
+func (p *Plugin) statusBanner(openHighPriority int) string {
+	if openHighPriority == 0 {
+		return ""
+	}
+
+	return localize(p.serverLocalizer(), &i18n.Message{
+		ID:    "channel.status_banner",
+		Other: ":red_circle: **High-priority SRE requests open: {{.Count}}**",
+	}, map[string]interface{}{"Count": openHighPriority})
+}
+
+// withStatusBanner returns the channel header with the previous banner replaced by the given one.
+func withStatusBanner(header, previous, banner string) string {
+	if previous != "" {
+		if header == previous {
+			header = ""
+		} else {
+			header = strings.TrimPrefix(header, previous+statusBannerSeparator)
+		}
+	}
+
+	switch {
+	case banner == "":
+		return header
+	case header == "":
+		return banner
+	default:
+		return banner + statusBannerSeparator + header
+	}
+}
+
+// refreshStatusBanner shows the number of open High-priority tickets of the team in the header of
+// its SRE channel, so that channel members see the current state at a glance, and removes the
+// banner once they are all resolved. Confidential tickets are not counted. The channel is only
+// patched when the banner changes.
+func (p *Plugin) refreshStatusBanner(teamID string) error {
+	configuration := p.getConfiguration()
+	channelID, ok := configuration.demoChannelIDs[teamID]
+	if !ok || configuration.MinimalPermissions {
+		return nil
+	}
+
+	p.statusBannerLock.Lock()
+	defer p.statusBannerLock.Unlock()
+
+	tickets, err := p.listCachedTickets(teamID)
+	if err != nil {
+		return err
+	}
+	openHighPriority := 0
+	for _, ticket := range tickets {
+		if ticket.Priority == ticketPriorityHigh && ticket.Status != ticketStatusResolved && !ticket.Confidential {
+			openHighPriority++
+		}
+	}
+	banner := p.statusBanner(openHighPriority)
+
+	var previous string
+	if err := p.client.KV.Get(statusBannerKey(channelID), &previous); err != nil {
+		return errors.Wrap(err, "failed to get status banner")
+	}
+	if banner == previous {
+		return nil
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get SRE channel")
+	}
+	header := withStatusBanner(channel.Header, previous, banner)
+	if header != channel.Header {
+		// The plugin API has no PatchChannel, so the patch is applied to the fetched channel.
+		channel.Patch(&model.ChannelPatch{Header: &header})
+		if _, appErr := p.API.UpdateChannel(channel); appErr != nil {
+			return errors.Wrap(appErr, "failed to update SRE channel header")
+		}
+	}
+
+	if _, err := p.client.KV.Set(statusBannerKey(channelID), banner); err != nil {
+		return errors.Wrap(err, "failed to save status banner")
+	}
+
+	return nil
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// statuspageAPIURL is the base URL of the Statuspage REST API.
+	statuspageAPIURL = "https://api.statuspage.io/v1"
+
+	dialogElementNameStatusUpdate = "message"
+
+	// defaultStatusUpdateTemplate renders customer updates when no template is configured.
+	defaultStatusUpdateTemplate = "**{{.Status}}: {{.Title}}**\n\n{{.Message}}"
+)
+
+var (
+	// internalDetailPatterns match the details of a summary that must not reach customers: links,
+	// user and channel mentions, addresses and inline code.
+	internalDetailPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`https?://\S+`),
+		regexp.MustCompile(`(?:^|\s)[@~][a-z][a-z0-9._-]*`),
+		regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`),
+		regexp.MustCompile("`[^`]*`"),
+	}
+
+	// whitespacePattern matches the runs of whitespace left by removed details.
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// statusUpdate holds the customer-facing fields available to the status update template.
+type statusUpdate struct {
+	// Title is the ticket summary stripped of internal details.
+	Title string
+
+	// Status is the customer-facing incident status, such as "Investigating".
+	Status string
+
+	// Impact describes the impact of the incident, such as "Major outage".
+	Impact string
+
+	// Message is a canned sentence describing the incident status.
+	Message string
+
+	// statuspageStatus is the incident status sent to Statuspage.
+	statuspageStatus string
+}
+
+// parseStatusUpdateTemplate parses the configured status update template, falling back to the
+// default template.
+func parseStatusUpdateTemplate(configuration *configuration) (*template.Template, error) {
+	text := configuration.StatusUpdateTemplate
+	if strings.TrimSpace(text) == "" {
+		text = defaultStatusUpdateTemplate
+	}
+
+	return template.New("status_update").Parse(text)
+}
+
+// isStatusPageConfigured reports whether customer updates have a destination.
+func (c *configuration) isStatusPageConfigured() bool {
+	return c.statusPageChannelID != "" || c.isStatuspageConfigured()
+}
+
+// isStatuspageConfigured reports whether the optional Statuspage integration is enabled.
+func (c *configuration) isStatuspageConfigured() bool {
+	return c.StatuspagePageID != "" && c.StatuspageAPIKey != ""
+}
+
+// sanitizeForCustomers strips internal details from the text.
+func sanitizeForCustomers(text string) string {
+	text = strings.TrimPrefix(text, "[Alert] ")
+	for _, pattern := range internalDetailPatterns {
+		text = pattern.ReplaceAllString(text, "")
+	}
+
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+}
+
+// newStatusUpdate maps the ticket to the customer-facing fields of a status update.
+func newStatusUpdate(ticket *Ticket) *statusUpdate {
+	update := &statusUpdate{
+		Title: sanitizeForCustomers(ticket.Summary),
+	}
+
+	switch ticket.Priority {
+	case ticketPriorityHigh:
+		update.Impact = "Major outage"
+	case ticketPriorityMedium:
+		update.Impact = "Partial outage"
+	default:
+		update.Impact = "Degraded performance"
+	}
+
+	switch ticket.Status {
+	case ticketStatusOpen:
+		update.Status = "Investigating"
+		update.statuspageStatus = "investigating"
+		update.Message = "We are investigating this issue and will provide an update as soon as we know more."
+	case ticketStatusResolved:
+		update.Status = "Resolved"
+		update.statuspageStatus = "resolved"
+		update.Message = "This issue has been resolved. We apologize for the inconvenience."
+	default:
+		update.Status = "Identified"
+		update.statuspageStatus = "identified"
+		update.Message = "The issue has been identified and we are working on a fix."
+	}
+
+	return update
+}
+
+// renderStatusUpdate renders the customer update of the ticket with the configured template.
+func (p *Plugin) renderStatusUpdate(ticket *Ticket) (string, error) {
+	var buffer bytes.Buffer
+	if err := p.getConfiguration().statusUpdateTemplate.Execute(&buffer, newStatusUpdate(ticket)); err != nil {
+		return "", errors.Wrap(err, "failed to render status update")
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// getStatusUpdateDialog returns the dialog reviewing the customer update of the ticket before it
+// is posted.
+func (p *Plugin) getStatusUpdateDialog(ticket *Ticket, message string) model.Dialog {
+	configuration := p.getConfiguration()
+
+	var destinations []string
+	if configuration.statusPageChannelID != "" {
+		destinations = append(destinations, "the status channel")
+	}
+	if configuration.isStatuspageConfigured() {
+		destinations = append(destinations, "Statuspage")
+	}
+
+	return model.Dialog{
+		CallbackId:       "statusupdate",
+		Title:            "Review customer update",
+		IntroductionText: fmt.Sprintf("This update will be posted to %s. Make sure it contains no internal details.", strings.Join(destinations, " and ")),
+		Elements: []model.DialogElement{{
+			DisplayName: "Update",
+			Name:        dialogElementNameStatusUpdate,
+			Type:        "textarea",
+			Default:     message,
+			MaxLength:   3000,
+		}},
+		SubmitLabel: "Post",
+		State:       ticket.ID,
+	}
+}
+
+func (p *Plugin) executeCommandStatusPage(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("statuspage"))
+	}
+	if !p.getConfiguration().isStatusPageConfigured() {
+		return ephemeralResponse("No status channel or Statuspage page is configured.")
+	}
+
+	ticket, err := p.getTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+	if !p.canEditTicket(args.UserId, ticket) {
+		return ephemeralResponse("You are not allowed to edit this ticket.")
+	}
+	if ticket.Confidential {
+		return ephemeralResponse("Customer updates cannot be posted for confidential tickets.")
+	}
+
+	message, err := p.renderStatusUpdate(ticket)
+	if err != nil {
+		p.API.LogError("Failed to render status update", "ticket_id", ticket.ID, "err", err.Error())
+		return ephemeralResponse("Failed to render the customer update, check the status update template.")
+	}
+
+	if appErr := p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: args.TriggerId,
+		URL:       pluginCallbackURL("/dialog/sre/statuspage"),
+		Dialog:    p.getStatusUpdateDialog(ticket, message),
+	}); appErr != nil {
+		p.API.LogError("Failed to open dialog", "err", appErr.Error())
+		return ephemeralResponse("Failed to open the customer update dialog.")
+	}
+
+	return &model.CommandResponse{}
+}
+
+// handleStatusUpdateDialog posts the reviewed customer update to the status channel and to
+// Statuspage, then records it in the ticket's thread.
+func (p *Plugin) handleStatusUpdateDialog(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.SubmitDialogRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		p.API.LogError("Failed to decode SubmitDialogRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if request.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	message, _ := request.Submission[dialogElementNameStatusUpdate].(string)
+	message = strings.TrimSpace(message)
+	if message == "" {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameStatusUpdate: "An update is required",
+			},
+		})
+		return
+	}
+
+	ticket, err := p.getTicket(request.State)
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "ticket_id", request.State, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if ticket == nil || ticket.Confidential || !p.canEditTicket(userID, ticket) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "You are not allowed to post customer updates for this ticket.",
+		})
+		return
+	}
+	if !p.teamEnabled(ticket.TeamID) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "The plugin is disabled in this team.",
+		})
+		return
+	}
+
+	if err := p.postStatusUpdate(ticket, message); err != nil {
+		p.API.LogError("Failed to post status update", "ticket_id", ticket.ID, "err", err.Error())
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "Failed to post the customer update. Please try again later.",
+		})
+		return
+	}
+
+	if err := p.postTicketReply(ticket, fmt.Sprintf(":loudspeaker: %s posted a customer update:\n\n%s", p.mentionUser(userID), message)); err != nil {
+		p.API.LogWarn("Failed to record status update", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// postStatusUpdate posts the customer update to the configured destinations.
+func (p *Plugin) postStatusUpdate(ticket *Ticket, message string) error {
+	configuration := p.getConfiguration()
+
+	if configuration.statusPageChannelID != "" {
+		if _, appErr := p.API.CreatePost(&model.Post{
+			UserId:    p.botID,
+			ChannelId: configuration.statusPageChannelID,
+			Message:   message,
+		}); appErr != nil {
+			return errors.Wrap(appErr, "failed to post to the status channel")
+		}
+	}
+
+	if configuration.isStatuspageConfigured() {
+		incidentID, err := p.upsertStatuspageIncident(ticket, message)
+		if isDryRun(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// The incident is recorded on the ticket as it was saved last, so that it is never lost.
+		if _, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+			ticket.StatuspageIncidentID = incidentID
+			recordTicketActivity(ticket, timelineEventSync, "", fmt.Sprintf("Synced a customer update to Statuspage incident %s", incidentID))
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upsertStatuspageIncident creates the Statuspage incident of the ticket, or updates it if the
+// ticket already has one, and returns its id.
+func (p *Plugin) upsertStatuspageIncident(ticket *Ticket, message string) (string, error) {
+	configuration := p.getConfiguration()
+	update := newStatusUpdate(ticket)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"incident": map[string]string{
+			"name":   update.Title,
+			"status": update.statuspageStatus,
+			"body":   message,
+		},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal Statuspage incident")
+	}
+
+	method := http.MethodPost
+	url := fmt.Sprintf("%s/pages/%s/incidents", statuspageAPIURL, configuration.StatuspagePageID)
+	if ticket.StatuspageIncidentID != "" {
+		method = http.MethodPatch
+		url += "/" + ticket.StatuspageIncidentID
+	}
+	if err := p.validateExternalURL(url, "Statuspage"); err != nil {
+		return "", err
+	}
+	if p.interceptDryRun("Statuspage", method+" "+url, body) {
+		return "", errDryRun
+	}
+	p.countUsage("integration: statuspage")
+	client := newExternalHTTPClient(configuration.allowedNetworks)
+
+	response, err := doWithRetry(client, func() (*http.Request, error) {
+		request, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "OAuth "+configuration.StatuspageAPIKey)
+
+		return request, nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to post Statuspage incident")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", errors.Errorf("failed to post Statuspage incident: %s: %s", response.Status, responseBody)
+	}
+
+	var incident struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&incident); err != nil {
+		return "", errors.Wrap(err, "failed to decode Statuspage incident")
+	}
+
+	return incident.ID, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	ticketStoreKV   = "kv"
+	ticketStoreDual = "dual"
+	ticketStoreSQL  = "sql"
+
+	// ticketKeyPrefix prefixes the KV key of every stored ticket.
+	ticketKeyPrefix = "ticket_"
+
+	// ticketPostKeyPrefix prefixes the KV index from a ticket's root post to the ticket id.
+	ticketPostKeyPrefix = "ticketpost_"
+
+	// kvListPerPage is the page size used when scanning the KV store.
+	kvListPerPage = 200
+)
+
+// TicketStore persists tickets. Lookups return a nil ticket and no error when the ticket does not
+// exist. Saves fail with errTicketConflict unless the saved ticket's revision is newer than the
+// stored one, which they check and write atomically.
+type TicketStore interface {
+	SaveTicket(ticket *Ticket) error
+	GetTicket(ticketID string) (*Ticket, error)
+	GetTicketByPostID(postID string) (*Ticket, error)
+	ListTickets() ([]*Ticket, error)
+	DeleteTicket(ticket *Ticket) error
+}
+
+// ticketStore returns the store selected by the TicketStore setting.
+func (p *Plugin) ticketStore() TicketStore {
+	if store := p.getConfiguration().ticketStore; store != nil {
+		return store
+	}
+
+	return &kvTicketStore{client: p.client}
+}
+
+// newTicketStore builds the store selected by the TicketStore setting. The SQL store carried over
+// from the previous configuration is reused, if any, so that its table is only set up once.
+func (p *Plugin) newTicketStore(configuration *configuration) (TicketStore, error) {
+	kvStore := &kvTicketStore{client: p.client}
+
+	mode := strings.ToLower(strings.TrimSpace(configuration.TicketStore))
+	if mode == "" || mode == ticketStoreKV {
+		return kvStore, nil
+	}
+	if mode != ticketStoreDual && mode != ticketStoreSQL {
+		return nil, errors.Errorf("unknown ticket store %q", configuration.TicketStore)
+	}
+
+	sqlStore := configuration.sqlStore
+	if sqlStore == nil {
+		var err error
+		sqlStore, err = newSQLTicketStore(p.client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	configuration.sqlStore = sqlStore
+
+	if mode == ticketStoreSQL {
+		return sqlStore, nil
+	}
+
+	switch primary := strings.ToLower(strings.TrimSpace(configuration.TicketStorePrimary)); primary {
+	case "", ticketStoreKV:
+		return &dualWriteTicketStore{primary: kvStore, shadow: sqlStore, log: p.API.LogWarn}, nil
+	case ticketStoreSQL:
+		return &dualWriteTicketStore{primary: sqlStore, shadow: kvStore, log: p.API.LogWarn}, nil
+	default:
+		return nil, errors.Errorf("unknown primary ticket store %q", configuration.TicketStorePrimary)
+	}
+}
+
+// kvTicketStore stores tickets in the plugin KV store, alongside an index from each ticket's root
+// post to its id.
+type kvTicketStore struct {
+	client *pluginapi.Client
+}
+
+func ticketKey(ticketID string) string {
+	return ticketKeyPrefix + ticketID
+}
+
+func ticketPostKey(postID string) string {
+	return ticketPostKeyPrefix + postID
+}
+
+func (s *kvTicketStore) SaveTicket(ticket *Ticket) error {
+	var stored []byte
+	if err := s.client.KV.Get(ticketKey(ticket.ID), &stored); err != nil {
+		return errors.Wrap(err, "failed to get stored ticket")
+	}
+	if err := checkTicketRevision(stored, ticket); err != nil {
+		return err
+	}
+
+	// The ticket is only saved if it wasn't saved since it was read above.
+	saved, err := s.client.KV.Set(ticketKey(ticket.ID), ticket, pluginapi.SetAtomic(stored))
+	if err != nil {
+		return errors.Wrap(err, "failed to save ticket")
+	}
+	if !saved {
+		return errTicketConflict
+	}
+
+	if ticket.PostID != "" {
+		if _, err := s.client.KV.Set(ticketPostKey(ticket.PostID), ticket.ID); err != nil {
+			return errors.Wrap(err, "failed to save ticket post index")
+		}
+	}
+
+	return nil
+}
+
+func (s *kvTicketStore) GetTicket(ticketID string) (*Ticket, error) {
+	var ticket *Ticket
+	if err := s.client.KV.Get(ticketKey(ticketID), &ticket); err != nil {
+		return nil, errors.Wrap(err, "failed to get ticket")
+	}
+
+	return ticket, nil
+}
+
+func (s *kvTicketStore) GetTicketByPostID(postID string) (*Ticket, error) {
+	var ticketID string
+	if err := s.client.KV.Get(ticketPostKey(postID), &ticketID); err != nil {
+		return nil, errors.Wrap(err, "failed to get ticket post index")
+	}
+
+	if ticketID == "" {
+		return nil, nil
+	}
+
+	return s.GetTicket(ticketID)
+}
+
+func (s *kvTicketStore) DeleteTicket(ticket *Ticket) error {
+	if err := s.client.KV.Delete(ticketKey(ticket.ID)); err != nil {
+		return errors.Wrap(err, "failed to delete ticket")
+	}
+
+	if ticket.PostID != "" {
+		if err := s.client.KV.Delete(ticketPostKey(ticket.PostID)); err != nil {
+			return errors.Wrap(err, "failed to delete ticket post index")
+		}
+	}
+
+	return nil
+}
+
+func (s *kvTicketStore) ListTickets() ([]*Ticket, error) {
+	var tickets []*Ticket
+	for page := 0; ; page++ {
+		keys, err := s.client.KV.ListKeys(page, kvListPerPage)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list keys")
+		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, ticketKeyPrefix) {
+				continue
+			}
+
+			ticket, err := s.GetTicket(strings.TrimPrefix(key, ticketKeyPrefix))
+			if err != nil {
+				return nil, err
+			}
+			if ticket != nil {
+				tickets = append(tickets, ticket)
+			}
+		}
+
+		if len(keys) < kvListPerPage {
+			return tickets, nil
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// dualWriteTicketStore supports migrating between stores: writes go to both stores, while reads are
+// served by the primary store and shadow-read from the other one to detect divergences. Failures of
+// the shadow store are logged but never surface to callers.
+type dualWriteTicketStore struct {
+	primary TicketStore
+	shadow  TicketStore
+	log     func(msg string, keyValuePairs ...interface{})
+}
+
+func (s *dualWriteTicketStore) SaveTicket(ticket *Ticket) error {
+	if err := s.primary.SaveTicket(ticket); err != nil {
+		return err
+	}
+
+	if err := s.shadow.SaveTicket(ticket); err != nil {
+		s.log("Failed to save ticket to the shadow store", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return nil
+}
+
+func (s *dualWriteTicketStore) GetTicket(ticketID string) (*Ticket, error) {
+	ticket, err := s.primary.GetTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowTicket, err := s.shadow.GetTicket(ticketID)
+	s.compare(ticketID, ticket, shadowTicket, err)
+
+	return ticket, nil
+}
+
+func (s *dualWriteTicketStore) GetTicketByPostID(postID string) (*Ticket, error) {
+	ticket, err := s.primary.GetTicketByPostID(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	shadowTicket, err := s.shadow.GetTicketByPostID(postID)
+	s.compare(postID, ticket, shadowTicket, err)
+
+	return ticket, nil
+}
+
+// ListTickets only reads the primary store: full listings are compared by the consistency job.
+func (s *dualWriteTicketStore) ListTickets() ([]*Ticket, error) {
+	return s.primary.ListTickets()
+}
+
+func (s *dualWriteTicketStore) DeleteTicket(ticket *Ticket) error {
+	if err := s.primary.DeleteTicket(ticket); err != nil {
+		return err
+	}
+
+	if err := s.shadow.DeleteTicket(ticket); err != nil {
+		s.log("Failed to delete ticket from the shadow store", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return nil
+}
+
+func (s *dualWriteTicketStore) compare(id string, ticket, shadowTicket *Ticket, shadowErr error) {
+	if shadowErr != nil {
+		s.log("Failed to read ticket from the shadow store", "id", id, "err", shadowErr.Error())
+		return
+	}
+
+	if !ticketsEqual(ticket, shadowTicket) {
+		s.log("Ticket diverges between the primary and shadow stores", "id", id)
+	}
+}
+
+func ticketsEqual(a, b *Ticket) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	aData, aErr := json.Marshal(a)
+	bData, bErr := json.Marshal(b)
+
+	return aErr == nil && bErr == nil && bytes.Equal(aData, bData)
+}
+
+// StoreConsistencyJob compares every ticket of the primary store with the shadow store while in
+// dual-write mode. Tickets missing from the shadow store, typically created before dual-write was
+// enabled, are backfilled; any other divergence is reported so it can be resolved before cutover.
+func (p *Plugin) StoreConsistencyJob() {
+	store, ok := p.ticketStore().(*dualWriteTicketStore)
+	if !ok {
+		return
+	}
+
+	tickets, err := store.primary.ListTickets()
+	if err != nil {
+		p.reportJobFailure("StoreConsistencyJob", "Failed to list tickets from the primary store", err)
+		return
+	}
+
+	shadowTickets, err := store.shadow.ListTickets()
+	if err != nil {
+		p.reportJobFailure("StoreConsistencyJob", "Failed to list tickets from the shadow store", err)
+		return
+	}
+
+	shadowTicketsByID := make(map[string]*Ticket, len(shadowTickets))
+	for _, ticket := range shadowTickets {
+		shadowTicketsByID[ticket.ID] = ticket
+	}
+
+	backfilled, diverged := 0, 0
+	for _, ticket := range tickets {
+		shadowTicket, ok := shadowTicketsByID[ticket.ID]
+		delete(shadowTicketsByID, ticket.ID)
+
+		if !ok {
+			if err := store.shadow.SaveTicket(ticket); err != nil {
+				p.API.LogError("Failed to backfill ticket to the shadow store", "ticket_id", ticket.ID, "err", err.Error())
+				diverged++
+				continue
+			}
+			backfilled++
+			continue
+		}
+
+		if !ticketsEqual(ticket, shadowTicket) {
+			p.API.LogWarn("Ticket diverges between the primary and shadow stores", "ticket_id", ticket.ID)
+			diverged++
+		}
+	}
+
+	for ticketID := range shadowTicketsByID {
+		p.API.LogWarn("Ticket only exists in the shadow store", "ticket_id", ticketID)
+		diverged++
+	}
+
+	if diverged > 0 {
+		p.API.LogWarn("Ticket stores are not consistent, do not cut over yet",
+			"tickets", len(tickets), "backfilled", backfilled, "diverged", diverged)
+		p.postAdminNotice(fmt.Sprintf(":warning: The ticket stores drifted: %d of %d tickets diverge between the primary and shadow stores, and %d were backfilled. Do not cut over yet.", diverged, len(tickets), backfilled))
+		return
+	}
+
+	p.API.LogInfo("Ticket stores are consistent", "tickets", len(tickets), "backfilled", backfilled)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+// sqlTicketStore stores tickets as JSON documents in a plugin-owned table of the Mattermost
+// database.
+type sqlTicketStore struct {
+	db         *sql.DB
+	driverName string
+}
+
+func newSQLTicketStore(client *pluginapi.Client) (*sqlTicketStore, error) {
+	db, err := client.Store.GetMasterDB()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get master database")
+	}
+
+	store := &sqlTicketStore{
+		db:         db,
+		driverName: client.Store.DriverName(),
+	}
+
+	if err := store.createTable(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *sqlTicketStore) createTable() error {
+	var statements []string
+	if s.driverName == model.DatabaseDriverMysql {
+		statements = []string{`
+			CREATE TABLE IF NOT EXISTS SRE_Tickets (
+				ID VARCHAR(26) PRIMARY KEY,
+				PostID VARCHAR(26) NOT NULL DEFAULT '',
+				UpdateAt BIGINT NOT NULL,
+				Data MEDIUMTEXT NOT NULL,
+				INDEX idx_sre_tickets_postid (PostID)
+			)`,
+		}
+	} else {
+		statements = []string{`
+			CREATE TABLE IF NOT EXISTS SRE_Tickets (
+				ID VARCHAR(26) PRIMARY KEY,
+				PostID VARCHAR(26) NOT NULL DEFAULT '',
+				UpdateAt BIGINT NOT NULL,
+				Data TEXT NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_sre_tickets_postid ON SRE_Tickets (PostID)`,
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil {
+			return errors.Wrap(err, "failed to create tickets table")
+		}
+	}
+
+	return nil
+}
+
+// rebind rewrites ? placeholders into the $n placeholders expected by Postgres.
+func (s *sqlTicketStore) rebind(query string) string {
+	if s.driverName == model.DatabaseDriverMysql {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+func (s *sqlTicketStore) SaveTicket(ticket *Ticket) error {
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal ticket")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	// The stored row is locked until the transaction ends, so that concurrent saves are serialized.
+	var stored string
+	err = tx.QueryRow(s.rebind("SELECT Data FROM SRE_Tickets WHERE ID = ? FOR UPDATE"), ticket.ID).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "failed to get stored ticket")
+	}
+	if err := checkTicketRevision([]byte(stored), ticket); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO SRE_Tickets (ID, PostID, UpdateAt, Data) VALUES (?, ?, ?, ?) `
+	if s.driverName == model.DatabaseDriverMysql {
+		query += `ON DUPLICATE KEY UPDATE PostID = VALUES(PostID), UpdateAt = VALUES(UpdateAt), Data = VALUES(Data)`
+	} else {
+		query += `ON CONFLICT (ID) DO UPDATE SET PostID = EXCLUDED.PostID, UpdateAt = EXCLUDED.UpdateAt, Data = EXCLUDED.Data`
+	}
+
+	if _, err := tx.Exec(s.rebind(query), ticket.ID, ticket.PostID, ticket.UpdateAt, string(data)); err != nil {
+		return errors.Wrap(err, "failed to save ticket")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit ticket")
+	}
+
+	return nil
+}
+
+func (s *sqlTicketStore) GetTicket(ticketID string) (*Ticket, error) {
+	return s.getTicketWhere("ID = ?", ticketID)
+}
+
+func (s *sqlTicketStore) GetTicketByPostID(postID string) (*Ticket, error) {
+	return s.getTicketWhere("PostID = ?", postID)
+}
+
+func (s *sqlTicketStore) getTicketWhere(condition string, arg interface{}) (*Ticket, error) {
+	var data string
+	err := s.db.QueryRow(s.rebind("SELECT Data FROM SRE_Tickets WHERE "+condition), arg).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get ticket")
+	}
+
+	var ticket *Ticket
+	if err := json.Unmarshal([]byte(data), &ticket); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal ticket")
+	}
+
+	return ticket, nil
+}
+
+func (s *sqlTicketStore) ListTickets() ([]*Ticket, error) {
+	rows, err := s.db.Query("SELECT Data FROM SRE_Tickets")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tickets")
+	}
+	defer rows.Close()
+
+	var tickets []*Ticket
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, errors.Wrap(err, "failed to scan ticket")
+		}
+
+		var ticket *Ticket
+		if err := json.Unmarshal([]byte(data), &ticket); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal ticket")
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to list tickets")
+	}
+
+	return tickets, nil
+}
+
+// deleteAllTickets deletes every ticket of the table, when wiping the plugin data.
+func (s *sqlTicketStore) deleteAllTickets() error {
+	if _, err := s.db.Exec("DELETE FROM SRE_Tickets"); err != nil {
+		return errors.Wrap(err, "failed to delete tickets")
+	}
+
+	return nil
+}
+
+func (s *sqlTicketStore) DeleteTicket(ticket *Ticket) error {
+	if _, err := s.db.Exec(s.rebind("DELETE FROM SRE_Tickets WHERE ID = ?"), ticket.ID); err != nil {
+		return errors.Wrap(err, "failed to delete ticket")
+	}
+
+	return nil
+}
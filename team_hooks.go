@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// UserHasJoinedTeam is invoked after the membership has been committed to the database. If
+// actor is not nil, the user was added to the team by the actor.
+//
+// This server version has no hook for created teams, so this implementation detects them when
+// their first member joins: it refreshes the team cache and ensures the new team's SRE channel
+// exists if the plugin is enabled in it.
+func (p *Plugin) UserHasJoinedTeam(c *plugin.Context, teamMember *model.TeamMember, actor *model.User) {
+	configuration := p.getConfiguration()
+
+	if configuration.disabled || configuration.MinimalPermissions {
+		return
+	}
+	if _, ok := configuration.demoChannelIDs[teamMember.TeamId]; ok || !p.teamEnabled(teamMember.TeamId) {
+		return
+	}
+
+	team, appErr := p.getCachedTeam(teamMember.TeamId)
+	if appErr != nil {
+		p.API.LogError("Failed to get new team", "team_id", teamMember.TeamId, "err", appErr.Error())
+		return
+	}
+	if !configuration.inTeamScope(team) {
+		return
+	}
+
+	p.teamCache.invalidate()
+
+	channelID, appErr := p.ensureDemoChannel(configuration, team)
+	if appErr != nil {
+		p.API.LogError("Failed to ensure SRE channel for new team", "team_id", team.Id, "err", appErr.Error())
+		return
+	}
+
+	configuration = configuration.Clone()
+	configuration.demoChannelIDs[team.Id] = channelID
+	p.setConfiguration(configuration)
+
+	// Adding the demo user triggers this hook again, so only do it once the channel is known.
+	if configuration.demoUserID != "" && teamMember.UserId != configuration.demoUserID {
+		if _, appErr := p.API.CreateTeamMember(team.Id, configuration.demoUserID); appErr != nil {
+			p.API.LogError("Failed to add demo user to new team", "team_id", team.Id, "err", appErr.Error())
+		}
+	}
+}
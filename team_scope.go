@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// parseScopedTeams parses the names of the teams the plugin is enabled in, returning nil when the
+// plugin is enabled in every team.
+func parseScopedTeams(configuration *configuration) map[string]bool {
+	var names map[string]bool
+	for _, name := range strings.Split(configuration.Teams, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			if names == nil {
+				names = make(map[string]bool)
+			}
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+// inTeamScope reports whether the plugin is enabled in the team.
+func (c *configuration) inTeamScope(team *model.Team) bool {
+	return c.scopedTeamNames == nil || c.scopedTeamNames[strings.ToLower(team.Name)]
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// disabledTeamsKey stores the ids of the teams the plugin was disabled in.
+	disabledTeamsKey = "disabled_teams"
+
+	// disabledTeamsCacheTTL bounds how long a plugin instance may keep serving hooks in a team
+	// after another instance of the cluster disabled the plugin in it.
+	disabledTeamsCacheTTL = time.Minute
+)
+
+// disabledTeamsCache is an in-memory copy of the teams the plugin is disabled in, saving the hooks
+// a KV store round trip. The zero value is an empty cache.
+type disabledTeamsCache struct {
+	lock     sync.Mutex
+	teamIDs  map[string]bool
+	expireAt time.Time
+}
+
+// get returns whether the plugin is disabled in the team, or false as second value if the cache
+// is empty or expired.
+func (c *disabledTeamsCache) get(teamID string) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.teamIDs == nil || time.Now().After(c.expireAt) {
+		return false, false
+	}
+
+	return c.teamIDs[teamID], true
+}
+
+// replace fills the cache with the given team ids.
+func (c *disabledTeamsCache) replace(teamIDs []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.teamIDs = make(map[string]bool, len(teamIDs))
+	for _, teamID := range teamIDs {
+		c.teamIDs[teamID] = true
+	}
+	c.expireAt = time.Now().Add(disabledTeamsCacheTTL)
+}
+
+// listDisabledTeams returns the ids of the teams the plugin was disabled in.
+func (p *Plugin) listDisabledTeams() ([]string, error) {
+	var teamIDs []string
+	if err := p.client.KV.Get(disabledTeamsKey, &teamIDs); err != nil {
+		return nil, errors.Wrap(err, "failed to get disabled teams")
+	}
+
+	return teamIDs, nil
+}
+
+// setTeamEnabled enables or disables the plugin in the team, for every instance of the cluster.
+func (p *Plugin) setTeamEnabled(teamID string, enabled bool) error {
+	var teamIDs []string
+	err := p.client.KV.SetAtomicWithRetries(disabledTeamsKey, func(oldValue []byte) (interface{}, error) {
+		teamIDs = nil
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &teamIDs); err != nil {
+				return nil, errors.Wrap(err, "failed to decode disabled teams")
+			}
+		}
+
+		updated := make([]string, 0, len(teamIDs)+1)
+		for _, id := range teamIDs {
+			if id != teamID {
+				updated = append(updated, id)
+			}
+		}
+		if !enabled {
+			updated = append(updated, teamID)
+		}
+		teamIDs = updated
+
+		return teamIDs, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to update disabled teams")
+	}
+
+	p.disabledTeams.replace(teamIDs)
+
+	return nil
+}
+
+// teamEnabled returns whether the plugin is enabled, both globally and in the team. Hooks call it
+// before acting on anything of a team. Failing to read the disabled teams is logged, and the team
+// considered enabled.
+func (p *Plugin) teamEnabled(teamID string) bool {
+	if p.getConfiguration().disabled {
+		return false
+	}
+	if teamID == "" {
+		return true
+	}
+
+	if disabled, ok := p.disabledTeams.get(teamID); ok {
+		return !disabled
+	}
+
+	teamIDs, err := p.listDisabledTeams()
+	if err != nil {
+		p.API.LogWarn("Failed to check if the plugin is enabled in the team", "team_id", teamID, "err", err.Error())
+		return true
+	}
+	p.disabledTeams.replace(teamIDs)
+
+	return !contains(teamIDs, teamID)
+}
+
+// executeCommandTeamToggle enables or disables the plugin in the given team, by name, or in the
+// current team.
+func (p *Plugin) executeCommandTeamToggle(args *model.CommandArgs, params []string, enabled bool) *model.CommandResponse {
+	subcommand := "disable"
+	if enabled {
+		subcommand = "enable"
+	}
+
+	if len(params) > 1 {
+		return ephemeralResponse(commandUsage(subcommand))
+	}
+
+	var team *model.Team
+	var appErr *model.AppError
+	if len(params) == 1 {
+		team, appErr = p.API.GetTeamByName(strings.ToLower(params[0]))
+	} else {
+		team, appErr = p.getCachedTeam(args.TeamId)
+	}
+	if appErr != nil {
+		if len(params) == 1 && appErr.StatusCode == http.StatusNotFound {
+			return ephemeralResponse(fmt.Sprintf("Team %s not found.", params[0]))
+		}
+		p.API.LogError("Failed to get team to toggle", "err", appErr.Error())
+		return ephemeralResponse("Failed to get the team.")
+	}
+
+	if err := p.setTeamEnabled(team.Id, enabled); err != nil {
+		p.API.LogError("Failed to toggle the plugin in team", "team_id", team.Id, "err", err.Error())
+		return ephemeralResponse(fmt.Sprintf("Failed to %s the plugin in %s.", subcommand, team.DisplayName))
+	}
+
+	if enabled {
+		return ephemeralResponse(fmt.Sprintf("The plugin is now enabled in %s.", team.DisplayName))
+	}
+
+	return ephemeralResponse(fmt.Sprintf("The plugin is now disabled in %s: its commands, dialogs and hooks ignore the team until it is enabled again with `/sre-request enable %s`.", team.DisplayName, team.Name))
+}
+
+// disabledTeamNames returns the sorted names of the teams the plugin was disabled in, for the
+// status endpoint. Teams that no longer exist are reported by id.
+func (p *Plugin) disabledTeamNames() ([]string, error) {
+	teamIDs, err := p.listDisabledTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(teamIDs))
+	for _, teamID := range teamIDs {
+		if team, appErr := p.getCachedTeam(teamID); appErr == nil {
+			names = append(names, team.Name)
+		} else {
+			names = append(names, teamID)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
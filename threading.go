@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// threadingModeThreaded keeps every bot follow-up in the ticket's thread.
+	threadingModeThreaded = "threaded"
+
+	// threadingModeBroadcast additionally posts important transitions, such as acknowledgements,
+	// escalations and resolutions, to the channel root.
+	threadingModeBroadcast = "broadcast"
+)
+
+// parseThreadingMode validates the ThreadingMode setting, defaulting to threaded.
+func parseThreadingMode(configuration *configuration) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(configuration.ThreadingMode)); mode {
+	case "":
+		return threadingModeThreaded, nil
+	case threadingModeThreaded, threadingModeBroadcast:
+		return mode, nil
+	default:
+		return "", errors.Errorf("invalid threading mode %q, expected %s or %s", configuration.ThreadingMode, threadingModeThreaded, threadingModeBroadcast)
+	}
+}
+
+// createTicketReply posts a bot follow-up in the ticket's thread, setting the id and creation time
+// of the post. In broadcast mode, transitions are also posted to the channel root, linking back to
+// the thread. Every bot follow-up about a ticket goes through here, so that the threading mode
+// applies uniformly.
+func (p *Plugin) createTicketReply(ticket *Ticket, post *model.Post, transition bool) error {
+	post.UserId = p.botID
+	post.ChannelId = ticket.ChannelID
+	post.RootId = ticket.PostID
+
+	created, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to create ticket reply")
+	}
+	post.Id = created.Id
+	post.CreateAt = created.CreateAt
+
+	if !transition || p.getConfiguration().threadingMode != threadingModeBroadcast || post.Message == "" {
+		return nil
+	}
+
+	message := fmt.Sprintf("**%s: %s**\n%s", ticket.ticketName(), ticket.Summary, post.Message)
+	if permalink, err := p.ticketPermalink(ticket); err == nil {
+		message += fmt.Sprintf("\n[View the request](%s)", permalink)
+	}
+
+	// The transition is already in the thread, so failing to broadcast it is not fatal.
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: ticket.ChannelID,
+		Message:   message,
+	}); appErr != nil {
+		p.API.LogWarn("Failed to broadcast ticket transition", "ticket_id", ticket.ID, "err", appErr.Error())
+	}
+
+	return nil
+}
+
+// postTicketReply posts a bot follow-up in the ticket's thread.
+func (p *Plugin) postTicketReply(ticket *Ticket, message string) error {
+	return p.createTicketReply(ticket, &model.Post{Message: message}, false)
+}
+
+// postTicketTransition posts an important transition of the ticket, such as its acknowledgement,
+// escalation or resolution, in the ticket's thread and, in broadcast mode, to the channel root.
+func (p *Plugin) postTicketTransition(ticket *Ticket, message string) error {
+	return p.createTicketReply(ticket, &model.Post{Message: message}, true)
+}
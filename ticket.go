@@ -0,0 +1,593 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	ticketPriorityHigh   = "High"
+	ticketPriorityMedium = "Medium"
+	ticketPriorityLow    = "Low"
+
+	ticketStatusOpen              = "Open"
+	ticketStatusAcknowledged      = "Acknowledged"
+	ticketStatusWaitingOnReporter = "Waiting on reporter"
+	ticketStatusResolved          = "Resolved"
+
+	// defaultMaxDescriptionLength is the number of characters of a ticket description posted when
+	// no maximum is configured. Longer descriptions are attached to the ticket post as a file.
+	defaultMaxDescriptionLength = 4000
+)
+
+// Ticket is an SRE request submitted through the intake dialog.
+type Ticket struct {
+	ID string `json:"id"`
+
+	// Key is the human-friendly key of the ticket, such as SRE-142. Tickets created before keys
+	// existed have none.
+	Key string `json:"key,omitempty"`
+
+	TeamID      string `json:"team_id"`
+	ReporterID  string `json:"reporter_id"`
+	AssigneeID  string `json:"assignee_id,omitempty"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	Status      string `json:"status"`
+
+	// SuggestedPriority is the priority suggested by the ticket's text when the reporter didn't
+	// pick one or picked an inconsistent one, and SuggestedPrioritySignals are what it is based on.
+	SuggestedPriority        string   `json:"suggested_priority,omitempty"`
+	SuggestedPrioritySignals []string `json:"suggested_priority_signals,omitempty"`
+
+	// Category is the kind of request picked in the first step of the built-in intake flow, if any.
+	Category string `json:"category,omitempty"`
+
+	// Service is the affected service picked in the intake dialog, if any.
+	Service string `json:"service,omitempty"`
+
+	// Confidential tickets are never posted to the public SRE channel. Instead, the bot opens a
+	// group message between the reporter, the assignee and the incident commander.
+	Confidential bool `json:"confidential,omitempty"`
+
+	// Anonymous tickets don't show their reporter in posts, integrations and API responses. The
+	// reporter is still stored, for SRE admins.
+	Anonymous bool `json:"anonymous,omitempty"`
+
+	// ChannelID is the channel holding the ticket's root post. For confidential tickets, this is
+	// the group message channel used as the private escalation path.
+	ChannelID string `json:"channel_id"`
+
+	// PostID is the root post of the ticket thread.
+	PostID string `json:"post_id"`
+
+	CreateAt       int64  `json:"create_at"`
+	UpdateAt       int64  `json:"update_at"`
+	AcknowledgedAt int64  `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+	ResolvedAt     int64  `json:"resolved_at,omitempty"`
+	ResolvedBy     string `json:"resolved_by,omitempty"`
+
+	// Revision counts the saves of the ticket. Saving a ticket loaded before another writer saved
+	// it fails with errTicketConflict.
+	Revision int64 `json:"revision,omitempty"`
+
+	// QueuedUntil is when business hours started for a Low or Medium priority ticket submitted
+	// outside them, and QueueAnnounced is set once its responders were notified. The SLA clock of
+	// queued tickets starts at QueuedUntil.
+	QueuedUntil    int64 `json:"queued_until,omitempty"`
+	QueueAnnounced bool  `json:"queue_announced,omitempty"`
+
+	// WaitingSince is when the ticket started waiting on its reporter, and StatusBeforeWaiting is
+	// the status it returns to once the reporter replies. PausedDuration is the total time, in
+	// milliseconds, of the previous waits, during which the SLA clock was paused.
+	WaitingSince        int64  `json:"waiting_since,omitempty"`
+	StatusBeforeWaiting string `json:"status_before_waiting,omitempty"`
+	PausedDuration      int64  `json:"paused_duration,omitempty"`
+
+	// SLABreachedAt is set once the ticket has been escalated for breaching its SLA, so that it is
+	// only escalated once.
+	SLABreachedAt int64 `json:"sla_breached_at,omitempty"`
+
+	// EscalationTier is the number of the last tier of the escalation policy of the ticket's
+	// priority that was executed, so that each tier is only executed once.
+	EscalationTier int `json:"escalation_tier,omitempty"`
+
+	// DueAt is the optional due date of the ticket. DueRemindersSent lists the reminders already
+	// posted before the due date, and OverdueAt is set once the ticket has been flagged overdue.
+	DueAt            int64    `json:"due_at,omitempty"`
+	DueRemindersSent []string `json:"due_reminders_sent,omitempty"`
+	OverdueAt        int64    `json:"overdue_at,omitempty"`
+
+	// PostmortemReviewAt is when the postmortem review of a resolved SEV1 is scheduled, and
+	// PostmortemReminderSent is set once responders have been reminded of it. PostmortemCompletedAt
+	// and PostmortemCompletedBy are set once the postmortem is done.
+	PostmortemReviewAt     int64  `json:"postmortem_review_at,omitempty"`
+	PostmortemReminderSent bool   `json:"postmortem_reminder_sent,omitempty"`
+	PostmortemCompletedAt  int64  `json:"postmortem_completed_at,omitempty"`
+	PostmortemCompletedBy  string `json:"postmortem_completed_by,omitempty"`
+
+	// SatisfactionSurveyPostID is the direct message asking the reporter to rate the resolution of
+	// the ticket, once sent. SatisfactionRating, SatisfactionComment and SatisfactionRatedAt are
+	// the reporter's answer, if any.
+	SatisfactionSurveyPostID string `json:"satisfaction_survey_post_id,omitempty"`
+	SatisfactionRating       string `json:"satisfaction_rating,omitempty"`
+	SatisfactionComment      string `json:"satisfaction_comment,omitempty"`
+	SatisfactionRatedAt      int64  `json:"satisfaction_rated_at,omitempty"`
+
+	// RepeatCount is how many near-identical submissions of the reporter were collapsed into the
+	// ticket, the last of them at LastRepeatAt.
+	RepeatCount  int   `json:"repeat_count,omitempty"`
+	LastRepeatAt int64 `json:"last_repeat_at,omitempty"`
+
+	// StatuspageIncidentID is the id of the Statuspage incident receiving the ticket's customer
+	// updates, if any.
+	StatuspageIncidentID string `json:"statuspage_incident_id,omitempty"`
+
+	// JiraIssueKey is the key of the Jira issue mirroring the ticket, if any.
+	JiraIssueKey string `json:"jira_issue_key,omitempty"`
+
+	// GitHubIssueNumber and GitHubIssueURL identify the GitHub issue mirroring the ticket, if any.
+	GitHubIssueNumber int    `json:"github_issue_number,omitempty"`
+	GitHubIssueURL    string `json:"github_issue_url,omitempty"`
+
+	// Files are the files posted in the ticket's thread.
+	Files []*TicketFile `json:"files,omitempty"`
+
+	// Watchers are the users receiving a direct message when the ticket's status changes or
+	// someone comments on it.
+	Watchers []string `json:"watchers,omitempty"`
+
+	// CustomFields holds the values of the custom fields defined in the configuration by field name.
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+
+	// PendingCustomFields are the names of the required custom fields the reporter is asked for in
+	// the ticket's thread, in order, when the ticket was opened without them.
+	PendingCustomFields []string `json:"pending_custom_fields,omitempty"`
+
+	// DuplicateOf is the id of the ticket this one was merged into as a duplicate, if any, and
+	// DuplicateIDs are the ids of the tickets merged into this one.
+	DuplicateOf  string   `json:"duplicate_of,omitempty"`
+	DuplicateIDs []string `json:"duplicate_ids,omitempty"`
+
+	// Labels are free-form lowercase tags of the ticket, sorted, used to filter tickets.
+	Labels []string `json:"labels,omitempty"`
+
+	// Commits are the commits referencing the ticket's key, as reported by CI.
+	Commits []*TicketCommit `json:"commits,omitempty"`
+
+	// VaultArtifacts are the sensitive files of the ticket stored in the secure vault.
+	VaultArtifacts []*VaultArtifact `json:"vault_artifacts,omitempty"`
+
+	// AlertFingerprint is the fingerprint of the Alertmanager or Grafana alert that opened the
+	// ticket, if any.
+	AlertFingerprint string `json:"alert_fingerprint,omitempty"`
+
+	// SentryIssueID is the id of the Sentry issue that opened the ticket, if any, and
+	// SentryOccurrences is how many of its events were reported while the ticket was unresolved.
+	SentryIssueID     string `json:"sentry_issue_id,omitempty"`
+	SentryOccurrences int    `json:"sentry_occurrences,omitempty"`
+
+	// DeleteAt and DeletedBy are set while the ticket is in the trash.
+	DeleteAt  int64  `json:"delete_at,omitempty"`
+	DeletedBy string `json:"deleted_by,omitempty"`
+
+	// Timeline holds the thread posts responders added to the ticket's timeline.
+	Timeline []*TimelineEntry `json:"timeline,omitempty"`
+
+	// Activity holds the events of the ticket that aren't recorded elsewhere, such as escalations
+	// and syncs with integrations, oldest first.
+	Activity []*TicketActivity `json:"activity,omitempty"`
+
+	// Comments holds the replies posted in the ticket's thread, oldest first.
+	Comments []*TicketComment `json:"comments,omitempty"`
+
+	// History holds the changes of the ticket's priority, assignee and status, oldest first.
+	History []*TicketChange `json:"history,omitempty"`
+}
+
+// saveTicket stores the ticket in the active ticket store, as its next revision. It fails with
+// errTicketConflict if the ticket was saved by someone else since it was loaded.
+func (p *Plugin) saveTicket(ticket *Ticket) error {
+	ticket.UpdateAt = model.GetMillis()
+	ticket.Revision++
+
+	if err := p.ticketStore().SaveTicket(ticket); err != nil {
+		ticket.Revision--
+		return err
+	}
+	p.ticketCache.update(ticket)
+	p.invalidateTicketCache(ticket.ID)
+
+	// A stale search index only affects search results, so it doesn't fail the save.
+	if err := p.indexTicket(ticket); err != nil {
+		p.API.LogWarn("Failed to index ticket for search", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	if err := p.refreshStatusBanner(ticket.TeamID); err != nil {
+		p.API.LogWarn("Failed to refresh SRE channel status banner", "team_id", ticket.TeamID, "err", err.Error())
+	}
+
+	return nil
+}
+
+// getTicket returns the ticket with the given id, or nil if it does not exist.
+func (p *Plugin) getTicket(ticketID string) (*Ticket, error) {
+	return p.ticketStore().GetTicket(ticketID)
+}
+
+// getTicketByPostID returns the ticket whose root post is postID, or nil if there is none.
+func (p *Plugin) getTicketByPostID(postID string) (*Ticket, error) {
+	return p.ticketStore().GetTicketByPostID(postID)
+}
+
+// listTickets returns every stored ticket.
+func (p *Plugin) listTickets() ([]*Ticket, error) {
+	return p.ticketStore().ListTickets()
+}
+
+// createTicket posts the ticket's root post and stores the ticket. Public tickets are posted to
+// the team's SRE channel, or its fallback channel if it is gone, while confidential tickets are
+// escalated through a group message.
+func (p *Plugin) createTicket(ticket *Ticket) error {
+	configuration := p.getConfiguration()
+
+	ticket.ID = model.NewId()
+	// A ticket without a key is still better than no ticket at all.
+	if key, err := p.nextTicketKey(); err != nil {
+		p.API.LogWarn("Failed to assign ticket key", "ticket_id", ticket.ID, "err", err.Error())
+	} else {
+		ticket.Key = key
+	}
+	ticket.Status = ticketStatusOpen
+	ticket.CreateAt = model.GetMillis()
+	configuration.queueOutsideBusinessHours(ticket)
+
+	newPost := func(channelID string) (*model.Post, error) {
+		ticket.ChannelID = channelID
+
+		var fileIDs []string
+		if descriptionOverflows(ticket, configuration.maxDescriptionLength()) {
+			fileInfo, appErr := p.API.UploadFile([]byte(ticketSubmissionText(ticket)), channelID, fmt.Sprintf("sre-request-%s.md", ticket.ID))
+			if appErr != nil {
+				return nil, errors.Wrap(appErr, "failed to upload ticket description")
+			}
+			fileIDs = append(fileIDs, fileInfo.Id)
+		}
+
+		post := &model.Post{
+			UserId:    p.botID,
+			ChannelId: channelID,
+			Message:   ticketMessage(ticket, configuration.maxDescriptionLength(), !configuration.postPriorityEnabled),
+			FileIds:   fileIDs,
+			Props: model.StringInterface{
+				"attachments": []*model.SlackAttachment{p.ticketAttachment(ticket)},
+			},
+		}
+		configuration.setTicketPostPriority(post, ticket)
+
+		return post, nil
+	}
+
+	var post *model.Post
+	if ticket.Confidential {
+		channelID, err := p.ensureConfidentialChannel(ticket)
+		if err != nil {
+			return errors.Wrap(err, "failed to create confidential escalation channel")
+		}
+
+		post, err = newPost(channelID)
+		if err != nil {
+			return err
+		}
+
+		var appErr *model.AppError
+		if post, appErr = p.API.CreatePost(post); appErr != nil {
+			return errors.Wrap(appErr, "failed to create ticket post")
+		}
+	} else {
+		var err error
+		if post, err = p.createTicketRootPost(ticket, newPost); err != nil {
+			return errors.Wrap(err, "failed to create ticket post")
+		}
+	}
+	ticket.PostID = post.Id
+
+	if err := p.saveTicket(ticket); err != nil {
+		return err
+	}
+	if ticket.Key != "" {
+		if err := p.saveTicketReference(ticket); err != nil {
+			p.API.LogWarn("Failed to index ticket key", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+	reporterID := ticket.ReporterID
+	if ticket.Anonymous {
+		reporterID = ""
+	}
+	p.sendTicketEvent(ticketEventCreated, ticket, reporterID)
+	if err := p.sendReporterConfirmation(ticket); err != nil {
+		p.API.LogWarn("Failed to send reporter confirmation", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return nil
+}
+
+// maxDescriptionLength returns the number of characters of a ticket description posted in the
+// ticket post.
+func (c *configuration) maxDescriptionLength() int {
+	if c.MaxDescriptionLength <= 0 {
+		return defaultMaxDescriptionLength
+	}
+
+	return c.MaxDescriptionLength
+}
+
+// descriptionOverflows reports whether the ticket's description is too long to be posted in full.
+func descriptionOverflows(ticket *Ticket, maxDescriptionLength int) bool {
+	return utf8.RuneCountInString(ticket.Description) > maxDescriptionLength
+}
+
+// ticketSubmissionText renders the complete submission of a ticket, attached to the ticket post
+// when its description is too long to be posted.
+func ticketSubmissionText(ticket *Ticket) string {
+	return fmt.Sprintf("# %s\n\nPriority: %s\n\n%s\n", ticket.Summary, ticket.Priority, ticket.Description)
+}
+
+// ticketMessage renders the message of the ticket's root post, truncating the description to
+// maxDescriptionLength characters. urgentLabel labels urgent tickets in the message itself, for
+// servers without message priorities.
+func ticketMessage(ticket *Ticket, maxDescriptionLength int, urgentLabel bool) string {
+	description := ticket.Description
+	if descriptionOverflows(ticket, maxDescriptionLength) {
+		description = string([]rune(description)[:maxDescriptionLength]) + "…\n\n_The description was truncated, the full text is attached._"
+	}
+
+	title := "SRE request"
+	if ticket.Key != "" {
+		title = ticket.Key
+	}
+	message := fmt.Sprintf("#### %s: %s\n%s", title, ticket.Summary, description)
+	if urgentLabel && isUrgentTicket(ticket) {
+		message = ":rotating_light: **URGENT**\n" + message
+	}
+	if ticket.Confidential {
+		message += "\n\n_This request is confidential and is only shared with the participants of this conversation._"
+	}
+
+	return message
+}
+
+// setTicketStatus transitions the ticket to the given status, recording the change and when and by
+// whom the ticket was acknowledged or resolved.
+func setTicketStatus(ticket *Ticket, status, userID string) {
+	now := model.GetMillis()
+	recordTicketChange(ticket, ticketFieldStatus, ticket.Status, status, userID, now)
+
+	// Track how long the ticket waits on its reporter, since the SLA clock is paused meanwhile.
+	if ticket.Status == ticketStatusWaitingOnReporter && status != ticketStatusWaitingOnReporter {
+		ticket.PausedDuration = ticket.pausedDuration(now)
+		ticket.WaitingSince = 0
+		ticket.StatusBeforeWaiting = ""
+	} else if ticket.Status != ticketStatusWaitingOnReporter && status == ticketStatusWaitingOnReporter {
+		ticket.WaitingSince = now
+		ticket.StatusBeforeWaiting = ticket.Status
+	}
+	ticket.Status = status
+
+	switch status {
+	case ticketStatusAcknowledged:
+		ticket.AcknowledgedAt = model.GetMillis()
+		ticket.AcknowledgedBy = userID
+	case ticketStatusResolved:
+		ticket.ResolvedAt = model.GetMillis()
+		ticket.ResolvedBy = userID
+	}
+}
+
+// ticketAttachment renders the ticket's fields as a message attachment, in the server language.
+func (p *Plugin) ticketAttachment(ticket *Ticket) *model.SlackAttachment {
+	localizer := p.serverLocalizer()
+
+	assignee := localize(localizer, &i18n.Message{ID: "ticket.unassigned", Other: "_Unassigned_"}, nil)
+	if ticket.AssigneeID != "" {
+		assignee = p.mentionUser(ticket.AssigneeID)
+	}
+
+	fields := []*model.SlackAttachmentField{{
+		Title: "Priority",
+		Value: localizeLabel(localizer, priorityLabels, ticket.Priority),
+		Short: true,
+	}, {
+		Title: "Status",
+		Value: localizeLabel(localizer, statusLabels, ticket.Status),
+		Short: true,
+	}, {
+		Title: "Reporter",
+		Value: p.reporterMention(ticket),
+		Short: true,
+	}, {
+		Title: "Assignee",
+		Value: assignee,
+		Short: true,
+	}}
+
+	if ticket.hasPrioritySuggestion() && ticket.Status != ticketStatusResolved {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Suggested priority",
+			Value: ticket.prioritySuggestion(),
+			Short: true,
+		})
+	}
+
+	if category, ok := getIntakeCategory(ticket.Category); ok {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Category",
+			Value: category.DisplayName,
+			Short: true,
+		})
+	}
+
+	if ticket.DueAt != 0 {
+		due := formatDueDate(ticket.DueAt)
+		if ticket.isOverdue(model.GetMillis()) {
+			due = ":warning: Overdue since " + due
+		}
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Due",
+			Value: due,
+			Short: true,
+		})
+	}
+
+	if ticket.PostmortemCompletedAt != 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Postmortem",
+			Value: "Done",
+			Short: true,
+		})
+	} else if ticket.PostmortemReviewAt != 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Postmortem",
+			Value: "Review " + formatReviewTime(ticket.PostmortemReviewAt),
+			Short: true,
+		})
+	}
+
+	if expected := p.getConfiguration().expectedResponse(ticket); expected != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Expected response",
+			Value: expected,
+			Short: true,
+		})
+	}
+
+	if paused := ticket.pausedDuration(model.GetMillis()); paused > 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "SLA paused",
+			Value: formatPausedDuration(paused),
+			Short: true,
+		})
+	}
+
+	if ticket.RepeatCount > 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Submitted",
+			Value: fmt.Sprintf("%d times", ticket.RepeatCount+1),
+			Short: true,
+		})
+	}
+
+	if ticket.JiraIssueKey != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Jira",
+			Value: fmt.Sprintf("[%s](%s)", ticket.JiraIssueKey, p.getConfiguration().jiraIssueURL(ticket.JiraIssueKey)),
+			Short: true,
+		})
+	}
+
+	if ticket.GitHubIssueNumber != 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "GitHub",
+			Value: fmt.Sprintf("[#%d](%s)", ticket.GitHubIssueNumber, ticket.GitHubIssueURL),
+			Short: true,
+		})
+	}
+
+	if len(ticket.VaultArtifacts) > 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Vault artifacts",
+			Value: vaultArtifactsValue(ticket),
+			Short: true,
+		})
+	}
+
+	if ticket.SentryIssueID != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Sentry",
+			Value: fmt.Sprintf("Issue %s, %d occurrences", ticket.SentryIssueID, ticket.SentryOccurrences),
+			Short: true,
+		})
+	}
+
+	if len(ticket.Watchers) > 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Watchers",
+			Value: p.watchersValue(ticket),
+			Short: true,
+		})
+	}
+
+	for _, field := range p.getConfiguration().customFields {
+		if value, ok := ticket.CustomFields[field.Name]; ok {
+			fields = append(fields, &model.SlackAttachmentField{
+				Title: field.DisplayName,
+				Value: value,
+				Short: field.Type != customFieldTypeTextarea,
+			})
+		}
+	}
+
+	if ticket.DuplicateOf != "" {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Duplicate of",
+			Value: p.duplicateOfValue(ticket),
+			Short: true,
+		})
+	}
+
+	if len(ticket.DuplicateIDs) > 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Duplicates",
+			Value: fmt.Sprintf("%d tickets", len(ticket.DuplicateIDs)),
+			Short: true,
+		})
+	}
+
+	if len(ticket.Labels) > 0 {
+		fields = append(fields, &model.SlackAttachmentField{
+			Title: "Labels",
+			Value: formatLabels(ticket.Labels),
+			Short: true,
+		})
+	}
+
+	// Field titles are identified by their English text, such as "ticket.field.sla_paused".
+	for _, field := range fields {
+		id := "ticket.field." + strings.ReplaceAll(strings.ToLower(field.Title), " ", "_")
+		field.Title = localize(localizer, &i18n.Message{ID: id, Other: field.Title}, nil)
+	}
+
+	return &model.SlackAttachment{
+		Fields:  fields,
+		Actions: ticketActions(ticket),
+		Footer:  ticketFooter(ticket),
+	}
+}
+
+// ticketFooter names the ticket in its attachment, with both its key and its id.
+func ticketFooter(ticket *Ticket) string {
+	if ticket.Key == "" {
+		return fmt.Sprintf("Ticket %s", ticket.ID)
+	}
+
+	return fmt.Sprintf("Ticket %s (%s)", ticket.Key, ticket.ID)
+}
+
+// mentionUser returns an @-mention for the given user, falling back to the user id if the user
+// cannot be found.
+func (p *Plugin) mentionUser(userID string) string {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get user for mention", "user_id", userID, "err", appErr.Error())
+		return userID
+	}
+
+	return "@" + user.Username
+}
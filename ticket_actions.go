@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	ticketActionAcknowledge = "acknowledge"
+	ticketActionEscalate    = "escalate"
+	ticketActionTimeline    = "timeline"
+	ticketActionTriage      = "triage"
+	ticketActionAssign      = "assign"
+	ticketActionHistory     = "history"
+	ticketActionWait        = "wait"
+	ticketActionResume      = "resume"
+
+	// ticketActionPostMenuContext marks the ticket actions sent by the post menu of the web app.
+	ticketActionPostMenuContext = "post_menu"
+)
+
+// TimelineEntry is a post from a ticket thread that a responder added to the ticket's timeline.
+type TimelineEntry struct {
+	PostID   string `json:"post_id"`
+	UserID   string `json:"user_id"`
+	Message  string `json:"message"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// TicketComment is a reply posted in a ticket's thread.
+type TicketComment struct {
+	PostID   string `json:"post_id"`
+	UserID   string `json:"user_id"`
+	Message  string `json:"message"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// ticketActionURL returns the integration URL of the given ticket action. The endpoints accept a
+// PostActionIntegrationRequest for any post of a ticket thread, so they back both the buttons of
+// the ticket post and post menu actions.
+func ticketActionURL(action string) string {
+	return pluginCallbackURL("/interactive/ticket/" + action)
+}
+
+// ticketActions returns the buttons attached to a ticket's root post.
+func ticketActions(ticket *Ticket) []*model.PostAction {
+	var actions []*model.PostAction
+	if ticket.Status == ticketStatusOpen {
+		actions = append(actions, &model.PostAction{
+			Id:          ticketActionAcknowledge,
+			Type:        model.PostActionTypeButton,
+			Name:        "Acknowledge",
+			Style:       "primary",
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionAcknowledge)},
+		})
+	}
+
+	switch ticket.Status {
+	case ticketStatusOpen, ticketStatusAcknowledged:
+		actions = append(actions, &model.PostAction{
+			Id:          ticketActionWait,
+			Type:        model.PostActionTypeButton,
+			Name:        "Wait on reporter",
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionWait)},
+		})
+	case ticketStatusWaitingOnReporter:
+		actions = append(actions, &model.PostAction{
+			Id:          ticketActionResume,
+			Type:        model.PostActionTypeButton,
+			Name:        "Resume",
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionResume)},
+		})
+	}
+
+	if ticket.Status != ticketStatusResolved {
+		actions = append(actions, &model.PostAction{
+			Id:          ticketActionTriage,
+			Type:        model.PostActionTypeButton,
+			Name:        "Triage",
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionTriage)},
+		}, &model.PostAction{
+			Id:          ticketActionEscalate,
+			Type:        model.PostActionTypeButton,
+			Name:        "Escalate",
+			Style:       "danger",
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionEscalate)},
+		}, &model.PostAction{
+			Id:          ticketActionSnooze,
+			Type:        model.PostActionTypeSelect,
+			Name:        "Snooze",
+			Options:     snoozeOptions,
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionSnooze)},
+		}, &model.PostAction{
+			Id:          ticketActionChangePriority,
+			Type:        model.PostActionTypeSelect,
+			Name:        "Change Priority",
+			Options:     priorityChangeOptions,
+			Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionChangePriority)},
+		})
+	}
+
+	actions = append(actions, &model.PostAction{
+		Id:          ticketActionHistory,
+		Type:        model.PostActionTypeButton,
+		Name:        "History",
+		Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionHistory)},
+	}, &model.PostAction{
+		Id:          ticketActionWatch,
+		Type:        model.PostActionTypeButton,
+		Name:        "Watch",
+		Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionWatch)},
+	}, &model.PostAction{
+		Id:          ticketActionUnwatch,
+		Type:        model.PostActionTypeButton,
+		Name:        "Unwatch",
+		Integration: &model.PostActionIntegration{URL: ticketActionURL(ticketActionUnwatch)},
+	})
+
+	return actions
+}
+
+// handleTicketAction runs a quick action on the ticket whose thread contains the post the action
+// was invoked on, so that responders can act on any post of the thread.
+func (p *Plugin) handleTicketAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.PostActionIntegrationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		p.API.LogError("Failed to decode PostActionIntegrationRequest", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	// Actions attached to ephemeral posts name their ticket in the context, since ephemeral posts
+	// cannot be looked up.
+	var post *model.Post
+	var ticket *Ticket
+	if ticketID, _ := request.Context["ticket_id"].(string); ticketID != "" {
+		ticket, err = p.getTicket(ticketID)
+	} else {
+		var appErr *model.AppError
+		post, appErr = p.API.GetPost(request.PostId)
+		if appErr != nil {
+			p.API.LogError("Failed to get post for ticket action", "post_id", request.PostId, "err", appErr.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rootID := post.RootId
+		if rootID == "" {
+			rootID = post.Id
+		}
+		ticket, err = p.getTicketByPostID(rootID)
+	}
+	if err != nil {
+		p.API.LogError("Failed to get ticket for ticket action", "post_id", request.PostId, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if ticket == nil || !p.canViewTicket(userID, ticket) {
+		p.writeTicketActionResponse(w, &request, post, userID, "This post is not part of an SRE request thread.")
+		return
+	}
+	if !p.teamEnabled(ticket.TeamID) {
+		p.writeTicketActionResponse(w, &request, post, userID, "The plugin is disabled in this team.")
+		return
+	}
+
+	var ephemeralText string
+	switch action := mux.Vars(r)["action"]; action {
+	case ticketActionAcknowledge:
+		ephemeralText, err = p.acknowledgeTicket(ticket, userID)
+	case ticketActionEscalate:
+		ephemeralText, err = p.escalateTicket(ticket, userID)
+	case ticketActionTimeline:
+		if post == nil {
+			ephemeralText = "Only posts of the request thread can be added to the timeline."
+			break
+		}
+		ephemeralText, err = p.addToTimeline(ticket, post, userID)
+	case ticketActionTriage:
+		ephemeralText, err = p.sendTriageView(ticket, userID)
+	case ticketActionAssign:
+		assigneeID, _ := request.Context["assignee_id"].(string)
+		ephemeralText, err = p.assignTicket(ticket, assigneeID, userID)
+	case ticketActionPriority:
+		priority, _ := request.Context["priority"].(string)
+		ephemeralText, err = p.openPriorityChangeDialog(ticket, priority, userID, request.TriggerId)
+	case ticketActionChangePriority:
+		priority, _ := request.Context["selected_option"].(string)
+		ephemeralText, err = p.openPriorityChangeDialog(ticket, priority, userID, request.TriggerId)
+	case ticketActionHistory:
+		ephemeralText, err = p.sendHistoryView(ticket)
+	case ticketActionWait:
+		ephemeralText, err = p.waitOnReporter(ticket, userID)
+	case ticketActionResume:
+		ephemeralText, err = p.resumeWaitingTicket(ticket, userID)
+	case ticketActionSnooze:
+		option, _ := request.Context["selected_option"].(string)
+		duration, parseErr := parseDuration(option)
+		if parseErr != nil || duration <= 0 {
+			ephemeralText = fmt.Sprintf("Invalid snooze duration %q.", option)
+			break
+		}
+		ephemeralText, err = p.scheduleReminder(ticket, duration, userID)
+	case ticketActionWatch:
+		ephemeralText, err = p.watchTicket(ticket, userID)
+	case ticketActionUnwatch:
+		ephemeralText, err = p.unwatchTicket(ticket, userID)
+	case ticketActionPostmortemSchedule:
+		reviewAt, _ := request.Context["review_at"].(float64)
+		ephemeralText, err = p.schedulePostmortemReview(ticket, int64(reviewAt), userID)
+	case ticketActionPostmortemDone:
+		ephemeralText, err = p.completePostmortem(ticket, userID)
+	case ticketActionRate:
+		rating, _ := request.Context["rating"].(string)
+		ephemeralText, err = p.rateTicket(ticket, rating, userID, request.TriggerId)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown ticket action: %s", action), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errTicketConflict) {
+		ephemeralText = ticketConflictMessage
+	} else if err != nil {
+		p.API.LogError("Failed to run ticket action", "ticket_id", ticket.ID, "err", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	p.writeTicketActionResponse(w, &request, post, userID, ephemeralText)
+}
+
+// writeTicketActionResponse replies to the user who ran a ticket action. The server only shows the
+// ephemeral text of the actions it relays from post buttons, so the reply to the post menu actions
+// of the web app is also sent as an ephemeral post in the thread.
+func (p *Plugin) writeTicketActionResponse(w http.ResponseWriter, request *model.PostActionIntegrationRequest, post *model.Post, userID, text string) {
+	if postMenu, _ := request.Context[ticketActionPostMenuContext].(bool); postMenu && post != nil && text != "" {
+		rootID := post.RootId
+		if rootID == "" {
+			rootID = post.Id
+		}
+		p.API.SendEphemeralPost(userID, &model.Post{
+			UserId:    p.botID,
+			ChannelId: post.ChannelId,
+			RootId:    rootID,
+			Message:   text,
+		})
+	}
+
+	p.writeJSON(w, &model.PostActionIntegrationResponse{
+		EphemeralText: text,
+	})
+}
+
+func (p *Plugin) acknowledgeTicket(ticket *Ticket, userID string) (string, error) {
+	if !p.canRespondToTicket(userID, ticket) {
+		return "You are not allowed to acknowledge this request.", nil
+	}
+	if ticket.Status != ticketStatusOpen {
+		return fmt.Sprintf("This request is already %s.", strings.ToLower(ticket.Status)), nil
+	}
+
+	var unchanged string
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if !p.canRespondToTicket(userID, ticket) {
+			unchanged = "You are not allowed to acknowledge this request."
+			return errTicketUnchanged
+		}
+		if ticket.Status != ticketStatusOpen {
+			unchanged = fmt.Sprintf("This request is already %s.", strings.ToLower(ticket.Status))
+			return errTicketUnchanged
+		}
+
+		setTicketStatus(ticket, ticketStatusAcknowledged, userID)
+		if ticket.AssigneeID == "" {
+			setTicketAssignee(ticket, userID, userID, ticket.AcknowledgedAt)
+		}
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return unchanged, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return "", err
+	}
+
+	p.sendTicketEvent(ticketEventAcknowledged, ticket, userID)
+
+	if err := p.postTicketTransition(ticket, localize(p.serverLocalizer(), &i18n.Message{
+		ID:    "ticket.acknowledged",
+		Other: ":eyes: {{.User}} acknowledged this request.",
+	}, map[string]interface{}{"User": p.mentionUser(userID)})); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+func (p *Plugin) resolveTicket(ticket *Ticket, userID string) (string, error) {
+	if ticket.Status == ticketStatusResolved {
+		return "This request is already resolved.", nil
+	}
+
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.Status == ticketStatusResolved {
+			return errTicketUnchanged
+		}
+		setTicketStatus(ticket, ticketStatusResolved, userID)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return "This request is already resolved.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return "", err
+	}
+
+	p.sendTicketEvent(ticketEventResolved, ticket, userID)
+
+	if err := p.postTicketTransition(ticket, localize(p.serverLocalizer(), &i18n.Message{
+		ID:    "ticket.resolved",
+		Other: ":white_check_mark: {{.User}} resolved this request.",
+	}, map[string]interface{}{"User": p.mentionUser(userID)})); err != nil {
+		return "", err
+	}
+	p.proposePostmortemReview(ticket)
+	p.sendSatisfactionSurvey(ticket)
+
+	return "", nil
+}
+
+func (p *Plugin) escalateTicket(ticket *Ticket, userID string) (string, error) {
+	if !p.canRespondToTicket(userID, ticket) {
+		return "You are not allowed to escalate this request.", nil
+	}
+	if ticket.Status == ticketStatusResolved {
+		return "This request is already resolved.", nil
+	}
+
+	localizer := p.serverLocalizer()
+	message := localize(localizer, &i18n.Message{
+		ID:    "ticket.escalated",
+		Other: ":arrow_double_up: {{.User}} escalated this request.",
+	}, map[string]interface{}{"User": p.mentionUser(userID)})
+	if mentions := p.ticketEscalationMentions(ticket, p.ticketDirectMessage(ticket, message)); mentions != "" {
+		message += "\n" + localize(localizer, takeALookMessage, map[string]interface{}{"Mentions": mentions})
+	}
+
+	if err := p.postTicketTransition(ticket, message); err != nil {
+		return "", err
+	}
+
+	// The escalation was already posted, so it is recorded even if the ticket changed meanwhile.
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		recordTicketActivity(ticket, timelineEventEscalation, userID, "Escalated by "+p.mentionUser(userID))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	p.sendTicketEvent(ticketEventEscalated, ticket, userID)
+
+	return "", nil
+}
+
+func (p *Plugin) addToTimeline(ticket *Ticket, post *model.Post, userID string) (string, error) {
+	for _, entry := range ticket.Timeline {
+		if entry.PostID == post.Id {
+			return "This post is already on the timeline.", nil
+		}
+	}
+
+	_, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		for _, entry := range ticket.Timeline {
+			if entry.PostID == post.Id {
+				return errTicketUnchanged
+			}
+		}
+		ticket.Timeline = append(ticket.Timeline, &TimelineEntry{
+			PostID:   post.Id,
+			UserID:   userID,
+			Message:  post.Message,
+			CreateAt: post.CreateAt,
+		})
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return "This post is already on the timeline.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return "Added the post to the request timeline.", nil
+}
+
+// updateTicketPost re-renders the ticket's root post after the ticket changed. While shedding load,
+// the refresh is deferred until the server recovers.
+func (p *Plugin) updateTicketPost(ticket *Ticket) error {
+	if p.sheddingLoad() {
+		p.loadShedder.deferRefresh(ticket.ID)
+		return nil
+	}
+
+	post, appErr := p.API.GetPost(ticket.PostID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get ticket post")
+	}
+
+	configuration := p.getConfiguration()
+	post.Message = ticketMessage(ticket, configuration.maxDescriptionLength(), !configuration.postPriorityEnabled)
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{p.ticketAttachment(ticket)})
+
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		return errors.Wrap(appErr, "failed to update ticket post")
+	}
+
+	return nil
+}
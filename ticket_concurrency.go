@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxTicketUpdateAttempts bounds how many times updateTicket applies its update to a freshly
+	// loaded ticket after losing a race with another writer.
+	maxTicketUpdateAttempts = 3
+
+	// ticketConflictMessage tells users their change lost a race with another writer.
+	ticketConflictMessage = "This ticket was updated by someone else in the meantime. Please review it and try again."
+)
+
+// errTicketConflict is returned when saving a ticket that another writer saved since it was loaded.
+var errTicketConflict = errors.New("ticket was updated by someone else")
+
+// errTicketUnchanged is returned by updates to leave the ticket unsaved, typically because the
+// ticket as another writer saved it no longer needs the update.
+var errTicketUnchanged = errors.New("ticket needs no update")
+
+// checkTicketRevision returns errTicketConflict unless the ticket's revision is newer than the
+// revision of the stored ticket data, if any. Stores call it within their compare-and-set, so that
+// concurrent writers of the same revision cannot both succeed.
+func checkTicketRevision(stored []byte, ticket *Ticket) error {
+	if len(stored) == 0 {
+		return nil
+	}
+
+	var storedTicket struct {
+		Revision int64 `json:"revision"`
+	}
+	if err := json.Unmarshal(stored, &storedTicket); err != nil {
+		return errors.Wrap(err, "failed to unmarshal stored ticket")
+	}
+	if storedTicket.Revision >= ticket.Revision {
+		return errTicketConflict
+	}
+
+	return nil
+}
+
+// updateTicket applies the update to the ticket and saves it. If another writer saved the ticket in
+// the meantime, the update is applied again to the ticket as they saved it, so updates must only
+// change the ticket: posts and notifications follow the successful save. It returns the saved
+// ticket, or the update's error, such as errTicketUnchanged.
+func (p *Plugin) updateTicket(ticket *Ticket, update func(ticket *Ticket) error) (*Ticket, error) {
+	for attempt := 1; ; attempt++ {
+		if err := update(ticket); err != nil {
+			return nil, err
+		}
+
+		err := p.saveTicket(ticket)
+		if err == nil {
+			return ticket, nil
+		}
+		if !errors.Is(err, errTicketConflict) || attempt == maxTicketUpdateAttempts {
+			return nil, err
+		}
+
+		ticketID := ticket.ID
+		if ticket, err = p.getTicket(ticketID); err != nil {
+			return nil, err
+		}
+		if ticket == nil {
+			return nil, errors.Errorf("ticket %s no longer exists", ticketID)
+		}
+	}
+}
+
+// ticketUpdateError returns the message telling the user a ticket update failed: that someone else
+// updated the ticket in the meantime, or else the given message.
+func ticketUpdateError(err error, message string) string {
+	if errors.Is(err, errTicketConflict) {
+		return ticketConflictMessage
+	}
+
+	return message
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+// ticketUpdateTestAPI fails the search index lookups, which only log a warning, and accepts the
+// cache invalidations of saved tickets.
+type ticketUpdateTestAPI struct {
+	plugin.API
+}
+
+func (a *ticketUpdateTestAPI) KVGet(string) ([]byte, *model.AppError) {
+	return nil, model.NewAppError("KVGet", "test.kv_get", nil, "", 500)
+}
+
+func (a *ticketUpdateTestAPI) PublishPluginClusterEvent(model.PluginClusterEvent, model.PluginClusterEventSendOptions) error {
+	return nil
+}
+
+func (a *ticketUpdateTestAPI) LogWarn(string, ...interface{}) {}
+
+// revisionTestStore stores tickets as JSON, checking their revision like the real stores. Its
+// beforeSave hook runs ahead of every save, to simulate concurrent writers.
+type revisionTestStore struct {
+	tickets    map[string][]byte
+	beforeSave func()
+}
+
+func (s *revisionTestStore) SaveTicket(ticket *Ticket) error {
+	if s.beforeSave != nil {
+		s.beforeSave()
+	}
+	if err := checkTicketRevision(s.tickets[ticket.ID], ticket); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+	s.tickets[ticket.ID] = data
+
+	return nil
+}
+
+func (s *revisionTestStore) GetTicket(ticketID string) (*Ticket, error) {
+	data, ok := s.tickets[ticketID]
+	if !ok {
+		return nil, nil
+	}
+
+	var ticket Ticket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+func (s *revisionTestStore) GetTicketByPostID(string) (*Ticket, error) { return nil, nil }
+func (s *revisionTestStore) ListTickets() ([]*Ticket, error)           { return nil, nil }
+func (s *revisionTestStore) DeleteTicket(*Ticket) error                { return nil }
+
+// write stores the ticket as another writer would, bypassing the revision check.
+func (s *revisionTestStore) write(t *testing.T, ticket *Ticket) {
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.tickets[ticket.ID] = data
+}
+
+func newTicketUpdateTestPlugin(store *revisionTestStore) *Plugin {
+	api := &ticketUpdateTestAPI{}
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.client = pluginapi.NewClient(api, nil)
+	p.configuration = &configuration{ticketStore: store}
+
+	return p
+}
+
+func TestCheckTicketRevision(t *testing.T) {
+	for name, test := range map[string]struct {
+		stored   string
+		revision int64
+		err      error
+	}{
+		"new ticket":        {revision: 1},
+		"next revision":     {stored: `{"revision": 3}`, revision: 4},
+		"same revision":     {stored: `{"revision": 3}`, revision: 3, err: errTicketConflict},
+		"stale revision":    {stored: `{"revision": 5}`, revision: 4, err: errTicketConflict},
+		"stored before any": {stored: `{"id": "ticket"}`, revision: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := checkTicketRevision([]byte(test.stored), &Ticket{Revision: test.revision})
+			if !errors.Is(err, test.err) {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+
+	t.Run("invalid stored ticket", func(t *testing.T) {
+		err := checkTicketRevision([]byte("{"), &Ticket{Revision: 1})
+		if err == nil || errors.Is(err, errTicketConflict) {
+			t.Errorf("got error %v, want a decoding error", err)
+		}
+	})
+}
+
+func TestUpdateTicket(t *testing.T) {
+	t.Run("retries on the ticket saved by another writer", func(t *testing.T) {
+		store := &revisionTestStore{tickets: map[string][]byte{}}
+		store.write(t, &Ticket{ID: "ticket", Revision: 1})
+		p := newTicketUpdateTestPlugin(store)
+
+		loaded, err := p.getTicket("ticket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.write(t, &Ticket{ID: "ticket", Revision: 2, Watchers: []string{"other"}})
+
+		attempts := 0
+		saved, err := p.updateTicket(loaded, func(ticket *Ticket) error {
+			attempts++
+			ticket.Watchers = append(ticket.Watchers, "user")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 2 {
+			t.Errorf("applied the update %d times, want 2", attempts)
+		}
+		if saved.Revision != 3 || len(saved.Watchers) != 2 {
+			t.Errorf("saved revision %d with watchers %v, want revision 3 with both watchers", saved.Revision, saved.Watchers)
+		}
+
+		stored, err := p.getTicket("ticket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Revision != 3 || len(stored.Watchers) != 2 {
+			t.Errorf("stored revision %d with watchers %v, want revision 3 with both watchers", stored.Revision, stored.Watchers)
+		}
+	})
+
+	t.Run("gives up after losing every race", func(t *testing.T) {
+		store := &revisionTestStore{tickets: map[string][]byte{}}
+		store.write(t, &Ticket{ID: "ticket", Revision: 1})
+		p := newTicketUpdateTestPlugin(store)
+
+		loaded, err := p.getTicket("ticket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		revision := int64(1)
+		store.beforeSave = func() {
+			revision++
+			store.write(t, &Ticket{ID: "ticket", Revision: revision})
+		}
+
+		attempts := 0
+		_, err = p.updateTicket(loaded, func(ticket *Ticket) error {
+			attempts++
+			return nil
+		})
+		if !errors.Is(err, errTicketConflict) {
+			t.Errorf("got error %v, want %v", err, errTicketConflict)
+		}
+		if attempts != maxTicketUpdateAttempts {
+			t.Errorf("applied the update %d times, want %d", attempts, maxTicketUpdateAttempts)
+		}
+	})
+
+	t.Run("leaves unchanged tickets unsaved", func(t *testing.T) {
+		store := &revisionTestStore{tickets: map[string][]byte{}}
+		store.write(t, &Ticket{ID: "ticket", Revision: 1})
+		p := newTicketUpdateTestPlugin(store)
+		store.beforeSave = func() {
+			t.Error("saved a ticket its update left unchanged")
+		}
+
+		loaded, err := p.getTicket("ticket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = p.updateTicket(loaded, func(ticket *Ticket) error {
+			return errTicketUnchanged
+		}); !errors.Is(err, errTicketUnchanged) {
+			t.Errorf("got error %v, want %v", err, errTicketUnchanged)
+		}
+	})
+}
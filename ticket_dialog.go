@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const (
+	dialogElementNameSummary      = "summary"
+	dialogElementNameDescription  = "description"
+	dialogElementNamePriority     = "priority"
+	dialogElementNameAssignee     = "assignee"
+	dialogElementNameConfidential = "confidential"
+	dialogElementNameAnonymous    = "anonymous"
+	dialogElementNameLabels       = "labels"
+	dialogElementNameService      = "service"
+)
+
+// getTicketDialog returns the intake dialog used to submit the details of an SRE request: the form
+// defined in the plugin settings if any, or the built-in form for the given category otherwise,
+// translated by the localizer.
+func (p *Plugin) getTicketDialog(category string, localizer *i18n.Localizer) model.Dialog {
+	configuration := p.getConfiguration()
+
+	var dialog model.Dialog
+	if configuration.dialog != nil {
+		dialog = *configuration.dialog
+	} else {
+		titleID := "dialog.srerequest.title"
+		if _, ok := getIntakeCategory(category); ok {
+			titleID = "intake.category." + category
+		}
+		dialog = localizeDialog(localizer, getBuiltInDialog(category), titleID)
+	}
+
+	// Custom fields are labeled by the admins, so they are not translated.
+	dialog.Elements = append([]model.DialogElement(nil), dialog.Elements...)
+	for _, field := range configuration.customFields {
+		dialog.Elements = append(dialog.Elements, field.dialogElement())
+	}
+
+	return dialog
+}
+
+// priorityDialogElement returns the element selecting the priority of a request.
+func priorityDialogElement() model.DialogElement {
+	return model.DialogElement{
+		DisplayName: "Priority",
+		Name:        dialogElementNamePriority,
+		Type:        "select",
+		Default:     ticketPriorityMedium,
+		Options: []*model.PostActionOptions{{
+			Text:  ticketPriorityHigh,
+			Value: ticketPriorityHigh,
+		}, {
+			Text:  ticketPriorityMedium,
+			Value: ticketPriorityMedium,
+		}, {
+			Text:  ticketPriorityLow,
+			Value: ticketPriorityLow,
+		}},
+	}
+}
+
+// getBuiltInDialog returns the intake dialog used when no form definition is configured, with the
+// fields of the given category. Without a category, the dialog asks for the priority itself.
+func getBuiltInDialog(categoryName string) model.Dialog {
+	dialog := model.Dialog{
+		CallbackId: "srerequest",
+		Title:      "SRE Request",
+		Elements: []model.DialogElement{{
+			DisplayName: "Summary",
+			Name:        dialogElementNameSummary,
+			Type:        "text",
+			Placeholder: "What is the problem?",
+			MaxLength:   150,
+		}, {
+			DisplayName: "Description",
+			Name:        dialogElementNameDescription,
+			Type:        "textarea",
+			Placeholder: "Steps to reproduce, impact, links to pipelines...",
+			MaxLength:   3000,
+		}, {
+			DisplayName: "Affected service",
+			Name:        dialogElementNameService,
+			Type:        "text",
+			Placeholder: "Which service is affected?",
+			Optional:    true,
+		}},
+		SubmitLabel: "Submit",
+	}
+
+	if category, ok := getIntakeCategory(categoryName); ok {
+		dialog.Title = category.DisplayName
+		dialog.Elements = append(dialog.Elements, category.Elements...)
+	} else {
+		dialog.Elements = append(dialog.Elements, priorityDialogElement())
+	}
+
+	dialog.Elements = append(dialog.Elements, model.DialogElement{
+		DisplayName: "Assignee",
+		Name:        dialogElementNameAssignee,
+		Type:        "select",
+		Placeholder: "Select a user...",
+		Optional:    true,
+		DataSource:  "users",
+	}, model.DialogElement{
+		DisplayName: "Confidential",
+		Name:        dialogElementNameConfidential,
+		Type:        "bool",
+		Placeholder: "Handle this request privately",
+		HelpText:    "Confidential requests are escalated in a group message instead of the SRE channel.",
+		Optional:    true,
+	}, model.DialogElement{
+		DisplayName: "Anonymous",
+		Name:        dialogElementNameAnonymous,
+		Type:        "bool",
+		Placeholder: "Submit anonymously",
+		HelpText:    "Your username is left out of the request post. SRE admins can still see who submitted it.",
+		Optional:    true,
+	}, model.DialogElement{
+		DisplayName: "Labels",
+		Name:        dialogElementNameLabels,
+		Type:        "text",
+		Placeholder: "database, networking",
+		HelpText:    "Comma-separated labels used to filter requests.",
+		Optional:    true,
+		MaxLength:   (maxLabelLength + 2) * maxTicketLabels,
+	})
+
+	return dialog
+}
+
+// prefillDialog returns a copy of the dialog whose elements default to the given values.
+func prefillDialog(dialog model.Dialog, values map[string]interface{}) model.Dialog {
+	dialog.Elements = append([]model.DialogElement(nil), dialog.Elements...)
+	for i, element := range dialog.Elements {
+		if value, ok := values[element.Name]; ok && value != nil {
+			dialog.Elements[i].Default = fmt.Sprint(value)
+		}
+	}
+
+	return dialog
+}
+
+// openTicketDialog opens the given intake dialog for a team, submitting it to handleDialog. The
+// options of its dynamic elements are listed as the dialog opens.
+func (p *Plugin) openTicketDialog(triggerID, teamID string, dialog model.Dialog) *model.AppError {
+	return p.API.OpenInteractiveDialog(model.OpenDialogRequest{
+		TriggerId: triggerID,
+		URL:       pluginCallbackURL("/dialog/sre"),
+		Dialog:    p.populateDialog(dialog, teamID),
+	})
+}
+
+// parseDialogDefinition parses a JSON form definition into an intake dialog. The definition must
+// be a valid dialog and contain at least the summary element.
+func parseDialogDefinition(definition string) (*model.Dialog, error) {
+	var dialog model.Dialog
+	if err := json.Unmarshal([]byte(definition), &dialog); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal dialog definition")
+	}
+
+	if dialog.CallbackId == "" {
+		dialog.CallbackId = "srerequest"
+	}
+	if dialog.SubmitLabel == "" {
+		dialog.SubmitLabel = "Submit"
+	}
+
+	if err := dialog.IsValid(); err != nil {
+		return nil, errors.Wrap(err, "invalid dialog definition")
+	}
+
+	hasSummary := false
+	for _, element := range dialog.Elements {
+		if element.Name == dialogElementNameSummary {
+			hasSummary = true
+			break
+		}
+	}
+	if !hasSummary {
+		return nil, errors.Errorf("dialog definition must contain a %q element", dialogElementNameSummary)
+	}
+
+	return &dialog, nil
+}
+
+// formatAdditionalFields renders the submitted values of elements the ticket has no field for, so
+// that admin-defined elements are kept in the ticket description. Custom fields are stored on the
+// ticket instead.
+func formatAdditionalFields(dialog model.Dialog, submission map[string]interface{}) string {
+	knownElements := map[string]bool{
+		dialogElementNameSummary:      true,
+		dialogElementNameDescription:  true,
+		dialogElementNamePriority:     true,
+		dialogElementNameAssignee:     true,
+		dialogElementNameConfidential: true,
+		dialogElementNameAnonymous:    true,
+		dialogElementNameLabels:       true,
+	}
+
+	var lines []string
+	for _, element := range dialog.Elements {
+		if knownElements[element.Name] || strings.HasPrefix(element.Name, customFieldElementPrefix) {
+			continue
+		}
+
+		value, ok := submission[element.Name]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("**%s:** %v", element.DisplayName, value))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (p *Plugin) handleDialog(w http.ResponseWriter, r *http.Request) {
+	logger := p.requestLogger(r)
+
+	userID := r.Header.Get("Mattermost-User-ID")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request model.SubmitDialogRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		logger.Error("Failed to decode SubmitDialogRequest", "err", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if request.Cancelled {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The submission names its user, but only the header set by the server can be trusted.
+	if request.UserId != userID {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+	if !p.isTeamMember(request.TeamId, userID) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "You are not a member of this team.",
+		})
+		return
+	}
+
+	if !p.teamEnabled(request.TeamId) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "The plugin is disabled in this team.",
+		})
+		return
+	}
+
+	if !p.getConfiguration().commandAllowedIn(request.TeamId, request.ChannelId) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: p.commandRedirectMessage(request.TeamId),
+		})
+		return
+	}
+
+	summary, _ := request.Submission[dialogElementNameSummary].(string)
+	description, _ := request.Submission[dialogElementNameDescription].(string)
+	priority, _ := request.Submission[dialogElementNamePriority].(string)
+	assigneeID, _ := request.Submission[dialogElementNameAssignee].(string)
+	service, _ := request.Submission[dialogElementNameService].(string)
+	confidential, _ := request.Submission[dialogElementNameConfidential].(bool)
+	anonymous, _ := request.Submission[dialogElementNameAnonymous].(bool)
+	labelsText, _ := request.Submission[dialogElementNameLabels].(string)
+
+	if strings.TrimSpace(summary) == "" {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameSummary: "A summary is required",
+			},
+		})
+		return
+	}
+	if confidential && p.getConfiguration().MinimalPermissions {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameConfidential: "Confidential requests are disabled on this server",
+			},
+		})
+		return
+	}
+	if anonymous && confidential {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameAnonymous: "Confidential requests are discussed with their reporter, so they cannot be anonymous",
+			},
+		})
+		return
+	}
+	if assigneeID != "" && !p.isAssignableUser(request.TeamId, assigneeID) {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameAssignee: "The assignee must be an active member of this team",
+			},
+		})
+		return
+	}
+	labels, err := parseLabels(labelsText)
+	if err != nil {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: map[string]string{
+				dialogElementNameLabels: err.Error(),
+			},
+		})
+		return
+	}
+
+	customFields, customFieldErrors := p.getConfiguration().customFieldsDialogSubmission(request.Submission)
+	if len(customFieldErrors) > 0 {
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Errors: customFieldErrors,
+		})
+		return
+	}
+
+	// The built-in flow asks for the priority in its first step, while admin-defined forms may omit
+	// the priority element.
+	state := decodeIntakeState(request.State)
+	if priority == "" {
+		priority = state.Priority
+	}
+	picked := isValidPriority(priority)
+	if !picked {
+		priority = ticketPriorityMedium
+	}
+
+	// The additional fields are posted to the channel, so they are labeled in the server language.
+	if additionalFields := formatAdditionalFields(p.getTicketDialog(state.Category, p.serverLocalizer()), request.Submission); additionalFields != "" {
+		description = strings.TrimSpace(description + "\n\n" + additionalFields)
+	}
+
+	ticket := &Ticket{
+		TeamID:       request.TeamId,
+		ReporterID:   userID,
+		AssigneeID:   assigneeID,
+		Summary:      strings.TrimSpace(summary),
+		Description:  description,
+		Priority:     priority,
+		Category:     state.Category,
+		Service:      strings.TrimSpace(service),
+		Confidential: confidential,
+		Anonymous:    anonymous,
+		Labels:       labels,
+	}
+	setTicketCustomFields(ticket, customFields)
+	p.getConfiguration().suggestTicketPriority(ticket, picked)
+
+	// Repeated submissions are collapsed even when the reporter chose to submit anyway, since they
+	// are near-identical to a ticket the reporter just submitted.
+	repeated, err := p.findRepeatedSubmission(ticket)
+	if err != nil {
+		logger.Warn("Failed to search for repeated submissions", "err", err.Error())
+	} else if repeated != nil {
+		if err := p.collapseRepeatedSubmission(repeated, request.ChannelId); err != nil {
+			logger.withTicket(repeated).Error("Failed to collapse repeated submission", "err", err.Error())
+			p.writeJSON(w, &model.SubmitDialogResponse{
+				Error: "Failed to submit the SRE request. Please try again later.",
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !state.Force {
+		duplicate, err := p.findDuplicateTicket(ticket)
+		if err != nil {
+			logger.Warn("Failed to search for duplicate tickets", "err", err.Error())
+		} else if duplicate != nil {
+			p.sendSubmitAnywayPrompt(&request, duplicate)
+			p.writeJSON(w, &model.SubmitDialogResponse{
+				Error: p.duplicateTicketError(duplicate),
+			})
+			return
+		}
+	}
+
+	if err := p.createTicket(ticket); err != nil {
+		logger.Error("Failed to create ticket", "err", err.Error())
+		p.writeJSON(w, &model.SubmitDialogResponse{
+			Error: "Failed to submit the SRE request. Please try again later.",
+		})
+		return
+	}
+
+	if err := p.notifyResponders(ticket); err != nil {
+		logger.withTicket(ticket).Error("Failed to notify responders", "err", err.Error())
+	}
+	if err := p.promptForFiles(ticket); err != nil {
+		logger.withTicket(ticket).Warn("Failed to prompt for ticket files", "err", err.Error())
+	}
+
+	// Link the issues one after the other, since both update the ticket.
+	go func() {
+		p.linkJiraIssue(ticket)
+		p.linkGitHubIssue(ticket)
+	}()
+
+	w.WriteHeader(http.StatusOK)
+}
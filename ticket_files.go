@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// TicketFile is a file posted in a ticket's thread, which is linked to the ticket since the intake
+// dialog cannot upload files.
+type TicketFile struct {
+	FileID   string `json:"file_id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	UserID   string `json:"user_id"`
+	PostID   string `json:"post_id"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// promptForFiles asks the reporter of a new ticket to post the files of the request in the
+// ticket's thread. Reporters of anonymous tickets aren't mentioned, so they aren't prompted.
+func (p *Plugin) promptForFiles(ticket *Ticket) error {
+	if ticket.Anonymous {
+		return nil
+	}
+
+	return p.postTicketReply(ticket, localize(p.serverLocalizer(), &i18n.Message{
+		ID:    "ticket.files_prompt",
+		Other: ":paperclip: {{.Reporter}}, drop any screenshots, logs or other files in this thread to attach them to the request.",
+	}, map[string]interface{}{"Reporter": p.mentionUser(ticket.ReporterID)}))
+}
+
+// linkPostFiles links the files of a post of the ticket's thread to the ticket. Files whose info
+// cannot be read are linked by id only.
+func (p *Plugin) linkPostFiles(ticket *Ticket, post *model.Post) {
+	for _, fileID := range post.FileIds {
+		file := &TicketFile{
+			FileID:   fileID,
+			UserID:   post.UserId,
+			PostID:   post.Id,
+			CreateAt: post.CreateAt,
+		}
+
+		fileInfo, appErr := p.API.GetFileInfo(fileID)
+		if appErr != nil {
+			p.API.LogWarn("Failed to get ticket file info", "ticket_id", ticket.ID, "file_id", fileID, "err", appErr.Error())
+		} else {
+			file.Name = fileInfo.Name
+			file.Size = fileInfo.Size
+		}
+
+		ticket.Files = append(ticket.Files, file)
+	}
+}
+
+// exportFiles renders the ticket's files on a single line for CSV exports, with their download
+// URLs.
+func (p *Plugin) exportFiles(ticket *Ticket) string {
+	siteURL := p.siteURL()
+
+	files := make([]string, 0, len(ticket.Files))
+	for _, file := range ticket.Files {
+		name := file.Name
+		if name == "" {
+			name = file.FileID
+		}
+		files = append(files, fmt.Sprintf("%s (%s)", name, fileDownloadURL(siteURL, file.FileID)))
+	}
+
+	return strings.Join(files, "; ")
+}
+
+// fileDownloadURL returns the URL downloading the file with the given id.
+func fileDownloadURL(siteURL, fileID string) string {
+	return fmt.Sprintf("%s/api/v4/files/%s?download=1", siteURL, fileID)
+}
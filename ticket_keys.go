@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// ticketKeyProject prefixes the human-friendly keys of tickets, such as SRE-142.
+	ticketKeyProject = "SRE"
+
+	// ticketSequenceKey is the KV key of the counter numbering ticket keys.
+	ticketSequenceKey = "ticketsequence"
+
+	// ticketReferenceKeyPrefix prefixes the KV index from a ticket's key to its id.
+	ticketReferenceKeyPrefix = "ticketref_"
+)
+
+// ticketKeyPattern matches a ticket key, case-insensitively.
+var ticketKeyPattern = regexp.MustCompile(`(?i)^` + ticketKeyProject + `-(\d+)$`)
+
+func ticketReferenceKey(key string) string {
+	return ticketReferenceKeyPrefix + key
+}
+
+// nextTicketKey increments the ticket counter and returns the resulting key. The counter is
+// updated with compare and set, so that nodes of a cluster never hand out the same key.
+func (p *Plugin) nextTicketKey() (string, error) {
+	var sequence int64
+	err := p.client.KV.SetAtomicWithRetries(ticketSequenceKey, func(oldValue []byte) (interface{}, error) {
+		sequence = 0
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &sequence); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal ticket sequence")
+			}
+		}
+		sequence++
+
+		return sequence, nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to increment ticket sequence")
+	}
+
+	return fmt.Sprintf("%s-%d", ticketKeyProject, sequence), nil
+}
+
+// saveTicketReference indexes the ticket by its key.
+func (p *Plugin) saveTicketReference(ticket *Ticket) error {
+	if _, err := p.client.KV.Set(ticketReferenceKey(ticket.Key), ticket.ID); err != nil {
+		return errors.Wrap(err, "failed to save ticket key index")
+	}
+
+	return nil
+}
+
+// normalizeTicketKey returns the canonical form of a ticket key, or false if the value is not a
+// ticket key.
+func normalizeTicketKey(value string) (string, bool) {
+	match := ticketKeyPattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", false
+	}
+
+	number, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s-%d", ticketKeyProject, number), true
+}
+
+// findTicket returns the ticket named by its key, such as SRE-142, or by its id, or nil if there is
+// none.
+func (p *Plugin) findTicket(reference string) (*Ticket, error) {
+	key, ok := normalizeTicketKey(reference)
+	if !ok {
+		return p.getTicket(reference)
+	}
+
+	var ticketID string
+	if err := p.client.KV.Get(ticketReferenceKey(key), &ticketID); err != nil {
+		return nil, errors.Wrap(err, "failed to get ticket key index")
+	}
+	if ticketID == "" {
+		return nil, nil
+	}
+
+	return p.getTicket(ticketID)
+}
+
+// ticketName returns the key of the ticket, falling back to its id for tickets created before keys
+// existed.
+func (t *Ticket) ticketName() string {
+	if t.Key != "" {
+		return t.Key
+	}
+
+	return t.ID
+}
+
+func (p *Plugin) executeCommandShow(args *model.CommandArgs, params []string, flags commandFlags) *model.CommandResponse {
+	// --admin reveals the reporter of anonymous tickets to SRE admins.
+	admin := flags.has("admin")
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("show"))
+	}
+	if admin && !p.isSREAdmin(args.UserId) {
+		return ephemeralResponse("Only SRE admins can use --admin.")
+	}
+
+	ticket, err := p.findTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+
+	title := ticket.Summary
+	if permalink, err := p.ticketPermalink(ticket); err == nil {
+		title = fmt.Sprintf("[%s](%s)", ticket.Summary, permalink)
+	}
+
+	assignee := "Unassigned"
+	if ticket.AssigneeID != "" {
+		assignee = p.username(ticket.AssigneeID)
+	}
+
+	reporter := p.username(ticket.ReporterID)
+	if ticket.Anonymous {
+		reporter = "Anonymous"
+		if admin || args.UserId == ticket.ReporterID {
+			reporter = fmt.Sprintf("%s (anonymous)", p.username(ticket.ReporterID))
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("#### %s: %s", ticket.ticketName(), title),
+		"| Status | Priority | Reporter | Assignee | Created |",
+		"| --- | --- | --- | --- | --- |",
+		fmt.Sprintf("| %s | %s | %s | %s | %s |", ticket.Status, ticket.Priority, reporter, assignee, time.UnixMilli(ticket.CreateAt).UTC().Format(time.RFC1123)),
+	}
+	if description := strings.TrimSpace(ticket.Description); description != "" {
+		lines = append(lines, "", description)
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// labelSearchTermPrefix prefixes the labels of tickets in the search index, so that the index
+	// doubles as the label index without labels matching words of the summaries and descriptions.
+	labelSearchTermPrefix = "label:"
+
+	// maxTicketLabels bounds the number of labels of a ticket.
+	maxTicketLabels = 10
+
+	// maxLabelLength bounds the length of labels, keeping their search terms within
+	// maxSearchTermLength.
+	maxLabelLength = 32
+)
+
+// normalizeLabel returns the label in lowercase, checking it only contains letters, digits, dashes,
+// underscores and dots.
+func normalizeLabel(label string) (string, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" {
+		return "", errors.New("labels cannot be empty")
+	}
+	if len(label) > maxLabelLength {
+		return "", errors.Errorf("label %q is longer than %d characters", label, maxLabelLength)
+	}
+	for _, r := range label {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.' {
+			return "", errors.Errorf("label %q may only contain letters, digits, dashes, underscores and dots", label)
+		}
+	}
+
+	return label, nil
+}
+
+// parseLabels parses a list of labels separated by commas or spaces, dropping duplicates.
+func parseLabels(text string) ([]string, error) {
+	var labels []string
+	for _, label := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		normalized, err := normalizeLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		if !contains(labels, normalized) {
+			labels = append(labels, normalized)
+		}
+	}
+	if len(labels) > maxTicketLabels {
+		return nil, errors.Errorf("tickets have at most %d labels", maxTicketLabels)
+	}
+	sort.Strings(labels)
+
+	return labels, nil
+}
+
+// labelSearchTerms returns the search terms indexing the labels.
+func labelSearchTerms(labels []string) []string {
+	terms := make([]string, 0, len(labels))
+	for _, label := range labels {
+		terms = append(terms, labelSearchTermPrefix+label)
+	}
+
+	return terms
+}
+
+// hasLabels reports whether the ticket has every one of the labels.
+func (t *Ticket) hasLabels(labels []string) bool {
+	for _, label := range labels {
+		if !contains(t.Labels, label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// formatLabels renders the labels as code spans, which stand out as colored tags.
+func formatLabels(labels []string) string {
+	formatted := make([]string, 0, len(labels))
+	for _, label := range labels {
+		formatted = append(formatted, "`"+label+"`")
+	}
+
+	return strings.Join(formatted, " ")
+}
+
+// setTicketLabel adds the label to, or removes it from, the ticket on behalf of userID, recording
+// the change in the ticket's activity. It returns false if the ticket already had, or didn't have,
+// the label.
+func setTicketLabel(ticket *Ticket, label, userID string, add bool) (bool, error) {
+	if contains(ticket.Labels, label) == add {
+		return false, nil
+	}
+
+	if !add {
+		labels := make([]string, 0, len(ticket.Labels))
+		for _, existing := range ticket.Labels {
+			if existing != label {
+				labels = append(labels, existing)
+			}
+		}
+		ticket.Labels = labels
+		recordTicketActivity(ticket, timelineEventLabels, userID, fmt.Sprintf("Removed the label %s", label))
+
+		return true, nil
+	}
+
+	if len(ticket.Labels) >= maxTicketLabels {
+		return false, errors.Errorf("tickets have at most %d labels", maxTicketLabels)
+	}
+	ticket.Labels = append(ticket.Labels, label)
+	sort.Strings(ticket.Labels)
+	recordTicketActivity(ticket, timelineEventLabels, userID, fmt.Sprintf("Added the label %s", label))
+
+	return true, nil
+}
+
+func (p *Plugin) executeCommandLabel(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 3 || (params[1] != "add" && params[1] != "remove") {
+		return ephemeralResponse(commandUsage("label"))
+	}
+
+	ticket, err := p.findTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+	if !p.canEditTicket(args.UserId, ticket) {
+		return ephemeralResponse("You are not allowed to edit this ticket.")
+	}
+
+	label, err := normalizeLabel(params[2])
+	if err != nil {
+		return ephemeralResponse(err.Error() + ".")
+	}
+
+	add := params[1] == "add"
+	ticketID, ticketName := ticket.ID, ticket.ticketName()
+	var unchanged string
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		if !p.canEditTicket(args.UserId, ticket) {
+			unchanged = "You are not allowed to edit this ticket."
+			return errTicketUnchanged
+		}
+		changed, err := setTicketLabel(ticket, label, args.UserId, add)
+		if err != nil {
+			unchanged = err.Error() + "."
+			return errTicketUnchanged
+		}
+		if !changed {
+			if add {
+				unchanged = fmt.Sprintf("%s already has the label %s.", ticketName, label)
+			} else {
+				unchanged = fmt.Sprintf("%s doesn't have the label %s.", ticketName, label)
+			}
+			return errTicketUnchanged
+		}
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return ephemeralResponse(unchanged)
+	}
+	if err != nil {
+		p.API.LogError("Failed to save ticket", "ticket_id", ticketID, "err", err.Error())
+		return ephemeralResponse(ticketUpdateError(err, "Failed to save the ticket."))
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	if add {
+		return ephemeralResponse(fmt.Sprintf("Added the label %s to %s.", label, ticket.ticketName()))
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Removed the label %s from %s.", label, ticket.ticketName()))
+}
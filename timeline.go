@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	timelineEventCreated    = "created"
+	timelineEventAssigned   = "assigned"
+	timelineEventPriority   = "priority_changed"
+	timelineEventStatus     = "status_changed"
+	timelineEventComment    = "comment"
+	timelineEventPost       = "post"
+	timelineEventEscalation = "escalation"
+	timelineEventSync       = "sync"
+	timelineEventLabels     = "labels_changed"
+
+	// maxTimelineViewEvents bounds the events shown by the timeline command, which posts them in a
+	// single ephemeral message.
+	maxTimelineViewEvents = 50
+)
+
+// TicketActivity is an event of a ticket that isn't recorded elsewhere on the ticket, such as an
+// escalation or a sync with an integration.
+type TicketActivity struct {
+	Type     string `json:"type"`
+	UserID   string `json:"user_id,omitempty"`
+	Message  string `json:"message"`
+	PostID   string `json:"post_id,omitempty"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// recordTicketActivity appends an event to the ticket's activity. The ticket must be saved by the
+// caller.
+func recordTicketActivity(ticket *Ticket, activityType, userID, message string) *TicketActivity {
+	activity := &TicketActivity{
+		Type:     activityType,
+		UserID:   userID,
+		Message:  message,
+		CreateAt: model.GetMillis(),
+	}
+	ticket.Activity = append(ticket.Activity, activity)
+
+	return activity
+}
+
+// timelineEvent is an event of a ticket's timeline, as returned by the timeline endpoint.
+type timelineEvent struct {
+	Type     string `json:"type"`
+	UserID   string `json:"user_id,omitempty"`
+	Message  string `json:"message"`
+	PostID   string `json:"post_id,omitempty"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// ticketTimeline returns every event of the ticket in chronological order: its submission, the
+// changes of its tracked fields, the comments and posts of its thread, the commits referencing it
+// and its recorded activity.
+func (p *Plugin) ticketTimeline(ticket *Ticket) []*timelineEvent {
+	reporterID := ticket.ReporterID
+	if ticket.Anonymous {
+		reporterID = ""
+	}
+
+	events := []*timelineEvent{{
+		Type:     timelineEventCreated,
+		UserID:   reporterID,
+		Message:  fmt.Sprintf("Submitted with %s priority: %s", ticket.priorityAtCreation(), ticket.Summary),
+		PostID:   ticket.PostID,
+		CreateAt: ticket.CreateAt,
+	}}
+
+	for _, change := range ticket.History {
+		eventType := timelineEventStatus
+		switch change.Field {
+		case ticketFieldAssignee:
+			eventType = timelineEventAssigned
+		case ticketFieldPriority:
+			eventType = timelineEventPriority
+		}
+		events = append(events, &timelineEvent{
+			Type:     eventType,
+			UserID:   change.UserID,
+			Message:  p.describeChange(change, p.formatExportChangeValue),
+			CreateAt: change.CreateAt,
+		})
+	}
+
+	for _, comment := range ticket.Comments {
+		events = append(events, &timelineEvent{
+			Type:     timelineEventComment,
+			UserID:   comment.UserID,
+			Message:  comment.Message,
+			PostID:   comment.PostID,
+			CreateAt: comment.CreateAt,
+		})
+	}
+
+	for _, entry := range ticket.Timeline {
+		events = append(events, &timelineEvent{
+			Type:     timelineEventPost,
+			UserID:   entry.UserID,
+			Message:  entry.Message,
+			PostID:   entry.PostID,
+			CreateAt: entry.CreateAt,
+		})
+	}
+
+	for _, commit := range ticket.Commits {
+		events = append(events, &timelineEvent{
+			Type:     timelineEventSync,
+			Message:  fmt.Sprintf("Commit %s referenced the ticket: %s", shortSHA(commit.SHA), firstLine(commit.Message)),
+			CreateAt: commit.CreateAt,
+		})
+	}
+
+	for _, activity := range ticket.Activity {
+		events = append(events, &timelineEvent{
+			Type:     activity.Type,
+			UserID:   activity.UserID,
+			Message:  activity.Message,
+			PostID:   activity.PostID,
+			CreateAt: activity.CreateAt,
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreateAt < events[j].CreateAt
+	})
+
+	return events
+}
+
+// priorityAtCreation returns the priority the ticket was submitted with, before any change.
+func (t *Ticket) priorityAtCreation() string {
+	for _, change := range t.History {
+		if change.Field == ticketFieldPriority {
+			return change.OldValue
+		}
+	}
+
+	return t.Priority
+}
+
+// firstLine returns the first line of the text.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+func (p *Plugin) executeCommandTimeline(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("timeline"))
+	}
+
+	ticket, err := p.findTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+
+	events := p.ticketTimeline(ticket)
+	lines := []string{fmt.Sprintf("#### Timeline of %s: %s", ticket.ticketName(), ticket.Summary)}
+	if len(events) > maxTimelineViewEvents {
+		lines = append(lines, fmt.Sprintf("_Showing the last %d of %d events. The REST API returns the complete timeline._", maxTimelineViewEvents, len(events)))
+		events = events[len(events)-maxTimelineViewEvents:]
+	}
+	for _, event := range events {
+		lines = append(lines, fmt.Sprintf("- %s: %s", time.UnixMilli(event.CreateAt).UTC().Format("Mon Jan 2 15:04 MST"), firstLine(event.Message)))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
+
+func (p *Plugin) handleGetTicketTimeline(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := p.ticketFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	p.writeJSON(w, p.ticketTimeline(ticket))
+}
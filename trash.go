@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// trashKeyPrefix prefixes the KV key of every deleted ticket kept in the trash.
+	trashKeyPrefix = "trash_"
+
+	// trashRetentionPeriod is how long deleted tickets can be restored before they are purged.
+	trashRetentionPeriod = 30 * 24 * time.Hour
+)
+
+func trashKey(ticketID string) string {
+	return trashKeyPrefix + ticketID
+}
+
+// deleteTicket moves the ticket to the trash. Trashed tickets are kept in the KV store regardless
+// of the active ticket store, until they are restored or purged.
+func (p *Plugin) deleteTicket(ticket *Ticket, userID string) error {
+	ticket.DeleteAt = model.GetMillis()
+	ticket.DeletedBy = userID
+
+	if _, err := p.client.KV.Set(trashKey(ticket.ID), ticket); err != nil {
+		return errors.Wrap(err, "failed to move ticket to the trash")
+	}
+
+	if err := p.ticketStore().DeleteTicket(ticket); err != nil {
+		return err
+	}
+	p.ticketCache.remove(ticket.ID)
+	p.invalidateTicketCache(ticket.ID)
+
+	if err := p.unindexTicket(ticket); err != nil {
+		p.API.LogWarn("Failed to remove ticket from the search index", "ticket_id", ticket.ID, "err", err.Error())
+	}
+	if err := p.refreshStatusBanner(ticket.TeamID); err != nil {
+		p.API.LogWarn("Failed to refresh SRE channel status banner", "team_id", ticket.TeamID, "err", err.Error())
+	}
+
+	return nil
+}
+
+// getTrashedTicket returns the trashed ticket with the given id, or nil if it is not in the trash.
+func (p *Plugin) getTrashedTicket(ticketID string) (*Ticket, error) {
+	var ticket *Ticket
+	if err := p.client.KV.Get(trashKey(ticketID), &ticket); err != nil {
+		return nil, errors.Wrap(err, "failed to get trashed ticket")
+	}
+
+	return ticket, nil
+}
+
+// listTrashedTickets returns the tickets in the trash, most recently deleted first.
+func (p *Plugin) listTrashedTickets() ([]*Ticket, error) {
+	var tickets []*Ticket
+	for page := 0; ; page++ {
+		keys, err := p.client.KV.ListKeys(page, kvListPerPage)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list keys")
+		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, trashKeyPrefix) {
+				continue
+			}
+
+			ticket, err := p.getTrashedTicket(strings.TrimPrefix(key, trashKeyPrefix))
+			if err != nil {
+				return nil, err
+			}
+			if ticket != nil {
+				tickets = append(tickets, ticket)
+			}
+		}
+
+		if len(keys) < kvListPerPage {
+			break
+		}
+	}
+
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i].DeleteAt > tickets[j].DeleteAt
+	})
+
+	return tickets, nil
+}
+
+// restoreTicket moves a ticket out of the trash and back into the active ticket store. It returns
+// nil if the ticket is not in the trash.
+func (p *Plugin) restoreTicket(ticketID string) (*Ticket, error) {
+	ticket, err := p.getTrashedTicket(ticketID)
+	if err != nil || ticket == nil {
+		return nil, err
+	}
+
+	// A ticket restored by someone else in the meantime is no longer in the trash.
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.DeleteAt == 0 {
+			return errTicketUnchanged
+		}
+		ticket.DeleteAt = 0
+		ticket.DeletedBy = ""
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.client.KV.Delete(trashKey(ticketID)); err != nil {
+		return nil, errors.Wrap(err, "failed to remove ticket from the trash")
+	}
+
+	return ticket, nil
+}
+
+// purgeTrash permanently deletes the tickets that have been in the trash for longer than the
+// retention period.
+func (p *Plugin) purgeTrash() {
+	tickets, err := p.listTrashedTickets()
+	if err != nil {
+		p.reportJobFailure("RetentionJob", "Failed to list trashed tickets", err)
+		return
+	}
+
+	cutoff := model.GetMillis() - trashRetentionPeriod.Milliseconds()
+	for _, ticket := range tickets {
+		if ticket.DeleteAt > cutoff {
+			continue
+		}
+
+		if err := p.client.KV.Delete(trashKey(ticket.ID)); err != nil {
+			p.API.LogError("Failed to purge trashed ticket", "ticket_id", ticket.ID, "err", err.Error())
+			continue
+		}
+		p.API.LogInfo("Purged trashed ticket", "ticket_id", ticket.ID)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// usageKeyPrefix prefixes the KV key of the usage counters of a week, named by the date of
+	// its Monday.
+	usageKeyPrefix = "usage_"
+
+	// usageRetentionWeeks is how many weeks of usage counters are kept.
+	usageRetentionWeeks = 12
+
+	// defaultUsageReportWeeks is how many weeks the usage report covers by default.
+	defaultUsageReportWeeks = 4
+)
+
+// usageCounters counts how many times each feature was used during a week.
+type usageCounters map[string]int64
+
+// usageWeekStart returns the start of the week of t, on Monday at midnight UTC.
+func usageWeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
+
+func usageKey(weekStart time.Time) string {
+	return usageKeyPrefix + weekStart.Format("2006-01-02")
+}
+
+// countUsage increments the usage counter of the feature for the current week. Failures are only
+// logged, since usage counters never block the feature itself.
+func (p *Plugin) countUsage(feature string) {
+	if p.sheddingLoad() {
+		return
+	}
+
+	err := p.client.KV.SetAtomicWithRetries(usageKey(usageWeekStart(time.Now())), func(oldValue []byte) (interface{}, error) {
+		counters := usageCounters{}
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &counters); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal usage counters")
+			}
+		}
+		counters[feature]++
+
+		return counters, nil
+	})
+	if err != nil {
+		p.API.LogWarn("Failed to count feature usage", "feature", feature, "err", err.Error())
+	}
+}
+
+// getUsageCounters returns the usage counters of the week starting at weekStart.
+func (p *Plugin) getUsageCounters(weekStart time.Time) (usageCounters, error) {
+	counters := usageCounters{}
+	if err := p.client.KV.Get(usageKey(weekStart), &counters); err != nil {
+		return nil, errors.Wrap(err, "failed to get usage counters")
+	}
+
+	return counters, nil
+}
+
+// countWebhookUsage counts the deliveries of each webhook receiver, including the deliveries that
+// are rejected, so that the report shows the volume senders actually produce.
+func (p *Plugin) countWebhookUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.countUsage("webhook: " + path.Base(r.URL.Path))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cleanUpUsageCounters deletes the usage counters older than the retention period.
+func (p *Plugin) cleanUpUsageCounters() {
+	cutoff := usageKey(usageWeekStart(time.Now()).AddDate(0, 0, -7*usageRetentionWeeks))
+
+	keys, err := p.client.KV.ListKeys(0, 1000, pluginapi.WithPrefix(usageKeyPrefix))
+	if err != nil {
+		p.API.LogError("Failed to list usage counters", "err", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		if key >= cutoff {
+			continue
+		}
+		if err := p.client.KV.Delete(key); err != nil {
+			p.API.LogError("Failed to delete usage counters", "key", key, "err", err.Error())
+		}
+	}
+}
+
+// unusedIntegrations returns the names of the configured integrations that were not used in the
+// report period, which are candidates for removal from the configuration.
+func (c *configuration) unusedIntegrations(totals usageCounters) []string {
+	type integration struct {
+		name       string
+		feature    string
+		configured bool
+	}
+	integrations := []integration{
+		{"Jira", "integration: jira", c.isJiraConfigured()},
+		{"GitHub", "integration: github", c.isGitHubConfigured()},
+		{"Statuspage", "integration: statuspage", c.isStatuspageConfigured()},
+		{"Event webhook", "integration: event webhook", c.EventWebhookURL != ""},
+		{"Email bridge", "integration: email", c.isEmailBridgeConfigured()},
+		{"Alertmanager", "webhook: alertmanager", c.AlertmanagerToken != ""},
+		{"Grafana", "webhook: grafana", c.GrafanaToken != ""},
+		{"Sentry", "webhook: sentry", c.SentryClientSecret != ""},
+		{"Slack command", "compat: slack command", c.SlackSigningSecret != ""},
+	}
+	for name := range c.genericWebhooks {
+		integrations = append(integrations, integration{"Generic webhook " + name, "webhook: " + name, true})
+	}
+
+	var unused []string
+	for _, integration := range integrations {
+		if integration.configured && totals[integration.feature] == 0 {
+			unused = append(unused, integration.name)
+		}
+	}
+
+	return unused
+}
+
+func (p *Plugin) executeCommandUsage(params []string) *model.CommandResponse {
+	weeks := defaultUsageReportWeeks
+	if len(params) > 0 {
+		var err error
+		if weeks, err = strconv.Atoi(params[0]); err != nil || weeks < 1 || weeks > usageRetentionWeeks {
+			return ephemeralResponse(fmt.Sprintf("%s, with at most %d weeks.", commandUsage("usage"), usageRetentionWeeks))
+		}
+	}
+
+	thisWeek := usageWeekStart(time.Now())
+	var current usageCounters
+	totals := usageCounters{}
+	for i := 0; i < weeks; i++ {
+		counters, err := p.getUsageCounters(thisWeek.AddDate(0, 0, -7*i))
+		if err != nil {
+			p.API.LogError("Failed to get usage counters", "err", err.Error())
+			return ephemeralResponse("Failed to get the feature usage.")
+		}
+		if i == 0 {
+			current = counters
+		}
+		for feature, count := range counters {
+			totals[feature] += count
+		}
+	}
+
+	features := make([]string, 0, len(totals))
+	for feature := range totals {
+		features = append(features, feature)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		if totals[features[i]] != totals[features[j]] {
+			return totals[features[i]] > totals[features[j]]
+		}
+		return features[i] < features[j]
+	})
+
+	lines := []string{fmt.Sprintf("#### Feature usage over the last %d weeks", weeks)}
+	if len(features) == 0 {
+		lines = append(lines, "No feature was used.")
+	} else {
+		lines = append(lines, "| Feature | This week | Total | Weekly average |", "| --- | --- | --- | --- |")
+		for _, feature := range features {
+			lines = append(lines, fmt.Sprintf("| %s | %d | %d | %.1f |", feature, current[feature], totals[feature], float64(totals[feature])/float64(weeks)))
+		}
+	}
+
+	if unused := p.getConfiguration().unusedIntegrations(totals); len(unused) > 0 {
+		lines = append(lines, "", fmt.Sprintf("Configured but unused integrations, which may be removed from the configuration: %s.", strings.Join(unused, ", ")))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
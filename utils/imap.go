@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// IMAPClient is a minimal IMAP4rev1 client over implicit TLS, supporting just what is needed to
+// read new messages from a mailbox and mark them as read.
+type IMAPClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse is an untagged response line of the server, with the literals it carries.
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+// DialIMAP connects to the IMAP server at addr, as "host:port", over TLS. Every command must
+// complete within the timeout.
+func DialIMAP(addr string, timeout time.Duration) (*IMAPClient, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid IMAP server %q", addr)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to IMAP server")
+	}
+
+	client := &IMAPClient{conn: conn, reader: bufio.NewReader(conn)}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to set IMAP deadline")
+	}
+
+	greeting, err := client.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to read IMAP greeting")
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		conn.Close()
+		return nil, errors.Errorf("unexpected IMAP greeting %q", greeting)
+	}
+
+	return client, nil
+}
+
+// SetDeadline bounds the time left to run the next commands.
+func (c *IMAPClient) SetDeadline(deadline time.Time) error {
+	return c.conn.SetDeadline(deadline)
+}
+
+// Close closes the connection, logging out first.
+func (c *IMAPClient) Close() error {
+	_, _ = c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+// Login authenticates with a username and password.
+func (c *IMAPClient) Login(username, password string) error {
+	_, err := c.command("LOGIN " + imapQuote(username) + " " + imapQuote(password))
+	return err
+}
+
+// Select opens the mailbox for reading and writing.
+func (c *IMAPClient) Select(mailbox string) error {
+	_, err := c.command("SELECT " + imapQuote(mailbox))
+	return err
+}
+
+// SearchUnseen returns the UIDs of the unread messages of the selected mailbox.
+func (c *IMAPClient) SearchUnseen() ([]uint32, error) {
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint32
+	for _, response := range responses {
+		fields := strings.Fields(response.line)
+		if len(fields) < 2 || fields[0] != "*" || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, field := range fields[2:] {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, errors.Errorf("invalid UID %q in IMAP search response", field)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+
+	return uids, nil
+}
+
+// FetchMessage returns the raw message with the UID, without marking it as read.
+func (c *IMAPClient) FetchMessage(uid uint32) ([]byte, error) {
+	responses, err := c.command(fmt.Sprintf("UID FETCH %d (BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, response := range responses {
+		if strings.Contains(strings.ToUpper(response.line), "FETCH") && len(response.literals) > 0 {
+			return response.literals[0], nil
+		}
+	}
+
+	return nil, errors.Errorf("message %d not found", uid)
+}
+
+// MarkSeen marks the message with the UID as read.
+func (c *IMAPClient) MarkSeen(uid uint32) error {
+	_, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid))
+	return err
+}
+
+// command sends the command and returns the untagged responses, or an error if the command did
+// not complete successfully.
+func (c *IMAPClient) command(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command); err != nil {
+		return nil, errors.Wrap(err, "failed to send IMAP command")
+	}
+
+	var responses []imapResponse
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+
+		if status, ok := strings.CutPrefix(response.line, tag+" "); ok {
+			if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+				// The command is left out of the error, since it may contain credentials.
+				verb, _, _ := strings.Cut(command, " ")
+				return nil, errors.Errorf("IMAP %s failed: %s", verb, status)
+			}
+			return responses, nil
+		}
+
+		responses = append(responses, response)
+	}
+}
+
+// readResponse reads a response line, along with the literals it announces with "{size}".
+func (c *IMAPClient) readResponse() (imapResponse, error) {
+	var response imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return response, err
+		}
+		response.line += line
+
+		if !strings.HasSuffix(line, "}") {
+			return response, nil
+		}
+		start := strings.LastIndex(line, "{")
+		if start == -1 {
+			return response, nil
+		}
+		size, err := strconv.Atoi(line[start+1 : len(line)-1])
+		if err != nil || size < 0 {
+			return response, nil
+		}
+
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return response, errors.Wrap(err, "failed to read IMAP literal")
+		}
+		response.literals = append(response.literals, literal)
+	}
+}
+
+func (c *IMAPClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read IMAP response")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// imapQuote quotes a string argument of a command.
+func imapQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JSONPath is a parsed JSONPath-style expression selecting a single value of a JSON document, such
+// as "$.alert.labels['team name'][0]". Only member and array index selectors are supported.
+type JSONPath []jsonPathStep
+
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// ParseJSONPath parses an expression starting with "$", followed by any number of ".key",
+// "['key']" or "[index]" selectors.
+func ParseJSONPath(expression string) (JSONPath, error) {
+	expression = strings.TrimSpace(expression)
+	if !strings.HasPrefix(expression, "$") {
+		return nil, errors.Errorf("invalid JSONPath %q, expected it to start with $", expression)
+	}
+
+	var path JSONPath
+	rest := expression[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, errors.Errorf("invalid JSONPath %q, expected a key after .", expression)
+			}
+			path = append(path, jsonPathStep{key: key})
+			rest = rest[end+1:]
+
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, errors.Errorf("invalid JSONPath %q, missing ]", expression)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				path = append(path, jsonPathStep{key: selector[1 : len(selector)-1]})
+				continue
+			}
+
+			index, err := strconv.Atoi(selector)
+			if err != nil || index < 0 {
+				return nil, errors.Errorf("invalid JSONPath %q, expected a quoted key or an array index in []", expression)
+			}
+			path = append(path, jsonPathStep{index: index, isIndex: true})
+
+		default:
+			return nil, errors.Errorf("invalid JSONPath %q, unexpected %q", expression, rest[0])
+		}
+	}
+
+	return path, nil
+}
+
+// Lookup returns the value selected by the path in a document decoded by encoding/json, and
+// whether it exists.
+func (p JSONPath) Lookup(document interface{}) (interface{}, bool) {
+	value := document
+	for _, step := range p {
+		if step.isIndex {
+			array, ok := value.([]interface{})
+			if !ok || step.index >= len(array) {
+				return nil, false
+			}
+			value = array[step.index]
+			continue
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[step.key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
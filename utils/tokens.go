@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize lowercases text and returns the set of its alphanumeric tokens, ignoring tokens shorter
+// than three characters.
+func Tokenize(text string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len(token) >= 3 {
+			tokens[token] = true
+		}
+	}
+
+	return tokens
+}
+
+// TokenOverlap returns the Jaccard similarity of two token sets, between 0 and 1.
+func TokenOverlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for token := range a {
+		if b[token] {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseNetworks parses a comma-separated list of CIDRs or IP addresses.
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network %q", entry)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// IsInternalIP reports whether the IP is a loopback, private, link-local, multicast or
+// unspecified address.
+func IsInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified()
+}
+
+// IsAllowedIP reports whether the IP is public or belongs to one of the allowed networks.
+func IsAllowedIP(ip net.IP, allowedNetworks []*net.IPNet) bool {
+	if !IsInternalIP(ip) {
+		return true
+	}
+
+	for _, network := range allowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateURL checks that the URL is an absolute http or https URL whose host only resolves to
+// public addresses or to addresses of the allowed networks.
+func ValidateURL(rawURL string, allowedNetworks []*net.IPNet) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid URL")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("URL has no host")
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = net.LookupIP(host); err != nil {
+			return errors.Wrapf(err, "failed to resolve %s", host)
+		}
+	}
+
+	for _, ip := range ips {
+		if !IsAllowedIP(ip, allowedNetworks) {
+			return errors.Errorf("%s resolves to the internal address %s", host, ip)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// defaultVaultLinkExpiry is how long vault links stay valid when VaultLinkExpiry is empty.
+	defaultVaultLinkExpiry = time.Hour
+
+	// maxVaultLinkExpiry is the longest validity S3 accepts for presigned URLs.
+	maxVaultLinkExpiry = 7 * 24 * time.Hour
+
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	awsDateLayout       = "20060102"
+	awsTimeLayout       = "20060102T150405Z"
+)
+
+// vaultFileNameUnsafe matches the characters replaced in the file names of vault artifacts.
+var vaultFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// VaultArtifact is a sensitive file of a ticket, stored in the secure vault rather than in
+// Mattermost file storage.
+type VaultArtifact struct {
+	Key         string `json:"key"`
+	FileName    string `json:"file_name"`
+	RequestedBy string `json:"requested_by"`
+	CreateAt    int64  `json:"create_at"`
+
+	// UploadExpiresAt is when the upload link of the artifact expires.
+	UploadExpiresAt int64 `json:"upload_expires_at"`
+}
+
+// isVaultConfigured reports whether the secure vault is enabled.
+func (c *configuration) isVaultConfigured() bool {
+	return c.VaultS3Bucket != "" && c.VaultS3Region != "" && c.VaultS3AccessKeyID != "" && c.VaultS3SecretAccessKey != ""
+}
+
+// parseVaultLinkExpiry parses VaultLinkExpiry, defaulting to an hour.
+func parseVaultLinkExpiry(configuration *configuration) (time.Duration, error) {
+	if strings.TrimSpace(configuration.VaultLinkExpiry) == "" {
+		return defaultVaultLinkExpiry, nil
+	}
+
+	expiry, err := parseDuration(strings.TrimSpace(configuration.VaultLinkExpiry))
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid vault link expiry %q", configuration.VaultLinkExpiry)
+	}
+	if expiry < time.Second || expiry > maxVaultLinkExpiry {
+		return 0, errors.Errorf("vault link expiry must be between 1s and %s", maxVaultLinkExpiry)
+	}
+
+	return expiry, nil
+}
+
+// vaultObjectKey returns the key of a new vault object of the ticket.
+func vaultObjectKey(ticket *Ticket, fileName string) string {
+	return fmt.Sprintf("tickets/%s/%s/%s", ticket.ID, model.NewId(), fileName)
+}
+
+// awsURIEncode encodes the value as required by AWS Signature Version 4, leaving slashes
+// unencoded when encoding paths.
+func awsURIEncode(value string, path bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && path:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return encoded.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// presignVaultURL returns a URL allowing anyone holding it to run the method on the vault object
+// until the expiry, signed with AWS Signature Version 4. VaultS3Endpoint selects an S3-compatible
+// service addressed with path-style URLs, instead of AWS S3.
+func presignVaultURL(configuration *configuration, method, key string, expiry time.Duration, now time.Time) (string, error) {
+	host := fmt.Sprintf("%s.s3.%s.amazonaws.com", configuration.VaultS3Bucket, configuration.VaultS3Region)
+	scheme := "https"
+	objectPath := "/" + key
+	if configuration.VaultS3Endpoint != "" {
+		endpoint, err := url.Parse(configuration.VaultS3Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return "", errors.Errorf("invalid vault S3 endpoint %q", configuration.VaultS3Endpoint)
+		}
+		host = endpoint.Host
+		scheme = endpoint.Scheme
+		objectPath = path.Join("/", endpoint.Path, configuration.VaultS3Bucket, key)
+	}
+
+	now = now.UTC()
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format(awsDateLayout), configuration.VaultS3Region)
+	query := map[string]string{
+		"X-Amz-Algorithm":     awsSigningAlgorithm,
+		"X-Amz-Credential":    configuration.VaultS3AccessKeyID + "/" + scope,
+		"X-Amz-Date":          now.Format(awsTimeLayout),
+		"X-Amz-Expires":       fmt.Sprintf("%d", int64(expiry.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parameters := make([]string, 0, len(names))
+	for _, name := range names {
+		parameters = append(parameters, awsURIEncode(name, false)+"="+awsURIEncode(query[name], false))
+	}
+	canonicalQuery := strings.Join(parameters, "&")
+	canonicalPath := awsURIEncode(objectPath, true)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalPath,
+		canonicalQuery,
+		"host:" + host,
+		"",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		awsSigningAlgorithm,
+		now.Format(awsTimeLayout),
+		scope,
+		hex.EncodeToString(hashedRequest[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+configuration.VaultS3SecretAccessKey), now.Format(awsDateLayout))
+	signingKey = hmacSHA256(signingKey, configuration.VaultS3Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", scheme, host, canonicalPath, canonicalQuery, signature), nil
+}
+
+// requestVaultUpload records a new artifact on the ticket and returns the presigned URL to upload
+// it with.
+func (p *Plugin) requestVaultUpload(ticket *Ticket, fileName, userID string) (*VaultArtifact, string, error) {
+	configuration := p.getConfiguration()
+
+	now := time.Now()
+	artifact := &VaultArtifact{
+		Key:             vaultObjectKey(ticket, fileName),
+		FileName:        fileName,
+		RequestedBy:     userID,
+		CreateAt:        now.UnixMilli(),
+		UploadExpiresAt: now.Add(configuration.vaultLinkExpiry).UnixMilli(),
+	}
+
+	uploadURL, err := presignVaultURL(configuration, "PUT", artifact.Key, configuration.vaultLinkExpiry, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ticket, err = p.updateTicket(ticket, func(ticket *Ticket) error {
+		ticket.VaultArtifacts = append(ticket.VaultArtifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		p.API.LogWarn("Failed to update ticket post", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return artifact, uploadURL, nil
+}
+
+// vaultArtifactsValue lists the artifacts of a ticket for its attachment.
+func vaultArtifactsValue(ticket *Ticket) string {
+	names := make([]string, 0, len(ticket.VaultArtifacts))
+	for _, artifact := range ticket.VaultArtifacts {
+		names = append(names, artifact.FileName)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// vaultCommandTicket returns the ticket a vault subcommand is run on, or the response to send if the
+// vault isn't configured or the user may not view the ticket.
+func (p *Plugin) vaultCommandTicket(args *model.CommandArgs, ticketID string) (*Ticket, *model.CommandResponse) {
+	if !p.getConfiguration().isVaultConfigured() {
+		return nil, ephemeralResponse("The secure vault is not configured.")
+	}
+
+	ticket, err := p.getTicket(ticketID)
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return nil, ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return nil, ephemeralResponse(fmt.Sprintf("Ticket %s not found.", ticketID))
+	}
+
+	return ticket, nil
+}
+
+func (p *Plugin) executeCommandVaultUpload(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 2 {
+		return ephemeralResponse(commandUsage("vault", "upload"))
+	}
+
+	ticket, response := p.vaultCommandTicket(args, params[0])
+	if ticket == nil {
+		return response
+	}
+
+	fileName := strings.Trim(vaultFileNameUnsafe.ReplaceAllString(params[1], "_"), "._")
+	if fileName == "" {
+		return ephemeralResponse("Invalid file name.")
+	}
+
+	artifact, uploadURL, err := p.requestVaultUpload(ticket, fileName, args.UserId)
+	if err != nil {
+		p.API.LogError("Failed to request vault upload", "ticket_id", ticket.ID, "err", err.Error())
+		return ephemeralResponse("Failed to create the upload link.")
+	}
+
+	if err := p.postTicketReply(ticket, fmt.Sprintf(":lock: %s requested a secure upload link for `%s`.", p.mentionUser(args.UserId), artifact.FileName)); err != nil {
+		p.API.LogWarn("Failed to post vault upload notice", "ticket_id", ticket.ID, "err", err.Error())
+	}
+
+	return ephemeralResponse(fmt.Sprintf("Upload `%s` to the secure vault before %s, without attaching it to Mattermost:\n```\ncurl -T %s '%s'\n```",
+		artifact.FileName, time.UnixMilli(artifact.UploadExpiresAt).UTC().Format(time.RFC1123), artifact.FileName, uploadURL))
+}
+
+func (p *Plugin) executeCommandVaultList(args *model.CommandArgs, params []string) *model.CommandResponse {
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage("vault", "list"))
+	}
+
+	ticket, response := p.vaultCommandTicket(args, params[0])
+	if ticket == nil {
+		return response
+	}
+	// The artifacts are sensitive, so only the people working on the ticket may download them,
+	// even when the ticket is visible to the whole team.
+	if !p.canEditTicket(args.UserId, ticket) {
+		return ephemeralResponse("Only the reporter, the assignee, the incident commander and system admins can download the artifacts of this ticket.")
+	}
+	if len(ticket.VaultArtifacts) == 0 {
+		return ephemeralResponse("This ticket has no artifacts in the secure vault.")
+	}
+
+	configuration := p.getConfiguration()
+	now := time.Now()
+	expiresAt := now.Add(configuration.vaultLinkExpiry).UTC().Format(time.RFC1123)
+	lines := []string{fmt.Sprintf("Download links of the artifacts of ticket %s, valid until %s:", ticket.ID, expiresAt)}
+	for _, artifact := range ticket.VaultArtifacts {
+		downloadURL, err := presignVaultURL(configuration, "GET", artifact.Key, configuration.vaultLinkExpiry, now)
+		if err != nil {
+			p.API.LogError("Failed to presign vault download", "ticket_id", ticket.ID, "err", err.Error())
+			return ephemeralResponse("Failed to create the download links.")
+		}
+		lines = append(lines, fmt.Sprintf("- [%s](%s), requested by %s on %s", artifact.FileName, downloadURL, p.mentionUser(artifact.RequestedBy), time.UnixMilli(artifact.CreateAt).UTC().Format(searchDateLayout)))
+	}
+
+	return ephemeralResponse(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// pausedDuration returns how long the ticket has waited on its reporter, including the current
+// wait, in milliseconds.
+func (t *Ticket) pausedDuration(now int64) int64 {
+	paused := t.PausedDuration
+	if t.Status == ticketStatusWaitingOnReporter && t.WaitingSince != 0 {
+		paused += now - t.WaitingSince
+	}
+
+	return paused
+}
+
+// formatPausedDuration formats a paused duration in milliseconds for display, to the minute.
+func formatPausedDuration(paused int64) string {
+	return (time.Duration(paused) * time.Millisecond).Round(time.Minute).String()
+}
+
+// waitOnReporter pauses the ticket's SLA clock and escalations until the reporter replies in the
+// ticket's thread.
+func (p *Plugin) waitOnReporter(ticket *Ticket, userID string) (string, error) {
+	if !p.canEditTicket(userID, ticket) {
+		return "You are not allowed to change the status of this request.", nil
+	}
+	switch ticket.Status {
+	case ticketStatusResolved:
+		return "This request is already resolved.", nil
+	case ticketStatusWaitingOnReporter:
+		return "This request is already waiting on its reporter.", nil
+	}
+
+	var unchanged string
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if !p.canEditTicket(userID, ticket) {
+			unchanged = "You are not allowed to change the status of this request."
+			return errTicketUnchanged
+		}
+		switch ticket.Status {
+		case ticketStatusResolved:
+			unchanged = "This request is already resolved."
+			return errTicketUnchanged
+		case ticketStatusWaitingOnReporter:
+			unchanged = "This request is already waiting on its reporter."
+			return errTicketUnchanged
+		}
+		setTicketStatus(ticket, ticketStatusWaitingOnReporter, userID)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return unchanged, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return "", err
+	}
+
+	p.sendTicketEvent(ticketEventWaiting, ticket, userID)
+
+	// The reporter of an anonymous ticket is told by direct message instead of being mentioned.
+	reporter := p.mentionUser(ticket.ReporterID)
+	if ticket.Anonymous {
+		reporter = "the reporter"
+		if err := p.sendDirectMessage(ticket.ReporterID, p.ticketDirectMessage(ticket, fmt.Sprintf(":hourglass: %s is waiting on you. Reply in the request thread to resume it.", p.mentionUser(userID)))); err != nil {
+			p.API.LogWarn("Failed to notify anonymous reporter", "ticket_id", ticket.ID, "err", err.Error())
+		}
+	}
+
+	message := fmt.Sprintf(":hourglass: %s is waiting on %s. The SLA clock is paused until they reply in this thread.", p.mentionUser(userID), reporter)
+	if err := p.postTicketTransition(ticket, message); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// resumeTicket returns a ticket waiting on its reporter to the status it had before.
+func resumeTicket(ticket *Ticket, userID string) {
+	status := ticket.StatusBeforeWaiting
+	if status == "" {
+		status = ticketStatusOpen
+	}
+	setTicketStatus(ticket, status, userID)
+}
+
+// announceResumedTicket tells the ticket's thread that it resumed, along with its SLA clock and
+// escalations.
+func (p *Plugin) announceResumedTicket(ticket *Ticket, userID, message string) error {
+	if err := p.updateTicketPost(ticket); err != nil {
+		return err
+	}
+
+	p.sendTicketEvent(ticketEventResumed, ticket, userID)
+
+	return p.postTicketTransition(ticket, fmt.Sprintf("%s The SLA clock was paused for %s in total.", message, formatPausedDuration(ticket.PausedDuration)))
+}
+
+// resumeWaitingTicket resumes a ticket waiting on its reporter from its Resume button.
+func (p *Plugin) resumeWaitingTicket(ticket *Ticket, userID string) (string, error) {
+	if !p.canEditTicket(userID, ticket) {
+		return "You are not allowed to change the status of this request.", nil
+	}
+	if ticket.Status != ticketStatusWaitingOnReporter {
+		return "This request is not waiting on its reporter.", nil
+	}
+
+	var unchanged string
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if !p.canEditTicket(userID, ticket) {
+			unchanged = "You are not allowed to change the status of this request."
+			return errTicketUnchanged
+		}
+		if ticket.Status != ticketStatusWaitingOnReporter {
+			unchanged = "This request is not waiting on its reporter."
+			return errTicketUnchanged
+		}
+		resumeTicket(ticket, userID)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return unchanged, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.announceResumedTicket(ticket, userID, fmt.Sprintf(":arrow_forward: %s resumed this request.", p.mentionUser(userID))); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// resumeOnReporterReply resumes the ticket if it is waiting on its reporter and the post is their
+// reply.
+func (p *Plugin) resumeOnReporterReply(ticket *Ticket, post *model.Post) {
+	if ticket.Status != ticketStatusWaitingOnReporter || post.UserId != ticket.ReporterID {
+		return
+	}
+
+	ticketID := ticket.ID
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.Status != ticketStatusWaitingOnReporter {
+			return errTicketUnchanged
+		}
+		resumeTicket(ticket, post.UserId)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return
+	}
+	if err != nil {
+		p.API.LogError("Failed to resume ticket on reporter reply", "ticket_id", ticketID, "err", err.Error())
+		return
+	}
+
+	if err := p.announceResumedTicket(ticket, post.UserId, fmt.Sprintf(":arrow_forward: %s replied, so this request resumed.", p.mentionUser(post.UserId))); err != nil {
+		p.API.LogError("Failed to resume ticket on reporter reply", "ticket_id", ticket.ID, "err", err.Error())
+	}
+}
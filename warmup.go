@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// warmUpTimeout bounds how long the warm-up waits for the ticket store after activation.
+	warmUpTimeout = 2 * time.Minute
+
+	// warmUpProgressInterval is how often the warm-up reports that it is still running.
+	warmUpProgressInterval = 10 * time.Second
+)
+
+// warmUp loads the ticket cache after activation, so that the first list and search requests
+// after a restart don't have to scan the whole ticket store. It gives up after warmUpTimeout,
+// leaving the cache to be loaded on demand.
+func (p *Plugin) warmUp() {
+	start := time.Now()
+	p.API.LogInfo("Warming up the ticket cache")
+
+	var tickets []*Ticket
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tickets, err = p.listTickets()
+	}()
+
+	progress := time.NewTicker(warmUpProgressInterval)
+	defer progress.Stop()
+	timeout := time.NewTimer(warmUpTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-done:
+			if err != nil {
+				p.API.LogError("Failed to warm up the ticket cache", "err", err.Error())
+				return
+			}
+
+			p.ticketCache.replace(tickets)
+			p.API.LogInfo("Warmed up the ticket cache", "tickets", len(tickets), "elapsed", time.Since(start).String())
+			return
+		case <-progress.C:
+			p.API.LogInfo("Still warming up the ticket cache", "elapsed", time.Since(start).String())
+		case <-timeout.C:
+			p.API.LogWarn("Gave up warming up the ticket cache, it will be loaded on demand", "elapsed", time.Since(start).String())
+			return
+		}
+	}
+}
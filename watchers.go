@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	ticketActionWatch   = "watch"
+	ticketActionUnwatch = "unwatch"
+)
+
+// ticketStatusEvents are the ticket events announcing a change of the ticket's status, which are
+// sent to the ticket's watchers.
+var ticketStatusEvents = map[string]bool{
+	ticketEventAcknowledged: true,
+	ticketEventResolved:     true,
+	ticketEventWaiting:      true,
+	ticketEventResumed:      true,
+}
+
+// isWatching reports whether the user watches the ticket.
+func (t *Ticket) isWatching(userID string) bool {
+	return contains(t.Watchers, userID)
+}
+
+// watchTicket subscribes the user to the status changes and comments of the ticket.
+func (p *Plugin) watchTicket(ticket *Ticket, userID string) (string, error) {
+	if ticket.isWatching(userID) {
+		return fmt.Sprintf("You are already watching %s.", ticket.ticketName()), nil
+	}
+
+	ticketName := ticket.ticketName()
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if ticket.isWatching(userID) {
+			return errTicketUnchanged
+		}
+		ticket.Watchers = append(ticket.Watchers, userID)
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return fmt.Sprintf("You are already watching %s.", ticketName), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("You are now watching %s. You will receive a direct message when its status changes or someone comments on it.", ticket.ticketName()), nil
+}
+
+// unwatchTicket unsubscribes the user from the ticket.
+func (p *Plugin) unwatchTicket(ticket *Ticket, userID string) (string, error) {
+	if !ticket.isWatching(userID) {
+		return fmt.Sprintf("You are not watching %s.", ticket.ticketName()), nil
+	}
+
+	ticketName := ticket.ticketName()
+	ticket, err := p.updateTicket(ticket, func(ticket *Ticket) error {
+		if !ticket.isWatching(userID) {
+			return errTicketUnchanged
+		}
+		watchers := make([]string, 0, len(ticket.Watchers)-1)
+		for _, watcherID := range ticket.Watchers {
+			if watcherID != userID {
+				watchers = append(watchers, watcherID)
+			}
+		}
+		ticket.Watchers = watchers
+		return nil
+	})
+	if errors.Is(err, errTicketUnchanged) {
+		return fmt.Sprintf("You are not watching %s.", ticketName), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := p.updateTicketPost(ticket); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("You stopped watching %s.", ticket.ticketName()), nil
+}
+
+// notifyWatchers sends the message to the watchers of the ticket by direct message, in the
+// background. The user behind the change is not notified of their own change, nor are watchers who
+// can no longer view the ticket.
+func (p *Plugin) notifyWatchers(ticket *Ticket, actorID, message string) {
+	if len(ticket.Watchers) == 0 {
+		return
+	}
+
+	ticket = ticket.clone()
+	go func() {
+		dmMessage := p.ticketDirectMessage(ticket, message)
+		for _, watcherID := range ticket.Watchers {
+			if watcherID == actorID || !p.canViewTicket(watcherID, ticket) {
+				continue
+			}
+
+			if err := p.sendDirectMessage(watcherID, dmMessage); err != nil {
+				p.API.LogWarn("Failed to notify ticket watcher", "ticket_id", ticket.ID, "user_id", watcherID, "err", err.Error())
+			}
+		}
+	}()
+}
+
+// notifyWatchersOfStatus tells the watchers of the ticket that its status changed.
+func (p *Plugin) notifyWatchersOfStatus(ticket *Ticket, userID string) {
+	message := fmt.Sprintf(":arrows_counterclockwise: The status of %s changed to **%s**.", ticket.ticketName(), ticket.Status)
+	if userID != "" && userID != p.botID {
+		message = fmt.Sprintf(":arrows_counterclockwise: %s changed the status of %s to **%s**.", p.mentionUser(userID), ticket.ticketName(), ticket.Status)
+	}
+
+	p.notifyWatchers(ticket, userID, message)
+}
+
+// notifyWatchersOfComment tells the watchers of the ticket that the post was added to its thread.
+func (p *Plugin) notifyWatchersOfComment(ticket *Ticket, post *model.Post) {
+	lines := strings.Split(post.Message, "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+
+	p.notifyWatchers(ticket, post.UserId, fmt.Sprintf(":speech_balloon: %s commented on %s:\n%s", p.mentionUser(post.UserId), ticket.ticketName(), strings.Join(lines, "\n")))
+}
+
+// watchersValue lists the watchers of the ticket for its attachment.
+func (p *Plugin) watchersValue(ticket *Ticket) string {
+	mentions := make([]string, 0, len(ticket.Watchers))
+	for _, watcherID := range ticket.Watchers {
+		mentions = append(mentions, p.mentionUser(watcherID))
+	}
+
+	return strings.Join(mentions, ", ")
+}
+
+func (p *Plugin) executeCommandWatch(args *model.CommandArgs, params []string, watch bool) *model.CommandResponse {
+	subcommand := "watch"
+	if !watch {
+		subcommand = "unwatch"
+	}
+	if len(params) != 1 {
+		return ephemeralResponse(commandUsage(subcommand))
+	}
+
+	ticket, err := p.findTicket(params[0])
+	if err != nil {
+		p.API.LogError("Failed to get ticket", "err", err.Error())
+		return ephemeralResponse("Failed to get the ticket.")
+	}
+	if ticket == nil || !p.canViewTicket(args.UserId, ticket) {
+		return ephemeralResponse(fmt.Sprintf("Ticket %s not found.", params[0]))
+	}
+
+	var message string
+	if watch {
+		message, err = p.watchTicket(ticket, args.UserId)
+	} else {
+		message, err = p.unwatchTicket(ticket, args.UserId)
+	}
+	if err != nil {
+		p.API.LogError("Failed to update ticket watchers", "ticket_id", ticket.ID, "err", err.Error())
+		return ephemeralResponse(ticketUpdateError(err, "Failed to update the watchers of the ticket."))
+	}
+
+	return ephemeralResponse(message)
+}
@@ -0,0 +1,145 @@
+// The web app bundle of the SRE request plugin. It adds the ticket quick actions to the menu of
+// every post, so that responders can act on any post of a ticket thread. The actions call the same
+// endpoints as the buttons of ticket posts, which reply with an ephemeral post in the thread.
+//
+// It also renders the channel and user settings of the System Console, suggesting the values
+// returned by the settings lookup endpoints as the admin types.
+(function () {
+    'use strict';
+
+    var pluginId = 'com.mattermost.server-hello-world';
+
+    var postMenuActions = [
+        {action: 'acknowledge', text: 'Acknowledge SRE request'},
+        {action: 'escalate', text: 'Escalate SRE request'},
+        {action: 'timeline', text: 'Add to SRE request timeline'},
+    ];
+
+    // csrfToken returns the CSRF token the server expects with requests authenticated by the
+    // session cookie.
+    function csrfToken() {
+        var match = document.cookie.match(/(?:^|;\s*)MMCSRF=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    function pluginURL(path) {
+        return (window.basename || '') + '/plugins/' + pluginId + path;
+    }
+
+    function runTicketAction(action, postId) {
+        return fetch(pluginURL('/interactive/ticket/' + action), {
+            method: 'POST',
+            credentials: 'same-origin',
+            headers: {
+                'Content-Type': 'application/json',
+                'X-Requested-With': 'XMLHttpRequest',
+                'X-CSRF-Token': csrfToken(),
+            },
+            body: JSON.stringify({
+                post_id: postId,
+                context: {post_menu: true},
+            }),
+        }).catch(function (err) {
+            console.error('Failed to run the ' + action + ' SRE request action', err); // eslint-disable-line no-console
+        });
+    }
+
+    // lookupSettings are the settings picking channels or users, by key. Lists are comma-separated,
+    // and prefix is prepended to the looked up values, such as the @ naming users in SREAdmins.
+    var lookupSettings = {
+        AdminChannel: {lookup: 'channels'},
+        StatusPageChannel: {lookup: 'channels'},
+        CommandChannels: {lookup: 'channels', list: true},
+        IncidentCommander: {lookup: 'users'},
+        EscalationUsers: {lookup: 'users', list: true},
+        HighPriorityResponders: {lookup: 'users', list: true, prefix: '@'},
+        MediumPriorityResponders: {lookup: 'users', list: true, prefix: '@'},
+        LowPriorityResponders: {lookup: 'users', list: true, prefix: '@'},
+        SREAdmins: {lookup: 'users', list: true, prefix: '@'},
+    };
+
+    function lookupSettingValues(lookup, term) {
+        return fetch(pluginURL('/api/v1/settings/' + lookup + '?term=' + encodeURIComponent(term)), {
+            credentials: 'same-origin',
+            headers: {'X-Requested-With': 'XMLHttpRequest'},
+        }).then(function (response) {
+            return response.ok ? response.json() : [];
+        }).catch(function () {
+            return [];
+        });
+    }
+
+    // lookupSetting returns the System Console component of a setting looked up with the settings
+    // lookup endpoints: a text input suggesting the values matching the last entry typed.
+    function lookupSetting(setting) {
+        return function LookupSetting(props) {
+            var React = window.React;
+            var options = React.useState([]);
+            var value = props.value || '';
+
+            // Lists look up their last entry, keeping the entries before it.
+            var head = '';
+            var term = value;
+            if (setting.list) {
+                head = value.slice(0, value.lastIndexOf(',') + 1);
+                term = value.slice(head.length);
+                if (head) {
+                    head += ' ';
+                }
+            }
+            term = term.trim().replace(/^@/, '');
+
+            React.useEffect(function () {
+                var current = true;
+                lookupSettingValues(setting.lookup, term).then(function (results) {
+                    if (current) {
+                        options[1](results);
+                    }
+                });
+                return function () {
+                    current = false;
+                };
+            }, [term]);
+
+            var listID = 'sre-request-' + props.id + '-options';
+            return React.createElement('div', null,
+                React.createElement('input', {
+                    id: props.id,
+                    className: 'form-control',
+                    type: 'text',
+                    list: listID,
+                    value: value,
+                    disabled: props.disabled || props.setByEnv,
+                    onChange: function (e) {
+                        props.onChange(props.id, e.target.value);
+                        props.setSaveNeeded();
+                    },
+                }),
+                React.createElement('datalist', {id: listID}, options[0].map(function (option) {
+                    return React.createElement('option', {
+                        key: option.value,
+                        value: head + (setting.prefix || '') + option.value,
+                        label: option.label,
+                    });
+                })),
+                props.helpText ? React.createElement('div', {className: 'help-text'}, props.helpText) : null,
+            );
+        };
+    }
+
+    function Plugin() {}
+
+    Plugin.prototype.initialize = function (registry) {
+        postMenuActions.forEach(function (item) {
+            registry.registerPostDropdownMenuAction(item.text, function (postId) {
+                runTicketAction(item.action, postId);
+            });
+        });
+
+        Object.keys(lookupSettings).forEach(function (key) {
+            registry.registerAdminConsoleCustomSetting(key, lookupSetting(lookupSettings[key]), {showTitle: true});
+        });
+    };
+
+    window.registerPlugin(pluginId, new Plugin());
+}());
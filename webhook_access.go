@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"plugin-test/utils"
+)
+
+// defaultForwardedHeader carries the client address forwarded by trusted proxies when the server
+// configures no trusted proxy header.
+const defaultForwardedHeader = "X-Forwarded-For"
+
+// clientCertHeader carries the URL-encoded PEM client certificate verified by the TLS-terminating
+// proxy, such as nginx's $ssl_client_escaped_cert. It is only honored from trusted proxies, which
+// must overwrite it on every request.
+const clientCertHeader = "X-SSL-Client-Cert"
+
+// parseCertFingerprints parses a comma-separated list of SHA-256 certificate fingerprints,
+// ignoring case and colons.
+func parseCertFingerprints(list string) map[string]bool {
+	fingerprints := make(map[string]bool)
+	for _, fingerprint := range strings.Split(list, ",") {
+		fingerprint = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+		if fingerprint != "" {
+			fingerprints[fingerprint] = true
+		}
+	}
+
+	return fingerprints
+}
+
+// containsIP reports whether the address belongs to one of the networks.
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedHeaders returns the headers carrying the client address forwarded by proxies: the
+// trusted proxy headers configured for the server, or X-Forwarded-For.
+func (p *Plugin) forwardedHeaders() []string {
+	if config := p.API.GetConfig(); config != nil && len(config.ServiceSettings.TrustedProxyIPHeader) > 0 {
+		return config.ServiceSettings.TrustedProxyIPHeader
+	}
+
+	return []string{defaultForwardedHeader}
+}
+
+// clientIP returns the address of the client that sent the request. The forwarded headers are only
+// honored for requests relayed by one of the trusted proxies, in which case the client is the last
+// forwarded address that isn't itself a trusted proxy, since the earlier ones may be forged by the
+// client.
+func (p *Plugin) clientIP(r *http.Request) net.IP {
+	ip := peerIP(r)
+
+	trustedProxies := p.getConfiguration().webhookTrustedProxies
+	if !containsIP(trustedProxies, ip) {
+		return ip
+	}
+
+	for _, header := range p.forwardedHeaders() {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		addresses := strings.Split(strings.Join(values, ","), ",")
+		for i := len(addresses) - 1; i >= 0; i-- {
+			forwarded := net.ParseIP(strings.TrimSpace(addresses[i]))
+			if forwarded == nil {
+				// Trust nothing past a malformed entry, and report the last proxy instead.
+				return ip
+			}
+			ip = forwarded
+			if !containsIP(trustedProxies, ip) {
+				return ip
+			}
+		}
+
+		return ip
+	}
+
+	return ip
+}
+
+// peerIP returns the address of the peer the request was received from, which may be a proxy.
+func peerIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// clientCertFingerprint returns the SHA-256 fingerprint of the client certificate, or an empty
+// string if the client did not present one. The certificate forwarded in clientCertHeader is only
+// honored for requests relayed by one of the trusted proxies, since certificates are public and any
+// client could send one of the allowed ones.
+func (p *Plugin) clientCertFingerprint(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		sum := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+		return hex.EncodeToString(sum[:])
+	}
+
+	if !containsIP(p.getConfiguration().webhookTrustedProxies, peerIP(r)) {
+		return ""
+	}
+	escaped := r.Header.Get(clientCertHeader)
+	if escaped == "" {
+		return ""
+	}
+	certPEM, err := url.QueryUnescape(escaped)
+	if err != nil {
+		return ""
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// webhookAccessControl restricts inbound webhooks to the configured source networks and, when
+// client certificate fingerprints are configured, to clients presenting one of those certificates.
+// Rejected requests are logged.
+func (p *Plugin) webhookAccessControl(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configuration := p.getConfiguration()
+
+		if len(configuration.webhookAllowedNetworks) > 0 {
+			if ip := p.clientIP(r); !containsIP(configuration.webhookAllowedNetworks, ip) {
+				p.API.LogWarn("Rejected webhook from a source outside the allow-list", "path", r.URL.Path, "ip", ip.String(), "remote_addr", r.RemoteAddr, "forwarded_for", r.Header.Get(defaultForwardedHeader))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		if len(configuration.webhookCertFingerprints) > 0 {
+			fingerprint := p.clientCertFingerprint(r)
+			if !configuration.webhookCertFingerprints[fingerprint] {
+				p.API.LogWarn("Rejected webhook without an allowed client certificate", "path", r.URL.Path, "fingerprint", fingerprint)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseWebhookAccessSettings parses the webhook source allow-list, trusted proxies and client
+// certificate fingerprints.
+func parseWebhookAccessSettings(configuration *configuration) error {
+	networks, err := utils.ParseNetworks(configuration.WebhookAllowedIPs)
+	if err != nil {
+		return err
+	}
+	trustedProxies, err := utils.ParseNetworks(configuration.WebhookTrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	configuration.webhookAllowedNetworks = networks
+	configuration.webhookTrustedProxies = trustedProxies
+	configuration.webhookCertFingerprints = parseCertFingerprints(configuration.WebhookClientCertFingerprints)
+
+	return nil
+}
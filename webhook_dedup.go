@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	// webhookDeliveryKeyPrefix prefixes the KV key marking a webhook delivery as processed.
+	webhookDeliveryKeyPrefix = "webhookdelivery_"
+
+	// webhookDeliveryRetention is how long processed delivery ids are remembered.
+	webhookDeliveryRetention = 24 * time.Hour
+
+	// webhookDeliveryLockTimeout bounds how long a delivery waits for a concurrent delivery with the
+	// same id to be processed by another node.
+	webhookDeliveryLockTimeout = 30 * time.Second
+)
+
+// webhookDeliveryIDHeaders are the headers carrying a delivery id, in order of preference. Senders
+// keep the delivery id when retrying a delivery.
+var webhookDeliveryIDHeaders = []string{
+	"X-Delivery-ID",
+	"X-GitHub-Delivery",
+	"X-Atlassian-Webhook-Identifier",
+	"X-Request-ID",
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// deduplicateDeliveries makes webhook receivers safe to run on every node of a cluster: deliveries
+// carrying an id are processed at most once, even when a retried delivery reaches another node
+// while the first attempt is still being processed. Only successful deliveries are recorded, so
+// that failed or rejected requests cannot claim the id of a later genuine delivery. It must run
+// after the delivery is authenticated.
+func (p *Plugin) deduplicateDeliveries(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveryID := ""
+		for _, header := range webhookDeliveryIDHeaders {
+			if deliveryID = r.Header.Get(header); deliveryID != "" {
+				break
+			}
+		}
+		if deliveryID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := webhookDeliveryKeyPrefix + deliveryID
+		mutex, err := cluster.NewMutex(p.API, key)
+		if err != nil {
+			p.API.LogError("Failed to create webhook delivery mutex", "delivery_id", deliveryID, "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), webhookDeliveryLockTimeout)
+		defer cancel()
+		if err := mutex.LockWithContext(ctx); err != nil {
+			p.API.LogWarn("Timed out waiting for concurrent webhook delivery", "delivery_id", deliveryID)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer mutex.Unlock()
+
+		var processed bool
+		if err := p.client.KV.Get(key, &processed); err != nil {
+			p.API.LogError("Failed to get webhook delivery", "delivery_id", deliveryID, "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if processed {
+			p.API.LogDebug("Skipping duplicate webhook delivery", "delivery_id", deliveryID)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status < http.StatusOK || recorder.status >= http.StatusMultipleChoices {
+			return
+		}
+		if _, err := p.client.KV.Set(key, true, pluginapi.SetExpiry(webhookDeliveryRetention)); err != nil {
+			p.API.LogError("Failed to record webhook delivery", "delivery_id", deliveryID, "err", err.Error())
+		}
+	})
+}
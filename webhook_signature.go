@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// webhookSignatureHeader carries the hex-encoded HMAC-SHA256 of the request body, optionally
+	// prefixed with "sha256=" as in the signatures of the event webhook. GitHub sends the same
+	// signature in githubSignatureHeader.
+	webhookSignatureHeader = "X-Signature"
+	githubSignatureHeader  = "X-Hub-Signature-256"
+
+	// maxWebhookBodySize bounds the size of the signed webhook bodies buffered for verification.
+	maxWebhookBodySize = 10 << 20
+)
+
+// validWebhookSignature reports whether the signature is the HMAC-SHA256 of the body with the
+// secret.
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// verifyWebhookSignature rejects inbound webhooks that are not signed with the configured
+// WebhookSigningSecret, logging the source address of the rejected requests. When no secret is
+// configured, webhooks are rejected unless AllowUnsignedWebhooks explicitly opts out of the check.
+func (p *Plugin) verifyWebhookSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configuration := p.getConfiguration()
+		secret := configuration.WebhookSigningSecret
+		if secret == "" {
+			if configuration.AllowUnsignedWebhooks {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p.API.LogWarn("Rejected webhook since no signing secret is configured", "path", r.URL.Path, "ip", p.clientIP(r).String())
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		signature := r.Header.Get(webhookSignatureHeader)
+		if signature == "" {
+			signature = r.Header.Get(githubSignatureHeader)
+		}
+		if signature == "" {
+			p.API.LogWarn("Rejected unsigned webhook", "path", r.URL.Path, "ip", p.clientIP(r).String())
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+		r.Body.Close()
+		if err != nil {
+			p.API.LogWarn("Failed to read webhook body", "path", r.URL.Path, "ip", p.clientIP(r).String(), "err", err.Error())
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if !validWebhookSignature(secret, body, signature) {
+			p.API.LogWarn("Rejected webhook with an invalid signature", "path", r.URL.Path, "ip", p.clientIP(r).String())
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
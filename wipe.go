@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// wipeRequestKey is the KV key of the pending request to wipe the plugin data.
+	wipeRequestKey = "wipe_request"
+
+	// wipeConfirmationWindow is how long the confirmation code of a wipe request stays valid.
+	wipeConfirmationWindow = 10 * time.Minute
+)
+
+// wipeRequest is the first step of wiping the plugin data. The data is only wiped once the same
+// user types back the confirmation code before it expires.
+type wipeRequest struct {
+	UserID       string `json:"user_id"`
+	Confirmation string `json:"confirmation"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// dataSnapshot is the backup of every piece of plugin data, taken before it is wiped. KV values
+// are kept as the raw bytes stored, base64 encoded by JSON.
+type dataSnapshot struct {
+	CreateAt   int64             `json:"create_at"`
+	CreatedBy  string            `json:"created_by"`
+	KV         map[string][]byte `json:"kv"`
+	SQLTickets []*Ticket         `json:"sql_tickets,omitempty"`
+}
+
+// wipeResult summarizes a completed wipe.
+type wipeResult struct {
+	DeletedKeys    int    `json:"deleted_keys"`
+	DeletedTickets int    `json:"deleted_sql_tickets"`
+	SnapshotFileID string `json:"snapshot_file_id"`
+}
+
+// requestWipe starts a wipe of the plugin data on behalf of the user, returning the confirmation
+// code the user must type back.
+func (p *Plugin) requestWipe(userID string) (*wipeRequest, error) {
+	request := &wipeRequest{
+		UserID:       userID,
+		Confirmation: "wipe-" + strings.ToLower(model.NewRandomString(6)),
+		ExpiresAt:    model.GetMillis() + wipeConfirmationWindow.Milliseconds(),
+	}
+	if _, err := p.client.KV.Set(wipeRequestKey, request); err != nil {
+		return nil, errors.Wrap(err, "failed to save wipe request")
+	}
+
+	return request, nil
+}
+
+// confirmWipe reports whether the confirmation code matches the pending wipe request of the user.
+func (p *Plugin) confirmWipe(userID, confirmation string) (bool, error) {
+	var request *wipeRequest
+	if err := p.client.KV.Get(wipeRequestKey, &request); err != nil {
+		return false, errors.Wrap(err, "failed to get wipe request")
+	}
+
+	return request != nil && request.UserID == userID && request.Confirmation == confirmation && model.GetMillis() < request.ExpiresAt, nil
+}
+
+// takeDataSnapshot reads every KV entry of the plugin, and every ticket of the SQL store if one is
+// configured.
+func (p *Plugin) takeDataSnapshot(userID string) (*dataSnapshot, error) {
+	snapshot := &dataSnapshot{
+		CreateAt:  model.GetMillis(),
+		CreatedBy: userID,
+		KV:        make(map[string][]byte),
+	}
+
+	for page := 0; ; page++ {
+		keys, err := p.client.KV.ListKeys(page, kvListPerPage)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list keys")
+		}
+
+		for _, key := range keys {
+			value, appErr := p.API.KVGet(key)
+			if appErr != nil {
+				return nil, errors.Wrapf(appErr, "failed to get key %s", key)
+			}
+			snapshot.KV[key] = value
+		}
+
+		if len(keys) < kvListPerPage {
+			break
+		}
+	}
+
+	if sqlStore := p.getConfiguration().sqlStore; sqlStore != nil {
+		tickets, err := sqlStore.ListTickets()
+		if err != nil {
+			return nil, err
+		}
+		snapshot.SQLTickets = tickets
+	}
+
+	return snapshot, nil
+}
+
+// sendDataSnapshot sends the snapshot as a file to the user in their direct channel with the bot,
+// returning the id of the file. The snapshot holds every ticket, including confidential tickets and
+// those of other teams, so it is only sent to system admins.
+func (p *Plugin) sendDataSnapshot(userID string, snapshot *dataSnapshot) (string, error) {
+	if !p.isSystemAdmin(userID) {
+		return "", errors.New("only system admins may receive a data snapshot")
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal snapshot")
+	}
+
+	channel, appErr := p.API.GetDirectChannel(userID, p.botID)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to get direct channel")
+	}
+
+	filename := fmt.Sprintf("sre-requests-snapshot-%s.json", time.UnixMilli(snapshot.CreateAt).UTC().Format("2006-01-02T150405Z"))
+	fileInfo, appErr := p.API.UploadFile(data, channel.Id, filename)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to upload snapshot")
+	}
+
+	if _, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+		Message:   fmt.Sprintf(":floppy_disk: Backup of the SRE request data taken before the wipe: %d KV entries and %d SQL tickets.", len(snapshot.KV), len(snapshot.SQLTickets)),
+		FileIds:   []string{fileInfo.Id},
+	}); appErr != nil {
+		return "", errors.Wrap(appErr, "failed to send snapshot")
+	}
+
+	return fileInfo.Id, nil
+}
+
+// wipePluginData deletes every piece of plugin data: tickets, indexes, schedules, preferences and
+// tokens. A snapshot of the data is sent to the user first, and nothing is deleted if it can't be.
+func (p *Plugin) wipePluginData(userID string) (*wipeResult, error) {
+	snapshot, err := p.takeDataSnapshot(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to take snapshot")
+	}
+
+	fileID, err := p.sendDataSnapshot(userID, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if sqlStore := p.getConfiguration().sqlStore; sqlStore != nil {
+		if err := sqlStore.deleteAllTickets(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.client.KV.DeleteAll(); err != nil {
+		return nil, errors.Wrap(err, "failed to delete KV data")
+	}
+	p.ticketCache.clear()
+	p.invalidateTicketCache("")
+
+	// There is no data left to migrate.
+	if _, err := p.client.KV.Set(kvSchemaVersionKey, len(kvMigrations)); err != nil {
+		p.API.LogWarn("Failed to save KV schema version after wipe", "err", err.Error())
+	}
+
+	p.API.LogWarn("Audit: wiped plugin data", "user_id", userID, "deleted_keys", len(snapshot.KV), "deleted_sql_tickets", len(snapshot.SQLTickets))
+	p.postAdminNotice(fmt.Sprintf(":wastebasket: %s wiped the SRE request data.", p.mentionUser(userID)))
+
+	return &wipeResult{
+		DeletedKeys:    len(snapshot.KV),
+		DeletedTickets: len(snapshot.SQLTickets),
+		SnapshotFileID: fileID,
+	}, nil
+}
+
+func (p *Plugin) executeCommandWipe(args *model.CommandArgs, params []string) *model.CommandResponse {
+	switch {
+	case len(params) == 0:
+		request, err := p.requestWipe(args.UserId)
+		if err != nil {
+			p.API.LogError("Failed to request wipe", "err", err.Error())
+			return ephemeralResponse("Failed to start the wipe.")
+		}
+
+		return ephemeralResponse(fmt.Sprintf(
+			":warning: This permanently deletes every SRE request, index, schedule, preference and token stored by the plugin. "+
+				"A backup is sent to you by direct message first.\nTo proceed, type `/sre-request wipe confirm %s` within %d minutes.",
+			request.Confirmation, int(wipeConfirmationWindow.Minutes())))
+	case len(params) == 2 && params[0] == "confirm":
+		confirmed, err := p.confirmWipe(args.UserId, params[1])
+		if err != nil {
+			p.API.LogError("Failed to confirm wipe", "err", err.Error())
+			return ephemeralResponse("Failed to confirm the wipe.")
+		}
+		if !confirmed {
+			return ephemeralResponse("The confirmation code is invalid or expired. Run `/sre-request wipe` to get a new one.")
+		}
+
+		result, err := p.wipePluginData(args.UserId)
+		if err != nil {
+			p.API.LogError("Failed to wipe plugin data", "err", err.Error())
+			return ephemeralResponse("Failed to wipe the plugin data.")
+		}
+
+		return ephemeralResponse(fmt.Sprintf("Wiped %d KV entries and %d SQL tickets. The backup was sent to you by direct message.", result.DeletedKeys, result.DeletedTickets))
+	default:
+		return ephemeralResponse(commandUsage("wipe"))
+	}
+}
+
+// handleWipe wipes the plugin data in two steps: a request without a confirmation returns the
+// confirmation code, which must be sent back to wipe the data.
+func (p *Plugin) handleWipe(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+	if !p.authorizeSystemAdminAction(userID, "wipe_data") {
+		http.Error(w, "Not authorized to wipe the plugin data", http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		Confirmation string `json:"confirmation"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	if body.Confirmation == "" {
+		request, err := p.requestWipe(userID)
+		if err != nil {
+			p.API.LogError("Failed to request wipe", "err", err.Error())
+			http.Error(w, "Failed to start the wipe", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(request); err != nil {
+			p.API.LogError("Failed to write wipe request", "err", err.Error())
+		}
+		return
+	}
+
+	confirmed, err := p.confirmWipe(userID, body.Confirmation)
+	if err != nil {
+		p.API.LogError("Failed to confirm wipe", "err", err.Error())
+		http.Error(w, "Failed to confirm the wipe", http.StatusInternalServerError)
+		return
+	}
+	if !confirmed {
+		http.Error(w, "Invalid or expired confirmation", http.StatusBadRequest)
+		return
+	}
+
+	result, err := p.wipePluginData(userID)
+	if err != nil {
+		p.API.LogError("Failed to wipe plugin data", "err", err.Error())
+		http.Error(w, "Failed to wipe the plugin data", http.StatusInternalServerError)
+		return
+	}
+
+	p.writeJSON(w, result)
+}